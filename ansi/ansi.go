@@ -0,0 +1,296 @@
+// Package ansi turns the output of programs, escape sequences and all, into
+// styled segments of text a frontend can render, keeping the colors and
+// attributes of SGR sequences and dropping any other sequence.
+package ansi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Color is a color of the text: "" for the default one, the name of one of
+// the 16 colors of the terminal such as "red" or "bright-blue", which a
+// frontend styles after its theme, or "#rrggbb" for the others.
+type Color string
+
+var basicColors = []Color{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+	"bright-black", "bright-red", "bright-green", "bright-yellow",
+	"bright-blue", "bright-magenta", "bright-cyan", "bright-white",
+}
+
+// Style is how a segment of text looks.
+type Style struct {
+	FG        Color `json:"fg,omitempty"`
+	BG        Color `json:"bg,omitempty"`
+	Bold      bool  `json:"bold,omitempty"`
+	Dim       bool  `json:"dim,omitempty"`
+	Italic    bool  `json:"italic,omitempty"`
+	Underline bool  `json:"underline,omitempty"`
+	Blink     bool  `json:"blink,omitempty"`
+	// Inverse swaps FG and BG
+	Inverse bool `json:"inverse,omitempty"`
+	Hidden  bool `json:"hidden,omitempty"`
+	Strike  bool `json:"strike,omitempty"`
+}
+
+// Segment is a run of text in a single style.
+type Segment struct {
+	Text  string `json:"text"`
+	Style Style  `json:"style"`
+}
+
+// maxSequence bounds the escape sequences a Parser waits for the end of.
+const maxSequence = 4096
+
+// Parser parses a stream of output fed to it piece by piece. The style set
+// by a piece carries over to the next ones, and so do escape sequences and
+// UTF-8 characters split between pieces.
+type Parser struct {
+	style Style
+	// pending is the incomplete end of the last piece
+	pending string
+}
+
+// Feed parses the next piece of output and returns its segments. Text in
+// the same style as the one before is merged into a single segment.
+func (p *Parser) Feed(data string) []Segment {
+	data = p.pending + data
+	p.pending = ""
+	segments := []Segment{}
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			segments = append(segments, Segment{text.String(), p.style})
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(data); {
+		c := data[i]
+		if c != 0x1b {
+			if c >= utf8.RuneSelf && !utf8.FullRuneInString(data[i:]) {
+				p.pending = data[i:]
+				break
+			}
+			_, size := utf8.DecodeRuneInString(data[i:])
+			if c >= ' ' || c == '\n' || c == '\t' || c == '\r' || c == '\b' {
+				text.WriteString(data[i : i+size])
+			}
+			i += size
+			continue
+		}
+		n, params, final := sequence(data[i:])
+		if n == 0 && len(data)-i > maxSequence {
+			// never ends, the escape is dropped alone
+			i++
+			continue
+		}
+		if n == 0 {
+			// cut off by the end of the piece
+			p.pending = data[i:]
+			break
+		}
+		if final == 'm' {
+			style := sgr(p.style, params)
+			if style != p.style {
+				flush()
+				p.style = style
+			}
+		}
+		i += n
+	}
+	flush()
+	return segments
+}
+
+// sequence returns the length of the escape sequence data starts with, 0 if
+// it's incomplete, and the parameters and final byte of a CSI sequence.
+func sequence(data string) (int, string, byte) {
+	if len(data) < 2 {
+		return 0, "", 0
+	}
+	switch data[1] {
+	case '[':
+		// CSI: parameter and intermediate bytes, then a final byte
+		for i := 2; i < len(data); i++ {
+			if c := data[i]; c >= 0x40 && c <= 0x7e {
+				return i + 1, data[2:i], c
+			}
+		}
+		return 0, "", 0
+	case ']', 'P', '_', '^', 'X':
+		// OSC and other strings, ended by BEL or ST
+		for i := 2; i < len(data); i++ {
+			if data[i] == 0x07 {
+				return i + 1, "", 0
+			}
+			if data[i] == 0x1b && i+1 < len(data) && data[i+1] == '\\' {
+				return i + 2, "", 0
+			}
+		}
+		return 0, "", 0
+	}
+	// intermediate bytes, then a final byte, such as ESC ( B
+	for i := 1; i < len(data); i++ {
+		if c := data[i]; c < 0x20 || c > 0x2f {
+			return i + 1, "", 0
+		}
+	}
+	return 0, "", 0
+}
+
+// sgr applies the parameters of an SGR sequence to style.
+func sgr(style Style, params string) Style {
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		sub := strings.Split(codes[i], ":")
+		code, _ := strconv.Atoi(sub[0])
+		switch {
+		case code == 0:
+			style = Style{}
+		case code == 1:
+			style.Bold = true
+		case code == 2:
+			style.Dim = true
+		case code == 3:
+			style.Italic = true
+		case code == 4 || code == 21:
+			style.Underline = len(sub) < 2 || sub[1] != "0"
+		case code == 5 || code == 6:
+			style.Blink = true
+		case code == 7:
+			style.Inverse = true
+		case code == 8:
+			style.Hidden = true
+		case code == 9:
+			style.Strike = true
+		case code == 22:
+			style.Bold, style.Dim = false, false
+		case code == 23:
+			style.Italic = false
+		case code == 24:
+			style.Underline = false
+		case code == 25:
+			style.Blink = false
+		case code == 27:
+			style.Inverse = false
+		case code == 28:
+			style.Hidden = false
+		case code == 29:
+			style.Strike = false
+		case code >= 30 && code <= 37:
+			style.FG = basicColors[code-30]
+		case code >= 90 && code <= 97:
+			style.FG = basicColors[code-90+8]
+		case code >= 40 && code <= 47:
+			style.BG = basicColors[code-40]
+		case code >= 100 && code <= 107:
+			style.BG = basicColors[code-100+8]
+		case code == 39:
+			style.FG = ""
+		case code == 49:
+			style.BG = ""
+		case code == 38 || code == 48:
+			var color Color
+			var ok bool
+			if len(sub) > 1 {
+				color, ok = extendedColor(colonArgs(sub[1:]))
+			} else {
+				var used int
+				color, ok, used = extendedColorArgs(codes[i+1:])
+				i += used
+			}
+			if ok && code == 38 {
+				style.FG = color
+			} else if ok {
+				style.BG = color
+			}
+		}
+	}
+	return style
+}
+
+// colonArgs returns the arguments of a colored SGR code given with colons,
+// dropping the color space of "2::r:g:b".
+func colonArgs(sub []string) []string {
+	if sub[0] == "2" && len(sub) == 5 {
+		return append([]string{"2"}, sub[2:]...)
+	}
+	return sub
+}
+
+// extendedColorArgs reads the color following 38 or 48 in the codes of an
+// SGR sequence, and returns how many codes it takes.
+func extendedColorArgs(codes []string) (Color, bool, int) {
+	if len(codes) == 0 {
+		return "", false, 0
+	}
+	n := 0
+	switch codes[0] {
+	case "5":
+		n = 2
+	case "2":
+		n = 4
+	default:
+		return "", false, 1
+	}
+	if len(codes) < n {
+		return "", false, len(codes)
+	}
+	color, ok := extendedColor(codes[:n])
+	return color, ok, n
+}
+
+// extendedColor returns the color of "5;n" or "2;r;g;b".
+func extendedColor(args []string) (Color, bool) {
+	nums := make([]int, len(args))
+	for i, a := range args {
+		n, err := strconv.Atoi(a)
+		if err != nil || n < 0 || n > 255 {
+			return "", false
+		}
+		nums[i] = n
+	}
+	switch {
+	case len(nums) == 2 && nums[0] == 5:
+		return paletteColor(nums[1]), true
+	case len(nums) == 4 && nums[0] == 2:
+		return rgb(nums[1], nums[2], nums[3]), true
+	}
+	return "", false
+}
+
+// paletteColor returns the color n of the 256 colors palette of xterm.
+func paletteColor(n int) Color {
+	switch {
+	case n < 16:
+		return basicColors[n]
+	case n < 232:
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + 40*v
+		}
+		return rgb(level(n/36), level(n/6%6), level(n%6))
+	}
+	gray := 8 + 10*(n-232)
+	return rgb(gray, gray, gray)
+}
+
+func rgb(r, g, b int) Color {
+	return Color(fmt.Sprintf("#%02x%02x%02x", r, g, b))
+}
+
+// Strip returns the text of data without any escape sequence.
+func Strip(data string) string {
+	var p Parser
+	var text strings.Builder
+	for _, s := range p.Feed(data) {
+		text.WriteString(s.Text)
+	}
+	return text.String()
+}
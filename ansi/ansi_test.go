@@ -0,0 +1,72 @@
+package ansi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFeed(t *testing.T) {
+	red := Style{FG: "red"}
+	var tests = []struct {
+		pieces   []string
+		segments []Segment
+	}{
+		{[]string{"plain\n"}, []Segment{{"plain\n", Style{}}}},
+		{[]string{"\x1b[31mred\x1b[0m done"}, []Segment{{"red", red}, {" done", Style{}}}},
+		{[]string{"\x1b[1;4;91;44mx\x1b[22;24m"}, []Segment{{"x", Style{FG: "bright-red", BG: "blue", Bold: true, Underline: true}}}},
+		{[]string{"\x1b[38;5;196ma\x1b[38;5;244mb\x1b[48;2;1;2;3mc"}, []Segment{
+			{"a", Style{FG: "#ff0000"}}, {"b", Style{FG: "#808080"}}, {"c", Style{FG: "#808080", BG: "#010203"}},
+		}},
+		{[]string{"\x1b[38:2::10:20:30mx\x1b[38:5:1my"}, []Segment{{"x", Style{FG: "#0a141e"}}, {"y", red}}},
+		// other sequences are dropped
+		{[]string{"a\x1b[2K\x1b[1Ab\x1b]0;title\x07c\x1b]8;;http://x\x1b\\d\x1b(Be\x00"}, []Segment{{"abcde", Style{}}}},
+		// split between pieces
+		{[]string{"\x1b[3", "1mre", "d\x1b", "[m!"}, []Segment{{"re", red}, {"d", red}, {"!", Style{}}}},
+		{[]string{"caf\xc3", "\xa9"}, []Segment{{"caf", Style{}}, {"é", Style{}}}},
+		// the style carries over, unchanged styles don't split segments
+		{[]string{"\x1b[31ma", "\x1b[31mb\x1b[39;49m"}, []Segment{{"a", red}, {"b", red}}},
+		{[]string{"\x1b[7;8;9;3;5;2mx\x1b[0m"}, []Segment{{"x", Style{Inverse: true, Hidden: true, Strike: true, Italic: true, Blink: true, Dim: true}}}},
+	}
+	for i, tt := range tests {
+		var p Parser
+		segments := []Segment{}
+		for _, piece := range tt.pieces {
+			segments = append(segments, p.Feed(piece)...)
+		}
+		if !reflect.DeepEqual(segments, tt.segments) {
+			t.Errorf("testcase: %d result: %+v expected: %+v", i, segments, tt.segments)
+		}
+	}
+}
+
+func TestFeedUnterminated(t *testing.T) {
+	var p Parser
+	p.Feed("\x1b]0;")
+	long := make([]byte, maxSequence+10)
+	for i := range long {
+		long[i] = 'x'
+	}
+	segments := p.Feed(string(long))
+	if len(segments) != 1 || len(segments[0].Text) < maxSequence {
+		t.Errorf("result: %d segments", len(segments))
+	}
+}
+
+func TestStrip(t *testing.T) {
+	if result := Strip("\x1b[1m\x1b[32mok\x1b[0m: 3 passed\x1b["); result != "ok: 3 passed" {
+		t.Errorf("result: %q", result)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	segments := []Segment{
+		{"<b>", Style{}},
+		{"red", Style{FG: "red", Bold: true, Underline: true, Strike: true}},
+		{"inv", Style{FG: "#010203", Inverse: true}},
+	}
+	expected := `&lt;b&gt;<span style="color:var(--ansi-red);font-weight:bold;text-decoration:underline line-through">red</span>` +
+		`<span style="color:var(--ansi-bg);background-color:#010203">inv</span>`
+	if result := HTML(segments); result != expected {
+		t.Errorf("result: %s expected: %s", result, expected)
+	}
+}
@@ -0,0 +1,77 @@
+package ansi
+
+import (
+	"html"
+	"strings"
+)
+
+// HTML renders the segments as HTML, each one styled by a span, its text
+// escaped. Named colors are given as CSS variables, such as var(--ansi-red),
+// for the page to pick their shades.
+func HTML(segments []Segment) string {
+	var b strings.Builder
+	for _, s := range segments {
+		css := s.Style.css()
+		if css == "" {
+			b.WriteString(html.EscapeString(s.Text))
+			continue
+		}
+		b.WriteString(`<span style="`)
+		b.WriteString(css)
+		b.WriteString(`">`)
+		b.WriteString(html.EscapeString(s.Text))
+		b.WriteString("</span>")
+	}
+	return b.String()
+}
+
+// css returns the style as CSS declarations.
+func (s Style) css() string {
+	fg, bg := s.FG, s.BG
+	if s.Inverse {
+		fg, bg = bg, fg
+		if fg == "" {
+			fg = "var(--ansi-bg)"
+		}
+		if bg == "" {
+			bg = "var(--ansi-fg)"
+		}
+	}
+	var decls []string
+	if fg != "" {
+		decls = append(decls, "color:"+cssColor(fg))
+	}
+	if bg != "" {
+		decls = append(decls, "background-color:"+cssColor(bg))
+	}
+	if s.Bold {
+		decls = append(decls, "font-weight:bold")
+	}
+	if s.Dim {
+		decls = append(decls, "opacity:0.7")
+	}
+	if s.Italic {
+		decls = append(decls, "font-style:italic")
+	}
+	var lines []string
+	if s.Underline {
+		lines = append(lines, "underline")
+	}
+	if s.Strike {
+		lines = append(lines, "line-through")
+	}
+	if len(lines) > 0 {
+		decls = append(decls, "text-decoration:"+strings.Join(lines, " "))
+	}
+	if s.Hidden {
+		decls = append(decls, "visibility:hidden")
+	}
+	return strings.Join(decls, ";")
+}
+
+func cssColor(c Color) string {
+	if strings.HasPrefix(string(c), "#") || strings.HasPrefix(string(c), "var(") {
+		return string(c)
+	}
+	return "var(--ansi-" + string(c) + ")"
+}
@@ -0,0 +1,701 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gtoc/bake"
+	"gtoc/commands"
+	"gtoc/docopt"
+	"gtoc/form"
+	"gtoc/i18n"
+	"gtoc/keymap"
+	"gtoc/onboard"
+	"gtoc/runner"
+	"gtoc/store"
+	"gtoc/watch"
+	"go.uber.org/zap"
+)
+
+// APIVersion is the version of the API the Backend binds, raised with every
+// change of a method or a DTO the frontend would break on.
+const APIVersion = 4
+
+// Backend is the API bound to the frontend, as window.go.main.Backend: the
+// sessions of the tabs of the GUI, each with its target command, the
+// patterns of the commands, and the Jobs running them. Its methods return
+// DTOs, whose JSON the frontend reads as is. The methods of a tab take the
+// ID of its session, see Session.
+type Backend struct {
+	*Jobs
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	opened   int
+	// focus is the tab the user is on and the field focused in its form,
+	// which files dropped on the window go to, see FocusField
+	focus    focus
+	commands commands.Index
+	// baked is the bundle of the command the app was baked for, nil if it
+	// wasn't, see package bake
+	baked *bake.Bundle
+	// settings are the preferences of the user, applied at start up, and
+	// layouts the layouts of the window for the commands
+	settings *store.Settings
+	layouts  *store.Layouts
+	catalog  *i18n.Catalog
+	// tray is the icon of gtoc in the tray, nil but in the window
+	tray *tray_icon
+	// watcher watches the programs of the targets, to probe them anew once
+	// they change, saved are the sessions saved as gtoc exits, library the
+	// commands the user made a GUI of, and snapshots the patterns of the
+	// targets for every build of their program, nil in an app baked
+	watcher   *watch.Watcher
+	saved     *store.Sessions
+	library   *store.Library
+	snapshots *store.Snapshots
+	// onboarding is the onboarding of a user with an empty library, nil
+	// once it's over, see greet
+	onboarding *onboard.Flow
+}
+
+// NewBackend returns the backend with a first session of the target
+// command, "" for the user to pick one, set up once wails starts it up, see
+// startup.
+// pat is the pattern the target was probed for, nil if there's none yet.
+func NewBackend(target string, pat *docopt.Pattern) *Backend {
+	b := &Backend{Jobs: &Jobs{}, sessions: make(map[string]*session), catalog: i18n.New()}
+	b.open(target, pat)
+	return b
+}
+
+// startup is called once the app is up in the view, which the events are
+// emitted to and the dialogs opened in. The sessions saved as gtoc last
+// exited are opened again, but in an app baked, see restore.
+func (b *Backend) startup(v view) error {
+	if err := b.Jobs.startup(v); err != nil {
+		return err
+	}
+	b.events.retried = b.retried
+	v.Hotkeys(b.settings.Get().Keys)
+	if b.baked == nil {
+		dir, err := store.Dir()
+		if err != nil {
+			return err
+		}
+		if b.saved, err = store.OpenSessions(filepath.Join(dir, "sessions.json")); err != nil {
+			return err
+		}
+		if b.library, err = store.OpenLibrary(filepath.Join(dir, "library.json")); err != nil {
+			return err
+		}
+		b.parse_options = func(command string) (store.ParseOptions, bool) {
+			e, ok := b.library.Get(command)
+			return e.Options, ok
+		}
+		if b.snapshots, err = store.OpenSnapshots(filepath.Join(dir, "snapshots.json")); err != nil {
+			return err
+		}
+		b.restore()
+		b.greet()
+		b.watcher = watch.New(b.reparse)
+		b.watch()
+		go b.check_update()
+	}
+	return nil
+}
+
+// watch watches the programs of the targets of the sessions, see reparse.
+func (b *Backend) watch() {
+	if b.watcher == nil {
+		return
+	}
+	b.mu.Lock()
+	var targets []string
+	for _, s := range b.sessions {
+		if s.target != "" {
+			targets = append(targets, s.target)
+		}
+	}
+	b.mu.Unlock()
+	b.watcher.Set(targets)
+}
+
+// reparse_timeout bounds the probe of a command whose program changed.
+const reparse_timeout = 30 * time.Second
+
+// reparse probes the command anew, its program having changed, upgraded or
+// rebuilt, and makes the pattern the pattern of the sessions whose target it
+// is. The values of their forms are migrated to it, see form.Migrate, and
+// those to undo are dropped. Every session is emitted as a "pattern:updated"
+// event carrying a PatternUpdate. The pattern is kept as a snapshot of the
+// new build, see snapshot.
+func (b *Backend) reparse(command string) {
+	ctx, cancel := context.WithTimeout(context.Background(), reparse_timeout)
+	defer cancel()
+	pat, err := get_pattern_context(ctx, command, nil)
+	if err != nil {
+		zap.S().Warnf("Probing %s anew failed: %s", command, err)
+		return
+	}
+	b.repattern(command, pat)
+	b.snapshot(command, pat)
+}
+
+// repattern makes pat the pattern of the sessions whose target is the
+// command, migrating the values of their forms, see reparse.
+func (b *Backend) repattern(command string, pat *docopt.Pattern) {
+	var updates []PatternUpdate
+	b.mu.Lock()
+	for id, s := range b.sessions {
+		if s.target != command || s.pattern == nil {
+			continue
+		}
+		values, dropped, err := form.Migrate(s.values, s.pattern, pat)
+		if err != nil {
+			zap.S().Warnf("Migrating the values of session %s failed: %s", id, err)
+			continue
+		}
+		s.set(command, pat)
+		s.values = values
+		updates = append(updates, PatternUpdate{s.snapshot(id), pattern_node(pat), dropped})
+	}
+	b.mu.Unlock()
+	for _, u := range updates {
+		b.view.Emit("pattern:updated", u)
+	}
+}
+
+// PatternUpdate is a session whose target was probed anew, with the values
+// of its form migrated, its new pattern and the IDs of the fields whose
+// values didn't fit it.
+type PatternUpdate struct {
+	Session Session      `json:"session"`
+	Pattern *PatternNode `json:"pattern"`
+	Dropped []string     `json:"dropped"`
+}
+
+// Branding returns how the app presents itself if it was baked for a single
+// command, nil if it's gtoc, see "gtoc bake".
+func (b *Backend) Branding() *bake.Brand {
+	if b.baked == nil {
+		return nil
+	}
+	return &b.baked.Brand
+}
+
+// probe returns the pattern of the command, as baked if the app was baked
+// for it. A baked app refuses other commands.
+func (b *Backend) probe(command string) (*docopt.Pattern, error) {
+	switch {
+	case b.baked == nil:
+		return get_pattern(command)
+	case command == b.baked.Command:
+		return b.baked.Pattern, nil
+	}
+	return nil, b.errorf("error.bakedFor", b.baked.Command)
+}
+
+// GetSettings returns the preferences of the user.
+func (b *Backend) GetSettings() store.Preferences {
+	return b.settings.Get()
+}
+
+// SetSettings saves the preferences and returns them. The log level, the
+// tray and the hotkeys are applied right away, the window size the next time
+// gtoc starts, and the rest is for the frontend, to which the preferences
+// are emitted as a "settings" event. The keymap is emitted as a "keymap"
+// event too when it changes, see GetKeymap. Preferences that can't be are
+// refused.
+func (b *Backend) SetSettings(prefs store.Preferences) (store.Preferences, error) {
+	before := b.settings.Get()
+	if err := b.settings.Set(prefs); err != nil {
+		return store.Preferences{}, err
+	}
+	log_level.UnmarshalText([]byte(prefs.LogLevel))
+	if b.tray != nil {
+		b.tray.set(prefs.Tray)
+	}
+	if prefs.Keys != before.Keys {
+		b.view.Hotkeys(prefs.Keys)
+		b.view.Emit("keymap", prefs.Keys)
+	}
+	b.view.Emit("settings", prefs)
+	return prefs, nil
+}
+
+// GetKeymap returns the hotkeys of the actions of the GUI, for the frontend
+// to show them by the actions. In the window, the backend registers them
+// and emits a "hotkey" event carrying the action of the one pressed, in a
+// browser the frontend handles them itself.
+func (b *Backend) GetKeymap() keymap.Keymap {
+	return b.settings.Get().Keys
+}
+
+// locale returns the locale of the GUI, as the settings say or else the
+// desktop's.
+func (b *Backend) locale() string {
+	if lang := b.settings.Get().Language; lang != "" {
+		return lang
+	}
+	return i18n.System()
+}
+
+// errorf returns the error of the message key of the catalog, in the
+// language of the GUI.
+func (b *Backend) errorf(key string, args ...interface{}) error {
+	return errors.New(b.catalog.Sprintf(b.locale(), key, args...))
+}
+
+// GetMessages returns the strings of the GUI in its language, see
+// SetLanguage.
+func (b *Backend) GetMessages() i18n.Bundle {
+	return b.catalog.Bundle(b.locale())
+}
+
+// Languages returns the locales the GUI is translated to.
+func (b *Backend) Languages() []string {
+	return b.catalog.Languages()
+}
+
+// SetLanguage makes the language closest to locale, such as "de" for
+// "de-AT", the language of the GUI and keeps it in the settings, "" following
+// the desktop's. It returns the
+// strings of the GUI in the language, which are emitted as a "language"
+// event as well, for every part of the frontend to switch.
+func (b *Backend) SetLanguage(locale string) (i18n.Bundle, error) {
+	prefs := b.settings.Get()
+	if prefs.Language = locale; locale != "" {
+		prefs.Language = b.catalog.Match(locale)
+	}
+	if err := b.settings.Set(prefs); err != nil {
+		return i18n.Bundle{}, err
+	}
+	bundle := b.GetMessages()
+	b.view.Emit("language", bundle)
+	return bundle, nil
+}
+
+// Version returns the APIVersion, for the frontend to check it's built for.
+func (b *Backend) Version() int {
+	return APIVersion
+}
+
+// SetTarget probes the command and makes it the target of the session,
+// returning its pattern. The values of the session's form are dropped, as
+// they were for the previous target, along with those to undo. The target
+// is emitted as a "target" event carrying the session ID and the command,
+// for every part of the tab to follow. A command whose pattern can't be had
+// is refused. Its program is watched, to be probed anew once it changes, see
+// reparse, its pattern kept as a snapshot of the build, see snapshot, and
+// the window is laid out as it was last for it.
+func (b *Backend) SetTarget(sessionID string, command string) (*PatternNode, error) {
+	if _, err := b.GetSession(sessionID); err != nil {
+		return nil, err
+	}
+	pat, err := b.probe(command)
+	if err != nil {
+		return nil, err
+	}
+	b.used(command)
+	go b.snapshot(command, pat)
+	b.mu.Lock()
+	s, err := b.session(sessionID)
+	if err == nil {
+		s.set(command, pat)
+	}
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	b.watch()
+	b.view.Emit("target", sessionID, command)
+	b.lay_out(command)
+	return pattern_node(pat), nil
+}
+
+// GetWindowLayout returns the layout of the window for the command, as it
+// was last laid out for it, or a layout of the size of the settings if it
+// never was.
+func (b *Backend) GetWindowLayout(command string) store.Layout {
+	if layout, ok := b.layouts.Get(command); ok {
+		return layout
+	}
+	prefs := b.settings.Get()
+	return store.Layout{Width: prefs.Width, Height: prefs.Height}
+}
+
+// SaveWindowLayout keeps the layout of the window for the command, for the
+// frontend to call whenever a split is moved or a group of options is
+// collapsed or expanded. The size is the window's, but in a browser. The
+// layout is restored when the command is made a target again, see SetTarget.
+func (b *Backend) SaveWindowLayout(command string, layout store.Layout) error {
+	if width, height := b.view.Size(); width > 0 && height > 0 {
+		layout.Width, layout.Height = width, height
+	}
+	return b.layouts.Set(command, layout)
+}
+
+// lay_out sizes the window as it was last for the command and emits its
+// layout as a "layout" event carrying the command and the store.Layout,
+// for the frontend to move the splits and collapse the groups, if it was
+// laid out for it before.
+func (b *Backend) lay_out(command string) {
+	layout, ok := b.layouts.Get(command)
+	if !ok {
+		return
+	}
+	if layout.Width > 0 && layout.Height > 0 {
+		b.view.Resize(layout.Width, layout.Height)
+	}
+	b.view.Emit("layout", command, layout)
+}
+
+// save_size keeps the size of the window for the target of the session the
+// user is on, as gtoc exits.
+func (b *Backend) save_size() error {
+	width, height := b.view.Size()
+	var target string
+	b.mu.Lock()
+	if s, ok := b.sessions[b.focus.session]; ok {
+		target = s.target
+	}
+	b.mu.Unlock()
+	if target == "" || width == 0 || height == 0 {
+		return nil
+	}
+	layout, _ := b.layouts.Get(target)
+	layout.Width, layout.Height = width, height
+	return b.layouts.Set(target, layout)
+}
+
+// PickTarget lets the user pick the program to make the target of the
+// session, see SetTarget, and returns its command. It returns "" if the user
+// gave up.
+func (b *Backend) PickTarget(sessionID string) (string, error) {
+	path, err := b.view.OpenFile()
+	if err != nil || path == "" {
+		return "", err
+	}
+	// the path is a word of the command line
+	command := runner.Quote([]string{path})
+	if _, err := b.SetTarget(sessionID, command); err != nil {
+		return "", err
+	}
+	return command, nil
+}
+
+// PatternNode is a node of the pattern of a command, as the frontend reads
+// it. Type is "argument", "command" or "option" for the leaves, and
+// "required", "optional", "optionsshortcut", "oneormore" or "either" for the
+// branches. Source is where the node comes from, "help" or "completion" for
+// instance, empty if that's unknown.
+type PatternNode struct {
+	Type        string                  `json:"type"`
+	Name        string                  `json:"name,omitempty"`
+	Short       string                  `json:"short,omitempty"`
+	Long        string                  `json:"long,omitempty"`
+	Argcount    int                     `json:"argcount,omitempty"`
+	Value       interface{}             `json:"value"`
+	Description string                  `json:"description,omitempty"`
+	Metavar     string                  `json:"metavar,omitempty"`
+	Default     string                  `json:"default,omitempty"`
+	Choices     []string                `json:"choices,omitempty"`
+	Section     string                  `json:"section,omitempty"`
+	EnvVar      string                  `json:"envVar,omitempty"`
+	Deprecated  bool                    `json:"deprecated,omitempty"`
+	Stdin       bool                    `json:"stdin,omitempty"`
+	Source      string                  `json:"source,omitempty"`
+	Children    []*PatternNode          `json:"children,omitempty"`
+	Subcommands map[string]*PatternNode `json:"subcommands,omitempty"`
+}
+
+// pattern_node returns the node of pat and of its children.
+func pattern_node(pat *docopt.Pattern) *PatternNode {
+	node := &PatternNode{
+		Type:        pat.T.String(),
+		Name:        pat.Name,
+		Short:       pat.Short,
+		Long:        pat.Long,
+		Argcount:    pat.Argcount,
+		Value:       pat.Value,
+		Description: pat.Description,
+		Metavar:     pat.Metavar,
+		Default:     pat.Default,
+		Choices:     pat.Choices,
+		Section:     pat.Section,
+		EnvVar:      pat.EnvVar,
+		Deprecated:  pat.Deprecated,
+		Stdin:       pat.Stdin,
+		Source:      pat.Provenance.Kind.String(),
+	}
+	for _, child := range pat.Children {
+		node.Children = append(node.Children, pattern_node(child))
+	}
+	for name, sub := range pat.Subcommands {
+		if node.Subcommands == nil {
+			node.Subcommands = make(map[string]*PatternNode)
+		}
+		node.Subcommands[name] = pattern_node(sub)
+	}
+	return node
+}
+
+// SearchCommands returns at most limit commands of the PATH matching query,
+// for the user to pick the target from; the PATH is scanned on the first
+// search.
+func (b *Backend) SearchCommands(query string, limit int) []commands.Command {
+	return b.commands.Search(query, limit)
+}
+
+// RescanCommands forgets the scanned commands, for the next search to scan
+// the PATH again once programs were installed or removed.
+func (b *Backend) RescanCommands() {
+	b.commands.Rescan()
+}
+
+// GetPattern starts probing the command for its help text, and the
+// subcommands it lists for theirs, see discover_subcommands, and returns the
+// ID of the task. How far it is is emitted as "pattern:progress" events
+// carrying a ParseProgress, and its end as a "pattern:done" event carrying a
+// PatternResult, or a "pattern:error" event carrying a ParseFailure. Probing
+// anew cancels the probe in progress, see start_parse and CancelPattern.
+func (b *Backend) GetPattern(command string) string {
+	ctx, cancel, task := start_parse()
+	go func() {
+		defer cancel()
+		pat, err := b.parse(ctx, task, command)
+		if err != nil {
+			b.view.Emit("pattern:error", ParseFailure{task, command, err.Error(), ctx.Err() != nil})
+			return
+		}
+		b.used(command)
+		b.view.Emit("pattern:done", PatternResult{task, command, pattern_node(pat)})
+	}()
+	return task
+}
+
+// parse probes the command and its subcommands for the task, see GetPattern.
+func (b *Backend) parse(ctx context.Context, task, command string) (*docopt.Pattern, error) {
+	pat, help, err := probe_help(ctx, command, nil, "", func(stage string) {
+		b.view.Emit("pattern:progress", ParseProgress{Task: task, Stage: stage, Command: command})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = discover_subcommands(ctx, command, pat, help, func(sub string, done, total int) {
+		b.view.Emit("pattern:progress", ParseProgress{task, "subcommands", sub, done, total})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pat, nil
+}
+
+// ParseProgress is how far the probe of a task is: Stage is "probe" while
+// the command is run for its help text, "parse" while that's parsed, and
+// "subcommands" while the subcommands it lists are probed, Done of Total,
+// Command being the one probed.
+type ParseProgress struct {
+	Task    string `json:"task"`
+	Stage   string `json:"stage"`
+	Command string `json:"command"`
+	Done    int    `json:"done"`
+	Total   int    `json:"total"`
+}
+
+// PatternResult is the pattern a task probed the command for.
+type PatternResult struct {
+	Task    string       `json:"task"`
+	Command string       `json:"command"`
+	Pattern *PatternNode `json:"pattern"`
+}
+
+// ParseFailure is why a task failed to probe the command, Canceled telling
+// whether it was canceled.
+type ParseFailure struct {
+	Task     string `json:"task"`
+	Command  string `json:"command"`
+	Error    string `json:"error"`
+	Canceled bool   `json:"canceled"`
+}
+
+// GetContainerPattern is like GetPattern, but probes the command in the
+// container, for runs in it.
+func (b *Backend) GetContainerPattern(command string, container runner.Container) (*PatternNode, error) {
+	pat, err := get_pattern_in(command, &container)
+	if err != nil {
+		return nil, err
+	}
+	return pattern_node(pat), nil
+}
+
+// CancelPattern cancels the probe of the task, if it's still in progress.
+func (b *Backend) CancelPattern(task string) {
+	parse_mu.Lock()
+	defer parse_mu.Unlock()
+	if task == parse_task {
+		cancel_parse()
+	}
+}
+
+// GetMermaid returns a Mermaid flowchart of the command's usage, for the
+// frontend to render as an overview.
+func (b *Backend) GetMermaid(command string) (string, error) {
+	pat, err := get_pattern(command)
+	if err != nil {
+		return "", err
+	}
+	return pat.Mermaid(), nil
+}
+
+// ValueModel is the kind of value an argument, command or option of a
+// command takes, see docopt.ValueModel. Kind is "bool", "count", "string"
+// or "stringlist", and Max is -1 for a value repeating without bound.
+type ValueModel struct {
+	Name string           `json:"name"`
+	Kind docopt.ValueKind `json:"kind"`
+	Min  int              `json:"min"`
+	Max  int              `json:"max"`
+}
+
+// GetValueModels returns the value models of the command's arguments,
+// commands and options, for the frontend to pick a widget for each: a list
+// it can add to and remove from for repeated values.
+func (b *Backend) GetValueModels(command string) ([]ValueModel, error) {
+	pat, err := get_pattern(command)
+	if err != nil {
+		return nil, err
+	}
+	models, err := pat.ValueModels()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]ValueModel, len(models))
+	for i, m := range models {
+		list[i] = ValueModel{m.Name, m.Kind, m.Min, m.Max}
+	}
+	return list, nil
+}
+
+// GetFormSpec returns the form of the target of the session, the widget of
+// every field decided, for the frontend to render as is. It's nil if the
+// session has no target yet.
+func (b *Backend) GetFormSpec(sessionID string) (*form.Spec, error) {
+	target, pat, err := b.target(sessionID)
+	if err != nil || pat == nil {
+		return nil, err
+	}
+	if b.baked != nil && target == b.baked.Command {
+		// the form was frozen when baking
+		return b.baked.Form, nil
+	}
+	return form.Build(pat)
+}
+
+// HelpExcerpt returns the lines of the help text of the target of the
+// session describing the argument, command or option id, as the command
+// printed them, for the frontend to show next to the field. It's an error
+// if the help text isn't known, as for a baked app, or doesn't describe id.
+func (b *Backend) HelpExcerpt(sessionID string, id string) (form.Excerpt, error) {
+	target, pat, err := b.target(sessionID)
+	if err != nil {
+		return form.Excerpt{}, err
+	}
+	if pat == nil {
+		return form.Excerpt{}, b.errorf("error.noTarget", sessionID)
+	}
+	help_mu.Lock()
+	doc, ok := help_texts[target]
+	help_mu.Unlock()
+	if !ok {
+		return form.Excerpt{}, b.errorf("error.noHelp", target)
+	}
+	excerpt, ok := form.HelpExcerpt(doc, pat, id)
+	if !ok {
+		return form.Excerpt{}, b.errorf("error.noExcerpt", id)
+	}
+	return excerpt, nil
+}
+
+// ExportForm returns the form.Document of the target of the session, the
+// form as other frontends read it, see "gtoc --form". It's nil if the
+// session has no target yet.
+func (b *Backend) ExportForm(sessionID string) (*form.Document, error) {
+	target, pat, err := b.target(sessionID)
+	if err != nil || pat == nil {
+		return nil, err
+	}
+	return form.Export(target, pat)
+}
+
+// GetEnvHints returns the environment variables the command's help text
+// mentions, for the environment editor to offer along with their current
+// values.
+func (b *Backend) GetEnvHints(command string) ([]runner.EnvHint, error) {
+	pat, err := get_pattern(command)
+	if err != nil {
+		return nil, err
+	}
+	return runner.EnvHints(pat), nil
+}
+
+// Validation tells whether the values of a run fit the pattern of its
+// command, and if they don't, why. Line is the command line they make, when
+// they do. Fields are why the values of the fields that don't fit don't,
+// keyed by field ID, see form.Check; Error may then be empty.
+type Validation struct {
+	Valid  bool              `json:"valid"`
+	Error  string            `json:"error,omitempty"`
+	Line   string            `json:"line,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Validate checks the values of the requested run against the pattern of
+// its command, without running it. Values that don't fit make an invalid
+// Validation, rather than an error, which tells the command couldn't be
+// probed.
+func (b *Backend) Validate(req RunRequest) (Validation, error) {
+	preview, err := b.Preview(req)
+	var usage *docopt.UsageError
+	if errors.As(err, &usage) {
+		return Validation{Error: usage.Error()}, nil
+	}
+	if err != nil {
+		return Validation{}, err
+	}
+	return Validation{Valid: true, Line: preview.Line}, nil
+}
+
+// ValidateValues checks the values of the form of the session against the
+// pattern of its target, like Validate, and tells the fields that don't fit
+// apart, for the frontend to mark each of them.
+func (b *Backend) ValidateValues(sessionID string, values map[string]interface{}) (Validation, error) {
+	target, pat, err := b.target(sessionID)
+	if err != nil {
+		return Validation{}, err
+	}
+	if pat == nil {
+		return Validation{}, b.errorf("error.noTarget", sessionID)
+	}
+	opts := runner.DecodeValues(values)
+	fields, err := form.Check(pat, opts)
+	if err != nil {
+		return Validation{}, err
+	}
+	var msg string
+	if err = pat.Validate(opts); err != nil {
+		msg = err.Error()
+	}
+	preview, err := runner.DryRun(runner.Request{Program: target, Pattern: pat, Values: opts})
+	if err != nil && msg == "" {
+		msg = err.Error()
+	}
+	if len(fields) > 0 || msg != "" {
+		return Validation{Error: msg, Fields: fields}, nil
+	}
+	return Validation{Valid: true, Line: preview.Line}, nil
+}
@@ -0,0 +1,149 @@
+// Package bake bakes the GUI of a single command into a copy of gtoc: the
+// pattern of the command, its form and the branding of the app are
+// appended to the executable, which then opens straight on the form of the
+// command, for maintainers to ship a GUI of their tool to users who never
+// see gtoc.
+package bake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"gtoc/docopt"
+	"gtoc/form"
+)
+
+// Version is the version of the format of the bundles, raised with every
+// change older versions of gtoc couldn't read.
+const Version = 1
+
+// magic ends a baked executable, after the size of the bundle.
+const magic = "gtocbake"
+
+// trailer is the size of what follows the bundle.
+const trailer = 8 + len(magic)
+
+// Brand is how a baked app presents itself.
+type Brand struct {
+	// Title is the title of the window
+	Title string `json:"title"`
+	// Colour is the background colour of the window, as "#rrggbb" or "#rgb"
+	Colour string `json:"colour,omitempty"`
+}
+
+// Bundle is what's baked into an app: the command, its pattern as it was
+// probed when baking, its form frozen then, and the branding.
+type Bundle struct {
+	Command string
+	Pattern *docopt.Pattern
+	Form    *form.Spec
+	Brand   Brand
+}
+
+// bundle is a Bundle as it's appended, the pattern encoded by
+// docopt.MarshalPattern.
+type bundle struct {
+	Version int             `json:"version"`
+	Command string          `json:"command"`
+	Pattern json.RawMessage `json:"pattern"`
+	Form    *form.Spec      `json:"form"`
+	Brand   Brand           `json:"brand"`
+}
+
+// New returns the bundle of command, whose pattern is pat, freezing its
+// form.
+func New(command string, pat *docopt.Pattern, brand Brand) (*Bundle, error) {
+	spec, err := form.Build(pat)
+	if err != nil {
+		return nil, err
+	}
+	if brand.Title == "" {
+		brand.Title = command
+	}
+	return &Bundle{Command: command, Pattern: pat, Form: spec, Brand: brand}, nil
+}
+
+// Write writes the executable exe with the bundle appended to out. A bundle
+// exe has already is replaced.
+func Write(out, exe string, b *Bundle) error {
+	data, err := ioutil.ReadFile(exe)
+	if err != nil {
+		return err
+	}
+	if size, ok := baked(data); ok {
+		data = data[:len(data)-trailer-size]
+	}
+	pat, err := docopt.MarshalPattern(b.Pattern)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(bundle{Version, b.Command, pat, b.Form, b.Brand})
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.Write(data)
+	buf.Write(payload)
+	binary.Write(&buf, binary.BigEndian, uint64(len(payload)))
+	buf.WriteString(magic)
+	return ioutil.WriteFile(out, buf.Bytes(), 0755)
+}
+
+// baked returns the size of the bundle appended to data, if any.
+func baked(data []byte) (int, bool) {
+	if len(data) < trailer || string(data[len(data)-len(magic):]) != magic {
+		return 0, false
+	}
+	size := binary.BigEndian.Uint64(data[len(data)-trailer:])
+	if size > uint64(len(data)-trailer) {
+		return 0, false
+	}
+	return int(size), true
+}
+
+// Read returns the bundle baked into the executable exe, nil if it has
+// none.
+func Read(exe string) (*Bundle, error) {
+	f, err := os.Open(exe)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil || end < int64(trailer) {
+		return nil, err
+	}
+	tail := make([]byte, trailer)
+	if _, err = f.ReadAt(tail, end-int64(trailer)); err != nil {
+		return nil, err
+	}
+	if string(tail[8:]) != magic {
+		return nil, nil
+	}
+	size := binary.BigEndian.Uint64(tail)
+	if size > uint64(end)-uint64(trailer) {
+		return nil, errors.New("the bundle baked into the executable is truncated")
+	}
+	payload := make([]byte, size)
+	if _, err = f.ReadAt(payload, end-int64(trailer)-int64(size)); err != nil {
+		return nil, err
+	}
+	var b bundle
+	if err = json.Unmarshal(payload, &b); err != nil {
+		return nil, fmt.Errorf("decoding the baked bundle failed: %v", err)
+	}
+	if b.Version > Version {
+		return nil, fmt.Errorf("the app was baked by a newer gtoc, with bundle version %d", b.Version)
+	}
+	pat, err := docopt.UnmarshalPattern(b.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Bundle{Command: b.Command, Pattern: pat, Form: b.Form, Brand: b.Brand}, nil
+}
@@ -0,0 +1,69 @@
+package bake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestBake(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-bake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "gtoc")
+	program := []byte("\x7fELF not quite a program")
+	if err = ioutil.WriteFile(exe, program, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := Read(exe); b != nil || err != nil {
+		t.Errorf("result: %v error: %v expected: no bundle", b, err)
+	}
+
+	pat, err := docopt.ParsePattern("Usage: tool [--fast] <file>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New("tool", pat, Brand{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Brand.Title != "tool" || len(b.Form.Fields) != 2 {
+		t.Errorf("result: %+v expected: the title and fields of tool", b)
+	}
+	out := filepath.Join(dir, "tool-gui")
+	if err = Write(out, exe, b); err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(out)
+	if err != nil || read == nil {
+		t.Fatalf("result: %v error: %v", read, err)
+	}
+	if read.Command != "tool" || !read.Pattern.Equal(pat) || !reflect.DeepEqual(read.Form, b.Form) || read.Brand != b.Brand {
+		t.Errorf("result: %+v expected: %+v", read, b)
+	}
+	if info, _ := os.Stat(out); info.Mode()&0111 == 0 {
+		t.Errorf("result: %v expected: an executable", info.Mode())
+	}
+
+	// baking a baked app replaces its bundle
+	b.Brand.Title = "Tool"
+	again := filepath.Join(dir, "tool-gui-2")
+	if err = Write(again, out, b); err != nil {
+		t.Fatal(err)
+	}
+	if read, err = Read(again); err != nil || read.Brand.Title != "Tool" {
+		t.Errorf("result: %+v error: %v", read, err)
+	}
+	data, _ := ioutil.ReadFile(again)
+	size, _ := baked(data)
+	if head := data[:len(data)-trailer-size]; !reflect.DeepEqual(head, program) {
+		t.Errorf("result: %q expected: %q", head, program)
+	}
+}
@@ -0,0 +1,107 @@
+// Package commands finds the programs installed on the PATH, described by
+// their manual pages or desktop entries, for the user to pick one to build
+// the GUI of.
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Command is a program found on the PATH.
+type Command struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+}
+
+// Scan returns the programs in the directories of path, a list as the PATH
+// variable holds, sorted by name. A name in several directories is the
+// program of the first, the one a shell runs.
+func Scan(path string) []Command {
+	dirs, names := map[string]bool{}, map[string]bool{}
+	var list []Command
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" || dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, info := range files {
+			full := filepath.Join(dir, info.Name())
+			if info.Mode()&os.ModeSymlink != 0 {
+				// the mode of what the link points to
+				if info, err = os.Stat(full); err != nil {
+					continue
+				}
+			}
+			name, ok := executable(info)
+			if !ok || names[name] {
+				continue
+			}
+			names[name] = true
+			list = append(list, Command{Name: name, Path: full})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Search returns the commands matching the query, at most limit of them if
+// it's positive: those whose name starts with it first, then those whose
+// name holds it, then those whose description does, ignoring case. An empty
+// query matches every command.
+func Search(list []Command, query string, limit int) []Command {
+	query = strings.ToLower(query)
+	var ranks [3][]Command
+	for _, c := range list {
+		name := strings.ToLower(c.Name)
+		switch {
+		case strings.HasPrefix(name, query):
+			ranks[0] = append(ranks[0], c)
+		case strings.Contains(name, query):
+			ranks[1] = append(ranks[1], c)
+		case strings.Contains(strings.ToLower(c.Description), query):
+			ranks[2] = append(ranks[2], c)
+		}
+	}
+	found := append(append(ranks[0], ranks[1]...), ranks[2]...)
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	return found
+}
+
+// Index is the commands of the PATH, scanned once and kept until Rescan.
+type Index struct {
+	mu       sync.Mutex
+	commands []Command
+	scanned  bool
+}
+
+// Search searches the commands as Search does, scanning the PATH and
+// describing its commands first if they weren't yet.
+func (x *Index) Search(query string, limit int) []Command {
+	x.mu.Lock()
+	if !x.scanned {
+		x.commands, x.scanned = Describe(Scan(os.Getenv("PATH"))), true
+	}
+	list := x.commands
+	x.mu.Unlock()
+	return Search(list, query, limit)
+}
+
+// Rescan forgets the commands, for the next search to scan the PATH again,
+// say once programs have been installed.
+func (x *Index) Rescan() {
+	x.mu.Lock()
+	x.commands, x.scanned = nil, false
+	x.mu.Unlock()
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-commands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bin, local := filepath.Join(dir, "bin"), filepath.Join(dir, "local")
+	for path, mode := range map[string]os.FileMode{
+		filepath.Join(local, "git"):    0755,
+		filepath.Join(local, "notes"):  0644,
+		filepath.Join(bin, "git"):      0755,
+		filepath.Join(bin, "ls"):       0755,
+		filepath.Join(bin, "sub", "x"): 0755,
+	} {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err = ioutil.WriteFile(path, nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.Symlink(filepath.Join(bin, "ls"), filepath.Join(local, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	path := local + string(os.PathListSeparator) + bin + string(os.PathListSeparator) + local +
+		string(os.PathListSeparator) + filepath.Join(dir, "missing")
+	expected := []Command{
+		{Name: "dir", Path: filepath.Join(local, "dir")},
+		{Name: "git", Path: filepath.Join(local, "git")},
+		{Name: "ls", Path: filepath.Join(bin, "ls")},
+	}
+	if result := Scan(path); !reflect.DeepEqual(result, expected) {
+		t.Errorf("result: %+v expected: %+v", result, expected)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	list := []Command{
+		{Name: "bzgrep", Description: "search possibly bzip2 compressed files"},
+		{Name: "grep", Description: "print lines that match patterns"},
+		{Name: "ls", Description: "list directory contents"},
+		{Name: "zgrep", Description: "search possibly compressed files"},
+	}
+	for i, tt := range []struct {
+		query    string
+		limit    int
+		expected []string
+	}{
+		{"GREP", 0, []string{"grep", "bzgrep", "zgrep"}},
+		{"grep", 2, []string{"grep", "bzgrep"}},
+		{"compressed", 0, []string{"bzgrep", "zgrep"}},
+		{"", 0, []string{"bzgrep", "grep", "ls", "zgrep"}},
+		{"nothing", 0, nil},
+	} {
+		var names []string
+		for _, c := range Search(list, tt.query, tt.limit) {
+			names = append(names, c.Name)
+		}
+		if !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("testcase: %d result: %v expected: %v", i, names, tt.expected)
+		}
+	}
+}
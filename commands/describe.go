@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Describe returns the commands with the descriptions of their manual pages,
+// or else of the desktop entries running them, if they have any. Either may
+// be missing, or not apply to the platform.
+func Describe(list []Command) []Command {
+	descriptions := manual()
+	for name, d := range desktop(dataDirs()) {
+		if _, ok := descriptions[name]; !ok {
+			descriptions[name] = d
+		}
+	}
+	described := make([]Command, len(list))
+	for i, c := range list {
+		described[i] = c
+		if d, ok := descriptions[c.Name]; ok {
+			described[i].Description = d
+		}
+	}
+	return described
+}
+
+// manual returns the descriptions of the manual pages of commands, as
+// apropos lists them.
+func manual() map[string]string {
+	output, err := exec.Command("apropos", ".").Output()
+	if err != nil {
+		return map[string]string{}
+	}
+	return parseApropos(string(output))
+}
+
+// an entry of apropos is "ls (1) - list directory contents" for man-db, and
+// "cat, tac(1) - concatenate files" for mandoc
+var aproposRe = regexp.MustCompile(`^([^(]+?)\s*\((\w+)\)\s+-+\s+(.*)$`)
+
+// parseApropos returns the descriptions of the entries of sections 1, 6 and
+// 8 of the manual, those of commands, by name. The first entry of a name is
+// kept.
+func parseApropos(output string) map[string]string {
+	descriptions := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		m := aproposRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil || !strings.ContainsAny(m[2][:1], "168") {
+			continue
+		}
+		for _, name := range strings.Split(m[1], ",") {
+			name = strings.TrimSpace(name)
+			if _, ok := descriptions[name]; !ok && name != "" {
+				descriptions[name] = m[3]
+			}
+		}
+	}
+	return descriptions
+}
+
+// dataDirs returns the directories of data files, those of the user first,
+// as the XDG base directory specification has it.
+func dataDirs() []string {
+	home := os.Getenv("XDG_DATA_HOME")
+	if home == "" {
+		if dir, err := os.UserHomeDir(); err == nil {
+			home = filepath.Join(dir, ".local", "share")
+		}
+	}
+	dirs := os.Getenv("XDG_DATA_DIRS")
+	if dirs == "" {
+		dirs = "/usr/local/share:/usr/share"
+	}
+	return append([]string{home}, strings.Split(dirs, ":")...)
+}
+
+// desktop returns the descriptions of the desktop entries in the
+// applications directories of dirs, by the name of the program they run.
+// The entries of the first directories win.
+func desktop(dirs []string) map[string]string {
+	descriptions := map[string]string{}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		paths, _ := filepath.Glob(filepath.Join(dir, "applications", "*.desktop"))
+		for _, path := range paths {
+			name, d := parseDesktop(path)
+			if _, ok := descriptions[name]; !ok && name != "" && d != "" {
+				descriptions[name] = d
+			}
+		}
+	}
+	return descriptions
+}
+
+// parseDesktop returns the name of the program the desktop entry runs, and
+// its comment, or else its generic name, or else its name.
+func parseDesktop(path string) (string, string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+	keys := map[string]string{}
+	in := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			in = line == "[Desktop Entry]"
+			continue
+		}
+		// localized keys such as Comment[fr] are left out
+		if kv := strings.SplitN(line, "=", 2); in && len(kv) == 2 {
+			keys[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	d := keys["Comment"]
+	for _, key := range []string{"GenericName", "Name"} {
+		if d == "" {
+			d = keys[key]
+		}
+	}
+	return program(keys["Exec"]), d
+}
+
+// program returns the name of the program of the Exec key of a desktop
+// entry, past env and the variables it sets.
+func program(command string) string {
+	for command != "" {
+		var word string
+		if strings.HasPrefix(command, `"`) {
+			end := strings.Index(command[1:], `"`)
+			if end < 0 {
+				return ""
+			}
+			word, command = command[1:end+1], command[end+2:]
+		} else {
+			fields := strings.SplitN(command, " ", 2)
+			word, command = fields[0], ""
+			if len(fields) == 2 {
+				command = fields[1]
+			}
+		}
+		command = strings.TrimSpace(command)
+		if word == "env" || word == "" || strings.Contains(word, "=") && !strings.Contains(word, "/") {
+			continue
+		}
+		return filepath.Base(word)
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseApropos(t *testing.T) {
+	output := `ls (1)               - list directory contents
+cat, tac(1) - concatenate and print files
+ls (1p)              - list directory contents, the POSIX way
+printf (3)           - formatted output conversion
+iptables (8)         -- administration tool
+`
+	expected := map[string]string{
+		"ls":       "list directory contents",
+		"cat":      "concatenate and print files",
+		"tac":      "concatenate and print files",
+		"iptables": "administration tool",
+	}
+	if result := parseApropos(output); !reflect.DeepEqual(result, expected) {
+		t.Errorf("result: %v expected: %v", result, expected)
+	}
+}
+
+func TestDesktop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-desktop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	user, system := filepath.Join(dir, "user"), filepath.Join(dir, "system")
+	for path, entry := range map[string]string{
+		filepath.Join(system, "applications", "gimp.desktop"): "[Desktop Entry]\nName=GIMP\nGenericName=Image Editor\nExec=gimp-2.10 %U\n",
+		filepath.Join(system, "applications", "vlc.desktop"): "[Desktop Entry]\nComment=Read media\nExec=/usr/bin/vlc --started-from-file %U\n" +
+			"[Desktop Action new]\nComment=Other\n",
+		filepath.Join(system, "applications", "app.desktop"):   "[Desktop Entry]\nName=App\nExec=env A=1 \"/opt/my app/app\" %f\n",
+		filepath.Join(user, "applications", "vlc.desktop"):     "[Desktop Entry]\nComment=My VLC\nExec=vlc\n",
+		filepath.Join(system, "applications", "empty.desktop"): "[Desktop Entry]\nExec=empty\n",
+	} {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err = ioutil.WriteFile(path, []byte(entry), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := map[string]string{"gimp-2.10": "Image Editor", "vlc": "My VLC", "app": "App"}
+	if result := desktop([]string{user, system}); !reflect.DeepEqual(result, expected) {
+		t.Errorf("result: %v expected: %v", result, expected)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package commands
+
+import "os"
+
+// executable returns the name of the command of the file, if it's a program
+// anyone may run.
+func executable(info os.FileInfo) (string, bool) {
+	return info.Name(), info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
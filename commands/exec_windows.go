@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// executable returns the name of the command of the file, without its
+// extension, if it has one of PATHEXT.
+func executable(info os.FileInfo) (string, bool) {
+	if !info.Mode().IsRegular() {
+		return "", false
+	}
+	exts := os.Getenv("PATHEXT")
+	if exts == "" {
+		exts = ".com;.exe;.bat;.cmd"
+	}
+	ext := filepath.Ext(info.Name())
+	for _, e := range filepath.SplitList(exts) {
+		if e != "" && strings.EqualFold(e, ext) {
+			return strings.TrimSuffix(info.Name(), ext), true
+		}
+	}
+	return "", false
+}
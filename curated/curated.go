@@ -0,0 +1,129 @@
+// Package curated holds the patterns of popular tools whose help texts are
+// hard to parse, such as tar, ffmpeg, rsync, curl and openssl, written and
+// checked by hand. They're embedded in gtoc, each for the versions of a tool
+// it fits, for the tool to be looked up here before its help text is probed.
+package curated
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"gtoc/docopt"
+	"gtoc/version"
+)
+
+// Entry is a pattern of the index, for the versions of a tool from Since,
+// and before Until if it's set. The version is found by running the tool
+// with VersionArgs, in the first group of Match in what it prints. File is
+// the help text of the pattern, in patterns/, and Subcommands those of the
+// patterns attached to it by subcommand, see docopt.Pattern.Resolve, for the
+// tools whose options differ by subcommand, such as openssl.
+type Entry struct {
+	Tool        string            `json:"tool"`
+	VersionArgs []string          `json:"versionArgs"`
+	Match       string            `json:"match"`
+	Since       string            `json:"since"`
+	Until       string            `json:"until,omitempty"`
+	File        string            `json:"file"`
+	Subcommands map[string]string `json:"subcommands,omitempty"`
+}
+
+//go:embed index.json patterns
+var files embed.FS
+
+// index are the entries of index.json, by tool.
+var index = func() map[string][]Entry {
+	data, err := files.ReadFile("index.json")
+	if err != nil {
+		panic(err)
+	}
+	var entries []Entry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		panic(err)
+	}
+	index := make(map[string][]Entry)
+	for _, e := range entries {
+		index[e.Tool] = append(index[e.Tool], e)
+	}
+	return index
+}()
+
+// Tools returns the tools there are patterns of.
+func Tools() []string {
+	tools := make([]string, 0, len(index))
+	for tool := range index {
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// Find returns the pattern of the tool, named as its program without its
+// directory, for the version version tells, along with that version. version
+// runs the tool with the arguments given and returns what it prints; it's
+// only called for the tools there are patterns of. The pattern is nil if
+// there's none for the version, or version failed.
+func Find(tool string, version func(args []string) (string, error)) (*docopt.Pattern, string, error) {
+	for _, e := range index[tool] {
+		output, err := version(e.VersionArgs)
+		if err != nil {
+			continue
+		}
+		v, ok := e.version(output)
+		if !ok {
+			continue
+		}
+		pat, err := e.Pattern()
+		if err != nil {
+			return nil, "", err
+		}
+		return pat, v, nil
+	}
+	return nil, "", nil
+}
+
+// version returns the version in the output of the tool, false if it's not
+// one the entry is for.
+func (e Entry) version(output string) (string, bool) {
+	m := regexp.MustCompile(`(?m)` + e.Match).FindStringSubmatch(output)
+	if m == nil || len(m) < 2 {
+		return "", false
+	}
+	v := m[1]
+	if version.Compare(v, e.Since) < 0 || e.Until != "" && version.Compare(v, e.Until) >= 0 {
+		return "", false
+	}
+	return v, true
+}
+
+// Pattern parses the pattern of the entry, with its subcommands attached,
+// marked as made by hand.
+func (e Entry) Pattern() (*docopt.Pattern, error) {
+	pat, err := parse(e.File)
+	if err != nil {
+		return nil, err
+	}
+	for name, file := range e.Subcommands {
+		sub, err := parse(file)
+		if err != nil {
+			return nil, err
+		}
+		pat.AttachSubcommand(name, sub)
+	}
+	return pat, nil
+}
+
+// parse parses the help text file of patterns/.
+func parse(file string) (*docopt.Pattern, error) {
+	doc, err := files.ReadFile("patterns/" + file)
+	if err != nil {
+		return nil, err
+	}
+	pat, err := docopt.ParsePattern(string(doc))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	pat.MarkProvenance(docopt.Provenance{Kind: docopt.SourceManual, Source: "curated:" + file})
+	return pat, nil
+}
@@ -0,0 +1,106 @@
+package curated
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestPatterns(t *testing.T) {
+	tools := Tools()
+	sort.Strings(tools)
+	if expected := []string{"curl", "ffmpeg", "openssl", "rsync", "tar"}; !reflect.DeepEqual(tools, expected) {
+		t.Errorf("result: %v expected: %v", tools, expected)
+	}
+	for _, tool := range tools {
+		for _, e := range index[tool] {
+			if _, err := regexp.Compile(e.Match); err != nil {
+				t.Errorf("tool: %s result: %v", tool, err)
+			}
+			pat, err := e.Pattern()
+			if err != nil {
+				t.Errorf("tool: %s result: %v", tool, err)
+				continue
+			}
+			for _, p := range append([]*docopt.Pattern{pat}, subcommands(pat)...) {
+				for _, o := range p.Options() {
+					if o.Provenance.Kind != docopt.SourceManual {
+						t.Errorf("tool: %s option: %s result: %v", tool, o.Name, o.Provenance)
+					}
+				}
+			}
+		}
+	}
+}
+
+func subcommands(pat *docopt.Pattern) []*docopt.Pattern {
+	var subs []*docopt.Pattern
+	for _, sub := range pat.Subcommands {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+func TestFind(t *testing.T) {
+	for i, tt := range []struct {
+		tool, output string
+		version      string
+		option       string
+	}{
+		{"tar", "tar (GNU tar) 1.34\nCopyright (C) 2021", "1.34", "--strip-components"},
+		{"tar", "bsdtar 3.5.1 - libarchive 3.5.1", "", ""},
+		{"tar", "tar (GNU tar) 1.23", "", ""},
+		{"ffmpeg", "ffmpeg version 6.0-6ubuntu1 Copyright (c) 2000-2023", "6.0", "-ss"},
+		{"rsync", "rsync  version 3.2.7  protocol version 31", "3.2.7", "--delete"},
+		{"curl", "curl 8.4.0 (x86_64-pc-linux-gnu) libcurl/8.4.0", "8.4.0", "--location"},
+		{"curl", "curl 7.68.0 (x86_64-pc-linux-gnu)", "", ""},
+		{"openssl", "OpenSSL 1.1.1w  11 Sep 2023", "1.1.1w", ""},
+		{"ls", "ls (GNU coreutils) 9.1", "", ""},
+	} {
+		pat, version, err := Find(tt.tool, func(args []string) (string, error) { return tt.output, nil })
+		switch {
+		case err != nil || version != tt.version:
+			t.Errorf("testcase: %d result: %s %v expected: %s", i, version, err, tt.version)
+		case tt.option == "" && (pat != nil) != (tt.version != ""):
+			t.Errorf("testcase: %d result: %v", i, pat)
+		case tt.option != "" && (pat == nil || pat.FindOption(tt.option) == nil):
+			t.Errorf("testcase: %d result: %v expected: a pattern with %s", i, pat, tt.option)
+		}
+	}
+	pat, _, err := Find("curl", func([]string) (string, error) { return "", errors.New("not found") })
+	if pat != nil || err != nil {
+		t.Errorf("result: %v %v expected: nothing", pat, err)
+	}
+}
+
+func TestArgv(t *testing.T) {
+	pat, _, err := Find("ffmpeg", func([]string) (string, error) { return "ffmpeg version n5.1.2", nil })
+	if err != nil || pat == nil {
+		t.Fatalf("result: %v %v", pat, err)
+	}
+	argv, err := pat.ToArgv(docopt.Opts{"-i": []string{"in.mp4"}, "-ss": "10", "-c:v": "copy", "<output>": "out.mkv"})
+	expected := []string{"-ss", "10", "-c:v", "copy", "-i", "in.mp4", "out.mkv"}
+	if err != nil || !reflect.DeepEqual(argv, expected) {
+		t.Errorf("result: %v %v expected: %v", argv, err, expected)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	pat, _, err := Find("openssl", func([]string) (string, error) { return "OpenSSL 3.0.2 15 Mar 2022", nil })
+	if err != nil || pat == nil {
+		t.Fatalf("result: %v %v", pat, err)
+	}
+	req, err := pat.Resolve([]string{"openssl", "req"})
+	if err != nil || req.FindOption("-subj") == nil || req.FindOption("-CAkey") != nil {
+		t.Errorf("result: %v %v", req, err)
+	}
+	argv, err := req.ToArgv(docopt.Opts{"req": true, "-x509": true, "-newkey": "rsa:2048", "-nodes": true})
+	expected := []string{"req", "-x509", "-newkey", "rsa:2048", "-nodes"}
+	if err != nil || !reflect.DeepEqual(argv, expected) {
+		t.Errorf("result: %v %v expected: %v", argv, err, expected)
+	}
+}
@@ -0,0 +1,156 @@
+// Package diff compares texts line by line, such as the output of two runs
+// of a command, with the algorithm of Myers' "An O(ND) Difference Algorithm
+// and Its Variations".
+package diff
+
+import "strings"
+
+// Op is what a line of a diff does.
+type Op string
+
+const (
+	// Equal is a line both texts have.
+	Equal Op = "equal"
+	// Delete is a line of the first text only.
+	Delete Op = "delete"
+	// Insert is a line of the second text only.
+	Insert Op = "insert"
+)
+
+// Line is a line of a diff. A and B are its numbers in the first and the
+// second text, counted from 1, or 0 for a text that hasn't it.
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+	A    int    `json:"a"`
+	B    int    `json:"b"`
+}
+
+// maxEdits bounds the edits looked for between the parts of the texts that
+// differ, which take memory in their square. Texts differing more are told
+// apart as a whole: the lines of the first deleted and those of the second
+// inserted.
+const maxEdits = 2000
+
+// Text returns the diff of the lines of a and b, a final newline ending the
+// last line rather than starting another.
+func Text(a, b string) []Line {
+	return Lines(split(a), split(b))
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Lines returns the diff turning the lines a into the lines b, with the
+// fewest lines deleted and inserted. The lines deleted come before the ones
+// inserted in their place.
+func Lines(a, b []string) []Line {
+	// the lines the texts start and end with alike are left out of the
+	// search
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	var lines []Line
+	for i := 0; i < pre; i++ {
+		lines = append(lines, Line{Equal, a[i], i + 1, i + 1})
+	}
+	lines = append(lines, edits(a[pre:len(a)-suf], b[pre:len(b)-suf], pre)...)
+	for i := suf; i > 0; i-- {
+		lines = append(lines, Line{Equal, a[len(a)-i], len(a) - i + 1, len(b) - i + 1})
+	}
+	return lines
+}
+
+// edits returns the diff of a and b, the lines of both following skip lines
+// alike.
+func edits(a, b []string, skip int) []Line {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxEdits {
+		max = maxEdits
+	}
+	// v holds the furthest x reached on every diagonal k, at v[off+k], and
+	// trace a copy of v[off-d:off+d+1] after every step d
+	off := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+				return backtrack(a, b, skip, trace)
+			}
+		}
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+	}
+	var lines []Line
+	for i, line := range a {
+		lines = append(lines, Line{Delete, line, skip + i + 1, 0})
+	}
+	for i, line := range b {
+		lines = append(lines, Line{Insert, line, 0, skip + i + 1})
+	}
+	return lines
+}
+
+// backtrack returns the diff of a and b found after len(trace)-1 edits,
+// following the steps back from the end of both.
+func backtrack(a, b []string, skip int, trace [][]int) []Line {
+	var lines []Line
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+		prevX, prevY := 0, 0
+		if d > 0 {
+			prev := trace[d-1]
+			at := func(k int) int { return prev[k+d-1] }
+			prevK := k - 1
+			if k == -d || (k != d && at(k-1) < at(k+1)) {
+				prevK = k + 1
+			}
+			prevX = at(prevK)
+			prevY = prevX - prevK
+		}
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, Line{Equal, a[x], skip + x + 1, skip + y + 1})
+		}
+		if d == 0 {
+			break
+		}
+		// the snake stops right after the edit of the step
+		if x == prevX {
+			y--
+			lines = append(lines, Line{Insert, b[y], 0, skip + y + 1})
+		} else {
+			x--
+			lines = append(lines, Line{Delete, a[x], skip + x + 1, 0})
+		}
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
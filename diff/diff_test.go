@@ -0,0 +1,103 @@
+package diff
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	for i, tt := range []struct {
+		a, b     string
+		expected []Line
+	}{
+		{"", "", nil},
+		{"a\n", "a", []Line{{Equal, "a", 1, 1}}},
+		{"", "a\nb\n", []Line{{Insert, "a", 0, 1}, {Insert, "b", 0, 2}}},
+		{"a\nb\nc\n", "a\nc\n", []Line{{Equal, "a", 1, 1}, {Delete, "b", 2, 0}, {Equal, "c", 3, 2}}},
+		{"a\nb\nc\n", "a\nx\nc\nd\n",
+			[]Line{{Equal, "a", 1, 1}, {Delete, "b", 2, 0}, {Insert, "x", 0, 2}, {Equal, "c", 3, 3}, {Insert, "d", 0, 4}}},
+		{"x\na\nb\n", "a\nb\ny\n",
+			[]Line{{Delete, "x", 1, 0}, {Equal, "a", 2, 1}, {Equal, "b", 3, 2}, {Insert, "y", 0, 3}}},
+	} {
+		if result := Text(tt.a, tt.b); !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("testcase: %d result: %v expected: %v", i, result, tt.expected)
+		}
+	}
+}
+
+// lcs returns the length of the longest common subsequence of a and b.
+func lcs(a, b []string) int {
+	row := make([]int, len(b)+1)
+	for i := range a {
+		prev := 0
+		for j := range b {
+			cur := row[j+1]
+			if a[i] == b[j] {
+				row[j+1] = prev + 1
+			} else if row[j] > row[j+1] {
+				row[j+1] = row[j]
+			}
+			prev = cur
+		}
+	}
+	return row[len(b)]
+}
+
+func TestLines(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, r.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + r.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+		var gotA, gotB []string
+		equal := 0
+		var last Op
+		for _, line := range Lines(a, b) {
+			// the lines deleted come first
+			if line.Op == Delete && last == Insert {
+				t.Errorf("testcase: %d deletion after insertion: %+v", i, line)
+			}
+			last = line.Op
+			if line.Op != Insert {
+				gotA = append(gotA, line.Text)
+				if a[line.A-1] != line.Text {
+					t.Fatalf("testcase: %d line: %+v", i, line)
+				}
+			}
+			if line.Op != Delete {
+				gotB = append(gotB, line.Text)
+				if b[line.B-1] != line.Text {
+					t.Fatalf("testcase: %d line: %+v", i, line)
+				}
+			}
+			if line.Op == Equal {
+				equal++
+			}
+		}
+		if strings.Join(gotA, ",") != strings.Join(a, ",") || strings.Join(gotB, ",") != strings.Join(b, ",") {
+			t.Errorf("testcase: %d result: %v %v expected: %v %v", i, gotA, gotB, a, b)
+		}
+		if expected := lcs(a, b); equal != expected {
+			t.Errorf("testcase: %d equal: %d expected: %d", i, equal, expected)
+		}
+	}
+}
+
+func TestTooDifferent(t *testing.T) {
+	var a, b []string
+	for i := 0; i < maxEdits; i++ {
+		a = append(a, "a")
+		b = append(b, "b")
+	}
+	lines := Lines(append(a, "c"), append(b, "c"))
+	if len(lines) != 2*maxEdits+1 || lines[0].Op != Delete || lines[maxEdits].Op != Insert || lines[2*maxEdits].Op != Equal {
+		t.Errorf("result: %d lines", len(lines))
+	}
+}
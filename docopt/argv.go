@@ -0,0 +1,275 @@
+package docopt
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ToArgv builds the command line, without the program name, that supplies
+// values to the pattern, values being keyed like the result of Match. Leaves
+// are emitted in the order of the usage, following for every either the
+// alternative that fits the given values best. A "--" separator is inserted
+// before the first argument value that would otherwise read as an option.
+// The values of lists are emitted in order. Leaves the usage doesn't require
+// are left out when they're empty or at their default, see Given, as the
+// command assumes defaults anyway. Values that are missing for a required
+// leaf, lists holding more or fewer values than the usage takes, and values
+// that don't fit the chosen usage are reported as a *UsageError.
+func (p *Pattern) ToArgv(values Opts) ([]string, error) {
+	if err := p.checkLists(values); err != nil {
+		return nil, err
+	}
+	b := &argvBuilder{values: values, emitted: map[string]bool{}, separator: -1}
+	b.digitOptions = hasDigitOption(p.Options())
+	if err := b.build(p); err != nil {
+		return nil, err
+	}
+	unused := []string{}
+	for name := range values {
+		if !b.emitted[name] && p.Given(values, name) && name != "--" {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return nil, newUserError("%s can't be used with the other values", strings.Join(unused, ", "))
+	}
+	return b.argv(), nil
+}
+
+type argvItem struct {
+	args       []string
+	positional bool
+	// protect is set for argument values that look like options
+	protect bool
+}
+
+type argvBuilder struct {
+	values  Opts
+	emitted map[string]bool
+	items   []argvItem
+	// separator is the position of a "--" the usage asks for, if any
+	separator int
+	// digitOptions is set when negative numbers read as options
+	digitOptions bool
+}
+
+func (b *argvBuilder) build(p *Pattern) error {
+	switch {
+	case p.T&patternLeaf != 0:
+		return b.leaf(p)
+	case p.T&(patternOptionAL|patternOptionSSHORTCUT) != 0:
+		for _, child := range p.Children {
+			if child.T&patternLeaf != 0 && !child.supplies(b.values[child.Name]) {
+				continue
+			}
+			if child.T&patternBranch != 0 && (!b.satisfies(child) || b.score(child) == 0) {
+				continue
+			}
+			if err := b.build(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case p.T&patternEither != 0:
+		best, bestScore := -1, -1
+		for i, alt := range p.Children {
+			if !b.satisfies(alt) {
+				continue
+			}
+			if score := b.score(alt); score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			return newUserError("values fit none of: %s", p.alternatives())
+		}
+		return b.build(p.Children[best])
+	}
+	// required and oneormore; repeated leaves carry all of their values, so a
+	// single pass is enough
+	for _, child := range p.Children {
+		if err := b.build(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// satisfies reports whether all the leaves p requires are given.
+func (b *argvBuilder) satisfies(p *Pattern) bool {
+	for name := range p.requiredSet() {
+		if !isGiven(b.values[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// score counts the leaves under p the values give, see Pattern.Given.
+func (b *argvBuilder) score(p *Pattern) int {
+	score := 0
+	for _, leaf := range p.FlatFunc(func(p *Pattern) bool { return p.T&patternLeaf != 0 }).uniqueNames() {
+		if leaf.supplies(b.values[leaf.Name]) {
+			score++
+		}
+	}
+	return score
+}
+
+func (b *argvBuilder) leaf(p *Pattern) error {
+	if b.emitted[p.Name] {
+		return nil
+	}
+	v := b.values[p.Name]
+	if !isGiven(v) {
+		return newUserError("missing required %s", p.Name)
+	}
+	b.emitted[p.Name] = true
+	switch p.T {
+	case patternCommand:
+		if p.Name == "--" {
+			// the separator is placed by argv
+			b.separator = len(b.items)
+			return nil
+		}
+		for i := 0; i < count(v); i++ {
+			b.items = append(b.items, argvItem{args: []string{p.Name}, positional: true})
+		}
+	case patternArgument:
+		for _, s := range strs(v) {
+			b.items = append(b.items, argvItem{args: []string{s}, positional: true, protect: b.looksLikeOption(s)})
+		}
+	case patternOption:
+		if p.Argcount == 0 {
+			for i := 0; i < count(v); i++ {
+				b.items = append(b.items, argvItem{args: []string{p.Name}})
+			}
+			return nil
+		}
+		for _, s := range strs(v) {
+			if p.Long != "" {
+				b.items = append(b.items, argvItem{args: []string{p.Long + "=" + s}})
+			} else {
+				b.items = append(b.items, argvItem{args: []string{p.Short, s}})
+			}
+		}
+	}
+	return nil
+}
+
+// argv flattens the items, putting a "--" before the first protected
+// argument, or where the usage has it, and moving any option that follows it
+// in front of the separator.
+func (b *argvBuilder) argv() []string {
+	separator := b.separator
+	for i, item := range b.items {
+		if item.protect && (separator < 0 || i < separator) {
+			separator = i
+			break
+		}
+	}
+	argv := []string{}
+	if separator < 0 {
+		for _, item := range b.items {
+			argv = append(argv, item.args...)
+		}
+		return argv
+	}
+	for _, item := range b.items[:separator] {
+		argv = append(argv, item.args...)
+	}
+	for _, item := range b.items[separator:] {
+		if !item.positional {
+			argv = append(argv, item.args...)
+		}
+	}
+	argv = append(argv, "--")
+	for _, item := range b.items[separator:] {
+		if item.positional {
+			argv = append(argv, item.args...)
+		}
+	}
+	return argv
+}
+
+// isGiven reports whether a value supplies its leaf: a true flag or command,
+// a positive count, a string or a non-empty list.
+func isGiven(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case int:
+		return v > 0
+	case string:
+		return true
+	case []string:
+		return len(v) > 0
+	}
+	return false
+}
+
+// Given reports whether values give the leaf called name on the command
+// line: a true flag or command, a positive count, a non-empty list, or a
+// string other than "" and the default of the option. Match fills defaults
+// in when options are missing, and the command assumes them anyway.
+func (p *Pattern) Given(values Opts, name string) bool {
+	leaves := p.FlatFunc(func(p *Pattern) bool { return p.T&patternLeaf != 0 && p.Name == name })
+	if len(leaves) == 0 {
+		return (&Pattern{}).supplies(values[name])
+	}
+	return leaves[0].supplies(values[name])
+}
+
+// supplies reports whether v gives the leaf, see Given.
+func (p *Pattern) supplies(v interface{}) bool {
+	if s, ok := v.(string); !isGiven(v) || ok && s == "" {
+		return false
+	}
+	return !p.isDefault(v)
+}
+
+// isDefault reports whether v is the default of the leaf, split into words
+// for the leaves holding lists as Match does.
+func (p *Pattern) isDefault(v interface{}) bool {
+	if p.Default == "" {
+		return false
+	}
+	switch v := v.(type) {
+	case string:
+		return v == p.Default
+	case []string:
+		return strings.Join(v, " ") == strings.Join(strings.Fields(p.Default), " ")
+	}
+	return false
+}
+
+func count(v interface{}) int {
+	if n, ok := v.(int); ok {
+		return n
+	}
+	return 1
+}
+
+func strs(v interface{}) []string {
+	switch v := v.(type) {
+	case []string:
+		return v
+	case string:
+		return []string{v}
+	case int:
+		return []string{strconv.Itoa(v)}
+	}
+	return nil
+}
+
+// looksLikeOption reports whether an argument value would be read as an
+// option on a command line. Negative numbers don't, unless there are options
+// named after digits.
+func (b *argvBuilder) looksLikeOption(s string) bool {
+	if reNegativeNumber.MatchString(s) {
+		return b.digitOptions
+	}
+	return strings.HasPrefix(s, "-") && s != "-"
+}
@@ -0,0 +1,143 @@
+package docopt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToArgv(t *testing.T) {
+	pat, err := ParsePattern(`Usage:
+  prog ship new <name>...
+  prog ship <name> move <x> <y> [--speed=<kn>]
+  prog mine (set|remove) <x> <y> [--moored | --drifting] [-v...]
+  prog -h | --help
+
+Options:
+  -h --help     Show this screen.
+  --speed=<kn>  Speed in knots [default: 10].
+  -v            Verbose.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range []struct {
+		values Opts
+		expect []string
+		err    string
+	}{
+		{Opts{"ship": true, "new": true, "<name>": []string{"a", "b"}},
+			[]string{"ship", "new", "a", "b"}, ""},
+		{Opts{"ship": true, "move": true, "<name>": []string{"a"}, "<x>": "1", "<y>": "2", "--speed": "20"},
+			[]string{"ship", "a", "move", "1", "2", "--speed=20"}, ""},
+		{Opts{"mine": true, "remove": true, "<x>": "1", "<y>": "-2", "--drifting": true, "-v": 2},
+			[]string{"mine", "remove", "1", "-2", "--drifting", "-v", "-v"}, ""},
+		{Opts{"mine": true, "remove": true, "<x>": "-y", "<y>": "-2", "--drifting": true},
+			[]string{"mine", "remove", "--drifting", "--", "-y", "-2"}, ""},
+		{Opts{"ship": true, "new": true, "<name>": []string{"-x"}},
+			[]string{"ship", "new", "--", "-x"}, ""},
+		{Opts{"ship": true, "move": true, "<name>": []string{"a"}, "<x>": "1", "<y>": "2", "--speed": "10"},
+			[]string{"ship", "a", "move", "1", "2"}, ""},
+		{Opts{"--help": true}, []string{"--help"}, ""},
+		{Opts{"mine": true, "set": true, "<x>": "1"}, nil, "values fit none of: ship new <name>... | " +
+			"ship <name> move <x> <y> [--speed=<kn>] | mine (set | remove) <x> <y> [--moored | --drifting] [-v...] | " +
+			"(--help | --help)"},
+		{Opts{"ship": true, "new": true, "<name>": []string{"a"}, "--drifting": true}, nil,
+			"--drifting can't be used with the other values"},
+	} {
+		argv, err := pat.ToArgv(c.values)
+		if c.err != "" {
+			if _, ok := err.(*UsageError); !ok || err.Error() != c.err {
+				t.Errorf("testcase: %d error: %v expect: %q", i, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase: %d error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(argv, c.expect) {
+			t.Errorf("testcase: %d result: %q expect: %q", i, argv, c.expect)
+		}
+		opts, err := Match(pat, argv)
+		if err != nil {
+			t.Errorf("testcase: %d match error: %v", i, err)
+			continue
+		}
+		for k, v := range c.values {
+			if !reflect.DeepEqual(opts[k], v) {
+				t.Errorf("testcase: %d round trip %s: %#v expect: %#v", i, k, opts[k], v)
+			}
+		}
+	}
+
+	// options left empty or at their default are left out
+	opts, err := Match(pat, []string{"ship", "a", "move", "1", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	argv, err := pat.ToArgv(opts)
+	if expect := []string{"ship", "a", "move", "1", "2"}; err != nil || !reflect.DeepEqual(argv, expect) {
+		t.Errorf("result: %q error: %v expect: %q", argv, err, expect)
+	}
+	opts["--speed"] = ""
+	argv, err = pat.ToArgv(opts)
+	if expect := []string{"ship", "a", "move", "1", "2"}; err != nil || !reflect.DeepEqual(argv, expect) {
+		t.Errorf("result: %q error: %v expect: %q", argv, err, expect)
+	}
+}
+
+func TestMatchDoubleDash(t *testing.T) {
+	pat, err := ParsePattern("Usage: prog [-v] <x>...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := Match(pat, []string{"-v", "--", "-v", "--", "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Opts{"-v": true, "<x>": []string{"-v", "--", "y"}}
+	if !reflect.DeepEqual(opts, expect) {
+		t.Errorf("result: %v expect: %v", opts, expect)
+	}
+
+	pat, err = ParsePattern("Usage: prog [--] <x>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err = Match(pat, []string{"--", "-y"})
+	if err != nil || opts["--"] != true || opts["<x>"] != "-y" {
+		t.Errorf("result: %v error: %v", opts, err)
+	}
+	argv, err := pat.ToArgv(Opts{"--": true, "<x>": "y"})
+	if err != nil || !reflect.DeepEqual(argv, []string{"--", "y"}) {
+		t.Errorf("result: %q error: %v", argv, err)
+	}
+}
+
+func TestMatchNegativeNumbers(t *testing.T) {
+	pat, err := ParsePattern("Usage: prog [-v] [-n NUM] <x> <y>\n\nOptions:\n  -v\n  -n NUM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := Match(pat, []string{"-5", "-v", "-0.3", "-n", "-1e3"})
+	expect := Opts{"-v": true, "-n": "-1e3", "<x>": "-5", "<y>": "-0.3"}
+	if err != nil || !reflect.DeepEqual(opts, expect) {
+		t.Errorf("result: %v error: %v", opts, err)
+	}
+	if _, err := Match(pat, []string{"-5x", "1"}); err == nil {
+		t.Error("error expected for -5x")
+	}
+
+	// with digit options negative numbers are options again
+	pat, err = ParsePattern("Usage: prog [-1] [<x>]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err = Match(pat, []string{"-1"})
+	if err != nil || opts["-1"] != true || opts["<x>"] != nil {
+		t.Errorf("result: %v error: %v", opts, err)
+	}
+	argv, err := pat.ToArgv(Opts{"<x>": "-2"})
+	if err != nil || !reflect.DeepEqual(argv, []string{"--", "-2"}) {
+		t.Errorf("result: %q error: %v", argv, err)
+	}
+}
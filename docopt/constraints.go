@@ -0,0 +1,293 @@
+package docopt
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ConstraintKind tells what a Constraint asks of the values of a command line.
+type ConstraintKind int
+
+const (
+	// ConstraintRequires asks that when Name is given all of Names are.
+	ConstraintRequires ConstraintKind = iota
+	// ConstraintConflicts asks that names of at most one of Groups are given.
+	ConstraintConflicts
+	// ConstraintOneOf asks that at least one of Names is given.
+	ConstraintOneOf
+)
+
+func (k ConstraintKind) String() string {
+	switch k {
+	case ConstraintRequires:
+		return "requires"
+	case ConstraintConflicts:
+		return "conflicts"
+	case ConstraintOneOf:
+		return "one of"
+	}
+	return ""
+}
+
+// Constraint is a rule between the arguments, commands and options of a
+// pattern, named as in the values Match returns.
+type Constraint struct {
+	Kind   ConstraintKind
+	Name   string
+	Names  []string
+	Groups [][]string
+}
+
+// Constraints are the rules a command line has to follow besides fitting the
+// usage, see Pattern.Constraints.
+type Constraints []Constraint
+
+var (
+	reConflictsWith = regexp.MustCompile(`(?i)\b(?:(?:cannot|can ?not|can't|may not|must not) be (?:used|combined|given)(?: together)? with|conflicts with|incompatible with|mutually exclusive with)\b`)
+	reRequires      = regexp.MustCompile(`(?i)\b(?:requires|must be used with|only (?:valid |allowed |used )?with)\b`)
+	reLeafReference = regexp.MustCompile(`<[^>]+>|--?[A-Za-z0-9][\w-]*`)
+)
+
+// Constraints returns the rules between the leaves of the pattern, so a
+// frontend can enable and disable its fields as values are filled in. They
+// come from the usage: a leaf requires what is given whenever it is, the
+// branches of an either conflict, and one of those of a required either has
+// to be given. Option descriptions add more, with phrases such as "cannot be
+// used with --x" or "requires --y".
+func (p *Pattern) Constraints() Constraints {
+	var cs Constraints
+	leaves := p.FlatFunc(func(p *Pattern) bool { return p.T&patternLeaf != 0 }).uniqueNames()
+	order := make(map[string]int)
+	for i, leaf := range leaves {
+		order[leaf.Name] = i
+	}
+	implications := p.implications()
+	for _, leaf := range leaves {
+		delete(implications[leaf.Name], leaf.Name)
+		if names := sortedNames(implications[leaf.Name], order); len(names) > 0 {
+			cs = append(cs, Constraint{Kind: ConstraintRequires, Name: leaf.Name, Names: names})
+		}
+	}
+	p.eitherConstraints(&cs, order, true, false)
+	for _, leaf := range leaves {
+		cs = append(cs, p.describedConstraints(leaf)...)
+	}
+	return cs
+}
+
+// implications returns for every leaf in the pattern the names that are given
+// whenever it is, itself included.
+func (p *Pattern) implications() map[string]map[string]bool {
+	if p.T&patternLeaf != 0 {
+		return map[string]map[string]bool{p.Name: {p.Name: true}}
+	}
+	result := make(map[string]map[string]bool)
+	// a leaf in several children of an either or an optional group only
+	// implies what all of them do, the children of other groups all match
+	intersect := p.T&(patternEither|patternOptionAL|patternOptionSSHORTCUT) != 0
+	var required map[string]bool
+	if !intersect {
+		required = p.requiredSet()
+	}
+	for _, child := range p.Children {
+		if intersect {
+			required = child.requiredSet()
+		}
+		for name, implied := range child.implications() {
+			for r := range required {
+				implied[r] = true
+			}
+			existing, ok := result[name]
+			switch {
+			case !ok:
+				result[name] = implied
+			case intersect:
+				for n := range existing {
+					if !implied[n] {
+						delete(existing, n)
+					}
+				}
+			default:
+				for n := range implied {
+					existing[n] = true
+				}
+			}
+		}
+	}
+	return result
+}
+
+// eitherConstraints adds the conflicts between the branches of the eithers in
+// the pattern, and the branches one of which has to be given for eithers that
+// are required. Branches that may repeat don't conflict.
+func (p *Pattern) eitherConstraints(cs *Constraints, order map[string]int, required, repeated bool) {
+	switch {
+	case p.T&patternEither != 0:
+		branches := make([]map[string]bool, len(p.Children))
+		seen := make(map[string]int)
+		for i, child := range p.Children {
+			branches[i] = make(map[string]bool)
+			for _, leaf := range child.FlatFunc(func(p *Pattern) bool { return p.T&patternLeaf != 0 }).uniqueNames() {
+				branches[i][leaf.Name] = true
+				seen[leaf.Name]++
+			}
+		}
+		if !repeated {
+			var groups [][]string
+			for _, branch := range branches {
+				exclusive := make(map[string]bool)
+				for name := range branch {
+					if seen[name] == 1 {
+						exclusive[name] = true
+					}
+				}
+				if len(exclusive) > 0 {
+					groups = append(groups, sortedNames(exclusive, order))
+				}
+			}
+			if len(groups) > 1 {
+				*cs = append(*cs, Constraint{Kind: ConstraintConflicts, Groups: groups})
+			}
+		}
+		if required {
+			requiredSets := make([]map[string]bool, len(p.Children))
+			requiredBy := make(map[string]int)
+			for i, child := range p.Children {
+				requiredSets[i] = child.requiredSet()
+				for name := range requiredSets[i] {
+					requiredBy[name]++
+				}
+			}
+			// every branch has to need a name no other branch does
+			oneOf := make(map[string]bool)
+			for _, set := range requiredSets {
+				distinct := false
+				for name := range set {
+					if requiredBy[name] == 1 {
+						oneOf[name] = true
+						distinct = true
+					}
+				}
+				if !distinct {
+					oneOf = nil
+					break
+				}
+			}
+			if len(oneOf) > 1 {
+				*cs = append(*cs, Constraint{Kind: ConstraintOneOf, Names: sortedNames(oneOf, order)})
+			}
+		}
+		// the branches are only required when they're picked
+		required = false
+	case p.T&(patternOptionAL|patternOptionSSHORTCUT) != 0:
+		required = false
+	case p.T&patternOneOrMore != 0:
+		repeated = true
+	}
+	for _, child := range p.Children {
+		child.eitherConstraints(cs, order, required, repeated)
+	}
+}
+
+// describedConstraints returns the constraints the description of leaf
+// states about the other leaves of the pattern.
+func (p *Pattern) describedConstraints(leaf *Pattern) Constraints {
+	var cs Constraints
+	for _, sentence := range strings.Split(leaf.Description, ". ") {
+		if loc := reConflictsWith.FindStringIndex(sentence); loc != nil {
+			if names := p.referencedNames(leaf, sentence[loc[1]:]); len(names) > 0 {
+				cs = append(cs, Constraint{Kind: ConstraintConflicts, Groups: [][]string{{leaf.Name}, names}})
+			}
+		} else if loc := reRequires.FindStringIndex(sentence); loc != nil {
+			if names := p.referencedNames(leaf, sentence[loc[1]:]); len(names) > 0 {
+				cs = append(cs, Constraint{Kind: ConstraintRequires, Name: leaf.Name, Names: names})
+			}
+		}
+	}
+	return cs
+}
+
+// referencedNames returns the names of the leaves other than leaf that text
+// mentions, such as "-v", "--verbose" or "<file>".
+func (p *Pattern) referencedNames(leaf *Pattern, text string) []string {
+	var names []string
+	for _, ref := range reLeafReference.FindAllString(text, -1) {
+		var found *Pattern
+		if strings.HasPrefix(ref, "<") {
+			for _, a := range p.Positionals() {
+				if a.Name == ref {
+					found = a
+				}
+			}
+		} else {
+			found = p.FindOption(ref)
+		}
+		if found != nil && found.Name != leaf.Name {
+			names = append(names, found.Name)
+		}
+	}
+	return names
+}
+
+func sortedNames(set map[string]bool, order map[string]int) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return order[names[i]] < order[names[j]] })
+	return names
+}
+
+// Validate checks values, as returned by Match or given to ToArgv, against
+// the constraints of the pattern and the cardinality of its lists, see
+// ValueModels. Options left empty or at their default don't count as given,
+// see Given. The first rule the values break is returned as a *UsageError.
+func (p *Pattern) Validate(values Opts) error {
+	if err := p.checkLists(values); err != nil {
+		return err
+	}
+	for _, c := range p.Constraints() {
+		if err := c.check(func(name string) bool { return p.Given(values, name) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check returns the error of values breaking the constraint, given telling
+// whether they give a leaf, see Pattern.Given.
+func (c Constraint) check(given func(name string) bool) error {
+	switch c.Kind {
+	case ConstraintRequires:
+		if !given(c.Name) {
+			return nil
+		}
+		for _, name := range c.Names {
+			if !given(name) {
+				return newUserError("%s requires %s", c.Name, name)
+			}
+		}
+	case ConstraintConflicts:
+		var used []string
+		for _, group := range c.Groups {
+			for _, name := range group {
+				if given(name) {
+					used = append(used, name)
+					break
+				}
+			}
+		}
+		if len(used) > 1 {
+			return newUserError("%s can't be used with %s", used[0], used[1])
+		}
+	case ConstraintOneOf:
+		for _, name := range c.Names {
+			if given(name) {
+				return nil
+			}
+		}
+		return newUserError("one of %s is required", strings.Join(c.Names, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package docopt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConstraints(t *testing.T) {
+	pat, err := ParsePattern(`Usage:
+  prog add [-f] <x>
+  prog rm [-r] <x>
+  prog export (--json | --yaml) [(--user <u> --password <p>)]
+
+Options:
+  -f  Force. Cannot be used with --json.
+  -r  Recurse, requires --yaml.
+  --json
+  --yaml
+  --user <u>
+  --password <p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Constraints{
+		{Kind: ConstraintRequires, Name: "add", Names: []string{"<x>"}},
+		{Kind: ConstraintRequires, Name: "-f", Names: []string{"add", "<x>"}},
+		{Kind: ConstraintRequires, Name: "rm", Names: []string{"<x>"}},
+		{Kind: ConstraintRequires, Name: "-r", Names: []string{"<x>", "rm"}},
+		{Kind: ConstraintRequires, Name: "--json", Names: []string{"export"}},
+		{Kind: ConstraintRequires, Name: "--yaml", Names: []string{"export"}},
+		{Kind: ConstraintRequires, Name: "--user", Names: []string{"export", "--password"}},
+		{Kind: ConstraintRequires, Name: "--password", Names: []string{"export", "--user"}},
+		{Kind: ConstraintConflicts, Groups: [][]string{{"add", "-f"}, {"rm", "-r"},
+			{"export", "--json", "--yaml", "--user", "--password"}}},
+		{Kind: ConstraintOneOf, Names: []string{"add", "rm", "export"}},
+		{Kind: ConstraintConflicts, Groups: [][]string{{"--json"}, {"--yaml"}}},
+		{Kind: ConstraintConflicts, Groups: [][]string{{"-f"}, {"--json"}}},
+		{Kind: ConstraintRequires, Name: "-r", Names: []string{"--yaml"}},
+	}
+	if cs := pat.Constraints(); !reflect.DeepEqual(cs, expect) {
+		t.Errorf("result: %v expect: %v", cs, expect)
+	}
+
+	for i, c := range []struct {
+		values Opts
+		err    string
+	}{
+		{Opts{"add": true, "<x>": "1", "-f": true}, ""},
+		{Opts{"add": true, "-f": true}, "add requires <x>"},
+		{Opts{"rm": true, "<x>": "1", "-r": true}, "-r requires --yaml"},
+		{Opts{"add": true, "rm": true, "<x>": "1"}, "add can't be used with rm"},
+		{Opts{"export": true, "--json": true, "--yaml": true}, "--json can't be used with --yaml"},
+		{Opts{"<x>": "1"}, "one of add, rm, export is required"},
+	} {
+		err := pat.Validate(c.values)
+		if c.err == "" && err != nil {
+			t.Errorf("testcase: %d error: %v", i, err)
+		} else if _, ok := err.(*UsageError); c.err != "" && (!ok || err.Error() != c.err) {
+			t.Errorf("testcase: %d error: %v expect: %s", i, err, c.err)
+		}
+	}
+
+	// options left empty or at their default aren't given
+	pat, err = ParsePattern(`Usage: prog [--format=<f>] [--json]
+
+Options:
+  --format=<f>  Output format. Cannot be used with --json. [default: text]
+  --json        Output JSON.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched, err := Match(pat, []string{"--json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range []struct {
+		values Opts
+		err    string
+	}{
+		{matched, ""},
+		{Opts{"--format": "", "--json": true}, ""},
+		{Opts{"--format": "text", "--json": true}, ""},
+		{Opts{"--format": "csv", "--json": false}, ""},
+		{Opts{"--format": "csv", "--json": true}, "--format can't be used with --json"},
+	} {
+		err := pat.Validate(c.values)
+		if c.err == "" && err != nil {
+			t.Errorf("testcase: %d error: %v", i, err)
+		} else if _, ok := err.(*UsageError); c.err != "" && (!ok || err.Error() != c.err) {
+			t.Errorf("testcase: %d error: %v expect: %s", i, err, c.err)
+		}
+	}
+}
@@ -45,5 +45,8 @@ exported fields of that struct, all at once.
 		Force   bool   // Gets the value of --force
 	}
 	opts.Bind(&config)
+
+These entry points are those of github.com/docopt/docopt-go, so programs using
+it switch to this package by changing the import path, as the examples do.
 */
 package docopt
@@ -5,10 +5,12 @@
 package docopt
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"regexp"
 	"strings"
+	"unicode"
 )
 
 type Parser struct {
@@ -52,6 +54,25 @@ var DefaultParser = &Parser{
 	SkipHelpFlags: false,
 }
 
+// Limits bounds the usage patterns the parser accepts, so that adversarial or
+// machine-generated help texts fail with a *ParseError instead of exhausting
+// the stack or memory. Zero means no limit.
+type Limits struct {
+	// MaxDepth is how deep groups in brackets or parentheses may nest,
+	// counting the group every usage line forms.
+	MaxDepth int
+	// MaxTokens is how many tokens the usage section may have, counting the
+	// parentheses around every usage line.
+	MaxTokens int
+}
+
+// DefaultLimits are the limits of ParsePattern, ParsePatternContext and
+// ParseArgs.
+var DefaultLimits = Limits{
+	MaxDepth:  64,
+	MaxTokens: 50000,
+}
+
 // ParseDoc parses os.Args[1:] based on the interface described in doc, using the default parser options.
 func ParseDoc(doc string) (Opts, error) {
 	return ParseArgs(doc, nil, "")
@@ -88,39 +109,134 @@ func Parse(doc string, argv []string, help bool, version string, optionsFirst bo
 	return p.parse(doc, argv, version)
 }
 
+// ParsePattern parses the usage and options sections of a help text into a
+// Pattern. Failures are reported as a *ParseError wrapping the cause.
 func ParsePattern(doc string) (*Pattern, error) {
-	var err error
-	var output string
+	return ParsePatternContext(context.Background(), doc)
+}
+
+// ParsePatternContext is like ParsePattern, but gives up with the context's
+// error as soon as ctx is done.
+func ParsePatternContext(ctx context.Context, doc string) (*Pattern, error) {
+	return ParsePatternLimits(ctx, doc, DefaultLimits)
+}
 
+// ParsePatternLimits is like ParsePatternContext, with limits in place of
+// DefaultLimits.
+func ParsePatternLimits(ctx context.Context, doc string, limits Limits) (*Pattern, error) {
 	usageSections := parseSection("usage:", doc)
 
 	if len(usageSections) == 0 {
-		err = newLanguageError("\"usage:\" (case-insensitive) not found.")
-		return nil, err
+		return nil, &ParseError{"", newLanguageError("\"usage:\" (case-insensitive) not found.")}
 	}
 	if len(usageSections) > 1 {
-		err = newLanguageError("More than one \"usage:\" (case-insensitive).")
-		return nil, err
+		return nil, &ParseError{"", newLanguageError("More than one \"usage:\" (case-insensitive).")}
 	}
 	usage := usageSections[0]
 	options := parseDefaults(doc)
 	formal, err := formalUsage(usage)
 	if err != nil {
-		output = handleError(err, usage)
-		fmt.Printf(output)
+		return nil, &ParseError{usage, err}
+	}
+
+	if err = ctx.Err(); err != nil {
 		return nil, err
 	}
+	pat, err := parsePattern(ctx, formal, &options, limits)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, &ParseError{usage, err}
+	}
+	if err = pat.expandOptionsShortcuts(doc); err != nil {
+		return nil, &ParseError{usage, err}
+	}
+	if err = pat.describeLeaves(doc); err != nil {
+		return nil, &ParseError{usage, err}
+	}
+	pat.locate(doc)
+	return pat, nil
+}
+
+// Match matches argv against a pattern returned by ParsePattern and returns
+// the values of all of its arguments, commands and options, the same way
+// ParseArgs does for a doc string. The pattern itself is left untouched. Long
+// options may be abbreviated to any unambiguous prefix, as with GNU getopt, and
+// everything after a "--" is taken as an argument. If argv doesn't fit the
+// pattern a *UsageError describing the problem is returned.
+func Match(pat *Pattern, argv []string) (Opts, error) {
+	return MatchContext(context.Background(), pat, argv)
+}
 
-	pat, err := parsePattern(formal, &options)
+// MatchContext is like Match, but gives up with the context's error as soon
+// as ctx is done.
+func MatchContext(ctx context.Context, pat *Pattern, argv []string) (Opts, error) {
+	pat = pat.clone()
+	options := PatternList{}
+	for _, o := range pat.Options() {
+		options = append(options, o.clone())
+	}
+	// long options may be abbreviated to any unambiguous prefix, parseArgv
+	// resolves them and adds the options it doesn't know to the list
+	known := len(options)
+	patternArgv, err := parseArgv(newTokenList(argv, errorUser), &options, false)
 	if err != nil {
-		output = handleError(err, usage)
-		fmt.Printf(output)
 		return nil, err
 	}
-	return pat, nil
+	if len(options) > known {
+		return nil, newUserError("unknown option: %s", options[known].Name)
+	}
+	if pat.FindCommand("--") == nil {
+		// "--" only ends the options, unless the usage mentions it
+		for i, a := range patternArgv {
+			if a.T == patternArgument && a.Value == "--" {
+				patternArgv = append(patternArgv[:i], patternArgv[i+1:]...)
+				break
+			}
+		}
+	}
+	if err = pat.fix(ctx); err != nil {
+		return nil, err
+	}
+	matched, left, collected := pat.match(&patternArgv, nil)
+	if !matched {
+		return nil, newUserError("command line doesn't match the usage")
+	}
+	if len(*left) > 0 {
+		unexpected := make([]string, len(*left))
+		for i, p := range *left {
+			unexpected[i] = p.argvString()
+		}
+		return nil, newUserError("unexpected arguments: %s", strings.Join(unexpected, " "))
+	}
+	patFlat, err := pat.Flat(patternDefault)
+	if err != nil {
+		return nil, err
+	}
+	return append(patFlat, *collected...).dictionary(), nil
 }
 
-func (p *Parser) parse(doc string, argv []string, version string) (map[string]interface{}, error) {
+// expandOptionsShortcuts fills every [options] shortcut with the options of
+// the doc's options sections that aren't already mentioned in the usage.
+func (p *Pattern) expandOptionsShortcuts(doc string) error {
+	patFlat, err := p.Flat(patternOption)
+	if err != nil {
+		return err
+	}
+	patternOptions := patFlat.unique()
+	patFlat, err = p.Flat(patternOptionSSHORTCUT)
+	if err != nil {
+		return err
+	}
+	for _, optionsShortcut := range patFlat {
+		docOptions := parseDefaults(doc)
+		optionsShortcut.Children = docOptions.unique().diff(patternOptions)
+	}
+	return nil
+}
+
+func (p *Parser) parse(doc string, argv []string, version string) (Opts, error) {
 	if argv == nil {
 		argv = os.Args[1:]
 	}
@@ -141,7 +257,7 @@ func (p *Parser) parse(doc string, argv []string, version string) (map[string]in
 // -----------------------------------------------------------------------------
 
 // parse and return a map of args, output and all errors
-func parse(doc string, argv []string, help bool, version string, optionsFirst bool) (args map[string]interface{}, output string, err error) {
+func parse(doc string, argv []string, help bool, version string, optionsFirst bool) (args Opts, output string, err error) {
 	if argv == nil && len(os.Args) > 1 {
 		argv = os.Args[1:]
 	}
@@ -165,7 +281,7 @@ func parse(doc string, argv []string, help bool, version string, optionsFirst bo
 		return
 	}
 
-	pat, err := parsePattern(formal, &options)
+	pat, err := parsePattern(context.Background(), formal, &options, DefaultLimits)
 	if err != nil {
 		output = handleError(err, usage)
 		return
@@ -176,34 +292,24 @@ func parse(doc string, argv []string, help bool, version string, optionsFirst bo
 		output = handleError(err, usage)
 		return
 	}
-	patFlat, err := pat.Flat(patternOption)
-	if err != nil {
-		output = handleError(err, usage)
-		return
-	}
-	patternOptions := patFlat.unique()
-
-	patFlat, err = pat.Flat(patternOptionSSHORTCUT)
+	err = pat.expandOptionsShortcuts(doc)
 	if err != nil {
 		output = handleError(err, usage)
 		return
 	}
-	for _, optionsShortcut := range patFlat {
-		docOptions := parseDefaults(doc)
-		optionsShortcut.Children = docOptions.unique().diff(patternOptions)
-	}
 
 	if output = extras(help, version, patternArgv, doc); len(output) > 0 {
 		return
 	}
 
-	err = pat.fix()
+	err = pat.fix(context.Background())
 	if err != nil {
 		output = handleError(err, usage)
 		return
 	}
 	matched, left, collected := pat.match(&patternArgv, nil)
 	if matched && len(*left) == 0 {
+		var patFlat PatternList
 		patFlat, err = pat.Flat(patternDefault)
 		if err != nil {
 			output = handleError(err, usage)
@@ -242,21 +348,28 @@ func parseDefaults(doc string) PatternList {
 	p := regexp.MustCompile(`\n[ \t]*(-\S+?)`)
 	for _, s := range parseSection("options:", doc) {
 		// FIXME corner case "bla: options: --foo"
-		_, _, s = stringPartition(s, ":") // get rid of "options:"
+		heading, _, s := stringPartition(s, ":") // get rid of "options:"
 		split := p.Split("\n"+s, -1)[1:]
 		match := p.FindAllStringSubmatch("\n"+s, -1)
 		for i := range split {
 			optionDescription := match[i][1] + split[i]
 			if strings.HasPrefix(optionDescription, "-") {
-				defaults = append(defaults, parseOption(optionDescription))
+				opt := parseOption(optionDescription)
+				opt.Section = strings.TrimSpace(heading)
+				defaults = append(defaults, opt)
 			}
 		}
 	}
 	return defaults
 }
 
-func parsePattern(source string, options *PatternList) (*Pattern, error) {
+func parsePattern(ctx context.Context, source string, options *PatternList, limits Limits) (*Pattern, error) {
 	tokens := tokenListFromPattern(source)
+	tokens.ctx = ctx
+	tokens.limits = limits
+	if limits.MaxTokens > 0 && tokens.length() > limits.MaxTokens {
+		return nil, tokens.errorFunc("usage too long: more than %d tokens", limits.MaxTokens)
+	}
 	result, err := parseExpr(tokens, options)
 	if err != nil {
 		return nil, err
@@ -289,7 +402,8 @@ func parseArgv(tokens *tokenList, options *PatternList, optionsFirst bool) (Patt
 				return nil, err
 			}
 			parsed = append(parsed, pl...)
-		} else if tokens.current().hasPrefix("-") && !tokens.current().eq("-") {
+		} else if tokens.current().hasPrefix("-") && !tokens.current().eq("-") &&
+			!isNegativeNumber(tokens.current().String(), *options) {
 			ps, err := parseShorts(tokens, options)
 			if err != nil {
 				return nil, err
@@ -307,14 +421,40 @@ func parseArgv(tokens *tokenList, options *PatternList, optionsFirst bool) (Patt
 	return parsed, nil
 }
 
+var reNegativeNumber = regexp.MustCompile(`^-(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// isNegativeNumber reports whether an argv token like "-5" or "-0.3" is a
+// negative number rather than short options. It is, unless one of the options
+// is itself a digit, like "-1".
+func isNegativeNumber(s string, options PatternList) bool {
+	if !reNegativeNumber.MatchString(s) {
+		return false
+	}
+	return !hasDigitOption(options)
+}
+
+func hasDigitOption(options PatternList) bool {
+	for _, o := range options {
+		if len(o.Short) == 2 && unicode.IsDigit(rune(o.Short[1])) {
+			return true
+		}
+	}
+	return false
+}
+
 func parseOption(optionDescription string) *Pattern {
 	optionDescription = strings.TrimSpace(optionDescription)
 	options, _, description := stringPartition(optionDescription, "  ")
+	// keep the commas of a "{a,b}" choices metavar
+	options = reBraces.ReplaceAllStringFunc(options, func(s string) string {
+		return strings.Replace(s, ",", "\x00", -1)
+	})
 	options = strings.Replace(options, ",", " ", -1)
 	options = strings.Replace(options, "=", " ", -1)
 
 	short := ""
 	long := ""
+	metavar := ""
 	argcount := 0
 	var value interface{}
 	value = false
@@ -327,6 +467,9 @@ func parseOption(optionDescription string) *Pattern {
 			short = s
 		} else {
 			argcount = 1
+			if metavar == "" {
+				metavar = strings.Replace(s, "\x00", ",", -1)
+			}
 		}
 		if argcount > 0 {
 			matched := reDefault.FindAllStringSubmatch(description, -1)
@@ -337,7 +480,107 @@ func parseOption(optionDescription string) *Pattern {
 			}
 		}
 	}
-	return newOption(short, long, argcount, value)
+	opt := newOption(short, long, argcount, value)
+	opt.Metavar = metavar
+	if s, ok := value.(string); ok {
+		opt.Default = s
+	}
+	parseMetadata(opt, description)
+	return opt
+}
+
+var (
+	reDefaultMarker  = regexp.MustCompile(`(?i)\[default: `)
+	reBraces         = regexp.MustCompile(`\{[^{}]*\}`)
+	reChoicesMetavar = regexp.MustCompile(`^[<{(]?\{([^{}]+)\}[>)]?$`)
+	reChoices        = regexp.MustCompile(`(?i)[\[(](?:choices|one of):\s*([^\])]*)[\])]`)
+	reEnvVar         = regexp.MustCompile(`(?i)[\[(](?:env|environment)(?: var(?:iable)?)?:?\s*\$?([A-Za-z_][A-Za-z0-9_]*)[\])]`)
+	reDeprecated     = regexp.MustCompile(`(?i)\bdeprecated\b`)
+	reStdin          = regexp.MustCompile(`(?i)\bstdin\b|\bstandard input\b`)
+	reDash           = regexp.MustCompile("(?:^|[\\s\"'(`])-(?:$|[\\s\"',.;:)`])")
+)
+
+// parseMetadata fills in the description derived metadata of a leaf: the
+// description itself, choices, the environment variable, deprecation and
+// whether it reads stdin, which takes a description mentioning both "-" and
+// stdin, not only reading from somewhere else than stdin.
+func parseMetadata(p *Pattern, description string) {
+	p.Description = strings.Join(strings.Fields(description), " ")
+	if m := reChoicesMetavar.FindStringSubmatch(p.Metavar); m != nil {
+		p.Choices = splitChoices(m[1])
+	} else if m := reChoices.FindStringSubmatch(description); m != nil {
+		p.Choices = splitChoices(m[1])
+	}
+	if m := reEnvVar.FindStringSubmatch(description); m != nil {
+		p.EnvVar = m[1]
+	}
+	p.Deprecated = reDeprecated.MatchString(description)
+	if reStdin.MatchString(description) && reDash.MatchString(description) && !(p.T == patternOption && p.Argcount == 0) {
+		p.Stdin = true
+	}
+}
+
+func splitChoices(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '|' || unicode.IsSpace(r)
+	})
+}
+
+// parseLeafDescriptions collects the entries of sections such as "arguments:"
+// or "commands:", keyed by argument or command name, and their names in the
+// order they're listed. Each entry is a name followed by two or more spaces
+// and its description, which may continue on further indented lines.
+func parseLeafDescriptions(name, doc string) ([]string, map[string]string) {
+	var names []string
+	descriptions := make(map[string]string)
+	p := regexp.MustCompile(`^[ \t]*(\S+)(?:[ \t]{2,}|\t)(.*)$`)
+	for _, s := range parseSection(name, doc) {
+		_, _, s = stringPartition(s, ":")
+		current := ""
+		for _, line := range strings.Split(s, "\n") {
+			if m := p.FindStringSubmatch(line); m != nil && !strings.HasPrefix(m[1], "-") {
+				current = m[1]
+				if _, ok := descriptions[current]; !ok {
+					names = append(names, current)
+				}
+				descriptions[current] = m[2]
+			} else if current != "" && strings.TrimSpace(line) != "" {
+				descriptions[current] += " " + strings.TrimSpace(line)
+			}
+		}
+	}
+	return names, descriptions
+}
+
+// describeLeaves attaches the descriptions found in the "arguments:" and
+// "commands:" sections of doc to the matching leaves of the pattern.
+func (p *Pattern) describeLeaves(doc string) error {
+	_, arguments := parseLeafDescriptions("arguments:", doc)
+	_, commands := parseLeafDescriptions("commands:", doc)
+	leaves, err := p.Flat(patternArgument | patternCommand)
+	if err != nil {
+		return err
+	}
+	reDefault := regexp.MustCompile(`(?i)\[default: (.*)\]`)
+	for _, leaf := range leaves {
+		var description string
+		var ok bool
+		if leaf.T == patternArgument {
+			description, ok = arguments[leaf.Name]
+		} else {
+			description, ok = commands[leaf.Name]
+		}
+		if !ok {
+			continue
+		}
+		if leaf.T == patternArgument {
+			if matched := reDefault.FindStringSubmatch(description); matched != nil {
+				leaf.Default = matched[1]
+			}
+		}
+		parseMetadata(leaf, description)
+	}
+	return nil
 }
 
 func parseExpr(tokens *tokenList, options *PatternList) (PatternList, error) {
@@ -377,6 +620,9 @@ func parseSeq(tokens *tokenList, options *PatternList) (PatternList, error) {
 	// seq ::= ( atom [ '...' ] )* ;
 	result := PatternList{}
 	for !tokens.current().match(true, "]", ")", "|") {
+		if err := tokens.ctxErr(); err != nil {
+			return nil, err
+		}
 		atom, err := parseAtom(tokens, options)
 		if err != nil {
 			return nil, err
@@ -397,7 +643,12 @@ func parseAtom(tokens *tokenList, options *PatternList) (PatternList, error) {
 	if tokens.current().match(false, "(", "[") {
 		tokens.move()
 		var matching string
+		if tokens.limits.MaxDepth > 0 && tokens.depth >= tokens.limits.MaxDepth {
+			return nil, tokens.errorFunc("groups nested too deep: more than %d levels", tokens.limits.MaxDepth)
+		}
+		tokens.depth++
 		pl, err := parseExpr(tokens, options)
+		tokens.depth--
 		if err != nil {
 			return nil, err
 		}
@@ -423,7 +674,10 @@ func parseAtom(tokens *tokenList, options *PatternList) (PatternList, error) {
 	} else if tok.hasPrefix("<") && tok.hasSuffix(">") || tok.isUpper() {
 		return PatternList{newArgument(tokens.move().String(), nil)}, nil
 	}
-	return PatternList{newCommand(tokens.move().String(), false)}, nil
+	// a lone "-" is the stdin operand, matched as a command like any other word
+	cmd := newCommand(tokens.move().String(), false)
+	cmd.Stdin = cmd.Name == "-"
+	return PatternList{cmd}, nil
 }
 
 func parseLong(tokens *tokenList, options *PatternList) (PatternList, error) {
@@ -438,7 +692,7 @@ func parseLong(tokens *tokenList, options *PatternList) (PatternList, error) {
 	}
 
 	if !strings.HasPrefix(long, "--") {
-		return nil, newError("long option '%s' doesn't start with --", long)
+		return nil, tokens.errorFunc("long option '%s' doesn't start with --", long)
 	}
 	similar := PatternList{}
 	for _, o := range *options {
@@ -462,10 +716,16 @@ func parseLong(tokens *tokenList, options *PatternList) (PatternList, error) {
 		return nil, tokens.errorFunc("%s is not a unique prefix: %s?", long, strings.Join(similarLong, ", "))
 	} else if len(similar) < 1 {
 		argcount := 0
+		var metavar string
 		if eq == "=" {
+			// an option only the usage mentions takes an argument only in
+			// the attached form, --opt=<arg>, the word after it being a
+			// positional
 			argcount = 1
+			metavar = v
 		}
 		opt = newOption("", long, argcount, false)
+		opt.Metavar = metavar
 		*options = append(*options, opt)
 		if tokens.err == errorUser {
 			var val interface{}
@@ -478,6 +738,7 @@ func parseLong(tokens *tokenList, options *PatternList) (PatternList, error) {
 		}
 	} else {
 		opt = newOption(similar[0].Short, similar[0].Long, similar[0].Argcount, similar[0].Value)
+		opt.copyMetadata(similar[0])
 		if opt.Argcount == 0 {
 			if value != nil {
 				return nil, tokens.errorFunc("%s must not have an argument", opt.Long)
@@ -509,7 +770,7 @@ func parseShorts(tokens *tokenList, options *PatternList) (PatternList, error) {
 	// shorts ::= '-' ( chars )* [ [ ' ' ] chars ] ;
 	tok := tokens.move()
 	if !tok.hasPrefix("-") || tok.hasPrefix("--") {
-		return nil, newError("short option '%s' doesn't start with -", tok)
+		return nil, tokens.errorFunc("short option '%s' doesn't start with -", tok)
 	}
 	left := strings.TrimLeft(tok.String(), "-")
 	parsed := PatternList{}
@@ -527,12 +788,20 @@ func parseShorts(tokens *tokenList, options *PatternList) (PatternList, error) {
 			return nil, tokens.errorFunc("%s is specified ambiguously %d times", short, len(similar))
 		} else if len(similar) < 1 {
 			opt = newOption(short, "", 0, false)
+			if tokens.err == errorLanguage && strings.HasPrefix(left, "<") && strings.HasSuffix(left, ">") {
+				// an option only the usage mentions takes an argument only
+				// attached, as in -n<num>: -nNUM stacks -n, -N, -U and -M
+				opt = newOption(short, "", 1, false)
+				opt.Metavar = left
+				left = ""
+			}
 			*options = append(*options, opt)
 			if tokens.err == errorUser {
 				opt = newOption(short, "", 0, true)
 			}
 		} else { // why copying is necessary here?
 			opt = newOption(short, similar[0].Long, similar[0].Argcount, similar[0].Value)
+			opt.copyMetadata(similar[0])
 			var value interface{}
 			if opt.Argcount > 0 {
 				if left == "" {
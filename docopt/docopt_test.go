@@ -8,13 +8,13 @@ Copyright (c) 2013 Keith Batten, kbatten@gmail.com
 package docopt
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"reflect"
-	"regexp"
 	"strings"
 	"testing"
 )
@@ -34,6 +34,22 @@ func TestPatternFlat(t *testing.T) {
 		t.Error(err)
 	}
 
+	q = PatternList{newArgument("N", nil), newArgument("M", nil)}
+	p = newRequired(
+		newOneOrMore(newArgument("N", nil)),
+		newOption("-a", "", 0, false),
+		newArgument("M", nil)).FlatFunc(func(p *Pattern) bool { return p.T == patternArgument })
+	if reflect.DeepEqual(p, q) != true {
+		t.Errorf("FlatFunc: %v", p)
+	}
+
+	either := newEither(newOption("-a", "", 0, false), newOneOrMore(newArgument("N", nil)))
+	q = PatternList{either, either.Children[1]}
+	p = newRequired(either).FlatFunc(func(p *Pattern) bool { return p.T&patternBranch != 0 && p.T != patternRequired })
+	if reflect.DeepEqual(p, q) != true {
+		t.Errorf("FlatFunc: %v", p)
+	}
+
 	q = PatternList{newOptionsShortcut()}
 	p, err = newRequired(
 		newOptional(newOptionsShortcut()),
@@ -44,68 +60,219 @@ func TestPatternFlat(t *testing.T) {
 	return
 }
 
+func TestPatternQuery(t *testing.T) {
+	pat, err := ParsePattern(`Usage: prog ship new <name>...
+       prog ship <name> move <x> <y> [--speed=<kn>]
+       prog -h | --help
+
+Options:
+  -h --help     Show this screen.
+  --speed=<kn>  Speed in knots [default: 10].`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(pl PatternList) []string {
+		result := []string{}
+		for _, p := range pl {
+			result = append(result, p.Name)
+		}
+		return result
+	}
+	if v := names(pat.Commands()); !reflect.DeepEqual(v, []string{"ship", "new", "move"}) {
+		t.Errorf("commands: %v", v)
+	}
+	if v := names(pat.Positionals()); !reflect.DeepEqual(v, []string{"<name>", "<x>", "<y>"}) {
+		t.Errorf("positionals: %v", v)
+	}
+	if v := names(pat.Options()); !reflect.DeepEqual(v, []string{"--speed", "--help"}) {
+		t.Errorf("options: %v", v)
+	}
+	if o := pat.FindOption("-h"); o == nil || o.Long != "--help" {
+		t.Errorf("FindOption(-h): %v", o)
+	}
+	if o := pat.FindOption("--speed"); o == nil || o.Default != "10" {
+		t.Errorf("FindOption(--speed): %v", o)
+	}
+	if pat.FindOption("--nope") != nil || pat.FindCommand("sink") != nil {
+		t.Fail()
+	}
+	if c := pat.FindCommand("move"); c == nil || c.T != patternCommand {
+		t.Errorf("FindCommand(move): %v", c)
+	}
+}
+
 func TestOption(t *testing.T) {
-	if !parseOption("-h").eq(newOption("-h", "", 0, false)) {
+	if !parseOption("-h").Equal(newOption("-h", "", 0, false)) {
 		t.Fail()
 	}
-	if !parseOption("--help").eq(newOption("", "--help", 0, false)) {
+	if !parseOption("--help").Equal(newOption("", "--help", 0, false)) {
 		t.Fail()
 	}
-	if !parseOption("-h --help").eq(newOption("-h", "--help", 0, false)) {
+	if !parseOption("-h --help").Equal(newOption("-h", "--help", 0, false)) {
 		t.Fail()
 	}
-	if !parseOption("-h, --help").eq(newOption("-h", "--help", 0, false)) {
+	if !parseOption("-h, --help").Equal(newOption("-h", "--help", 0, false)) {
 		t.Fail()
 	}
 
-	if !parseOption("-h TOPIC").eq(newOption("-h", "", 1, false)) {
+	if !parseOption("-h TOPIC").Equal(withMetadata(newOption("-h", "", 1, false), "TOPIC", "", "")) {
 		t.Fail()
 	}
-	if !parseOption("--help TOPIC").eq(newOption("", "--help", 1, false)) {
+	if !parseOption("--help TOPIC").Equal(withMetadata(newOption("", "--help", 1, false), "TOPIC", "", "")) {
 		t.Fail()
 	}
-	if !parseOption("-h TOPIC --help TOPIC").eq(newOption("-h", "--help", 1, false)) {
+	if !parseOption("-h TOPIC --help TOPIC").Equal(withMetadata(newOption("-h", "--help", 1, false), "TOPIC", "", "")) {
 		t.Fail()
 	}
-	if !parseOption("-h TOPIC, --help TOPIC").eq(newOption("-h", "--help", 1, false)) {
+	if !parseOption("-h TOPIC, --help TOPIC").Equal(withMetadata(newOption("-h", "--help", 1, false), "TOPIC", "", "")) {
 		t.Fail()
 	}
-	if !parseOption("-h TOPIC, --help=TOPIC").eq(newOption("-h", "--help", 1, false)) {
+	if !parseOption("-h TOPIC, --help=TOPIC").Equal(withMetadata(newOption("-h", "--help", 1, false), "TOPIC", "", "")) {
 		t.Fail()
 	}
 
-	if !parseOption("-h  Description...").eq(newOption("-h", "", 0, false)) {
+	if !parseOption("-h  Description...").Equal(withMetadata(newOption("-h", "", 0, false), "", "", "Description...")) {
 		t.Fail()
 	}
-	if !parseOption("-h --help  Description...").eq(newOption("-h", "--help", 0, false)) {
+	if !parseOption("-h --help  Description...").Equal(withMetadata(newOption("-h", "--help", 0, false), "", "", "Description...")) {
 		t.Fail()
 	}
-	if !parseOption("-h TOPIC  Description...").eq(newOption("-h", "", 1, false)) {
+	if !parseOption("-h TOPIC  Description...").Equal(withMetadata(newOption("-h", "", 1, false), "TOPIC", "", "Description...")) {
 		t.Fail()
 	}
 
-	if !parseOption("    -h").eq(newOption("-h", "", 0, false)) {
+	if !parseOption("    -h").Equal(newOption("-h", "", 0, false)) {
 		t.Fail()
 	}
 
-	if !parseOption("-h TOPIC  Description... [default: 2]").eq(newOption("-h", "", 1, "2")) {
+	if !parseOption("-h TOPIC  Description... [default: 2]").Equal(withMetadata(newOption("-h", "", 1, "2"), "TOPIC", "2", "Description... [default: 2]")) {
 		t.Fail()
 	}
-	if !parseOption("-h TOPIC  Descripton... [default: topic-1]").eq(newOption("-h", "", 1, "topic-1")) {
+	if !parseOption("-h TOPIC  Descripton... [default: topic-1]").Equal(withMetadata(newOption("-h", "", 1, "topic-1"), "TOPIC", "topic-1", "Descripton... [default: topic-1]")) {
 		t.Fail()
 	}
-	if !parseOption("--help=TOPIC  ... [default: 3.14]").eq(newOption("", "--help", 1, "3.14")) {
+	if !parseOption("--help=TOPIC  ... [default: 3.14]").Equal(withMetadata(newOption("", "--help", 1, "3.14"), "TOPIC", "3.14", "... [default: 3.14]")) {
 		t.Fail()
 	}
-	if !parseOption("-h, --help=DIR  ... [default: ./]").eq(newOption("-h", "--help", 1, "./")) {
+	if !parseOption("-h, --help=DIR  ... [default: ./]").Equal(withMetadata(newOption("-h", "--help", 1, "./"), "DIR", "./", "... [default: ./]")) {
 		t.Fail()
 	}
-	if !parseOption("-h TOPIC  Descripton... [dEfAuLt: 2]").eq(newOption("-h", "", 1, "2")) {
+	if !parseOption("-h TOPIC  Descripton... [dEfAuLt: 2]").Equal(withMetadata(newOption("-h", "", 1, "2"), "TOPIC", "2", "Descripton... [dEfAuLt: 2]")) {
 		t.Fail()
 	}
 	return
 }
 
+func TestOptionMetadata(t *testing.T) {
+	o := parseOption("-f, --format={json,yaml}  Output format [env: FMT].")
+	if o.Metavar != "{json,yaml}" || o.EnvVar != "FMT" || o.Deprecated ||
+		!reflect.DeepEqual(o.Choices, []string{"json", "yaml"}) {
+		t.Errorf("unexpected metadata: %#v", o)
+	}
+	o = parseOption("--level=<n>  Level (choices: 1, 2, 3). Deprecated, use --verbosity.")
+	if o.Metavar != "<n>" || !o.Deprecated || !reflect.DeepEqual(o.Choices, []string{"1", "2", "3"}) {
+		t.Errorf("unexpected metadata: %#v", o)
+	}
+
+	pat, err := ParsePattern(`Usage: prog [options] <file> (start | stop)
+
+Arguments:
+  <file>  The input file
+          to read [default: in.txt].
+
+Commands:
+  start   Start it.
+  stop    Stop it.
+
+Advanced Options:
+  -q  Quiet mode.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaves, _ := pat.Flat(patternArgument | patternCommand)
+	descriptions := map[string]string{}
+	for _, l := range leaves {
+		descriptions[l.Name] = l.Description
+		if l.Name == "<file>" && l.Default != "in.txt" {
+			t.Errorf("unexpected default: %q", l.Default)
+		}
+	}
+	expect := map[string]string{
+		"<file>": "The input file to read [default: in.txt].",
+		"start":  "Start it.",
+		"stop":   "Stop it.",
+	}
+	if !reflect.DeepEqual(descriptions, expect) {
+		t.Errorf("result: %v expect: %v", descriptions, expect)
+	}
+	if o := parseDefaults("Advanced Options:\n  -q  Quiet mode.")[0]; o.Section != "Advanced Options" {
+		t.Errorf("unexpected section: %q", o.Section)
+	}
+}
+
+func TestPatternEqual(t *testing.T) {
+	p := newRequired(newOption("-a", "", 0, false), newOneOrMore(newArgument("N", nil)))
+	q := newRequired(newOption("-a", "", 0, false), newOneOrMore(newArgument("N", nil)))
+	if !p.Equal(q) || !p.EqualIgnoreValues(q) {
+		t.Fail()
+	}
+	q.Children[0].Value = true
+	if p.Equal(q) || !p.EqualIgnoreValues(q) {
+		t.Fail()
+	}
+	q.Children[1].Children[0].Name = "M"
+	if p.EqualIgnoreValues(q) {
+		t.Fail()
+	}
+	if newArgument("N", []string{}).Equal(newArgument("N", nil)) {
+		t.Fail()
+	}
+	if !newArgument("N", []string{"a"}).Equal(newArgument("N", []string{"a"})) {
+		t.Fail()
+	}
+	o := parseOption("--fmt={a,b}  Format.")
+	if o.Equal(parseOption("--fmt={a,c}  Format.")) || !o.Equal(parseOption("--fmt={a,b}  Format.")) {
+		t.Fail()
+	}
+}
+
+func TestPatternHash(t *testing.T) {
+	a, _ := ParsePattern("Usage: prog (--left | --right) FILE\n\nOptions:\n  --left  Left.")
+	b, _ := ParsePattern("Usage: prog (--right | --left) FILE\n\nOptions:\n  --left  Left.")
+	c, _ := ParsePattern("Usage: prog (--left | --right) FILE\n\nOptions:\n  --left  Left side.")
+	d, _ := ParsePattern("Usage: prog --left --right FILE\n\nOptions:\n  --left  Left.")
+	if len(a.Hash()) != 64 || a.Hash() != a.Hash() {
+		t.Fatalf("unstable hash: %s", a.Hash())
+	}
+	if a.Hash() != b.Hash() {
+		t.Error("either order changed the hash")
+	}
+	if a.Hash() == c.Hash() || a.Hash() == d.Hash() {
+		t.Error("different patterns hash the same")
+	}
+	opt := a.FindOption("--left")
+	opt.Value = true
+	if a.Hash() != b.Hash() {
+		t.Error("value changed the hash")
+	}
+
+	plain := a.Hash()
+	sub, _ := ParsePattern("Usage: prog add <file>")
+	a.AttachSubcommand("add", sub)
+	attached := a.Hash()
+	sub.FindCommand("add").Description = "Add a file."
+	if attached == plain || a.Hash() == attached {
+		t.Error("subcommands didn't change the hash")
+	}
+	other, _ := ParsePattern("Usage: prog rm <file>")
+	a.AttachSubcommand("rm", other)
+	b.AttachSubcommand("rm", other.clone())
+	b.AttachSubcommand("add", sub.clone())
+	if a.Hash() != b.Hash() {
+		t.Error("the order subcommands were attached in changed the hash")
+	}
+}
+
 func TestOptionName(t *testing.T) {
 	if newOption("-h", "", 0, false).Name != "-h" {
 		t.Fail()
@@ -145,6 +312,36 @@ func TestCommands(t *testing.T) {
 	return
 }
 
+func TestTokenize(t *testing.T) {
+	tokens, err := Tokenize("Usage: prog [-v] (--out=<file name> | FILE)... [options]\n       prog --")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Tokens{
+		{"(", TokenOpen}, {"[", TokenOpen}, {"-v", TokenShortOptions}, {"]", TokenClose},
+		{"(", TokenOpen}, {"--out=<file name>", TokenLongOption}, {"|", TokenPipe},
+		{"FILE", TokenArgument}, {")", TokenClose}, {"...", TokenEllipsis},
+		{"[", TokenOpen}, {"options", TokenOptionsShortcut}, {"]", TokenClose},
+		{")", TokenClose}, {"|", TokenPipe}, {"(", TokenOpen}, {"--", TokenDoubleDash},
+		{")", TokenClose},
+	}
+	if !reflect.DeepEqual(tokens, expect) {
+		t.Errorf("result: %v expect: %v", tokens, expect)
+	}
+	if _, err := Tokenize("usage:"); err == nil {
+		t.Error("error expected")
+	}
+
+	argv := TokenizeArgv([]string{"-vq", "-", "-5", "-0.3", "--out", "x", "--", "-n"})
+	expect = Tokens{
+		{"-vq", TokenShortOptions}, {"-", TokenArgument}, {"-5", TokenArgument}, {"-0.3", TokenArgument},
+		{"--out", TokenLongOption}, {"x", TokenArgument}, {"--", TokenDoubleDash}, {"-n", TokenArgument},
+	}
+	if !reflect.DeepEqual(argv, expect) {
+		t.Errorf("result: %v expect: %v", argv, expect)
+	}
+}
+
 func TestFormalUsage(t *testing.T) {
 	doc := `
     Usage: prog [-hv] ARG
@@ -242,31 +439,31 @@ func TestParsePattern(t *testing.T) {
 		newOption("-f", "--file", 1, false),
 	}
 
-	p, err := parsePattern("[ -h ]", &o)
+	p, err := parsePattern(context.Background(), "[ -h ]", &o, Limits{})
 	q := newRequired(newOptional(newOption("-h", "", 0, false)))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("[ ARG ... ]", &o)
+	p, err = parsePattern(context.Background(), "[ ARG ... ]", &o, Limits{})
 	q = newRequired(newOptional(
 		newOneOrMore(
 			newArgument("ARG", nil))))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("[ -h | -v ]", &o)
+	p, err = parsePattern(context.Background(), "[ -h | -v ]", &o, Limits{})
 	q = newRequired(
 		newOptional(
 			newEither(
 				newOption("-h", "", 0, false),
 				newOption("-v", "--verbose", 0, false))))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("( -h | -v [ --file <f> ] )", &o)
+	p, err = parsePattern(context.Background(), "( -h | -v [ --file <f> ] )", &o, Limits{})
 	q = newRequired(
 		newRequired(
 			newEither(
@@ -275,11 +472,11 @@ func TestParsePattern(t *testing.T) {
 					newOption("-v", "--verbose", 0, false),
 					newOptional(
 						newOption("-f", "--file", 1, nil))))))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("(-h|-v[--file=<f>]N...)", &o)
+	p, err = parsePattern(context.Background(), "(-h|-v[--file=<f>]N...)", &o, Limits{})
 	q = newRequired(
 		newRequired(
 			newEither(
@@ -290,11 +487,11 @@ func TestParsePattern(t *testing.T) {
 						newOption("-f", "--file", 1, nil)),
 					newOneOrMore(
 						newArgument("N", nil))))))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("(N [M | (K | L)] | O P)", &o)
+	p, err = parsePattern(context.Background(), "(N [M | (K | L)] | O P)", &o, Limits{})
 	q = newRequired(
 		newRequired(
 			newEither(
@@ -310,61 +507,61 @@ func TestParsePattern(t *testing.T) {
 				newRequired(
 					newArgument("O", nil),
 					newArgument("P", nil)))))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("[ -h ] [N]", &o)
+	p, err = parsePattern(context.Background(), "[ -h ] [N]", &o, Limits{})
 	q = newRequired(
 		newOptional(
 			newOption("-h", "", 0, false)),
 		newOptional(
 			newArgument("N", nil)))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("[options]", &o)
+	p, err = parsePattern(context.Background(), "[options]", &o, Limits{})
 	q = newRequired(
 		newOptional(
 			newOptionsShortcut()))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("[options] A", &o)
+	p, err = parsePattern(context.Background(), "[options] A", &o, Limits{})
 	q = newRequired(
 		newOptional(
 			newOptionsShortcut()),
 		newArgument("A", nil))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("-v [options]", &o)
+	p, err = parsePattern(context.Background(), "-v [options]", &o, Limits{})
 	q = newRequired(
 		newOption("-v", "--verbose", 0, false),
 		newOptional(
 			newOptionsShortcut()))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("ADD", &o)
+	p, err = parsePattern(context.Background(), "ADD", &o, Limits{})
 	q = newRequired(newArgument("ADD", nil))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("<add>", &o)
+	p, err = parsePattern(context.Background(), "<add>", &o, Limits{})
 	q = newRequired(newArgument("<add>", nil))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 
-	p, err = parsePattern("add", &o)
+	p, err = parsePattern(context.Background(), "add", &o, Limits{})
 	q = newRequired(newCommand("add", false))
-	if p.eq(q) != true {
+	if p.Equal(q) != true {
 		t.Error(err)
 	}
 }
@@ -812,7 +1009,7 @@ func TestListArgumentMatch(t *testing.T) {
 	p := newRequired(
 		newArgument("N", nil),
 		newArgument("N", nil))
-	p.fix()
+	p.fix(context.Background())
 	v, w, x := p.match(&PatternList{newArgument("", "1"),
 		newArgument("", "2")}, nil)
 	y := PatternList{newArgument("N", []string{"1", "2"})}
@@ -823,7 +1020,7 @@ func TestListArgumentMatch(t *testing.T) {
 	}
 
 	p = newOneOrMore(newArgument("N", nil))
-	p.fix()
+	p.fix(context.Background())
 	v, w, x = p.match(&PatternList{newArgument("", "1"),
 		newArgument("", "2"), newArgument("", "3")}, nil)
 	y = PatternList{newArgument("N", []string{"1", "2", "3"})}
@@ -835,7 +1032,7 @@ func TestListArgumentMatch(t *testing.T) {
 
 	p = newRequired(newArgument("N", nil),
 		newOneOrMore(newArgument("N", nil)))
-	p.fix()
+	p.fix(context.Background())
 	v, w, x = p.match(&PatternList{
 		newArgument("", "1"),
 		newArgument("", "2"),
@@ -849,7 +1046,7 @@ func TestListArgumentMatch(t *testing.T) {
 
 	p = newRequired(newArgument("N", nil),
 		newRequired(newArgument("N", nil)))
-	p.fix()
+	p.fix(context.Background())
 	v, w, x = p.match(&PatternList{
 		newArgument("", "1"),
 		newArgument("", "2")}, nil)
@@ -908,80 +1105,16 @@ func TestBasicPatternMatching(t *testing.T) {
 	}
 }
 
-func TestPatternEither(t *testing.T) {
-	p := newOption("-a", "", 0, false).transform()
-	q := newEither(newRequired(
-		newOption("-a", "", 0, false)))
-	if p.eq(q) != true {
-		t.Fail()
-	}
-
-	p = newArgument("A", nil).transform()
-	q = newEither(newRequired(
-		newArgument("A", nil)))
-	if p.eq(q) != true {
-		t.Fail()
-	}
-
-	p = newRequired(
-		newEither(
-			newOption("-a", "", 0, false),
-			newOption("-b", "", 0, false)),
-		newOption("-c", "", 0, false)).transform()
-	q = newEither(
-		newRequired(
-			newOption("-a", "", 0, false),
-			newOption("-c", "", 0, false)),
-		newRequired(
-			newOption("-b", "", 0, false),
-			newOption("-c", "", 0, false)))
-	if p.eq(q) != true {
-		t.Fail()
-	}
-
-	p = newOptional(newOption("-a", "", 0, false),
-		newEither(newOption("-b", "", 0, false),
-			newOption("-c", "", 0, false))).transform()
-	q = newEither(
-		newRequired(
-			newOption("-b", "", 0, false), newOption("-a", "", 0, false)),
-		newRequired(
-			newOption("-c", "", 0, false), newOption("-a", "", 0, false)))
-	if p.eq(q) != true {
-		t.Fail()
-	}
-
-	p = newEither(newOption("-x", "", 0, false),
-		newEither(newOption("-y", "", 0, false),
-			newOption("-z", "", 0, false))).transform()
-	q = newEither(
-		newRequired(newOption("-x", "", 0, false)),
-		newRequired(newOption("-y", "", 0, false)),
-		newRequired(newOption("-z", "", 0, false)))
-	if p.eq(q) != true {
-		t.Fail()
-	}
-
-	p = newOneOrMore(newArgument("N", nil),
-		newArgument("M", nil)).transform()
-	q = newEither(
-		newRequired(newArgument("N", nil), newArgument("M", nil),
-			newArgument("N", nil), newArgument("M", nil)))
-	if p.eq(q) != true {
-		t.Fail()
-	}
-}
-
 func TestPatternFixRepeatingArguments(t *testing.T) {
 	p := newOption("-a", "", 0, false)
-	p.fixRepeatingArguments()
-	if p.eq(newOption("-a", "", 0, false)) != true {
+	p.fixRepeatingArguments(context.Background())
+	if p.Equal(newOption("-a", "", 0, false)) != true {
 		t.Fail()
 	}
 
 	p = newArgument("N", nil)
-	p.fixRepeatingArguments()
-	if p.eq(newArgument("N", nil)) != true {
+	p.fixRepeatingArguments(context.Background())
+	if p.Equal(newArgument("N", nil)) != true {
 		t.Fail()
 	}
 
@@ -991,8 +1124,8 @@ func TestPatternFixRepeatingArguments(t *testing.T) {
 	q := newRequired(
 		newArgument("N", []string{}),
 		newArgument("N", []string{}))
-	p.fixRepeatingArguments()
-	if p.eq(q) != true {
+	p.fixRepeatingArguments(context.Background())
+	if p.Equal(q) != true {
 		t.Fail()
 	}
 
@@ -1002,8 +1135,8 @@ func TestPatternFixRepeatingArguments(t *testing.T) {
 	q = newEither(
 		newArgument("N", []string{}),
 		newOneOrMore(newArgument("N", []string{})))
-	p.fix()
-	if p.eq(q) != true {
+	p.fix(context.Background())
+	if p.Equal(q) != true {
 		t.Fail()
 	}
 }
@@ -1028,7 +1161,7 @@ func TestPatternFixIdentities1(t *testing.T) {
 	if len(p.Children) < 2 {
 		t.FailNow()
 	}
-	if p.Children[0].eq(p.Children[1]) != true {
+	if p.Children[0].Equal(p.Children[1]) != true {
 		t.Fail()
 	}
 	if p.Children[0] == p.Children[1] {
@@ -1052,7 +1185,7 @@ func TestPatternFixIdentities2(t *testing.T) {
 	if len(p.Children[0].Children) < 2 {
 		t.FailNow()
 	}
-	if p.Children[0].Children[1].eq(p.Children[1]) != true {
+	if p.Children[0].Children[1].Equal(p.Children[1]) != true {
 		t.Fail()
 	}
 	if p.Children[0].Children[1] == p.Children[1] {
@@ -1188,6 +1321,88 @@ func TestLanguageErrors(t *testing.T) {
 	}
 }
 
+func TestParseLimits(t *testing.T) {
+	deep := "Usage: prog " + strings.Repeat("[", 100000) + "a" + strings.Repeat("]", 100000)
+	_, err := ParsePattern(deep)
+	var parseErr *ParseError
+	var languageErr *LanguageError
+	if !errors.As(err, &parseErr) || !errors.As(err, &languageErr) {
+		t.Errorf("result: %#v", err)
+	}
+
+	nested := "Usage: prog " + strings.Repeat("(", 10) + "a" + strings.Repeat(")", 10)
+	for i, c := range []struct {
+		limits Limits
+		ok     bool
+	}{
+		{Limits{}, true},
+		{Limits{MaxDepth: 11}, true},
+		{Limits{MaxDepth: 10}, false},
+		{Limits{MaxTokens: 23}, true},
+		{Limits{MaxTokens: 22}, false},
+	} {
+		_, err := ParsePatternLimits(context.Background(), nested, c.limits)
+		if (err == nil) != c.ok || err != nil && !errors.As(err, &parseErr) {
+			t.Errorf("testcase: %d result: %v expect: %v", i, err, c.ok)
+		}
+	}
+
+	_, err = testParser.ParseArgs(deep, []string{}, "")
+	if _, ok := err.(*LanguageError); !ok {
+		t.Errorf("result: %#v", err)
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	_, err := ParsePattern("no usage here")
+	var parseErr *ParseError
+	var languageErr *LanguageError
+	if !errors.As(err, &parseErr) || !errors.As(err, &languageErr) {
+		t.Errorf("expected a ParseError wrapping a LanguageError, got: %#v", err)
+	}
+	_, err = ParsePattern("Usage: prog [-a")
+	if !errors.As(err, &parseErr) || parseErr.Usage != "Usage: prog [-a" || !errors.As(err, &languageErr) {
+		t.Errorf("expected a ParseError wrapping a LanguageError, got: %#v", err)
+	}
+
+	pat, _ := ParsePattern("Usage: prog <x>")
+	_, err = Match(pat, []string{})
+	var usageErr *UsageError
+	if !errors.As(err, &usageErr) || errors.As(err, &parseErr) {
+		t.Errorf("expected a UsageError, got: %#v", err)
+	}
+
+	_, err = pat.Resolve(nil)
+	if !errors.As(err, &usageErr) {
+		t.Errorf("expected a UsageError, got: %#v", err)
+	}
+	_, err = UnmarshalPattern([]byte(`{"schemaVersion": 999}`))
+	if !errors.As(err, &parseErr) || !errors.As(err, &languageErr) {
+		t.Errorf("expected a ParseError wrapping a LanguageError, got: %#v", err)
+	}
+	_, err = UnmarshalPattern([]byte(`{`))
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &parseErr) || !errors.As(err, &syntaxErr) {
+		t.Errorf("expected a ParseError wrapping a json.SyntaxError, got: %#v", err)
+	}
+	var bindErr *BindError
+	var bound struct{ X int }
+	err = Opts{"<x>": "a"}.Bind(&bound)
+	if !errors.As(err, &bindErr) || bindErr.Key != "<x>" || bindErr.Field != "X" {
+		t.Errorf("expected a BindError of <x> and X, got: %#v", err)
+	}
+	err = Opts{"--nope": true}.Bind(&bound)
+	if !errors.As(err, &bindErr) || bindErr.Key != "--nope" || bindErr.Field != "" {
+		t.Errorf("expected a BindError of --nope, got: %#v", err)
+	}
+
+	cause := errors.New("exit status 1")
+	err = &ExecError{Command: "prog", Args: []string{"--help"}, Stderr: "boom\n", Err: cause}
+	if !errors.Is(err, cause) || err.Error() != "executing 'prog --help' failed: exit status 1: boom" {
+		t.Errorf("unexpected ExecError: %v", err)
+	}
+}
+
 func TestIssue40(t *testing.T) {
 	_, output, err := parseOutput("usage: prog --help-commands | --help", []string{"--help"}, true, "", false)
 	if err != nil || len(output) == 0 {
@@ -1392,7 +1607,8 @@ usage: pit stop`
 
 func TestIssue126DefaultsNotParsedCorrectlyWhenTabs(t *testing.T) {
 	section := "Options:\n\t--foo=<arg>  [default: bar]"
-	v := PatternList{newOption("", "--foo", 1, "bar")}
+	v := PatternList{withMetadata(newOption("", "--foo", 1, "bar"), "<arg>", "bar", "[default: bar]")}
+	v[0].Section = "Options"
 	if reflect.DeepEqual(parseDefaults(section), v) != true {
 		t.Fail()
 	}
@@ -1407,86 +1623,48 @@ func TestFileTestcases(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		tests, err := parseTest(raw)
+		tests, err := ParseTestcases(raw)
 		if err != nil {
 			t.Fatal(err)
 		}
 		for _, c := range tests {
-			result, err := testParser.ParseArgs(c.doc, c.argv, "")
-			if _, ok := err.(*UserError); c.userError && !ok {
+			r := c.Run()
+			if c.UserError && !r.Passed {
 				// expected a user-error
-				t.Error("testcase:", c.id, "result:", result)
-			} else if _, ok := err.(*UserError); !c.userError && ok {
-				// unexpected user-error
-				t.Error("testcase:", c.id, "error:", err, "result:", result)
-			} else if reflect.DeepEqual(c.expect, result) != true {
-				t.Error("testcase:", c.id, "result:", result, "expect:", c.expect)
+				t.Error("testcase:", c.ID, "result:", r.Result)
+			} else if !r.Passed {
+				t.Error("testcase:", c.ID, "error:", r.Err, "result:", r.Result, "expect:", c.Expect)
 			}
 		}
 	}
 }
 
-type testcase struct {
-	id        int
-	doc       string
-	prog      string
-	argv      []string
-	expect    Opts
-	userError bool
-}
+func TestTestcaseRunPattern(t *testing.T) {
+	cases, err := ParseTestcases([]byte(`
+r"""Usage: prog [-v] <x>
 
-func parseTest(raw []byte) ([]testcase, error) {
-	var res []testcase
-	commentPattern := regexp.MustCompile("#.*")
-	raw = commentPattern.ReplaceAll(raw, []byte(""))
-	raw = bytes.TrimSpace(raw)
-	if bytes.HasPrefix(raw, []byte(`"""`)) {
-		raw = raw[3:]
-	}
-
-	id := 0
-	for _, fixture := range bytes.Split(raw, []byte(`r"""`)) {
-		doc, _, body := stringPartition(string(fixture), `"""`)
-		for _, cas := range strings.Split(body, "$")[1:] {
-			argvString, _, expectString := stringPartition(strings.TrimSpace(cas), "\n")
-			prog, _, argvString := stringPartition(strings.TrimSpace(argvString), " ")
-			argv := []string{}
-			if len(argvString) > 0 {
-				argv = strings.Fields(argvString)
-			}
-			var expectUntyped interface{}
-			err := json.Unmarshal([]byte(expectString), &expectUntyped)
-			if err != nil {
-				return nil, err
-			}
-			switch expect := expectUntyped.(type) {
-			case string: // user-error
-				res = append(res, testcase{id, doc, prog, argv, nil, true})
-			case map[string]interface{}:
-				// convert []interface{} values to []string
-				// convert float64 values to int
-				for k, vUntyped := range expect {
-					switch v := vUntyped.(type) {
-					case []interface{}:
-						itemList := make([]string, len(v))
-						for i, itemUntyped := range v {
-							if item, ok := itemUntyped.(string); ok {
-								itemList[i] = item
-							}
-						}
-						expect[k] = itemList
-					case float64:
-						expect[k] = int(v)
-					}
-				}
-				res = append(res, testcase{id, doc, prog, argv, expect, false})
-			default:
-				return nil, fmt.Errorf("unhandled json data type")
-			}
-			id++
+"""
+$ prog -v 1
+{"-v": true, "<x>": "1"}
+
+$ prog 1 2
+"user-error"
+
+$ prog 1
+{"-v": true, "<x>": "1"}  # wrong on purpose
+`))
+	if err != nil || len(cases) != 3 {
+		t.Fatalf("result: %v error: %v", cases, err)
+	}
+	pat, err := ParsePattern(cases[0].Doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expect := range []bool{true, true, false} {
+		if r := cases[i].RunPattern(pat); r.Passed != expect {
+			t.Errorf("testcase: %d result: %v error: %v", i, r.Result, r.Err)
 		}
 	}
-	return res, nil
 }
 
 // parseOutput uses a custom parser which also returns the output
@@ -1501,6 +1679,15 @@ func parseOutput(doc string, argv []string, help bool, version string, optionsFi
 	return args, output, err
 }
 
+// withMetadata sets the metadata parseOption derives from an option
+// description on an expected pattern.
+func withMetadata(p *Pattern, metavar, def, description string) *Pattern {
+	p.Metavar = metavar
+	p.Default = def
+	p.Description = description
+	return p
+}
+
 var debugEnabled = false
 
 func debugOn(l ...interface{}) {
@@ -1517,3 +1704,83 @@ func debug(l ...interface{}) {
 		fmt.Println(l...)
 	}
 }
+
+func TestPatternFixWideEither(t *testing.T) {
+	// 2^40 alternatives when expanded
+	usage := "Usage: prog"
+	argv := []string{}
+	for i := 0; i < 40; i++ {
+		usage += fmt.Sprintf(" (--a%d | --b%d)", i, i)
+		argv = append(argv, fmt.Sprintf("--b%d", i))
+	}
+	usage += " <x> [<x>]"
+	pat, err := ParsePattern(usage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := Match(pat, append(argv, "1", "2"))
+	if err != nil || opts["--a0"] != false || opts["--b0"] != true || !reflect.DeepEqual(opts["<x>"], []string{"1", "2"}) {
+		t.Errorf("result: %v error: %v", opts, err)
+	}
+
+	// nested repetitions double the expansion at every level
+	usage = "Usage: prog " + strings.Repeat("(a|b|[c]...|<x>|", 6) + "d" + strings.Repeat(")...", 6)
+	if pat, err = ParsePattern(usage); err != nil {
+		t.Fatal(err)
+	}
+	opts, err = Match(pat, strings.Fields(strings.Repeat("a b c ", 8)))
+	if err != nil || opts["a"] != 8 || opts["c"] != 8 || opts["d"] != 0 {
+		t.Errorf("result: %v error: %v", opts, err)
+	}
+}
+
+func TestUsageOnlyOptions(t *testing.T) {
+	pat, err := ParsePattern("Usage: prog [--out=<file>] [-vn<num>] [--level=LVL] [--dry] <x>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range []struct {
+		name     string
+		argcount int
+		metavar  string
+	}{
+		{"--out", 1, "<file>"},
+		{"-v", 0, ""},
+		{"-n", 1, "<num>"},
+		{"--level", 1, "LVL"},
+		{"--dry", 0, ""},
+	} {
+		o := pat.FindOption(c.name)
+		if o == nil || o.Argcount != c.argcount || o.Metavar != c.metavar {
+			t.Errorf("testcase: %d result: %+v", i, o)
+		}
+	}
+	opts, err := Match(pat, []string{"--out", "a", "-vn", "3", "--dry", "b"})
+	expect := Opts{"--out": "a", "-v": true, "-n": "3", "--level": nil, "--dry": true, "<x>": "b"}
+	if err != nil || !reflect.DeepEqual(opts, expect) {
+		t.Errorf("result: %v error: %v", opts, err)
+	}
+	// separated by a space, the word after an option is a positional
+	for i, c := range []struct {
+		usage    string
+		argv     []string
+		expected Opts
+	}{
+		{"Usage: cp -r <src> <dst>", []string{"-r", "a", "b"},
+			Opts{"-r": true, "<src>": "a", "<dst>": "b"}},
+		{"Usage: prog --verbose <file>", []string{"--verbose", "f"},
+			Opts{"--verbose": true, "<file>": "f"}},
+		{"Usage: prog [-vn NUM]", []string{"-vn", "3"},
+			Opts{"-v": true, "-n": true, "NUM": "3"}},
+		{"Usage: prog [--out FILE]", []string{}, Opts{"--out": false, "FILE": nil}},
+	} {
+		pat, err := ParsePattern(c.usage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts, err := Match(pat, c.argv)
+		if err != nil || !reflect.DeepEqual(opts, c.expected) {
+			t.Errorf("testcase: %d result: %v error: %v expected: %v", i, opts, err, c.expected)
+		}
+	}
+}
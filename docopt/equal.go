@@ -0,0 +1,83 @@
+package docopt
+
+import "reflect"
+
+// Equal reports whether p and other describe the same pattern: the same type,
+// name, option fields, value and metadata, and pairwise equal children.
+func (p *Pattern) Equal(other *Pattern) bool {
+	return p.equal(other, false)
+}
+
+// EqualIgnoreValues is like Equal but doesn't compare the values of the nodes,
+// so a pattern still equals itself after values have been matched into it.
+func (p *Pattern) EqualIgnoreValues(other *Pattern) bool {
+	return p.equal(other, true)
+}
+
+func (p *Pattern) equal(other *Pattern, ignoreValues bool) bool {
+	if p == other {
+		return true
+	}
+	if p == nil || other == nil {
+		return false
+	}
+	if p.T != other.T ||
+		p.Name != other.Name ||
+		p.Short != other.Short ||
+		p.Long != other.Long ||
+		p.Argcount != other.Argcount ||
+		p.Description != other.Description ||
+		p.Metavar != other.Metavar ||
+		p.Default != other.Default ||
+		p.Section != other.Section ||
+		p.EnvVar != other.EnvVar ||
+		p.Deprecated != other.Deprecated ||
+		p.Stdin != other.Stdin ||
+		!stringsEqual(p.Choices, other.Choices) ||
+		len(p.Children) != len(other.Children) {
+		return false
+	}
+	if !ignoreValues && !valueEqual(p.Value, other.Value) {
+		return false
+	}
+	for i, child := range p.Children {
+		if !child.equal(other.Children[i], ignoreValues) {
+			return false
+		}
+	}
+	return true
+}
+
+// valueEqual compares the values a pattern can hold: nil, bool, int, string
+// and []string. Anything else falls back to reflect.DeepEqual.
+func valueEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	case int:
+		b, ok := b.(int)
+		return ok && a == b
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	case []string:
+		b, ok := b.([]string)
+		return ok && (a == nil) == (b == nil) && stringsEqual(a, b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
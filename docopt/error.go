@@ -2,6 +2,7 @@ package docopt
 
 import (
 	"fmt"
+	"strings"
 )
 
 type errorType int
@@ -21,19 +22,22 @@ func (e errorType) String() string {
 	return ""
 }
 
-// UserError records an error with program arguments.
-type UserError struct {
+// UsageError records an error with program arguments, i.e. bad user input.
+type UsageError struct {
 	msg   string
 	Usage string
 }
 
-func (e UserError) Error() string {
+func (e UsageError) Error() string {
 	return e.msg
 }
 func newUserError(msg string, f ...interface{}) error {
-	return &UserError{fmt.Sprintf(msg, f...), ""}
+	return &UsageError{fmt.Sprintf(msg, f...), ""}
 }
 
+// UserError is the original name of UsageError.
+type UserError = UsageError
+
 // LanguageError records an error with the doc string.
 type LanguageError struct {
 	msg string
@@ -46,4 +50,60 @@ func newLanguageError(msg string, f ...interface{}) error {
 	return &LanguageError{fmt.Sprintf(msg, f...)}
 }
 
-var newError = fmt.Errorf
+// ParseError records a help text, or a pattern saved by MarshalPattern, that
+// couldn't be parsed into a Pattern. Err is the underlying error, usually a
+// *LanguageError.
+type ParseError struct {
+	// Usage is the usage section the parser worked on, empty if none was
+	// found.
+	Usage string
+	Err   error
+}
+
+func (e ParseError) Error() string {
+	return "parsing pattern failed: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error, for errors.Is and errors.As.
+func (e ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ExecError records a command that couldn't be run, or didn't print a help
+// text, when probing it for its usage.
+type ExecError struct {
+	Command string
+	Args    []string
+	// Stderr holds what the command printed on stderr, if anything.
+	Stderr string
+	Err    error
+}
+
+func (e ExecError) Error() string {
+	cmd := strings.Join(append([]string{e.Command}, e.Args...), " ")
+	if e.Stderr != "" {
+		return fmt.Sprintf("executing '%s' failed: %s: %s", cmd, e.Err, strings.TrimSpace(e.Stderr))
+	}
+	return fmt.Sprintf("executing '%s' failed: %s", cmd, e.Err)
+}
+
+// Unwrap returns the underlying error, for errors.Is and errors.As.
+func (e ExecError) Unwrap() error {
+	return e.Err
+}
+
+// BindError records matched values that couldn't be bound to a struct by
+// Opts.Bind. Key is the option or argument and Field the field of the struct
+// it was bound to, either empty if the error isn't about one.
+type BindError struct {
+	msg   string
+	Key   string
+	Field string
+}
+
+func (e BindError) Error() string {
+	return e.msg
+}
+func newBindError(key, field string, msg string, f ...interface{}) error {
+	return &BindError{fmt.Sprintf(msg, f...), key, field}
+}
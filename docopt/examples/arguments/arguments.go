@@ -2,7 +2,7 @@ package main
 
 import (
 	"fmt"
-	"github.com/docopt/docopt-go"
+	"gtoc/docopt"
 )
 
 var usage = `Usage: arguments [-vqrh] [FILE] ...
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"github.com/docopt/docopt-go/examples"
+	"gtoc/docopt/examples"
 )
 
 func Example() {
@@ -2,7 +2,7 @@ package main
 
 import (
 	"fmt"
-	"github.com/docopt/docopt-go"
+	"gtoc/docopt"
 	"os"
 	"os/exec"
 )
@@ -0,0 +1,206 @@
+package docopt
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUsageRoundTrip(t *testing.T) {
+	for i, doc := range []string{
+		`Usage: prog [-vqrh] [FILE] ...
+       prog (--left | --right) CORRECTION FILE
+
+Options:
+  -h --help
+  -v       verbose mode
+  -q       quiet mode
+  -r       make report
+  --left   use left-hand side
+  --right  use right-hand side`,
+		`Usage:
+  naval_fate ship new <name>...
+  naval_fate ship <name> move <x> <y> [--speed=<kn>]
+  naval_fate mine (set|remove) <x> <y> [--moored | --drifting]
+  naval_fate -h | --help
+
+Options:
+  -h --help     Show this screen.
+  --speed=<kn>  Speed in knots [default: 10].
+  --moored      Moored (anchored) mine.
+  --drifting    Drifting mine.
+
+Arguments:
+  <name>  Name of the ship.`,
+		`Usage: prog [options] [-o FILE] (a b | c)...
+
+Options:
+  -o FILE  Output.
+  -n, --dry-run  Don't do it.`,
+	} {
+		pat, err := ParsePattern(doc)
+		if err != nil {
+			t.Fatalf("testcase: %d parse err: %v", i, err)
+		}
+		usage := pat.Usage("prog")
+		again, err := ParsePattern(usage)
+		if err != nil {
+			t.Fatalf("testcase: %d reparse err: %v\n%s", i, err, usage)
+		}
+		if !again.EqualIgnoreValues(pat) {
+			t.Errorf("testcase: %d\n%s\nresult: %v\nexpect: %v", i, usage, again, pat)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	pat, err := ParsePattern(`Usage: prog [-v | -q] (--left | --right) <file>...
+
+Options:
+  -o, --out=<path>  Output path [default: out.txt].
+  -v                Verbose.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `Usage:
+  prog [-v | -q] (--left | --right) <file>...
+
+Options:
+  -v       Verbose.
+  -q
+  --left
+  --right
+`
+	if usage := pat.Usage("prog"); usage != expect {
+		t.Errorf("result:\n%s\nexpect:\n%s", usage, expect)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	pat, err := ParsePattern(`Usage: prog [-v...] [--format=<fmt>] (add | rm) <file>...
+
+Options:
+  --format=<fmt>  Output format (choices: json, yaml) [default: json].`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := pat.JSONSchema("prog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(schema); err != nil {
+		t.Fatal(err)
+	}
+	expect := `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "prog",
+  "type": "object",
+  "properties": {
+    "--format": {
+      "description": "Output format (choices: json, yaml) [default: json].",
+      "type": [
+        "string",
+        "null"
+      ],
+      "enum": [
+        "json",
+        "yaml",
+        null
+      ],
+      "default": "json"
+    },
+    "-v": {
+      "type": "integer",
+      "minimum": 0
+    },
+    "<file>": {
+      "type": "array",
+      "items": {
+        "type": "string"
+      },
+      "minItems": 1
+    },
+    "add": {
+      "type": "boolean"
+    },
+    "rm": {
+      "type": "boolean"
+    }
+  },
+  "required": [
+    "<file>"
+  ],
+  "additionalProperties": false
+}
+`
+	if b.String() != expect {
+		t.Errorf("result:\n%s\nexpect:\n%s", b.String(), expect)
+	}
+	if pat.FindOption("-v").Value != false {
+		t.Error("exporting changed the pattern")
+	}
+
+	// an optional choice that's absent matches as null, which validates
+	pat, err = ParsePattern(`Usage: prog [--level=<l>]
+
+Options:
+  --level=<l>  The level (choices: low, high).`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := Match(pat, []string{})
+	if err != nil || opts["--level"] != nil {
+		t.Fatalf("result: %v error: %v", opts, err)
+	}
+	schema, err = pat.JSONSchema("prog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	level := schema.Properties["--level"]
+	null := false
+	for _, v := range level.Enum {
+		null = null || v == nil
+	}
+	if !reflect.DeepEqual(level.Type, []string{"string", "null"}) || !null {
+		t.Errorf("result: %v %v expected: null admitted by the type and the enum", level.Type, level.Enum)
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	pat, err := ParsePattern(`Usage: prog ship <name> [--speed=<kn>]
+       prog (set | remove) "x"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `flowchart LR
+  n0(["usage"]):::root
+  n1["line 1"]:::line
+  n0 --> n1
+  n2("ship"):::command
+  n1 --> n2
+  n3[/"#lt;name#gt;"/]:::argument
+  n1 --> n3
+  n4{{"optional"}}:::group
+  n5["--speed=#lt;kn#gt;"]:::option
+  n4 --> n5
+  n1 --> n4
+  n6["line 2"]:::line
+  n0 --> n6
+  n7{{"one of"}}:::group
+  n8("set"):::command
+  n7 --> n8
+  n9("remove"):::command
+  n7 --> n9
+  n6 --> n7
+  n10("#quot;x#quot;"):::command
+  n6 --> n10
+`
+	if result := pat.Mermaid(); !strings.HasPrefix(result, expect) {
+		t.Errorf("result:\n%s\nexpect:\n%s", result, expect)
+	}
+}
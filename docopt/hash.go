@@ -0,0 +1,79 @@
+package docopt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"sort"
+	"strconv"
+)
+
+// Hash returns a stable hex encoded SHA-256 digest of the pattern tree. It
+// covers the structure, names, option fields and help text metadata, and the
+// attached subcommands, but not the matched values, and doesn't depend on
+// the order of either branches, so equivalent patterns parsed at different
+// times hash the same.
+func (p *Pattern) Hash() string {
+	sum := p.hash()
+	return hex.EncodeToString(sum)
+}
+
+func (p *Pattern) hash() []byte {
+	h := sha256.New()
+	writeHashFields(h,
+		p.T.String(),
+		p.Name,
+		p.Short,
+		p.Long,
+		strconv.Itoa(p.Argcount),
+		p.Description,
+		p.Metavar,
+		p.Default,
+		p.Section,
+		p.EnvVar,
+		strconv.FormatBool(p.Deprecated),
+		strconv.FormatBool(p.Stdin),
+	)
+	writeHashFields(h, p.Choices...)
+
+	children := make([][]byte, len(p.Children))
+	for i, child := range p.Children {
+		children[i] = child.hash()
+	}
+	if p.T == patternEither {
+		sort.Slice(children, func(i, j int) bool {
+			return bytes.Compare(children[i], children[j]) < 0
+		})
+	}
+	binary.Write(h, binary.BigEndian, uint64(len(children)))
+	for _, c := range children {
+		h.Write(c)
+	}
+
+	// patterns without subcommands hash as they did before there were any
+	if len(p.Subcommands) > 0 {
+		names := make([]string, 0, len(p.Subcommands))
+		for name := range p.Subcommands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		binary.Write(h, binary.BigEndian, uint64(len(names)))
+		for _, name := range names {
+			writeHashFields(h, name)
+			h.Write(p.Subcommands[name].hash())
+		}
+	}
+	return h.Sum(nil)
+}
+
+// writeHashFields writes the count and then each length prefixed field, so
+// that field boundaries can't be shifted to produce the same input.
+func writeHashFields(h hash.Hash, fields ...string) {
+	binary.Write(h, binary.BigEndian, uint64(len(fields)))
+	for _, f := range fields {
+		binary.Write(h, binary.BigEndian, uint64(len(f)))
+		h.Write([]byte(f))
+	}
+}
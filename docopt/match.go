@@ -0,0 +1,304 @@
+package docopt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// match matches the pattern against the argv patterns in left, adding what it
+// consumes to collected. It returns whether it matched and what is left and
+// collected after it; left and collected themselves are never modified.
+func (p *Pattern) match(left *PatternList, collected *PatternList) (bool, *PatternList, *PatternList) {
+	return newMatcher().match(p, left, collected)
+}
+
+// matcher matches patterns against argv without recursion: every branch
+// being matched has a frame on an explicit stack, so deeply nested groups
+// cost heap rather than goroutine stack.
+//
+// It also remembers the outcome of every branch node for the left and
+// collected lists it was given. These lists are never modified, so the same
+// lists always mean the same state. Either tries each alternative from the
+// same state, and the alternatives of large tools share a lot of structure,
+// such as the [options] shortcut or the global options that start every usage
+// line. Branches are therefore told apart by their shape rather than identity,
+// so the same group in another alternative is matched only once.
+type matcher struct {
+	memo map[memoKey]matchOutcome
+	// shapes numbers the distinct branch shapes seen, shape caches them
+	shapes map[string]int
+	shape  map[*Pattern]int
+}
+
+type memoKey struct {
+	shape     int
+	left      *PatternList
+	collected *PatternList
+}
+
+type matchOutcome struct {
+	matched   bool
+	left      *PatternList
+	collected *PatternList
+}
+
+// matchFrame is a branch in the middle of being matched.
+type matchFrame struct {
+	node *Pattern
+	key  memoKey
+	// left and collected as given to the node
+	left      *PatternList
+	collected *PatternList
+	// l and c are the state reached so far, the input of the next child
+	l, c *PatternList
+	// next is the index of the next child to match, once it is past the
+	// first the frame is waiting for the outcome of a child
+	next int
+	// times and lAlt track the iterations of OneOrMore
+	times int
+	lAlt  *PatternList
+	// best is the outcome Either picked so far, firstLen the length of the
+	// left of its first matching alternative
+	best     *matchOutcome
+	firstLen int
+}
+
+func newMatcher() *matcher {
+	return &matcher{
+		memo:   make(map[memoKey]matchOutcome),
+		shapes: make(map[string]int),
+		shape:  make(map[*Pattern]int),
+	}
+}
+
+func (m *matcher) match(p *Pattern, left *PatternList, collected *PatternList) (bool, *PatternList, *PatternList) {
+	if collected == nil {
+		collected = &PatternList{}
+	}
+	var stack []*matchFrame
+	out, f := m.enter(p, left, collected)
+	if f != nil {
+		stack = append(stack, f)
+	}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		child, done := f.resume(out)
+		if done {
+			out = f.outcome()
+			m.memo[f.key] = out
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		// the frame gets the child's outcome right away, or once the frame
+		// of the child is done
+		var cf *matchFrame
+		if out, cf = m.enter(child, f.l, f.c); cf != nil {
+			stack = append(stack, cf)
+		}
+	}
+	return out.matched, out.left, out.collected
+}
+
+// enter starts matching p. Leaves and memoized branches are done at once and
+// their outcome returned, other branches return a frame to be pushed.
+func (m *matcher) enter(p *Pattern, left *PatternList, collected *PatternList) (matchOutcome, *matchFrame) {
+	// leaves are cheaper to match than to look up
+	if p.T&patternBranch == 0 {
+		matched, l, c := p.matchLeaf(left, collected)
+		return matchOutcome{matched, l, c}, nil
+	}
+	if p.T&patternOneOrMore != 0 && len(p.Children) != 1 {
+		panic("OneOrMore.match(): assert len(p.children) == 1")
+	}
+	key := memoKey{m.shapeOf(p), left, collected}
+	if o, ok := m.memo[key]; ok {
+		return o, nil
+	}
+	return matchOutcome{}, &matchFrame{node: p, key: key, left: left, collected: collected, l: left, c: collected}
+}
+
+// resume takes the outcome of the child the frame was waiting for, if it was,
+// and returns the next child to match, or done once the frame has its
+// outcome. A frame that failed is left with a nil l.
+func (f *matchFrame) resume(child matchOutcome) (*Pattern, bool) {
+	p := f.node
+	waiting := f.next > 0
+	switch {
+	case p.T&patternRequired != 0:
+		if waiting {
+			if !child.matched {
+				f.l = nil
+				return nil, true
+			}
+			f.l, f.c = child.left, child.collected
+		}
+	case p.T&patternOptionAL != 0 || p.T&patternOptionSSHORTCUT != 0:
+		if waiting {
+			f.l, f.c = child.left, child.collected
+		}
+	case p.T&patternOneOrMore != 0:
+		if waiting {
+			// could it be that something didn't match but changed l or c?
+			f.l, f.c = child.left, child.collected
+			if child.matched {
+				f.times++
+			}
+			if !child.matched || f.lAlt == f.l {
+				if f.times == 0 {
+					f.l = nil
+				}
+				return nil, true
+			}
+			f.lAlt = f.l
+		}
+		f.next = 1
+		return p.Children[0], false
+	case p.T&patternEither != 0:
+		if waiting && child.matched {
+			// the first alternative that matched, unless a later one left
+			// less of argv than it did
+			if f.best == nil {
+				f.firstLen = len(*child.left)
+				f.best = &child
+			} else if len(*child.left) < f.firstLen {
+				f.best = &child
+			}
+		}
+		if f.next == len(p.Children) {
+			if f.best == nil {
+				f.l = nil
+			}
+			return nil, true
+		}
+		// every alternative starts from what the either was given
+		f.l, f.c = f.left, f.collected
+	}
+	if f.next == len(p.Children) {
+		return nil, true
+	}
+	f.next++
+	return p.Children[f.next-1], false
+}
+
+// outcome returns the outcome of a frame that is done.
+func (f *matchFrame) outcome() matchOutcome {
+	if f.best != nil {
+		return *f.best
+	}
+	if f.l == nil {
+		return matchOutcome{false, f.left, f.collected}
+	}
+	return matchOutcome{true, f.l, f.c}
+}
+
+// shapeOf numbers the shape of a branch: its type and the shapes of its
+// children. Leaves of the same name are one and the same pattern once the
+// pattern is fixed, so they are told apart by identity.
+func (m *matcher) shapeOf(p *Pattern) int {
+	// children are numbered before their parents, from a stack rather than
+	// by recursion for the same reason match doesn't recurse
+	stack := PatternList{p}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		if _, ok := m.shape[n]; ok {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		var b strings.Builder
+		if n.T&patternBranch == 0 {
+			fmt.Fprintf(&b, "%p", n)
+		} else {
+			pending := false
+			for _, child := range n.Children {
+				if _, ok := m.shape[child]; !ok {
+					stack = append(stack, child)
+					pending = true
+				}
+			}
+			if pending {
+				continue
+			}
+			b.WriteString(n.T.String())
+			for _, child := range n.Children {
+				b.WriteByte(' ')
+				b.WriteString(strconv.Itoa(m.shape[child]))
+			}
+		}
+		id, ok := m.shapes[b.String()]
+		if !ok {
+			id = len(m.shapes)
+			m.shapes[b.String()] = id
+		}
+		m.shape[n] = id
+		stack = stack[:len(stack)-1]
+	}
+	return m.shape[p]
+}
+
+// matchLeaf matches an argument, command or option leaf: it takes the first
+// fitting pattern out of left and adds it to collected, or adds up its value
+// for repeated leaves.
+func (p *Pattern) matchLeaf(left *PatternList, collected *PatternList) (bool, *PatternList, *PatternList) {
+	pos, match := p.singleMatch(left)
+	var increment interface{}
+	if match == nil {
+		return false, left, collected
+	}
+	leftAlt := make(PatternList, len((*left)[:pos]), len((*left)[:pos])+len((*left)[pos+1:]))
+	copy(leftAlt, (*left)[:pos])
+	leftAlt = append(leftAlt, (*left)[pos+1:]...)
+	sameName := PatternList{}
+	for _, a := range *collected {
+		if a.Name == p.Name {
+			sameName = append(sameName, a)
+		}
+	}
+
+	switch p.Value.(type) {
+	case int, []string:
+		switch p.Value.(type) {
+		case int:
+			increment = 1
+		case []string:
+			switch match.Value.(type) {
+			case string:
+				increment = []string{match.Value.(string)}
+			default:
+				increment = match.Value
+			}
+		}
+		// never modify match or the collected patterns in place: they
+		// are shared with argv and with the outcomes of other branches
+		if len(sameName) == 0 {
+			matchAlt := *match
+			matchAlt.Value = increment
+			collectedMatch := make(PatternList, len(*collected), len(*collected)+1)
+			copy(collectedMatch, *collected)
+			collectedMatch = append(collectedMatch, &matchAlt)
+			return true, &leftAlt, &collectedMatch
+		}
+		accumulated := *sameName[0]
+		switch v := sameName[0].Value.(type) {
+		case int:
+			accumulated.Value = v + increment.(int)
+		case []string:
+			values := make([]string, 0, len(v)+len(increment.([]string)))
+			values = append(values, v...)
+			accumulated.Value = append(values, increment.([]string)...)
+		}
+		collectedMatch := make(PatternList, len(*collected))
+		for i, c := range *collected {
+			if c == sameName[0] {
+				collectedMatch[i] = &accumulated
+			} else {
+				collectedMatch[i] = c
+			}
+		}
+		return true, &leftAlt, &collectedMatch
+	}
+	collectedMatch := make(PatternList, len(*collected), len(*collected)+1)
+	copy(collectedMatch, *collected)
+	collectedMatch = append(collectedMatch, match)
+	return true, &leftAlt, &collectedMatch
+}
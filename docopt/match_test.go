@@ -0,0 +1,222 @@
+package docopt
+
+import (
+	"context"
+	"reflect"
+	rtdebug "runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	pat, err := ParsePattern(`Usage: prog [options] <x> [<y>...]
+       prog ship (--left | --right)
+
+Options:
+  -v --verbose    Verbose.
+  -n NUM          Number [default: 3].`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := pat.clone()
+	for i, c := range []struct {
+		argv   string
+		expect Opts
+		err    string
+	}{
+		{"1", Opts{"--verbose": false, "-n": "3", "<x>": "1", "<y>": []string{},
+			"ship": false, "--left": false, "--right": false}, ""},
+		{"-vn5 1 2 3", Opts{"--verbose": true, "-n": "5", "<x>": "1", "<y>": []string{"2", "3"},
+			"ship": false, "--left": false, "--right": false}, ""},
+		{"ship --right", Opts{"--verbose": false, "-n": "3", "<x>": nil, "<y>": []string{},
+			"ship": true, "--left": false, "--right": true}, ""},
+		{"ship --right --left", nil, "unexpected arguments: --right"},
+		{"", nil, "command line doesn't match the usage"},
+		{"--nope 1", nil, ""},
+	} {
+		opts, err := Match(pat, strings.Fields(c.argv))
+		if c.expect == nil {
+			if _, ok := err.(*UserError); !ok {
+				t.Errorf("testcase: %d expected a user error, got: %v", i, err)
+			} else if c.err != "" && err.Error() != c.err {
+				t.Errorf("testcase: %d error: %q expect: %q", i, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase: %d error: %v", i, err)
+		} else if !reflect.DeepEqual(opts, c.expect) {
+			t.Errorf("testcase: %d result: %v expect: %v", i, opts, c.expect)
+		}
+	}
+	if !pat.Equal(before) {
+		t.Errorf("pattern changed by matching: %v", pat)
+	}
+}
+
+func TestMatchLeavesInputsUntouched(t *testing.T) {
+	left := PatternList{newArgument("", "1"), newOption("-v", "", 0, true)}
+	collected := PatternList{newArgument("N", []string{"0"}), newOption("-v", "", 0, 1)}
+	pat := newRequired(
+		newOneOrMore(newArgument("N", []string{})),
+		newOneOrMore(newOption("-v", "", 0, 0)))
+
+	for i := 0; i < 2; i++ {
+		matched, l, c := pat.match(&left, &collected)
+		expect := PatternList{newArgument("N", []string{"0", "1"}), newOption("-v", "", 0, 2)}
+		if !matched || len(*l) != 0 || !reflect.DeepEqual(*c, expect) {
+			t.Fatalf("run %d: %v %v %v", i, matched, l, c)
+		}
+	}
+	if !reflect.DeepEqual(left, PatternList{newArgument("", "1"), newOption("-v", "", 0, true)}) {
+		t.Errorf("left changed: %v", left)
+	}
+	if !reflect.DeepEqual(collected, PatternList{newArgument("N", []string{"0"}), newOption("-v", "", 0, 1)}) {
+		t.Errorf("collected changed: %v", collected)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ParsePatternContext(ctx, "Usage: prog [-a] <x>"); err != context.Canceled {
+		t.Errorf("ParsePatternContext: %v", err)
+	}
+	pat, err := ParsePattern("Usage: prog [-a] <x>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MatchContext(ctx, pat, []string{"x"}); err != context.Canceled {
+		t.Errorf("MatchContext: %v", err)
+	}
+}
+
+func TestMatchAbbreviatedLongOptions(t *testing.T) {
+	pat, err := ParsePattern(`Usage: prog [options]
+
+Options:
+  --verbose    Verbose.
+  --version    Version.
+  --out=<f>    Output.
+  --ver        Exactly ver.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range []struct {
+		argv   []string
+		key    string
+		expect interface{}
+		err    string
+	}{
+		{[]string{"--verb"}, "--verbose", true, ""},
+		{[]string{"--vers"}, "--version", true, ""},
+		{[]string{"--ver"}, "--ver", true, ""},
+		{[]string{"--o", "x"}, "--out", "x", ""},
+		{[]string{"--ou=x"}, "--out", "x", ""},
+		{[]string{"--ve"}, "", nil, "--ve is not a unique prefix: --verbose, --version, --ver?"},
+		{[]string{"--nope"}, "", nil, "unknown option: --nope"},
+		{[]string{"--verb=1"}, "", nil, "--verbose must not have an argument"},
+	} {
+		opts, err := Match(pat, c.argv)
+		if c.err != "" {
+			if _, ok := err.(*UsageError); !ok || err.Error() != c.err {
+				t.Errorf("testcase: %d error: %v expect: %q", i, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("testcase: %d error: %v", i, err)
+		} else if opts[c.key] != c.expect {
+			t.Errorf("testcase: %d result: %v", i, opts)
+		}
+	}
+}
+
+func TestMatchStdin(t *testing.T) {
+	pat, err := ParsePattern(`Usage: prog cat [-o FILE] <file>...
+       prog read [-]
+
+Options:
+  -o FILE  Output file, or - for standard output.
+
+Arguments:
+  <file>  Input file, - reads stdin.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, leaf := range pat.StdinLeaves() {
+		names = append(names, leaf.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"<file>", "-"}) {
+		t.Errorf("result: %v", names)
+	}
+	opts, err := Match(pat, []string{"cat", "a", "-", "-o", "-"})
+	if err != nil || !reflect.DeepEqual(opts["<file>"], []string{"a", "-"}) || opts["-o"] != "-" {
+		t.Errorf("result: %v error: %v", opts, err)
+	}
+	opts, err = Match(pat, []string{"read", "-"})
+	if err != nil || opts["-"] != true {
+		t.Errorf("result: %v error: %v", opts, err)
+	}
+	argv, err := pat.ToArgv(Opts{"read": true, "-": true})
+	if err != nil || !reflect.DeepEqual(argv, []string{"read", "-"}) {
+		t.Errorf("result: %q error: %v", argv, err)
+	}
+
+	// reading from elsewhere than stdin isn't taking "-" for it
+	pat, err = ParsePattern(`Usage: prog [--input=FILE] [<script>]
+
+Options:
+  --input=FILE  Read from FILE instead of stdin.
+
+Arguments:
+  <script>  Script to run, "-" for standard input.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = nil
+	for _, leaf := range pat.StdinLeaves() {
+		names = append(names, leaf.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"<script>"}) {
+		t.Errorf("result: %v", names)
+	}
+}
+
+func TestMatchMemoized(t *testing.T) {
+	options := PatternList{newOption("-a", "", 0, false), newOption("-b", "", 0, false)}
+	lines := PatternList{}
+	for _, name := range []string{"add", "rm", "mv"} {
+		lines = append(lines, newRequired(newOptional(options...), newCommand(name, false)))
+	}
+	pat := newEither(lines...)
+	left := PatternList{newOption("-b", "", 0, true), newArgument("", "mv")}
+	m := newMatcher()
+	matched, l, c := m.match(pat, &left, nil)
+	expect := PatternList{newOption("-b", "", 0, true), newCommand("mv", true)}
+	if !matched || len(*l) != 0 || !reflect.DeepEqual(*c, expect) {
+		t.Errorf("result: %v %v %v", matched, l, c)
+	}
+	// the either, its three lines and the options group they share
+	if len(m.memo) != 5 {
+		t.Errorf("result: %d memoized outcomes expect: 5", len(m.memo))
+	}
+}
+
+func TestMatchDeeplyNested(t *testing.T) {
+	// a recursive matcher needs far more stack than this for 100000 groups
+	defer rtdebug.SetMaxStack(rtdebug.SetMaxStack(1 << 20))
+	pat := newArgument("N", nil)
+	for i := 0; i < 100000; i++ {
+		if i%2 == 0 {
+			pat = newRequired(pat)
+		} else {
+			pat = newOptional(pat)
+		}
+	}
+	matched, l, c := pat.match(&PatternList{newArgument("", "9")}, nil)
+	if !matched || len(*l) != 0 || !reflect.DeepEqual(*c, PatternList{newArgument("N", "9")}) {
+		t.Errorf("result: %v %v %v", matched, l, c)
+	}
+}
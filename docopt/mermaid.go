@@ -0,0 +1,79 @@
+package docopt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mermaid renders the pattern as a Mermaid flowchart definition: the usage
+// lines hang off a root node, groups become nodes labelled after their kind
+// and the arguments, commands and options become leaves, each kind drawn in
+// its own shape and class.
+func (p *Pattern) Mermaid() string {
+	m := &mermaid{}
+	m.b.WriteString("flowchart LR\n")
+	root := m.node("usage", "([", "])", "root")
+	for i, line := range p.usageLines() {
+		id := m.node(fmt.Sprintf("line %d", i+1), "[", "]", "line")
+		m.edge(root, id)
+		for _, child := range line {
+			m.edge(id, m.pattern(child))
+		}
+	}
+	m.b.WriteString("  classDef root fill:#444,color:#fff\n")
+	m.b.WriteString("  classDef line fill:#eee\n")
+	m.b.WriteString("  classDef group stroke-dasharray:4 2\n")
+	m.b.WriteString("  classDef command fill:#cde\n")
+	m.b.WriteString("  classDef argument fill:#dec\n")
+	m.b.WriteString("  classDef option fill:#edc\n")
+	return m.b.String()
+}
+
+type mermaid struct {
+	b     strings.Builder
+	count int
+}
+
+func (m *mermaid) pattern(p *Pattern) string {
+	// single child requireds are plain parentheses, skip them
+	for p.T == patternRequired && len(p.Children) == 1 {
+		p = p.Children[0]
+	}
+	switch p.T {
+	case patternCommand:
+		return m.node(p.Name, "(", ")", "command")
+	case patternArgument:
+		return m.node(p.Name, "[/", "/]", "argument")
+	case patternOption:
+		return m.node(p.optionSpec(), "[", "]", "option")
+	}
+	labels := map[patternType]string{
+		patternRequired:        "all of",
+		patternOptionAL:        "optional",
+		patternOptionSSHORTCUT: "options",
+		patternOneOrMore:       "one or more",
+		patternEither:          "one of",
+	}
+	id := m.node(labels[p.T], "{{", "}}", "group")
+	for _, child := range p.Children {
+		m.edge(id, m.pattern(child))
+	}
+	return id
+}
+
+func (m *mermaid) node(label, open, close, class string) string {
+	id := fmt.Sprintf("n%d", m.count)
+	m.count++
+	fmt.Fprintf(&m.b, "  %s%s\"%s\"%s:::%s\n", id, open, mermaidEscape(label), close, class)
+	return id
+}
+
+func (m *mermaid) edge(from, to string) {
+	fmt.Fprintf(&m.b, "  %s --> %s\n", from, to)
+}
+
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+func mermaidEscape(s string) string {
+	return mermaidEscaper.Replace(s)
+}
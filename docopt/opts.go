@@ -19,8 +19,8 @@ func errStrconv(key string, convErr error) error {
 }
 
 // Opts is a map of command line options to their values, with some convenience
-// methods for value type conversion (bool, float64, int, string). For example,
-// to get an option value as an int:
+// methods for value type conversion (bool, float64, int, string, []string).
+// For example, to get an option value as an int:
 //
 //   opts, _ := docopt.ParseDoc("Usage: sleep <seconds>")
 //   secs, _ := opts.Int("<seconds>")
@@ -65,7 +65,12 @@ func (o Opts) Bool(key string) (b bool, err error) {
 	return
 }
 
+// Int returns the value of key as an int. Counted flags already hold an int,
+// any other value has to be a string holding a decimal number.
 func (o Opts) Int(key string) (i int, err error) {
+	if v, ok := o[key].(int); ok {
+		return v, nil
+	}
 	s, err := o.String(key)
 	if err != nil {
 		return
@@ -77,6 +82,25 @@ func (o Opts) Int(key string) (i int, err error) {
 	return
 }
 
+// Strings returns the value of key as a slice of strings, as held by repeated
+// arguments and options. A single string value is returned as a slice of one.
+func (o Opts) Strings(key string) (s []string, err error) {
+	v, ok := o[key]
+	if !ok {
+		err = errKey(key)
+		return
+	}
+	switch v := v.(type) {
+	case []string:
+		s = v
+	case string:
+		s = []string{v}
+	default:
+		err = errType(key)
+	}
+	return
+}
+
 func (o Opts) Float64(key string) (f float64, err error) {
 	s, err := o.String(key)
 	if err != nil {
@@ -107,17 +131,18 @@ func (o Opts) Float64(key string) (f float64, err error) {
 // with an option's key, Bind will try to map the option to an appropriately
 // named field (as above).
 //
-// Bind also handles conversion to bool, float, int or string types.
+// Bind also handles conversion to bool, float, int, uint or string types, and
+// binds a single string value to a []string field.
 func (o Opts) Bind(v interface{}) error {
 	structVal := reflect.ValueOf(v)
 	if structVal.Kind() != reflect.Ptr {
-		return newError("'v' argument is not pointer to struct type")
+		return newBindError("", "", "'v' argument is not pointer to struct type")
 	}
 	for structVal.Kind() == reflect.Ptr {
 		structVal = structVal.Elem()
 	}
 	if structVal.Kind() != reflect.Struct {
-		return newError("'v' argument is not pointer to struct type")
+		return newBindError("", "", "'v' argument is not pointer to struct type")
 	}
 	structType := structVal.Type()
 
@@ -160,12 +185,12 @@ func (o Opts) Bind(v interface{}) error {
 			if k == "--help" || k == "--version" { // Don't require these to be mapped.
 				continue
 			}
-			return newError("mapping of %q is not found in given struct, or is an unexported field", k)
+			return newBindError(k, "", "mapping of %q is not found in given struct, or is an unexported field", k)
 		}
 		fieldVal := structVal.Field(i)
 		zeroVal := reflect.Zero(fieldVal.Type())
 		if !reflect.DeepEqual(fieldVal.Interface(), zeroVal.Interface()) {
-			return newError("%q field is non-zero, will be overwritten by value of %q", structType.Field(i).Name, k)
+			return newBindError(k, structType.Field(i).Name, "%q field is non-zero, will be overwritten by value of %q", structType.Field(i).Name, k)
 		}
 		indexMap[k] = i
 	}
@@ -189,7 +214,7 @@ func (o Opts) Bind(v interface{}) error {
 			continue
 		}
 		if !field.CanSet() {
-			return newError("%q field cannot be set", structType.Field(i).Name)
+			return newBindError(k, structType.Field(i).Name, "%q field cannot be set", structType.Field(i).Name)
 		}
 		// Try to assign now if able. bool and string values should be assignable already.
 		if optVal.Type().AssignableTo(field.Type()) {
@@ -200,25 +225,33 @@ func (o Opts) Bind(v interface{}) error {
 		switch field.Kind() {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 			if x, err := o.Int(k); err == nil {
+				if field.OverflowInt(int64(x)) {
+					return newBindError(k, structType.Field(i).Name, "value %d of %q overflows %q field", x, k, structType.Field(i).Name)
+				}
 				field.SetInt(int64(x))
 				continue
 			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if x, err := o.Int(k); err == nil && x >= 0 {
+				if field.OverflowUint(uint64(x)) {
+					return newBindError(k, structType.Field(i).Name, "value %d of %q overflows %q field", x, k, structType.Field(i).Name)
+				}
+				field.SetUint(uint64(x))
+				continue
+			}
 		case reflect.Float32, reflect.Float64:
 			if x, err := o.Float64(k); err == nil {
 				field.SetFloat(x)
 				continue
 			}
+		case reflect.Slice:
+			// a single option value bound to a []string field
+			if field.Type().Elem().Kind() == reflect.String && optVal.Kind() == reflect.String {
+				field.Set(reflect.Append(field, optVal.Convert(field.Type().Elem())))
+				continue
+			}
 		}
-		// TODO: Something clever (recursive?) with non-string slices.
-		// case reflect.Slice:
-		// 	if optVal.Kind() == reflect.Slice {
-		// 		for i := 0; i < optVal.Len(); i++ {
-		// 			sliceVal := optVal.Index(i)
-		// 			fmt.Printf("%v", sliceVal)
-		// 		}
-		// 		fmt.Printf("\n")
-		// 	}
-		return newError("value of %q is not assignable to %q field", k, structType.Field(i).Name)
+		return newBindError(k, structType.Field(i).Name, "value of %q is not assignable to %q field", k, structType.Field(i).Name)
 	}
 
 	return nil
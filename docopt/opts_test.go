@@ -1,6 +1,7 @@
 package docopt
 
 import (
+	"errors"
 	"reflect"
 	"strings"
 	"testing"
@@ -33,6 +34,29 @@ func TestOptsUsage(t *testing.T) {
 	}
 }
 
+func TestOptsStringsAndCounts(t *testing.T) {
+	usage := "Usage: prog [-v...] <file>... [--out=<path>]"
+	opts, err := testParser.ParseArgs(usage, []string{"-vv", "a", "b", "--out=x"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := opts.Int("-v"); err != nil || i != 2 {
+		t.Errorf("Int(-v): %d, %v", i, err)
+	}
+	if s, err := opts.Strings("<file>"); err != nil || !reflect.DeepEqual(s, []string{"a", "b"}) {
+		t.Errorf("Strings(<file>): %v, %v", s, err)
+	}
+	if s, err := opts.Strings("--out"); err != nil || !reflect.DeepEqual(s, []string{"x"}) {
+		t.Errorf("Strings(--out): %v, %v", s, err)
+	}
+	if _, err := opts.Strings("-v"); err == nil {
+		t.Error("Strings(-v): error expected")
+	}
+	if _, err := opts.Strings("<missing>"); err == nil {
+		t.Error("Strings(<missing>): error expected")
+	}
+}
+
 func TestOptsErrors(t *testing.T) {
 	usage := "Usage: sleep <seconds> [--now]"
 	var opts Opts
@@ -336,3 +360,46 @@ func TestBindHyphenatedTags(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestBindCountsAndLists(t *testing.T) {
+	var testParser = &Parser{HelpHandler: NoHelpHandler, SkipHelpFlags: true}
+	opts, err := testParser.ParseArgs("Usage: prog [-v...] [--tag=<t>] <file>...",
+		[]string{"-vvv", "--tag=x", "a", "b"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opt struct {
+		Verbosity uint8    `docopt:"-v"`
+		Tags      []string `docopt:"--tag"`
+		Files     []string `docopt:"<file>"`
+	}
+	if err := opts.Bind(&opt); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Verbosity != 3 || !reflect.DeepEqual(opt.Tags, []string{"x"}) ||
+		!reflect.DeepEqual(opt.Files, []string{"a", "b"}) {
+		t.Errorf("result: %#v", opt)
+	}
+
+	// values out of the range of the field aren't wrapped
+	opts, err = testParser.ParseArgs("Usage: prog --count=<n> --level=<l>",
+		[]string{"--count=300", "--level=-200"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count struct {
+		Count uint8 `docopt:"--count"`
+		Level int   `docopt:"--level"`
+	}
+	var bindErr *BindError
+	if err = opts.Bind(&count); !errors.As(err, &bindErr) || bindErr.Key != "--count" || bindErr.Field != "Count" {
+		t.Errorf("result: %v expect: a BindError of --count", err)
+	}
+	var level struct {
+		Count uint16 `docopt:"--count"`
+		Level int8   `docopt:"--level"`
+	}
+	if err = opts.Bind(&level); !errors.As(err, &bindErr) || bindErr.Key != "--level" || bindErr.Field != "Level" {
+		t.Errorf("result: %v expect: a BindError of --level", err)
+	}
+}
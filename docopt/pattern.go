@@ -1,8 +1,8 @@
 package docopt
 
 import (
+	"context"
 	"fmt"
-	"reflect"
 	"strings"
 )
 
@@ -74,6 +74,32 @@ type Pattern struct {
 	Short    string
 	Long     string
 	Argcount int
+
+	// Metadata collected from the help text. It takes no part in matching,
+	// it is only carried along for consumers of the tree.
+	Description string
+	Metavar     string
+	Default     string
+	Choices     []string
+	Section     string
+	EnvVar      string
+	Deprecated  bool
+	// Stdin is set on leaves standing for standard input: the "-" command,
+	// and arguments and option values described as taking "-" for stdin.
+	Stdin bool
+
+	// Where the pattern comes from in the help text: Span is its place in
+	// the usage, DescriptionSpan the entry describing it in the options,
+	// arguments or commands sections. Spans take no part in Equal or Hash.
+	Span            Span
+	DescriptionSpan Span
+	// Provenance is the source the node comes from, see Merge. It takes no
+	// part in Equal or Hash either.
+	Provenance Provenance
+	// Subcommands are the patterns attached to the root with
+	// AttachSubcommand, keyed by command name. They take no part in Equal or
+	// Hash, nor in matching, see Resolve.
+	Subcommands map[string]*Pattern
 }
 
 type PatternList []*Pattern
@@ -151,6 +177,56 @@ func newOption(short, long string, argcount int, value interface{}) *Pattern {
 	return &p
 }
 
+// copyMetadata copies the help text metadata of other into p.
+func (p *Pattern) copyMetadata(other *Pattern) {
+	p.Description = other.Description
+	p.Metavar = other.Metavar
+	p.Default = other.Default
+	if other.Choices != nil {
+		p.Choices = make([]string, len(other.Choices))
+		copy(p.Choices, other.Choices)
+	}
+	p.Section = other.Section
+	p.EnvVar = other.EnvVar
+	p.Deprecated = other.Deprecated
+	p.Stdin = other.Stdin
+}
+
+// clone returns a deep copy of the pattern tree.
+func (p *Pattern) clone() *Pattern {
+	c := *p
+	c.copyMetadata(p)
+	if v, ok := p.Value.([]string); ok {
+		c.Value = append([]string{}, v...)
+	}
+	if p.Children != nil {
+		c.Children = make(PatternList, len(p.Children))
+		for i, child := range p.Children {
+			c.Children[i] = child.clone()
+		}
+	}
+	if p.Subcommands != nil {
+		c.Subcommands = make(map[string]*Pattern, len(p.Subcommands))
+		for name, sub := range p.Subcommands {
+			c.Subcommands[name] = sub.clone()
+		}
+	}
+	return &c
+}
+
+// argvString returns the command line argument a pattern parsed from argv
+// came from.
+func (p *Pattern) argvString() string {
+	switch v := p.Value.(type) {
+	case string:
+		if p.T == patternOption {
+			return p.Name + "=" + v
+		}
+		return v
+	}
+	return p.Name
+}
+
 func (p *Pattern) Flat(types patternType) (PatternList, error) {
 	if p.T&patternLeaf != 0 {
 		if types == patternDefault {
@@ -176,16 +252,33 @@ func (p *Pattern) Flat(types patternType) (PatternList, error) {
 		}
 		return result, nil
 	}
-	return nil, newError("unknown pattern type: %d, %d", p.T, types)
+	return nil, newLanguageError("unknown pattern type: %d, %d", p.T, types)
 }
 
-func (p *Pattern) fix() error {
+// FlatFunc returns every node of the pattern tree, branches included, for
+// which f returns true, in depth-first order. Unlike Flat it keeps descending
+// into the children of selected branches.
+func (p *Pattern) FlatFunc(f func(*Pattern) bool) PatternList {
+	result := PatternList{}
+	p.flatFunc(f, &result)
+	return result
+}
+
+func (p *Pattern) flatFunc(f func(*Pattern) bool, result *PatternList) {
+	if f(p) {
+		*result = append(*result, p)
+	}
+	for _, child := range p.Children {
+		child.flatFunc(f, result)
+	}
+}
+
+func (p *Pattern) fix(ctx context.Context) error {
 	err := p.fixIdentities(nil)
 	if err != nil {
 		return err
 	}
-	p.fixRepeatingArguments()
-	return nil
+	return p.fixRepeatingArguments(ctx)
 }
 
 func (p *Pattern) fixIdentities(uniq PatternList) error {
@@ -217,157 +310,102 @@ func (p *Pattern) fixIdentities(uniq PatternList) error {
 	return nil
 }
 
-func (p *Pattern) fixRepeatingArguments() {
-	// Fix elements that should accumulate/increment values.
-	var either []PatternList
-
-	for _, child := range p.transform().Children {
-		either = append(either, child.Children)
+func (p *Pattern) fixRepeatingArguments(ctx context.Context) error {
+	// Fix elements that should accumulate/increment values: those that occur
+	// more than once in some alternative of the pattern. The occurrences are
+	// counted without expanding the pattern into an Either of its
+	// alternatives, which grows exponentially with the number of Eithers.
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	for _, cas := range either {
-		casMultiple := PatternList{}
-		for _, e := range cas {
-			if cas.count(e) > 1 {
-				casMultiple = append(casMultiple, e)
-			}
-		}
-		for _, e := range casMultiple {
-			if e.T == patternArgument || e.T == patternOption && e.Argcount > 0 {
-				switch e.Value.(type) {
-				case string:
-					e.Value = strings.Fields(e.Value.(string))
-				case []string:
-				default:
-					e.Value = []string{}
-				}
-			}
-			if e.T == patternCommand || e.T == patternOption && e.Argcount == 0 {
-				e.Value = 0
-			}
-		}
+	pFlat, err := p.Flat(patternDefault)
+	if err != nil {
+		return err
 	}
-}
-
-func (p *Pattern) match(left *PatternList, collected *PatternList) (bool, *PatternList, *PatternList) {
-	if collected == nil {
-		collected = &PatternList{}
-	}
-	if p.T&patternRequired != 0 {
-		l := left
-		c := collected
-		for _, p := range p.Children {
-			var matched bool
-			matched, l, c = p.match(l, c)
-			if !matched {
-				return false, left, collected
-			}
-		}
-		return true, l, c
-	} else if p.T&patternOptionAL != 0 || p.T&patternOptionSSHORTCUT != 0 {
-		for _, p := range p.Children {
-			_, left, collected = p.match(left, collected)
+	uniq := pFlat.unique()
+	class := make(map[*Pattern]int)
+	for _, e := range pFlat {
+		if class[e], err = uniq.index(e); err != nil {
+			return err
 		}
-		return true, left, collected
-	} else if p.T&patternOneOrMore != 0 {
-		if len(p.Children) != 1 {
-			panic("OneOrMore.match(): assert len(p.children) == 1")
+	}
+	_, with := p.occurrences(class)
+	for e, n := range with {
+		if n < 2 {
+			continue
 		}
-		l := left
-		c := collected
-		var lAlt *PatternList
-		matched := true
-		times := 0
-		for matched {
-			// could it be that something didn't match but changed l or c?
-			matched, l, c = p.Children[0].match(l, c)
-			if matched {
-				times++
-			}
-			if lAlt == l {
-				break
+		if e.T == patternArgument || e.T == patternOption && e.Argcount > 0 {
+			switch e.Value.(type) {
+			case string:
+				e.Value = strings.Fields(e.Value.(string))
+			case []string:
+			default:
+				e.Value = []string{}
 			}
-			lAlt = l
-		}
-		if times >= 1 {
-			return true, l, c
 		}
-		return false, left, collected
-	} else if p.T&patternEither != 0 {
-		type outcomeStruct struct {
-			matched   bool
-			left      *PatternList
-			collected *PatternList
-			length    int
+		if e.T == patternCommand || e.T == patternOption && e.Argcount == 0 {
+			e.Value = 0
 		}
-		outcomes := []outcomeStruct{}
-		for _, p := range p.Children {
-			matched, l, c := p.match(left, collected)
-			outcome := outcomeStruct{matched, l, c, len(*l)}
-			if matched {
-				outcomes = append(outcomes, outcome)
+	}
+	return nil
+}
+
+// occurrences counts the leaves in every alternative of the pattern, as if
+// its Eithers were expanded, where class numbers the leaves that are equal.
+// It returns for every class the most times it occurs in any alternative,
+// and for every leaf the most times its class occurs in an alternative
+// holding that leaf.
+func (p *Pattern) occurrences(class map[*Pattern]int) (max map[int]int, with map[*Pattern]int) {
+	if p.T&patternBranch == 0 {
+		return map[int]int{class[p]: 1}, map[*Pattern]int{p: 1}
+	}
+	max = make(map[int]int)
+	with = make(map[*Pattern]int)
+	if p.T&patternEither != 0 {
+		// an alternative holds one of the children
+		for _, child := range p.Children {
+			childMax, childWith := child.occurrences(class)
+			for k, n := range childMax {
+				if n > max[k] {
+					max[k] = n
+				}
 			}
-		}
-		if len(outcomes) > 0 {
-			minLen := outcomes[0].length
-			minIndex := 0
-			for i, v := range outcomes {
-				if v.length < minLen {
-					minIndex = i
+			for e, n := range childWith {
+				if n > with[e] {
+					with[e] = n
 				}
 			}
-			return outcomes[minIndex].matched, outcomes[minIndex].left, outcomes[minIndex].collected
-		}
-		return false, left, collected
-	} else if p.T&patternLeaf != 0 {
-		pos, match := p.singleMatch(left)
-		var increment interface{}
-		if match == nil {
-			return false, left, collected
 		}
-		leftAlt := make(PatternList, len((*left)[:pos]), len((*left)[:pos])+len((*left)[pos+1:]))
-		copy(leftAlt, (*left)[:pos])
-		leftAlt = append(leftAlt, (*left)[pos+1:]...)
-		sameName := PatternList{}
-		for _, a := range *collected {
-			if a.Name == p.Name {
-				sameName = append(sameName, a)
-			}
+		return max, with
+	}
+	// an alternative holds all of the children, OneOrMore holds its child
+	// twice and may pick different alternatives of it each time
+	var maxes []map[int]int
+	var withs []map[*Pattern]int
+	for _, child := range p.Children {
+		childMax, childWith := child.occurrences(class)
+		maxes = append(maxes, childMax)
+		withs = append(withs, childWith)
+	}
+	if p.T&patternOneOrMore != 0 {
+		maxes = append(maxes, maxes...)
+		withs = append(withs, withs...)
+	}
+	for _, childMax := range maxes {
+		for k, n := range childMax {
+			max[k] += n
 		}
-
-		switch p.Value.(type) {
-		case int, []string:
-			switch p.Value.(type) {
-			case int:
-				increment = 1
-			case []string:
-				switch match.Value.(type) {
-				case string:
-					increment = []string{match.Value.(string)}
-				default:
-					increment = match.Value
-				}
-			}
-			if len(sameName) == 0 {
-				match.Value = increment
-				collectedMatch := make(PatternList, len(*collected), len(*collected)+1)
-				copy(collectedMatch, *collected)
-				collectedMatch = append(collectedMatch, match)
-				return true, &leftAlt, &collectedMatch
-			}
-			switch sameName[0].Value.(type) {
-			case int:
-				sameName[0].Value = sameName[0].Value.(int) + increment.(int)
-			case []string:
-				sameName[0].Value = append(sameName[0].Value.([]string), increment.([]string)...)
+	}
+	for i, childWith := range withs {
+		for e, n := range childWith {
+			// the leaf's own child holds it, the others add what they can
+			n += max[class[e]] - maxes[i][class[e]]
+			if n > with[e] {
+				with[e] = n
 			}
-			return true, &leftAlt, collected
 		}
-		collectedMatch := make(PatternList, len(*collected), len(*collected)+1)
-		copy(collectedMatch, *collected)
-		collectedMatch = append(collectedMatch, match)
-		return true, &leftAlt, &collectedMatch
 	}
-	panic("unmatched type")
+	return max, with
 }
 
 func (p *Pattern) singleMatch(left *PatternList) (int, *Pattern) {
@@ -417,65 +455,6 @@ func (p *Pattern) String() string {
 	panic("unmatched type")
 }
 
-func (p *Pattern) transform() *Pattern {
-	/*
-		Expand pattern into an (almost) equivalent one, but with single Either.
-
-		Example: ((-a | -b) (-c | -d)) => (-a -c | -a -d | -b -c | -b -d)
-		Quirks: [-a] => (-a), (-a...) => (-a -a)
-	*/
-	result := []PatternList{}
-	groups := []PatternList{PatternList{p}}
-	parents := patternRequired +
-		patternOptionAL +
-		patternOptionSSHORTCUT +
-		patternEither +
-		patternOneOrMore
-	for len(groups) > 0 {
-		children := groups[0]
-		groups = groups[1:]
-		var child *Pattern
-		for _, c := range children {
-			if c.T&parents != 0 {
-				child = c
-				break
-			}
-		}
-		if child != nil {
-			children.remove(child)
-			if child.T&patternEither != 0 {
-				for _, c := range child.Children {
-					r := PatternList{}
-					r = append(r, c)
-					r = append(r, children...)
-					groups = append(groups, r)
-				}
-			} else if child.T&patternOneOrMore != 0 {
-				r := PatternList{}
-				r = append(r, child.Children.double()...)
-				r = append(r, children...)
-				groups = append(groups, r)
-			} else {
-				r := PatternList{}
-				r = append(r, child.Children...)
-				r = append(r, children...)
-				groups = append(groups, r)
-			}
-		} else {
-			result = append(result, children)
-		}
-	}
-	either := PatternList{}
-	for _, e := range result {
-		either = append(either, newRequired(e...))
-	}
-	return newEither(either...)
-}
-
-func (p *Pattern) eq(other *Pattern) bool {
-	return reflect.DeepEqual(p, other)
-}
-
 func (pl PatternList) unique() PatternList {
 	table := make(map[string]bool)
 	result := PatternList{}
@@ -490,17 +469,17 @@ func (pl PatternList) unique() PatternList {
 
 func (pl PatternList) index(p *Pattern) (int, error) {
 	for i, c := range pl {
-		if c.eq(p) {
+		if c.Equal(p) {
 			return i, nil
 		}
 	}
-	return -1, newError("%s not in list", p)
+	return -1, newLanguageError("%s not in list", p)
 }
 
 func (pl PatternList) count(p *Pattern) int {
 	count := 0
 	for _, c := range pl {
-		if c.eq(p) {
+		if c.Equal(p) {
 			count++
 		}
 	}
@@ -515,7 +494,7 @@ func (pl PatternList) diff(l PatternList) PatternList {
 		if v != nil {
 			match := false
 			for i, w := range lAlt {
-				if w.eq(v) {
+				if w.Equal(v) {
 					match = true
 					lAlt[i] = nil
 					break
@@ -529,20 +508,8 @@ func (pl PatternList) diff(l PatternList) PatternList {
 	return result
 }
 
-func (pl PatternList) double() PatternList {
-	l := len(pl)
-	result := make(PatternList, l*2)
-	copy(result, pl)
-	copy(result[l:2*l], pl)
-	return result
-}
-
-func (pl *PatternList) remove(p *Pattern) {
-	(*pl) = pl.diff(PatternList{p})
-}
-
-func (pl PatternList) dictionary() map[string]interface{} {
-	dict := make(map[string]interface{})
+func (pl PatternList) dictionary() Opts {
+	dict := make(Opts)
 	for _, a := range pl {
 		dict[a.Name] = a.Value
 	}
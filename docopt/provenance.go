@@ -0,0 +1,204 @@
+package docopt
+
+// SourceKind tells where a pattern node comes from.
+type SourceKind int
+
+const (
+	SourceUnknown SourceKind = iota
+	// SourceHelp is the help text of the command.
+	SourceHelp
+	// SourceCompletion is a shell completion script of the command.
+	SourceCompletion
+	// SourceManual is a correction made by hand.
+	SourceManual
+)
+
+func (k SourceKind) String() string {
+	switch k {
+	case SourceHelp:
+		return "help"
+	case SourceCompletion:
+		return "completion"
+	case SourceManual:
+		return "manual"
+	}
+	return "unknown"
+}
+
+// Provenance records where a pattern node comes from: the kind of source and
+// an identifier of it, such as the command whose help text was parsed or the
+// path of a completion script.
+type Provenance struct {
+	Kind   SourceKind
+	Source string
+}
+
+// MarkProvenance sets the provenance of every node of the pattern that has
+// none yet, so that nodes added from another source keep theirs.
+func (p *Pattern) MarkProvenance(prov Provenance) {
+	for _, node := range p.FlatFunc(func(*Pattern) bool { return true }) {
+		if node.Provenance.Kind == SourceUnknown {
+			node.Provenance = prov
+		}
+	}
+}
+
+// Merge returns a copy of p that keeps the manual corrections of saved, a
+// pattern of the same command from before p was parsed again, along with
+// the corrections it couldn't keep. The branches of saved with SourceManual
+// provenance, such as a group made an Either or a repeat, replace the nodes
+// of the copy holding the same leaves, provided those nodes hold nothing
+// else. The leaves of saved with SourceManual provenance hand their metadata
+// and provenance to the leaves of the same name in the copy. Everything else
+// comes from p.
+func (p *Pattern) Merge(saved *Pattern) (*Pattern, []Conflict) {
+	merged := p.clone()
+	var conflicts []Conflict
+	for _, branch := range saved.manualBranches() {
+		if reason := merged.graft(branch); reason != "" {
+			conflicts = append(conflicts, Conflict{branch, reason})
+		}
+	}
+
+	leaves := make(map[leafKey]bool)
+	for _, leaf := range merged.FlatFunc(func(p *Pattern) bool { return p.T&patternLeaf != 0 }) {
+		leaves[keyOf(leaf)] = true
+	}
+	manual := make(map[leafKey]*Pattern)
+	for _, leaf := range saved.FlatFunc(func(p *Pattern) bool { return p.T&patternLeaf != 0 }) {
+		if leaf.Provenance.Kind != SourceManual {
+			continue
+		}
+		if _, ok := manual[keyOf(leaf)]; !ok && !leaves[keyOf(leaf)] {
+			conflicts = append(conflicts, Conflict{leaf, leaf.Name + " isn't in the pattern any more"})
+		}
+		manual[keyOf(leaf)] = leaf
+	}
+	for _, leaf := range merged.FlatFunc(func(p *Pattern) bool { return p.T&patternLeaf != 0 }) {
+		if m, ok := manual[keyOf(leaf)]; ok {
+			leaf.Choices = nil
+			leaf.copyMetadata(m)
+			leaf.Provenance = m.Provenance
+		}
+	}
+	return merged, conflicts
+}
+
+// Conflict is a manual correction of a saved pattern that Merge couldn't
+// keep in the pattern parsed again, and why.
+type Conflict struct {
+	// Node is the node of the saved pattern corrected by hand
+	Node   *Pattern
+	Reason string
+}
+
+func (c Conflict) String() string {
+	return c.Node.String() + ": " + c.Reason
+}
+
+// manualBranches returns the branches of the pattern with SourceManual
+// provenance, but not those inside another one.
+func (p *Pattern) manualBranches() PatternList {
+	if p.T&patternBranch == 0 {
+		return nil
+	}
+	if p.Provenance.Kind == SourceManual {
+		return PatternList{p}
+	}
+	var result PatternList
+	for _, child := range p.Children {
+		result = append(result, child.manualBranches()...)
+	}
+	return result
+}
+
+// graft puts a copy of branch in place of the children of the node of p
+// that hold the leaves of branch, which must occur once in p and be all
+// those children hold. Otherwise it returns why it can't.
+func (p *Pattern) graft(branch *Pattern) string {
+	var paths []PatternList
+	for _, leaf := range branch.FlatFunc(func(p *Pattern) bool { return p.T&patternLeaf != 0 }) {
+		found := p.pathsTo(keyOf(leaf), nil)
+		switch {
+		case len(found) == 0:
+			return leaf.Name + " isn't in the pattern any more"
+		case len(found) > 1:
+			return leaf.Name + " is in more than one place of the pattern"
+		}
+		paths = append(paths, found[0])
+	}
+	if len(paths) == 0 {
+		return "it holds no leaves"
+	}
+	// the deepest branch on the way to every leaf
+	depth := 0
+	for ; depth < len(paths[0])-1; depth++ {
+		same := true
+		for _, path := range paths[1:] {
+			same = same && depth < len(path)-1 && path[depth+1] == paths[0][depth+1]
+		}
+		if !same {
+			break
+		}
+	}
+	parent := paths[0][depth]
+	if parent.T&patternBranch == 0 {
+		// a single leaf, held by the branch above it
+		depth--
+		parent = paths[0][depth]
+	}
+	held := make(map[*Pattern]bool)
+	for _, path := range paths {
+		held[path[depth+1]] = true
+	}
+	want := make(map[leafKey]bool)
+	for _, path := range paths {
+		want[keyOf(path[len(path)-1])] = true
+	}
+	children := PatternList{}
+	placed := false
+	for _, child := range parent.Children {
+		if !held[child] {
+			children = append(children, child)
+			continue
+		}
+		for _, leaf := range child.FlatFunc(func(p *Pattern) bool { return p.T&patternLeaf != 0 }) {
+			if !want[keyOf(leaf)] {
+				return "the pattern groups its leaves with " + leaf.Name
+			}
+		}
+		if !placed {
+			children = append(children, branch.clone())
+			placed = true
+		}
+	}
+	parent.Children = children
+	return ""
+}
+
+// pathsTo returns the paths from p, through its branches, to every leaf
+// that is key, each path starting with the nodes in path.
+func (p *Pattern) pathsTo(key leafKey, path PatternList) []PatternList {
+	path = append(path[:len(path):len(path)], p)
+	if p.T&patternLeaf != 0 {
+		if keyOf(p) == key {
+			return []PatternList{path}
+		}
+		return nil
+	}
+	var result []PatternList
+	for _, child := range p.Children {
+		result = append(result, child.pathsTo(key, path)...)
+	}
+	return result
+}
+
+// leafKey tells leaves apart across patterns parsed from different texts.
+type leafKey struct {
+	t    patternType
+	name string
+}
+
+func keyOf(leaf *Pattern) leafKey {
+	return leafKey{leaf.T, leaf.Name}
+}
@@ -0,0 +1,107 @@
+package docopt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	saved, err := ParsePattern("Usage: prog [-v] [--out=<f>] <x>\n\nOptions:\n  -v  Talk.\n  --out=<f>  Output.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved.MarkProvenance(Provenance{SourceHelp, "prog"})
+	// corrected by hand
+	out := saved.FindOption("--out")
+	out.Choices = []string{"a.txt", "b.txt"}
+	out.Description = "Output file."
+	out.Provenance = Provenance{SourceManual, "user"}
+
+	fresh, err := ParsePattern("Usage: prog [-v] [--out=<f>] [-q] <x>\n\nOptions:\n  -v  Talk more.\n  --out=<f>  Output.\n  -q  Quiet.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh.MarkProvenance(Provenance{SourceHelp, "prog"})
+	merged, conflicts := fresh.Merge(saved)
+	if len(conflicts) != 0 {
+		t.Errorf("result: %v", conflicts)
+	}
+
+	out = merged.FindOption("--out")
+	if out.Description != "Output file." || !reflect.DeepEqual(out.Choices, []string{"a.txt", "b.txt"}) ||
+		out.Provenance != (Provenance{SourceManual, "user"}) {
+		t.Errorf("result: %+v", out)
+	}
+	if v := merged.FindOption("-v"); v.Description != "Talk more." || v.Provenance.Kind != SourceHelp {
+		t.Errorf("result: %+v", v)
+	}
+	if merged.FindOption("-q") == nil {
+		t.Error("-q missing")
+	}
+	if fresh.FindOption("--out").Description != "Output." {
+		t.Error("fresh pattern changed")
+	}
+
+	gone, _ := ParsePattern("Usage: prog [-v] <x>")
+	if _, conflicts = gone.Merge(saved); len(conflicts) != 1 || conflicts[0].Node != saved.FindOption("--out") {
+		t.Errorf("result: %v expected: --out gone", conflicts)
+	}
+}
+
+func TestMergeStructure(t *testing.T) {
+	saved, err := ParsePattern("Usage: prog [-a] [-b] [-c] [-d] <x> <y>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved.MarkProvenance(Provenance{SourceHelp, "prog"})
+	// -a and -b made exclusive and <y> repeatable by hand, and a group of
+	// -c and <x>
+	line := saved.Children[0]
+	either := newOptional(newEither(saved.FindOption("-a"), saved.FindOption("-b")))
+	repeat := newOneOrMore(line.Children[5])
+	group := newRequired(saved.FindOption("-c"), line.Children[4])
+	line.Children = PatternList{either, group, line.Children[3], repeat}
+	for _, branch := range []*Pattern{either, repeat, group} {
+		branch.Provenance = Provenance{SourceManual, "user"}
+	}
+
+	fresh, err := ParsePattern("Usage: prog [-a] [-b] [-c] [-d] [-e] <x> <y>\n       prog -c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh.MarkProvenance(Provenance{SourceHelp, "prog"})
+	merged, conflicts := fresh.Merge(saved)
+
+	// -c is on both lines, there's no telling which one the group is of
+	if len(conflicts) != 1 || conflicts[0].Node != group {
+		t.Errorf("result: %v", conflicts)
+	}
+	expect, _ := ParsePattern("Usage: prog [-a | -b] [-c] [-d] [-e] <x> <y>...\n       prog -c")
+	if !merged.Equal(expect) {
+		t.Errorf("result: %v expected: %v", merged, expect)
+	}
+	opts, err := Match(merged, []string{"-b", "x", "y1", "y2"})
+	if err != nil || !reflect.DeepEqual(opts["<y>"], []string{"y1", "y2"}) || opts["-b"] != true {
+		t.Errorf("result: %v error: %v", opts, err)
+	}
+	if _, err = Match(merged, []string{"-a", "-b", "x", "y"}); err == nil {
+		t.Error("result: -a and -b together expected: a usage error")
+	}
+
+	for i, tt := range []struct {
+		usage, reason string
+	}{
+		{"Usage: prog [-a -z] [-b] [-c] [-d] <x> <y>", "the pattern groups its leaves with -z"},
+		{"Usage: prog [-a] [-c] [-d] <x> <y>", "-b isn't in the pattern any more"},
+	} {
+		fresh, _ = ParsePattern(tt.usage)
+		kept := fresh.clone()
+		_, conflicts = fresh.Merge(saved)
+		if len(conflicts) != 1 || conflicts[0].Node != either || conflicts[0].Reason != tt.reason {
+			t.Errorf("testcase: %d result: %v expected: %s", i, conflicts, tt.reason)
+		}
+		if !fresh.Equal(kept) {
+			t.Errorf("testcase: %d fresh pattern changed", i)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package docopt
+
+// FindOption returns the option leaf with the given long or short name, e.g.
+// "--verbose" or "-v", or nil if the pattern has no such option.
+func (p *Pattern) FindOption(longOrShort string) *Pattern {
+	for _, o := range p.Options() {
+		if o.Long == longOrShort || o.Short == longOrShort {
+			return o
+		}
+	}
+	return nil
+}
+
+// FindCommand returns the command leaf called name, or nil if the pattern
+// has no such command.
+func (p *Pattern) FindCommand(name string) *Pattern {
+	for _, c := range p.Commands() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Options returns the option leaves of the pattern, one per option name, in
+// the order they appear in the usage.
+func (p *Pattern) Options() PatternList {
+	return p.leaves(patternOption)
+}
+
+// Positionals returns the argument leaves of the pattern, one per argument
+// name, in the order they appear in the usage.
+func (p *Pattern) Positionals() PatternList {
+	return p.leaves(patternArgument)
+}
+
+// Commands returns the command leaves of the pattern, one per command name,
+// in the order they appear in the usage.
+func (p *Pattern) Commands() PatternList {
+	return p.leaves(patternCommand)
+}
+
+// StdinLeaves returns the leaves of the pattern that stand for standard
+// input, see Pattern.Stdin. A frontend can offer to feed stdin for them.
+func (p *Pattern) StdinLeaves() PatternList {
+	return p.FlatFunc(func(p *Pattern) bool { return p.Stdin }).uniqueNames()
+}
+
+// EnvLeaves returns the leaves of the pattern whose description names an
+// environment variable they can be set with, see Pattern.EnvVar.
+func (p *Pattern) EnvLeaves() PatternList {
+	return p.FlatFunc(func(p *Pattern) bool { return p.EnvVar != "" }).uniqueNames()
+}
+
+func (p *Pattern) leaves(t patternType) PatternList {
+	return p.FlatFunc(func(p *Pattern) bool { return p.T == t }).uniqueNames()
+}
+
+// uniqueNames returns the first pattern of every name in the list.
+func (pl PatternList) uniqueNames() PatternList {
+	seen := make(map[string]bool)
+	result := PatternList{}
+	for _, v := range pl {
+		if !seen[v.Name] {
+			seen[v.Name] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package docopt
+
+import "sort"
+
+// RequiredNames returns the names of the arguments, commands and options that
+// have to be supplied for any command line to match the pattern, in the order
+// they appear in the usage. A leaf is required when it's not nested in an
+// optional group and it's required in every branch of the enclosing eithers.
+func (p *Pattern) RequiredNames() []string {
+	required := p.requiredSet()
+	leaves, err := p.Flat(patternLeaf)
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for _, leaf := range leaves {
+		if required[leaf.Name] {
+			names = append(names, leaf.Name)
+			delete(required, leaf.Name)
+		}
+	}
+	// names left over can only come from leaves Flat doesn't reach, keep
+	// the result deterministic anyway
+	rest := []string{}
+	for name := range required {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// IsRequired reports whether the argument, command or option called name has
+// to be supplied for any command line to match the pattern.
+func (p *Pattern) IsRequired(name string) bool {
+	return p.requiredSet()[name]
+}
+
+func (p *Pattern) requiredSet() map[string]bool {
+	switch {
+	case p.T&patternLeaf != 0:
+		return map[string]bool{p.Name: true}
+	case p.T&(patternOptionAL|patternOptionSSHORTCUT) != 0:
+		return map[string]bool{}
+	case p.T&patternEither != 0:
+		var result map[string]bool
+		for _, child := range p.Children {
+			set := child.requiredSet()
+			if result == nil {
+				result = set
+				continue
+			}
+			for name := range result {
+				if !set[name] {
+					delete(result, name)
+				}
+			}
+		}
+		if result == nil {
+			result = map[string]bool{}
+		}
+		return result
+	}
+	// required and oneormore need all of their children
+	result := map[string]bool{}
+	for _, child := range p.Children {
+		for name := range child.requiredSet() {
+			result[name] = true
+		}
+	}
+	return result
+}
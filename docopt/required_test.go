@@ -0,0 +1,36 @@
+package docopt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequiredNames(t *testing.T) {
+	for i, c := range []struct {
+		usage  string
+		expect []string
+	}{
+		{"Usage: prog <a> [<b>]", []string{"<a>"}},
+		{"Usage: prog [-v] (--left | --right) CORRECTION FILE", []string{"CORRECTION", "FILE"}},
+		{"Usage: prog (-a | -a -b) <x>...", []string{"-a", "<x>"}},
+		{"Usage: prog ship new <name>\n       prog ship move <name> [--speed=<kn>]", []string{"ship", "<name>"}},
+		{"Usage: prog [options]\n\nOptions:\n  -q  Quiet.", []string{}},
+		{"Usage: prog [(-a <x>)]", []string{}},
+	} {
+		pat, err := ParsePattern(c.usage)
+		if err != nil {
+			t.Fatalf("testcase: %d parse err: %q", i, err)
+		}
+		if result := pat.RequiredNames(); !reflect.DeepEqual(result, c.expect) {
+			t.Errorf("testcase: %d result: %v expect: %v", i, result, c.expect)
+		}
+	}
+
+	pat, err := ParsePattern("Usage: prog (--left | --right) FILE [-v]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pat.IsRequired("FILE") || pat.IsRequired("--left") || pat.IsRequired("-v") {
+		t.Fail()
+	}
+}
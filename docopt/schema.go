@@ -0,0 +1,116 @@
+package docopt
+
+import "context"
+
+// JSONSchema is a JSON Schema (draft-07) document, or a subschema of one.
+// Only the keywords the exporter produces are modelled.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	MinItems             int                    `json:"minItems,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	Deprecated           bool                   `json:"deprecated,omitempty"`
+}
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// JSONSchema describes the values object matching returns for the pattern,
+// as in Match: one property per argument, command and option, typed after
+// what the leaf collects (booleans for flags and commands, integers for
+// counted ones, arrays for repeated ones and strings otherwise), with the
+// choices as enums and the leaves that must be supplied as required.
+func (p *Pattern) JSONSchema(title string) (*JSONSchema, error) {
+	// fixing the repeating arguments tells the leaves holding lists and
+	// counters apart, do it on a copy
+	fixed := p.clone()
+	if err := fixed.fix(context.Background()); err != nil {
+		return nil, err
+	}
+	leaves, err := fixed.Flat(patternDefault)
+	if err != nil {
+		return nil, err
+	}
+	required := p.requiredSet()
+
+	noAdditional := false
+	schema := &JSONSchema{
+		Schema:               jsonSchemaDraft,
+		Title:                title,
+		Type:                 "object",
+		Properties:           map[string]*JSONSchema{},
+		Required:             []string{},
+		AdditionalProperties: &noAdditional,
+	}
+	for _, leaf := range leaves.uniqueNames() {
+		schema.Properties[leaf.Name] = leaf.jsonSchema(required[leaf.Name])
+	}
+	for _, name := range p.RequiredNames() {
+		if _, ok := schema.Properties[name]; ok {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema, nil
+}
+
+func (p *Pattern) jsonSchema(required bool) *JSONSchema {
+	s := &JSONSchema{Description: p.Description, Deprecated: p.Deprecated}
+	switch p.Value.(type) {
+	case bool:
+		s.Type = "boolean"
+		s.Default = false
+	case int:
+		zero := 0
+		s.Type = "integer"
+		s.Minimum = &zero
+		if required {
+			one := 1
+			s.Minimum = &one
+		}
+	case []string:
+		s.Type = "array"
+		s.Items = &JSONSchema{Type: "string", Enum: enum(p.Choices, false)}
+		if required {
+			s.MinItems = 1
+		}
+	default:
+		if required {
+			s.Type = "string"
+		} else {
+			s.Type = []string{"string", "null"}
+		}
+		// an absent option is null, which the enum must admit as well
+		s.Enum = enum(p.Choices, !required)
+		if p.Default != "" {
+			s.Default = p.Default
+		}
+	}
+	if p.T == patternCommand {
+		// commands are true when given, there's no default worth showing
+		s.Default = nil
+	}
+	return s
+}
+
+// enum returns the choices as the values of an enum, null included if null,
+// or nil if there are no choices.
+func enum(choices []string, null bool) []interface{} {
+	if len(choices) == 0 {
+		return nil
+	}
+	values := make([]interface{}, 0, len(choices)+1)
+	for _, c := range choices {
+		values = append(values, c)
+	}
+	if null {
+		values = append(values, nil)
+	}
+	return values
+}
@@ -0,0 +1,86 @@
+package docopt
+
+import "encoding/json"
+
+// SchemaVersion is the version of the format MarshalPattern writes. It's
+// raised whenever the Pattern model changes in a way older readers would get
+// wrong, along with a migration from the previous version.
+const SchemaVersion = 1
+
+// patternDocument is the serialized form of a pattern.
+type patternDocument struct {
+	SchemaVersion int      `json:"schemaVersion"`
+	Pattern       *Pattern `json:"pattern"`
+}
+
+// migrations[v] turns a decoded document of schema version v into one of
+// version v+1.
+var migrations = []func(doc map[string]interface{}) map[string]interface{}{
+	// version 0 is a bare pattern, as encoding/json writes a *Pattern
+	func(doc map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"pattern": doc}
+	},
+}
+
+// MarshalPattern encodes the pattern as JSON, tagged with SchemaVersion, for
+// caches, profiles and anything else saved across gtoc versions.
+func MarshalPattern(p *Pattern) ([]byte, error) {
+	return json.Marshal(patternDocument{SchemaVersion, p})
+}
+
+// UnmarshalPattern decodes a pattern written by MarshalPattern, by any older
+// gtoc version, or by encoding/json before patterns carried a schema version.
+// Older documents are migrated to the current model first. Documents of a
+// newer version than SchemaVersion are refused. Documents that can't be
+// decoded are reported as a *ParseError.
+func UnmarshalPattern(data []byte) (*Pattern, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, &ParseError{"", err}
+	}
+	version := 0
+	if v, ok := doc["schemaVersion"].(float64); ok {
+		version = int(v)
+	}
+	if version < 0 || version > SchemaVersion {
+		return nil, &ParseError{"", newLanguageError("pattern has schema version %d, this gtoc reads up to %d", version, SchemaVersion)}
+	}
+	for _, migrate := range migrations[version:] {
+		doc = migrate(doc)
+	}
+	doc["schemaVersion"] = SchemaVersion
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var decoded patternDocument
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, &ParseError{"", err}
+	}
+	if decoded.Pattern == nil {
+		return nil, &ParseError{"", newLanguageError("no pattern")}
+	}
+	decoded.Pattern.restoreValues()
+	return decoded.Pattern, nil
+}
+
+// restoreValues gives back their types to the values JSON decodes as float64
+// and []interface{}.
+func (p *Pattern) restoreValues() {
+	switch v := p.Value.(type) {
+	case float64:
+		p.Value = int(v)
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, item := range v {
+			list[i], _ = item.(string)
+		}
+		p.Value = list
+	}
+	for _, child := range p.Children {
+		child.restoreValues()
+	}
+	for _, sub := range p.Subcommands {
+		sub.restoreValues()
+	}
+}
@@ -0,0 +1,45 @@
+package docopt
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMarshalPattern(t *testing.T) {
+	pat, err := ParsePattern(`Usage: prog [-v...] <file>... [--out=<f>]
+
+Options:
+  -v          Verbose.
+  --out=<f>   Output [default: out.txt].`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = pat.fix(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sub, _ := ParsePattern("Usage: prog sub <x>")
+	pat.AttachSubcommand("sub", sub)
+
+	data, err := MarshalPattern(pat)
+	if err != nil || !strings.HasPrefix(string(data), `{"schemaVersion":1,`) {
+		t.Fatalf("result: %s error: %v", data, err)
+	}
+	loaded, err := UnmarshalPattern(data)
+	if err != nil || !loaded.Equal(pat) || !loaded.Subcommands["sub"].Equal(sub) {
+		t.Errorf("result: %v error: %v", loaded, err)
+	}
+
+	// patterns saved before they carried a schema version
+	bare, _ := json.Marshal(pat)
+	if loaded, err = UnmarshalPattern(bare); err != nil || !loaded.Equal(pat) {
+		t.Errorf("result: %v error: %v", loaded, err)
+	}
+
+	for i, data := range []string{`{"schemaVersion":2,"pattern":{}}`, `{"schemaVersion":1}`, `[`} {
+		if _, err := UnmarshalPattern([]byte(data)); err == nil {
+			t.Errorf("testcase: %d expected an error", i)
+		}
+	}
+}
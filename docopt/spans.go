@@ -0,0 +1,256 @@
+package docopt
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Span is a range of bytes in the help text a pattern was parsed from, with
+// the line and column it starts at, both counted from 1.
+type Span struct {
+	Start, End   int
+	Line, Column int
+}
+
+// IsZero reports whether the span is unknown.
+func (s Span) IsZero() bool {
+	return s == Span{}
+}
+
+func newSpan(doc string, start, end int) Span {
+	line := strings.Count(doc[:start], "\n") + 1
+	column := start - strings.LastIndex(doc[:start], "\n")
+	return Span{start, end, line, column}
+}
+
+// join returns the smallest span holding both s and other.
+func (s Span) join(other Span) Span {
+	if s.IsZero() {
+		return other
+	}
+	if other.IsZero() {
+		return s
+	}
+	if other.Start < s.Start {
+		s.Start, s.Line, s.Column = other.Start, other.Line, other.Column
+	}
+	if other.End > s.End {
+		s.End = other.End
+	}
+	return s
+}
+
+// sectionSpans returns where the sections parseSection finds start and end
+// in source, without their surrounding whitespace.
+func sectionSpans(name, source string) [][2]int {
+	p := regexp.MustCompile(`(?im)^([^\n]*` + name + `[^\n]*\n?(?:[ \t].*?(?:\n|$))*)`)
+	var spans [][2]int
+	for _, loc := range p.FindAllStringIndex(source, -1) {
+		s := source[loc[0]:loc[1]]
+		start := loc[0] + len(s) - len(strings.TrimLeft(s, " \t\r\n"))
+		end := loc[0] + len(strings.TrimRight(s, " \t\r\n"))
+		spans = append(spans, [2]int{start, end})
+	}
+	return spans
+}
+
+// locate sets the spans of the pattern parsed from doc: every leaf gets the
+// usage token it comes from, every branch the range of its leaves, and the
+// leaves described in the options, arguments or commands sections the entry
+// describing them.
+func (p *Pattern) locate(doc string) {
+	usage := sectionSpans("usage:", doc)
+	if len(usage) != 1 {
+		return
+	}
+	tokens := usageTokenSpans(doc, usage[0][0], usage[0][1])
+	for _, leaf := range p.usageLeaves() {
+		if len(tokens) == 0 || !tokens[0].produces(leaf) {
+			// the tree doesn't read as expected, rather leave the rest
+			// unknown than point at the wrong text
+			break
+		}
+		tok := tokens[0]
+		leaf.Span = newSpan(doc, tok.start, tok.end)
+		tokens = tokens[1:]
+		if tok.shorts != "" {
+			// the short options stacked in one token share it
+			tokens = append([]usageToken{tok.rest(leaf)}, tokens...)
+			if tokens[0].shorts == "" {
+				tokens = tokens[1:]
+			}
+		}
+		if leaf.T == patternOption && leaf.Argcount > 0 && !tok.hasValue(leaf) && len(tokens) > 0 {
+			// the option's value is the next token
+			leaf.Span = leaf.Span.join(newSpan(doc, tokens[0].start, tokens[0].end))
+			tokens = tokens[1:]
+		}
+	}
+	p.locateBranches()
+	p.locateDescriptions(doc)
+}
+
+// usageLeaves returns the leaves written in the usage, in order: those that
+// the options shortcut stands for are left out, the shortcut itself isn't.
+func (p *Pattern) usageLeaves() PatternList {
+	if p.T&(patternLeaf|patternOptionSSHORTCUT) != 0 {
+		return PatternList{p}
+	}
+	leaves := PatternList{}
+	for _, child := range p.Children {
+		leaves = append(leaves, child.usageLeaves()...)
+	}
+	return leaves
+}
+
+// locateBranches sets the span of every branch to the range of its leaves.
+func (p *Pattern) locateBranches() Span {
+	if p.T&patternBranch == 0 || p.T&patternOptionSSHORTCUT != 0 {
+		return p.Span
+	}
+	var span Span
+	for _, child := range p.Children {
+		span = span.join(child.locateBranches())
+	}
+	p.Span = span
+	return span
+}
+
+// locateDescriptions sets the description spans of the leaves from the
+// entries of the options, arguments and commands sections.
+func (p *Pattern) locateDescriptions(doc string) {
+	entries := make(map[string]Span)
+	entry := regexp.MustCompile(`\n[ \t]*-\S`)
+	for _, section := range sectionSpans("options:", doc) {
+		// entries start at the option they describe and end before the next
+		start := section[0] + strings.Index(doc[section[0]:section[1]], ":") + 1
+		text := doc[start:section[1]]
+		// the offsets are one past those in text, which is matched with a
+		// newline in front so that the first entry is found too
+		locs := entry.FindAllStringIndex("\n"+text, -1)
+		for i, loc := range locs {
+			begin, end := loc[1]-3, len(text)
+			if i+1 < len(locs) {
+				end = locs[i+1][0] - 1
+			}
+			description := strings.TrimRight(text[begin:end], " \t\r\n")
+			span := newSpan(doc, start+begin, start+begin+len(description))
+			opt := parseOption(description)
+			for _, name := range []string{opt.Short, opt.Long} {
+				if name != "" {
+					entries[name] = span
+				}
+			}
+		}
+	}
+	line := regexp.MustCompile(`(?m)^[ \t]*(\S+)(?:[ \t]{2,}|\t).*$`)
+	for _, name := range []string{"arguments:", "commands:"} {
+		for _, section := range sectionSpans(name, doc) {
+			start := section[0] + strings.Index(doc[section[0]:section[1]], ":") + 1
+			for _, loc := range line.FindAllStringSubmatchIndex(doc[start:section[1]], -1) {
+				if leaf := doc[start+loc[2] : start+loc[3]]; !strings.HasPrefix(leaf, "-") {
+					entries[leaf] = newSpan(doc, start+loc[2], start+loc[1])
+				}
+			}
+		}
+	}
+	for _, leaf := range p.FlatFunc(func(p *Pattern) bool { return p.T&patternLeaf != 0 }) {
+		if leaf.T == patternOption {
+			if span, ok := entries[leaf.Short]; ok && leaf.Short != "" {
+				leaf.DescriptionSpan = span
+			} else if span, ok := entries[leaf.Long]; ok && leaf.Long != "" {
+				leaf.DescriptionSpan = span
+			}
+		} else if span, ok := entries[leaf.Name]; ok {
+			leaf.DescriptionSpan = span
+		}
+	}
+}
+
+// usageToken is a token of the usage section that stands for a leaf, such as
+// "<file>", "--out=<f>", "-vq" or "options".
+type usageToken struct {
+	text       string
+	start, end int
+	// shorts are the stacked short options not yet located
+	shorts string
+}
+
+// usageTokenSpans splits the usage section doc[start:end] the way the usage
+// is tokenized for parsing, and returns the tokens that stand for leaves: not
+// the brackets, pipes and ellipses, nor the program name.
+func usageTokenSpans(doc string, start, end int) []usageToken {
+	start += strings.Index(doc[start:end], ":") + 1
+	var tokens []usageToken
+	var prog string
+	for i := start; i < end; {
+		c := doc[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case strings.IndexByte("[]()|", c) >= 0:
+			i++
+			continue
+		case strings.HasPrefix(doc[i:end], "..."):
+			i += 3
+			continue
+		}
+		j := i
+		for j < end && strings.IndexByte(" \t\r\n[]()|", doc[j]) < 0 && !strings.HasPrefix(doc[j:end], "...") {
+			if doc[j] == '<' {
+				if k := strings.IndexByte(doc[j:end], '>'); k >= 0 {
+					j += k
+				}
+			}
+			j++
+		}
+		text := doc[i:j]
+		switch {
+		case prog == "":
+			prog = text
+		case text != prog:
+			tok := usageToken{text: text, start: i, end: j}
+			if strings.HasPrefix(text, "-") && !strings.HasPrefix(text, "--") && text != "-" {
+				tok.shorts = text[1:]
+			}
+			tokens = append(tokens, tok)
+		}
+		i = j
+	}
+	return tokens
+}
+
+// produces reports whether the token is where leaf comes from.
+func (t usageToken) produces(leaf *Pattern) bool {
+	switch {
+	case leaf.T == patternOptionSSHORTCUT:
+		return t.text == "options"
+	case t.shorts != "":
+		return leaf.T == patternOption && leaf.Short == "-"+t.shorts[:1]
+	case leaf.T == patternOption:
+		name, _, _ := stringPartition(t.text, "=")
+		return leaf.Long == name
+	}
+	return leaf.Name == t.text
+}
+
+// rest returns the token with the short option of leaf located, and its
+// value too if it's stuck to the option.
+func (t usageToken) rest(leaf *Pattern) usageToken {
+	if leaf.Argcount > 0 {
+		t.shorts = ""
+	} else {
+		t.shorts = t.shorts[1:]
+	}
+	return t
+}
+
+// hasValue reports whether the token holds the value of the option leaf,
+// as in "--out=<file>" or "-o<file>".
+func (t usageToken) hasValue(leaf *Pattern) bool {
+	if strings.HasPrefix(t.text, "--") {
+		return strings.Contains(t.text, "=")
+	}
+	return strings.Index(t.text, leaf.Short[1:]) < len(t.text)-1
+}
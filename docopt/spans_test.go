@@ -0,0 +1,54 @@
+package docopt
+
+import "testing"
+
+func TestSpans(t *testing.T) {
+	doc := `Usage:
+  prog cp [-vf] [--out=<dir>] <src>... -n NUM
+  prog rm [options] <src>
+
+Options:
+  -v, --verbose  Talk more.
+  -f             Force.
+  --out=<dir>    Where to.
+  -n NUM         How many.
+
+Arguments:
+  <src>  The sources.`
+	pat, err := ParsePattern(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := func(s Span) string { return doc[s.Start:s.End] }
+	for i, c := range []struct {
+		leaf        *Pattern
+		span        string
+		line        int
+		description string
+	}{
+		{pat.FindCommand("cp"), "cp", 2, ""},
+		{pat.FindOption("-v"), "-vf", 2, "-v, --verbose  Talk more."},
+		{pat.FindOption("-f"), "-vf", 2, "-f             Force."},
+		{pat.FindOption("--out"), "--out=<dir>", 2, "--out=<dir>    Where to."},
+		{pat.FindOption("-n"), "-n NUM", 2, "-n NUM         How many."},
+		{pat.Positionals()[0], "<src>", 2, "<src>  The sources."},
+	} {
+		if c.leaf == nil {
+			t.Errorf("testcase: %d leaf not found", i)
+			continue
+		}
+		if text(c.leaf.Span) != c.span || c.leaf.Span.Line != c.line {
+			t.Errorf("testcase: %d result: %q %d expect: %q %d", i, text(c.leaf.Span), c.leaf.Span.Line, c.span, c.line)
+		}
+		if text(c.leaf.DescriptionSpan) != c.description {
+			t.Errorf("testcase: %d result: %q expect: %q", i, text(c.leaf.DescriptionSpan), c.description)
+		}
+	}
+	lines := pat.Children[0].Children
+	if text(lines[0].Span) != "cp [-vf] [--out=<dir>] <src>... -n NUM" || lines[0].Span.Column != 8 {
+		t.Errorf("result: %q %+v", text(lines[0].Span), lines[0].Span)
+	}
+	if text(lines[1].Span) != "rm [options] <src>" {
+		t.Errorf("result: %q", text(lines[1].Span))
+	}
+}
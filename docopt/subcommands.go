@@ -0,0 +1,134 @@
+package docopt
+
+// AttachSubcommand attaches sub, the pattern parsed from the help text of the
+// subcommand name, to p, so that Resolve can follow the command into it. Any
+// pattern attached under the same name before is replaced.
+func (p *Pattern) AttachSubcommand(name string, sub *Pattern) {
+	if p.Subcommands == nil {
+		p.Subcommands = make(map[string]*Pattern)
+	}
+	p.Subcommands[name] = sub
+}
+
+// ListedSubcommands returns the commands listed in the "commands:" sections
+// of doc, the help text p was parsed from, that its usage doesn't name, in
+// the order they're listed. Those are the subcommands of a program whose
+// usage takes the command as an argument, such as git, whose patterns can be
+// parsed from their own help texts and attached with AttachSubcommand.
+func (p *Pattern) ListedSubcommands(doc string) []string {
+	names, _ := parseLeafDescriptions("commands:", doc)
+	listed := []string{}
+	for _, name := range names {
+		if p.FindCommand(name) == nil {
+			listed = append(listed, name)
+		}
+	}
+	return listed
+}
+
+// Resolve returns the grammar in effect once the commands of path are
+// chosen, path being the program name followed by the commands, such as
+// ["git", "remote", "add"]. It's made of the usage lines that fit the
+// commands, taken from the pattern the last of them is attached to, and the
+// options of the lines that lead to every attached subcommand on the way,
+// which are inherited as global options. The result holds no subcommands and
+// can be given to Match, ToArgv and the rest like any parsed pattern. A
+// command that no usage line fits, or an empty path, is reported as a
+// *UsageError.
+func (p *Pattern) Resolve(path []string) (*Pattern, error) {
+	if len(path) == 0 {
+		return nil, newUserError("no program name in the command path")
+	}
+	cur := p
+	lines := p.lineGroups()
+	consumed := make(map[string]bool)
+	var inherited PatternList
+	for _, name := range path[1:] {
+		if fit := linesWithCommand(lines, name); len(fit) > 0 {
+			lines = fit
+			consumed[name] = true
+			continue
+		}
+		sub, ok := cur.Subcommands[name]
+		if !ok {
+			return nil, newUserError("unknown command: %s", name)
+		}
+		inherited = append(inherited, dispatchingLines(lines, consumed).options()...)
+		cur, lines = sub, sub.lineGroups()
+		consumed = make(map[string]bool)
+		// the help text of a subcommand usually names it in its usage too
+		if fit := linesWithCommand(lines, name); len(fit) > 0 {
+			lines = fit
+			consumed[name] = true
+		}
+	}
+
+	global := PatternList{}
+	for _, o := range inherited.uniqueNames() {
+		if cur.FindOption(o.Name) == nil {
+			global = append(global, o.clone())
+		}
+	}
+	resolved := PatternList{}
+	for _, line := range lines {
+		line = line.clone()
+		if len(global) > 0 {
+			line.Children = append(line.Children, newOptional(global...))
+		}
+		resolved = append(resolved, line)
+	}
+	if len(resolved) == 1 {
+		return newRequired(resolved...), nil
+	}
+	return newRequired(newEither(resolved...)), nil
+}
+
+// lineGroups returns the usage lines of the pattern, each one as a group.
+func (p *Pattern) lineGroups() PatternList {
+	groups := PatternList{}
+	for _, line := range p.usageLines() {
+		groups = append(groups, newRequired(line...))
+	}
+	return groups
+}
+
+func linesWithCommand(lines PatternList, name string) PatternList {
+	fit := PatternList{}
+	for _, line := range lines {
+		if line.FindCommand(name) != nil {
+			fit = append(fit, line)
+		}
+	}
+	return fit
+}
+
+// dispatchingLines returns the lines that hand over to a subcommand: those
+// with an argument to take its name and no commands but the consumed ones.
+// All of lines are returned if there are none such.
+func dispatchingLines(lines PatternList, consumed map[string]bool) PatternList {
+	dispatching := PatternList{}
+	for _, line := range lines {
+		other := false
+		for _, cmd := range line.Commands() {
+			if !consumed[cmd.Name] {
+				other = true
+			}
+		}
+		if !other && len(line.FlatFunc(func(p *Pattern) bool { return p.T == patternArgument })) > 0 {
+			dispatching = append(dispatching, line)
+		}
+	}
+	if len(dispatching) == 0 {
+		return lines
+	}
+	return dispatching
+}
+
+// options returns the options in the patterns of the list.
+func (pl PatternList) options() PatternList {
+	options := PatternList{}
+	for _, p := range pl {
+		options = append(options, p.Options()...)
+	}
+	return options
+}
@@ -0,0 +1,94 @@
+package docopt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	git, err := ParsePattern(`Usage:
+  git [--version] [-C <path>] <command> [<args>...]
+  git help [<topic>]
+
+Options:
+  -C <path>  Run as if started in <path>.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := ParsePattern(`Usage:
+  git remote [-v]
+  git remote add [-f] <name> <url>
+  git remote remove <name>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	git.AttachSubcommand("remote", remote)
+
+	pat, err := git.Resolve([]string{"git", "remote", "add"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pat.Subcommands != nil || pat.FindCommand("remove") != nil {
+		t.Errorf("result: %v", pat)
+	}
+	opts, err := Match(pat, []string{"remote", "-C", "repo", "add", "-f", "origin", "url"})
+	expect := Opts{"remote": true, "add": true, "-f": true, "<name>": "origin", "<url>": "url",
+		"--version": false, "-C": "repo"}
+	if err != nil || !reflect.DeepEqual(opts, expect) {
+		t.Errorf("result: %v error: %v", opts, err)
+	}
+
+	pat, err = git.Resolve([]string{"git", "help"})
+	if err != nil || pat.FindCommand("help") == nil || pat.FindOption("-C") != nil {
+		t.Errorf("result: %v error: %v", pat, err)
+	}
+
+	pat, err = git.Resolve([]string{"git"})
+	if err != nil || !pat.Equal(git) {
+		t.Errorf("result: %v error: %v", pat, err)
+	}
+
+	_, err = git.Resolve([]string{"git", "remote", "rename"})
+	if _, ok := err.(*UserError); !ok {
+		t.Errorf("result: %#v", err)
+	}
+	_, err = git.Resolve([]string{"git", "push"})
+	if _, ok := err.(*UserError); !ok {
+		t.Errorf("result: %#v", err)
+	}
+
+	clone := git.clone()
+	if clone.Subcommands["remote"] == remote || !clone.Subcommands["remote"].Equal(remote) {
+		t.Errorf("result: %v", clone.Subcommands)
+	}
+}
+
+func TestListedSubcommands(t *testing.T) {
+	doc := `Usage:
+  tool [-q] <command> [<args>...]
+  tool help [<topic>]
+
+Commands:
+  build    Build the project,
+           with the default target.
+  help     Show the help of a command.
+  test     Run the tests.
+  build    Listed twice.
+
+Options:
+  -q  Quiet.`
+	pat, err := ParsePattern(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, expected := pat.ListedSubcommands(doc), []string{"build", "test"}; !reflect.DeepEqual(result, expected) {
+		t.Errorf("result: %v expected: %v", result, expected)
+	}
+	pat, err = ParsePattern("Usage: tool <file>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := pat.ListedSubcommands("Usage: tool <file>"); len(result) != 0 {
+		t.Errorf("result: %v expected: none", result)
+	}
+}
@@ -0,0 +1,114 @@
+package docopt
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Testcase is a case of the language agnostic testcases.docopt format shared
+// by the docopt implementations: a doc, a command line, and the values it
+// parses into or a usage error.
+type Testcase struct {
+	ID   int
+	Doc  string
+	Prog string
+	Argv []string
+	// Expect holds the expected values, it is nil if UserError is set
+	Expect    Opts
+	UserError bool
+}
+
+// TestcaseResult is the outcome of running a Testcase.
+type TestcaseResult struct {
+	Testcase
+	Result Opts
+	Err    error
+	Passed bool
+}
+
+// ParseTestcases parses the cases of a file in the testcases.docopt format.
+// Every doc is written as r"""...""" and followed by its cases, each one a
+// "$ prog args" line and the JSON object of the expected values, or the JSON
+// string "user-error". Comments start with # and run to the end of the line.
+func ParseTestcases(raw []byte) ([]Testcase, error) {
+	var res []Testcase
+	raw = regexp.MustCompile("#.*").ReplaceAll(raw, []byte(""))
+	raw = bytes.TrimSpace(raw)
+	if bytes.HasPrefix(raw, []byte(`"""`)) {
+		raw = raw[3:]
+	}
+
+	id := 0
+	for _, fixture := range bytes.Split(raw, []byte(`r"""`)) {
+		doc, _, body := stringPartition(string(fixture), `"""`)
+		for _, cas := range strings.Split(body, "$")[1:] {
+			argvString, _, expectString := stringPartition(strings.TrimSpace(cas), "\n")
+			prog, _, argvString := stringPartition(strings.TrimSpace(argvString), " ")
+			argv := []string{}
+			if len(argvString) > 0 {
+				argv = strings.Fields(argvString)
+			}
+			var expectUntyped interface{}
+			if err := json.Unmarshal([]byte(expectString), &expectUntyped); err != nil {
+				return nil, newLanguageError("testcase %d: %s", id, err)
+			}
+			switch expect := expectUntyped.(type) {
+			case string: // user-error
+				res = append(res, Testcase{id, doc, prog, argv, nil, true})
+			case map[string]interface{}:
+				// convert []interface{} values to []string
+				// convert float64 values to int
+				for k, vUntyped := range expect {
+					switch v := vUntyped.(type) {
+					case []interface{}:
+						itemList := make([]string, len(v))
+						for i, itemUntyped := range v {
+							if item, ok := itemUntyped.(string); ok {
+								itemList[i] = item
+							}
+						}
+						expect[k] = itemList
+					case float64:
+						expect[k] = int(v)
+					}
+				}
+				res = append(res, Testcase{id, doc, prog, argv, expect, false})
+			default:
+				return nil, newLanguageError("testcase %d: unhandled json data type", id)
+			}
+			id++
+		}
+	}
+	return res, nil
+}
+
+// Run parses the case's argv with its doc, the way ParseArgs would but
+// without printing help or exiting, and compares the outcome with the
+// expected one.
+func (c Testcase) Run() TestcaseResult {
+	p := &Parser{HelpHandler: NoHelpHandler}
+	result, err := p.ParseArgs(c.Doc, c.Argv, "")
+	return c.result(result, err)
+}
+
+// RunPattern matches the case's argv against pat instead of its own doc, so
+// the cases written for a tool can be checked against the pattern gtoc made
+// of its help text.
+func (c Testcase) RunPattern(pat *Pattern) TestcaseResult {
+	result, err := Match(pat, c.Argv)
+	return c.result(result, err)
+}
+
+func (c Testcase) result(result Opts, err error) TestcaseResult {
+	r := TestcaseResult{Testcase: c, Result: result, Err: err}
+	_, userError := err.(*UserError)
+	if c.UserError {
+		r.Passed = userError
+	} else {
+		r.Passed = err == nil && reflect.DeepEqual(c.Expect, result)
+	}
+	return r
+}
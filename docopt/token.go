@@ -1,6 +1,7 @@
 package docopt
 
 import (
+	"context"
 	"regexp"
 	"strings"
 	"unicode"
@@ -10,17 +11,20 @@ type tokenList struct {
 	tokens    []string
 	errorFunc func(string, ...interface{}) error
 	err       errorType
+	// ctx, if set, cancels parsing
+	ctx context.Context
+	// limits bounds the groups parsed, depth is how deep they nest so far
+	limits Limits
+	depth  int
 }
 type token string
 
 func newTokenList(source []string, err errorType) *tokenList {
-	errorFunc := newError
+	errorFunc := newLanguageError
 	if err == errorUser {
 		errorFunc = newUserError
-	} else if err == errorLanguage {
-		errorFunc = newLanguageError
 	}
-	return &tokenList{source, errorFunc, err}
+	return &tokenList{tokens: source, errorFunc: errorFunc, err: err}
 }
 
 func tokenListFromString(source string) *tokenList {
@@ -84,6 +88,7 @@ func (t *token) isUpper() bool {
 	}
 	return isStringUppercase(string(*t))
 }
+
 func (t *token) String() string {
 	if t == nil {
 		return ""
@@ -98,6 +103,14 @@ func (tl *tokenList) current() *token {
 	return nil
 }
 
+// ctxErr returns the error of the token list's context, if it's done.
+func (tl *tokenList) ctxErr() error {
+	if tl.ctx == nil {
+		return nil
+	}
+	return tl.ctx.Err()
+}
+
 func (tl *tokenList) length() int {
 	return len(tl.tokens)
 }
@@ -124,3 +137,133 @@ func isStringUppercase(s string) bool {
 	}
 	return false
 }
+
+// TokenKind classifies a token the way the parser treats it.
+type TokenKind int
+
+const (
+	TokenCommand TokenKind = iota
+	TokenArgument
+	TokenLongOption
+	TokenShortOptions
+	TokenDoubleDash
+	TokenOptionsShortcut
+	TokenOpen
+	TokenClose
+	TokenPipe
+	TokenEllipsis
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenCommand:
+		return "command"
+	case TokenArgument:
+		return "argument"
+	case TokenLongOption:
+		return "long"
+	case TokenShortOptions:
+		return "shorts"
+	case TokenDoubleDash:
+		return "doubledash"
+	case TokenOptionsShortcut:
+		return "optionsshortcut"
+	case TokenOpen:
+		return "open"
+	case TokenClose:
+		return "close"
+	case TokenPipe:
+		return "pipe"
+	case TokenEllipsis:
+		return "ellipsis"
+	}
+	return ""
+}
+
+// Token is a lexed piece of a usage pattern or of an argument vector.
+type Token struct {
+	Text string
+	Kind TokenKind
+}
+
+// Tokens is the output of the lexer, in the order the parser consumes it.
+type Tokens []Token
+
+// Texts returns the text of every token.
+func (ts Tokens) Texts() []string {
+	texts := make([]string, len(ts))
+	for i, t := range ts {
+		texts[i] = t.Text
+	}
+	return texts
+}
+
+// Tokenize lexes a usage pattern into the tokens the pattern parser reads. A
+// whole usage section ("Usage: prog ...") is first rewritten the way the
+// parser does it: the program name is dropped and each usage line becomes a
+// parenthesized alternative.
+func Tokenize(usage string) (Tokens, error) {
+	if len(parseSection("usage:", usage)) > 0 {
+		formal, err := formalUsage(strings.TrimSpace(usage))
+		if err != nil {
+			return nil, err
+		}
+		usage = formal
+	}
+	tokens := Tokens{}
+	for _, s := range tokenListFromPattern(usage).tokens {
+		tokens = append(tokens, Token{s, patternTokenKind(token(s))})
+	}
+	return tokens, nil
+}
+
+// TokenizeArgv lexes an argument vector the way the matcher reads it.
+// Everything after "--" is an argument, and so are negative numbers such as
+// "-5", which the matcher only reads as options for patterns with an option
+// named after a digit, see isNegativeNumber.
+func TokenizeArgv(argv []string) Tokens {
+	tokens := Tokens{}
+	for i, s := range argv {
+		t := token(s)
+		switch {
+		case t.eq("--"):
+			tokens = append(tokens, Token{s, TokenDoubleDash})
+			for _, rest := range argv[i+1:] {
+				tokens = append(tokens, Token{rest, TokenArgument})
+			}
+			return tokens
+		case t.hasPrefix("--"):
+			tokens = append(tokens, Token{s, TokenLongOption})
+		case t.hasPrefix("-") && !t.eq("-") && !isNegativeNumber(s, nil):
+			tokens = append(tokens, Token{s, TokenShortOptions})
+		default:
+			tokens = append(tokens, Token{s, TokenArgument})
+		}
+	}
+	return tokens
+}
+
+// patternTokenKind mirrors the decisions of parseSeq and parseAtom.
+func patternTokenKind(t token) TokenKind {
+	switch {
+	case t.match(false, "(", "["):
+		return TokenOpen
+	case t.match(false, ")", "]"):
+		return TokenClose
+	case t.eq("|"):
+		return TokenPipe
+	case t.eq("..."):
+		return TokenEllipsis
+	case t.eq("options"):
+		return TokenOptionsShortcut
+	case t.eq("--"):
+		return TokenDoubleDash
+	case t.hasPrefix("--"):
+		return TokenLongOption
+	case t.hasPrefix("-") && !t.eq("-"):
+		return TokenShortOptions
+	case t.hasPrefix("<") && t.hasSuffix(">") || t.isUpper():
+		return TokenArgument
+	}
+	return TokenCommand
+}
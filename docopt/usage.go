@@ -0,0 +1,192 @@
+package docopt
+
+import (
+	"strings"
+)
+
+// Usage renders the pattern back into a docopt help text: a canonical
+// "Usage:" section with one line per alternative of the top level, followed
+// by sections describing the options, arguments and commands. Parsing the
+// result with ParsePattern yields an equivalent pattern.
+func (p *Pattern) Usage(progName string) string {
+	var b strings.Builder
+	b.WriteString("Usage:\n")
+	for _, line := range p.usageLines() {
+		b.WriteString("  " + progName)
+		if len(line) == 1 && line[0].T == patternEither {
+			// "prog -h | --help" needs no parentheses
+			b.WriteString(" " + line[0].alternatives() + "\n")
+			continue
+		}
+		for _, child := range line {
+			b.WriteString(" " + child.usageString())
+		}
+		b.WriteString("\n")
+	}
+
+	options := p.Options()
+	if len(options) > 0 {
+		b.WriteString("\nOptions:\n")
+		rows := make([][2]string, len(options))
+		for i, o := range options {
+			rows[i] = [2]string{o.optionSpec(), o.describe()}
+		}
+		writeRows(&b, rows)
+	}
+	for _, section := range []struct {
+		heading string
+		leaves  PatternList
+	}{
+		{"Arguments:", p.Positionals()},
+		{"Commands:", p.Commands()},
+	} {
+		rows := [][2]string{}
+		for _, l := range section.leaves {
+			if l.Description != "" {
+				rows = append(rows, [2]string{l.Name, l.Description})
+			}
+		}
+		if len(rows) > 0 {
+			b.WriteString("\n" + section.heading + "\n")
+			writeRows(&b, rows)
+		}
+	}
+	return b.String()
+}
+
+// usageLines splits the pattern into its usage lines: the alternatives of a
+// top level either, or the sequence of a single line.
+func (p *Pattern) usageLines() []PatternList {
+	root := p
+	for root.T == patternRequired && len(root.Children) == 1 && root.Children[0].T&patternBranch != 0 {
+		if root.Children[0].T == patternEither {
+			lines := []PatternList{}
+			for _, alt := range root.Children[0].Children {
+				if alt.T == patternRequired {
+					lines = append(lines, alt.Children)
+				} else {
+					lines = append(lines, PatternList{alt})
+				}
+			}
+			return lines
+		}
+		if root.Children[0].T != patternRequired {
+			break
+		}
+		root = root.Children[0]
+	}
+	if root.T == patternRequired {
+		return []PatternList{root.Children}
+	}
+	return []PatternList{{root}}
+}
+
+// usageString renders a node in usage syntax.
+func (p *Pattern) usageString() string {
+	switch p.T {
+	case patternArgument, patternCommand:
+		return p.Name
+	case patternOption:
+		if p.Long != "" {
+			if p.Argcount > 0 {
+				return p.Long + "=" + p.metavar()
+			}
+			return p.Long
+		}
+		if p.Argcount > 0 {
+			return p.Short + " " + p.metavar()
+		}
+		return p.Short
+	case patternOptionSSHORTCUT:
+		return "options"
+	case patternRequired:
+		if len(p.Children) == 1 && p.Children[0].T == patternEither {
+			return p.Children[0].usageString()
+		}
+		return "(" + p.Children.usageString(" ") + ")"
+	case patternOptionAL:
+		if len(p.Children) == 1 && p.Children[0].T == patternEither {
+			return "[" + p.Children[0].alternatives() + "]"
+		}
+		return "[" + p.Children.usageString(" ") + "]"
+	case patternEither:
+		return "(" + p.alternatives() + ")"
+	case patternOneOrMore:
+		return p.Children.usageString(" ") + "..."
+	}
+	return ""
+}
+
+func (p *Pattern) alternatives() string {
+	alts := make([]string, len(p.Children))
+	for i, alt := range p.Children {
+		if alt.T == patternRequired {
+			alts[i] = alt.Children.usageString(" ")
+		} else {
+			alts[i] = alt.usageString()
+		}
+	}
+	return strings.Join(alts, " | ")
+}
+
+func (pl PatternList) usageString(sep string) string {
+	parts := make([]string, len(pl))
+	for i, p := range pl {
+		parts[i] = p.usageString()
+	}
+	return strings.Join(parts, sep)
+}
+
+func (p *Pattern) metavar() string {
+	if p.Metavar != "" {
+		return p.Metavar
+	}
+	return "<" + strings.TrimLeft(p.Name, "-") + ">"
+}
+
+// optionSpec renders the left column of an options section entry.
+func (p *Pattern) optionSpec() string {
+	names := []string{}
+	if p.Short != "" {
+		names = append(names, p.Short)
+	}
+	if p.Long != "" {
+		names = append(names, p.Long)
+	}
+	spec := strings.Join(names, ", ")
+	if p.Argcount > 0 {
+		if p.Long != "" {
+			spec += "=" + p.metavar()
+		} else {
+			spec += " " + p.metavar()
+		}
+	}
+	return spec
+}
+
+// describe renders the description of an option, making sure its default
+// survives a round trip.
+func (p *Pattern) describe() string {
+	description := p.Description
+	if p.Default != "" && !reDefaultMarker.MatchString(description) {
+		description = strings.TrimSpace(description + " [default: " + p.Default + "]")
+	}
+	return description
+}
+
+// writeRows writes two aligned columns, separated by at least two spaces.
+func writeRows(b *strings.Builder, rows [][2]string) {
+	width := 0
+	for _, r := range rows {
+		if len(r[0]) > width {
+			width = len(r[0])
+		}
+	}
+	for _, r := range rows {
+		if r[1] == "" {
+			b.WriteString("  " + r[0] + "\n")
+			continue
+		}
+		b.WriteString("  " + r[0] + strings.Repeat(" ", width-len(r[0])+2) + r[1] + "\n")
+	}
+}
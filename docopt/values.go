@@ -0,0 +1,172 @@
+package docopt
+
+import "context"
+
+// ValueKind tells what a leaf collects when matched, see ValueModel.
+type ValueKind int
+
+const (
+	// ValueBool is a flag or command given at most once.
+	ValueBool ValueKind = iota
+	// ValueCount is a flag or command that may repeat, counting its
+	// occurrences.
+	ValueCount
+	// ValueString is an argument or option value given at most once.
+	ValueString
+	// ValueStringList is an argument or option value that may repeat, such as
+	// <file>..., collecting its values in order.
+	ValueStringList
+)
+
+func (k ValueKind) String() string {
+	switch k {
+	case ValueBool:
+		return "bool"
+	case ValueCount:
+		return "count"
+	case ValueString:
+		return "string"
+	case ValueStringList:
+		return "stringlist"
+	}
+	return ""
+}
+
+// MarshalText encodes the kind as its name, for frontends reading the models
+// as JSON.
+func (k ValueKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// ValueModel describes the value of a leaf in the result of Match: its kind,
+// and how many times the leaf may occur on a command line. For string lists
+// that's how many values the list holds.
+type ValueModel struct {
+	Name string
+	Kind ValueKind
+	Min  int
+	// Max is -1 when the leaf may repeat without bound
+	Max int
+}
+
+// ValueModels returns the value models of the arguments, commands and options
+// of the pattern, in the order of the usage.
+func (p *Pattern) ValueModels() ([]ValueModel, error) {
+	// fixing the repeating arguments tells the leaves holding lists and
+	// counters apart, do it on a copy as JSONSchema does
+	fixed := p.clone()
+	if err := fixed.fix(context.Background()); err != nil {
+		return nil, err
+	}
+	leaves, err := fixed.Flat(patternDefault)
+	if err != nil {
+		return nil, err
+	}
+	bounds := p.occurrenceBounds()
+	models := []ValueModel{}
+	for _, leaf := range leaves.uniqueNames() {
+		m := ValueModel{Name: leaf.Name, Min: bounds[leaf.Name].min, Max: bounds[leaf.Name].max}
+		switch leaf.Value.(type) {
+		case bool:
+			m.Kind = ValueBool
+		case int:
+			m.Kind = ValueCount
+		case []string:
+			m.Kind = ValueStringList
+		default:
+			m.Kind = ValueString
+		}
+		models = append(models, m)
+	}
+	return models, nil
+}
+
+type occurrences struct {
+	min, max int
+}
+
+// occurrenceBounds returns for every leaf in the pattern how many times it
+// occurs at least and at most in a matching command line.
+func (p *Pattern) occurrenceBounds() map[string]occurrences {
+	if p.T&patternLeaf != 0 {
+		return map[string]occurrences{p.Name: {1, 1}}
+	}
+	result := make(map[string]occurrences)
+	if p.T&patternEither != 0 {
+		// a name occurs as often as in the branch picked, none if the branch
+		// goes without it
+		branches := make([]map[string]occurrences, len(p.Children))
+		for i, child := range p.Children {
+			branches[i] = child.occurrenceBounds()
+			for name := range branches[i] {
+				result[name] = branches[i][name]
+			}
+		}
+		for name, r := range result {
+			for _, bounds := range branches {
+				b := bounds[name]
+				if b.min < r.min {
+					r.min = b.min
+				}
+				if r.max >= 0 && (b.max < 0 || b.max > r.max) {
+					r.max = b.max
+				}
+			}
+			result[name] = r
+		}
+		return result
+	}
+	for _, child := range p.Children {
+		for name, b := range child.occurrenceBounds() {
+			r := result[name]
+			r.min += b.min
+			if r.max >= 0 && b.max >= 0 {
+				r.max += b.max
+			} else {
+				r.max = -1
+			}
+			result[name] = r
+		}
+	}
+	for name, r := range result {
+		if p.T&(patternOptionAL|patternOptionSSHORTCUT) != 0 {
+			r.min = 0
+		}
+		if p.T&patternOneOrMore != 0 {
+			r.max = -1
+		}
+		result[name] = r
+	}
+	return result
+}
+
+// check reports a list value holding more or fewer values than the leaf
+// takes as a *UsageError.
+func (m ValueModel) check(v interface{}) error {
+	list, ok := v.([]string)
+	if m.Kind != ValueStringList || !ok || len(list) == 0 {
+		return nil
+	}
+	if len(list) < m.Min {
+		return newUserError("%s takes at least %d values, got %d", m.Name, m.Min, len(list))
+	}
+	if m.Max >= 0 && len(list) > m.Max {
+		return newUserError("%s takes at most %d values, got %d", m.Name, m.Max, len(list))
+	}
+	return nil
+}
+
+// checkLists checks the lists in values against the value models of the
+// pattern.
+func (p *Pattern) checkLists(values Opts) error {
+	models, err := p.ValueModels()
+	if err != nil {
+		return err
+	}
+	for _, m := range models {
+		if err := m.check(values[m.Name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
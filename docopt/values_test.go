@@ -0,0 +1,60 @@
+package docopt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValueModels(t *testing.T) {
+	pat, err := ParsePattern(`Usage:
+  prog cp <src>... <dst> [-v...]
+  prog mv <pair> <pair> [--tag=<t>]...
+  prog ls [<dir>] [-a]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []ValueModel{
+		{"cp", ValueBool, 0, 1},
+		{"<src>", ValueStringList, 0, -1},
+		{"<dst>", ValueString, 0, 1},
+		{"-v", ValueCount, 0, -1},
+		{"mv", ValueBool, 0, 1},
+		{"<pair>", ValueStringList, 0, 2},
+		{"--tag", ValueStringList, 0, -1},
+		{"ls", ValueBool, 0, 1},
+		{"<dir>", ValueString, 0, 1},
+		{"-a", ValueBool, 0, 1},
+	}
+	if models, err := pat.ValueModels(); err != nil || !reflect.DeepEqual(models, expect) {
+		t.Errorf("result: %v error: %v", models, err)
+	}
+
+	pat, _ = ParsePattern("Usage: prog <a> <a> [<a>] <file>...")
+	expect = []ValueModel{
+		{"<a>", ValueStringList, 2, 3},
+		{"<file>", ValueStringList, 1, -1},
+	}
+	if models, err := pat.ValueModels(); err != nil || !reflect.DeepEqual(models, expect) {
+		t.Errorf("result: %v error: %v", models, err)
+	}
+
+	for i, c := range []struct {
+		values Opts
+		argv   []string
+		ok     bool
+	}{
+		{Opts{"<a>": []string{"1", "2"}, "<file>": []string{"z", "y", "x"}}, []string{"1", "2", "z", "y", "x"}, true},
+		{Opts{"<a>": []string{"1", "2", "3"}, "<file>": []string{"x"}}, []string{"1", "2", "3", "x"}, true},
+		{Opts{"<a>": []string{"1"}, "<file>": []string{"x"}}, nil, false},
+		{Opts{"<a>": []string{"1", "2", "3", "4"}, "<file>": []string{"x"}}, nil, false},
+	} {
+		err := pat.Validate(c.values)
+		argv, argvErr := pat.ToArgv(c.values)
+		if (err == nil) != c.ok || (argvErr == nil) != c.ok || c.ok && !reflect.DeepEqual(argv, c.argv) {
+			t.Errorf("testcase: %d result: %v %v %v", i, argv, err, argvErr)
+		}
+		if _, isUser := err.(*UserError); err != nil && !isUser {
+			t.Errorf("testcase: %d expected a UserError, got: %#v", i, err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Package filter reduces the output of runs before it's shown: the lines
+// matching a regular expression, the first or the last lines, a count of the
+// lines, or what a jq expression makes of JSON, so that huge outputs needn't
+// be piped through another command.
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// Kind is what a filter does.
+type Kind string
+
+const (
+	// Grep keeps the lines matching a regular expression.
+	Grep Kind = "grep"
+	// Head keeps the first lines.
+	Head Kind = "head"
+	// Tail keeps the last lines, once the output has ended.
+	Tail Kind = "tail"
+	// Count replaces the lines by their count, once the output has ended.
+	Count Kind = "count"
+	// JQ runs a jq expression on the JSON values of the output, once it has
+	// ended, and writes the results as JSON.
+	JQ Kind = "jq"
+)
+
+// Filter is a post-processor of output. Expr is the regular expression of
+// Grep, whose lines not matching it are kept instead if Invert is set, or
+// the expression of JQ. Lines is how many lines Head and Tail keep.
+type Filter struct {
+	Kind   Kind   `json:"kind"`
+	Expr   string `json:"expr,omitempty"`
+	Invert bool   `json:"invert,omitempty"`
+	Lines  int    `json:"lines,omitempty"`
+}
+
+// stage is a filter at work. Lines are passed with their newline, but for
+// the last line of the output if it has none.
+type stage interface {
+	// line takes a line and returns the lines to pass on
+	line(s string) []string
+	// end returns the lines left to pass on at the end of the output
+	end() []string
+}
+
+// Chain is filters applied in turn to a stream of output, each one reading
+// what the one before lets through.
+type Chain struct {
+	stages []stage
+	// partial is the line being written
+	partial string
+}
+
+// New returns the chain of the filters, an error telling of the first that
+// isn't valid.
+func New(filters []Filter) (*Chain, error) {
+	c := &Chain{}
+	for i, f := range filters {
+		s, err := f.stage()
+		if err != nil {
+			return nil, fmt.Errorf("filter %d: %v", i+1, err)
+		}
+		c.stages = append(c.stages, s)
+	}
+	return c, nil
+}
+
+func (f Filter) stage() (stage, error) {
+	switch f.Kind {
+	case Grep:
+		re, err := regexp.Compile(f.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &grep{re, f.Invert}, nil
+	case Head, Tail:
+		if f.Lines < 0 {
+			return nil, fmt.Errorf("%s of %d lines", f.Kind, f.Lines)
+		}
+		if f.Kind == Head {
+			return &head{left: f.Lines}, nil
+		}
+		return &tail{size: f.Lines}, nil
+	case Count:
+		return &count{}, nil
+	case JQ:
+		query, err := gojq.Parse(f.Expr)
+		if err != nil {
+			return nil, err
+		}
+		code, err := gojq.Compile(query)
+		if err != nil {
+			return nil, err
+		}
+		return &jq{code: code}, nil
+	}
+	return nil, fmt.Errorf("unknown kind %q", f.Kind)
+}
+
+// Write feeds text to the chain and returns what it lets through so far, the
+// lines being passed on once they end.
+func (c *Chain) Write(text string) string {
+	var out strings.Builder
+	text = c.partial + text
+	for {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			break
+		}
+		c.push(0, []string{text[:i+1]}, &out)
+		text = text[i+1:]
+	}
+	c.partial = text
+	return out.String()
+}
+
+// Close ends the output and returns what the chain lets through of the
+// rest.
+func (c *Chain) Close() string {
+	var out strings.Builder
+	if c.partial != "" {
+		c.push(0, []string{c.partial}, &out)
+		c.partial = ""
+	}
+	for i, s := range c.stages {
+		c.push(i+1, s.end(), &out)
+	}
+	return out.String()
+}
+
+// push passes the lines through the stages from the from-th on, writing to
+// out what they let through.
+func (c *Chain) push(from int, lines []string, out *strings.Builder) {
+	for _, s := range c.stages[from:] {
+		var next []string
+		for _, line := range lines {
+			next = append(next, s.line(line)...)
+		}
+		lines = next
+	}
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+type grep struct {
+	re     *regexp.Regexp
+	invert bool
+}
+
+func (g *grep) line(s string) []string {
+	if g.re.MatchString(strings.TrimRight(s, "\r\n")) != g.invert {
+		return []string{s}
+	}
+	return nil
+}
+
+func (g *grep) end() []string { return nil }
+
+type head struct {
+	left int
+}
+
+func (h *head) line(s string) []string {
+	if h.left == 0 {
+		return nil
+	}
+	h.left--
+	return []string{s}
+}
+
+func (h *head) end() []string { return nil }
+
+// tail keeps the last size lines in a ring, next being the index of the
+// oldest once it's full.
+type tail struct {
+	size  int
+	lines []string
+	next  int
+}
+
+func (t *tail) line(s string) []string {
+	if t.size == 0 {
+		return nil
+	}
+	if len(t.lines) < t.size {
+		t.lines = append(t.lines, s)
+		return nil
+	}
+	t.lines[t.next] = s
+	t.next = (t.next + 1) % t.size
+	return nil
+}
+
+func (t *tail) end() []string {
+	return append(t.lines[t.next:], t.lines[:t.next]...)
+}
+
+type count struct {
+	n int
+}
+
+func (c *count) line(string) []string {
+	c.n++
+	return nil
+}
+
+func (c *count) end() []string {
+	return []string{fmt.Sprintf("%d\n", c.n)}
+}
+
+// jq keeps the output until it ends, when it's decoded as a sequence of JSON
+// values the code is run on.
+type jq struct {
+	code  *gojq.Code
+	input strings.Builder
+}
+
+func (q *jq) line(s string) []string {
+	q.input.WriteString(s)
+	return nil
+}
+
+func (q *jq) end() []string {
+	var out strings.Builder
+	dec := json.NewDecoder(strings.NewReader(q.input.String()))
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			fmt.Fprintf(&out, "jq: output isn't JSON: %v\n", err)
+			break
+		}
+		iter := q.code.Run(v)
+		for {
+			result, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := result.(error); ok {
+				fmt.Fprintf(&out, "jq: %v\n", err)
+				break
+			}
+			data, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(&out, "jq: %v\n", err)
+				break
+			}
+			out.Write(data)
+			out.WriteByte('\n')
+		}
+	}
+	return lines(out.String())
+}
+
+// lines splits s into lines, keeping their newlines.
+func lines(s string) []string {
+	var list []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n') + 1
+		if i == 0 {
+			i = len(s)
+		}
+		list = append(list, s[:i])
+		s = s[i:]
+	}
+	return list
+}
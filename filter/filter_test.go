@@ -0,0 +1,65 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	input := []string{"a1\nb", "2\na3\n", "b4\na5"}
+	for i, tt := range []struct {
+		filters []Filter
+		written string
+		closed  string
+	}{
+		{nil, "a1\nb2\na3\nb4\n", "a5"},
+		{[]Filter{{Kind: Grep, Expr: "^a"}}, "a1\na3\n", "a5"},
+		{[]Filter{{Kind: Grep, Expr: "^a", Invert: true}}, "b2\nb4\n", ""},
+		{[]Filter{{Kind: Head, Lines: 2}}, "a1\nb2\n", ""},
+		{[]Filter{{Kind: Tail, Lines: 2}}, "", "b4\na5"},
+		{[]Filter{{Kind: Tail, Lines: 10}}, "", "a1\nb2\na3\nb4\na5"},
+		{[]Filter{{Kind: Count}}, "", "5\n"},
+		{[]Filter{{Kind: Grep, Expr: "a"}, {Kind: Tail, Lines: 2}, {Kind: Count}}, "", "2\n"},
+		{[]Filter{{Kind: Tail, Lines: 3}, {Kind: Head, Lines: 1}}, "", "a3\n"},
+	} {
+		c, err := New(tt.filters)
+		if err != nil {
+			t.Fatalf("testcase: %d error: %v", i, err)
+		}
+		written := ""
+		for _, s := range input {
+			written += c.Write(s)
+		}
+		if closed := c.Close(); written != tt.written || closed != tt.closed {
+			t.Errorf("testcase: %d result: %q %q expected: %q %q", i, written, closed, tt.written, tt.closed)
+		}
+	}
+}
+
+func TestJQ(t *testing.T) {
+	c, err := New([]Filter{{Kind: JQ, Expr: ".name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := c.Write(`{"name": "x"}` + "\n" + `{"name": {"first": "y"}}`)
+	if closed := c.Close(); written != "" || closed != "\"x\"\n{\n  \"first\": \"y\"\n}\n" {
+		t.Errorf("result: %q %q", written, closed)
+	}
+	c, _ = New([]Filter{{Kind: JQ, Expr: "."}})
+	c.Write("not json\n")
+	if closed := c.Close(); !strings.HasPrefix(closed, "jq: output isn't JSON") {
+		t.Errorf("result: %q", closed)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for i, f := range []Filter{
+		{Kind: Grep, Expr: "("},
+		{Kind: Head, Lines: -1},
+		{Kind: "sort"},
+	} {
+		if _, err := New([]Filter{f}); err == nil {
+			t.Errorf("testcase: %d filter accepted", i)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package form
+
+import (
+	"sort"
+
+	"gtoc/docopt"
+)
+
+// ChangeKind is how an option, argument or command changed between two
+// builds of a program.
+type ChangeKind string
+
+const (
+	// Added is new to the build after.
+	Added ChangeKind = "added"
+	// Removed isn't in the build after anymore.
+	Removed ChangeKind = "removed"
+	// Renamed is an option that kept a long or short name but changed the
+	// other, such as -v getting --verbose, see Migrate.
+	Renamed ChangeKind = "renamed"
+	// Default is an option whose default value changed.
+	Default ChangeKind = "default"
+)
+
+// Change is an option, argument or command that changed between the
+// patterns of two builds of a program. Name is its name after, but for one
+// removed; From and To are its name before and after if it was renamed, and
+// its default before and after if that changed. Subcommand is the
+// subcommand whose pattern it's in, "" for the command itself.
+type Change struct {
+	Kind       ChangeKind `json:"kind"`
+	Subcommand string     `json:"subcommand,omitempty"`
+	Name       string     `json:"name"`
+	From       string     `json:"from,omitempty"`
+	To         string     `json:"to,omitempty"`
+}
+
+// Changes returns what changed from before to after, the patterns of two
+// builds of a program, in the subcommands attached to both as well, see
+// docopt.Pattern.AttachSubcommand. They're sorted by subcommand, kind and
+// name; there are none if the builds take the same options.
+func Changes(before, after *docopt.Pattern) []Change {
+	list := changes("", before, after)
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Subcommand != b.Subcommand {
+			return a.Subcommand < b.Subcommand
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return list
+}
+
+func changes(sub string, before, after *docopt.Pattern) []Change {
+	var list []Change
+	renamed := renames(before, after)
+	to := make(map[string]bool, len(renamed))
+	for from, name := range renamed {
+		to[name] = true
+		list = append(list, Change{Kind: Renamed, Subcommand: sub, Name: name, From: from, To: name})
+	}
+	leaves := func(p *docopt.Pattern) map[string]*docopt.Pattern {
+		m := make(map[string]*docopt.Pattern)
+		for _, l := range append(append(p.Options(), p.Positionals()...), p.Commands()...) {
+			m[l.Name] = l
+		}
+		return m
+	}
+	old, now := leaves(before), leaves(after)
+	for name, l := range old {
+		if _, ok := renamed[name]; ok {
+			name = renamed[name]
+		} else if _, ok := now[name]; !ok {
+			list = append(list, Change{Kind: Removed, Subcommand: sub, Name: name})
+			continue
+		}
+		if d := now[name].Default; d != l.Default {
+			list = append(list, Change{Kind: Default, Subcommand: sub, Name: name, From: l.Default, To: d})
+		}
+	}
+	for name := range now {
+		if _, ok := old[name]; !ok && !to[name] {
+			list = append(list, Change{Kind: Added, Subcommand: sub, Name: name})
+		}
+	}
+	for name, p := range after.Subcommands {
+		if q, ok := before.Subcommands[name]; ok {
+			if sub != "" {
+				name = sub + " " + name
+			}
+			list = append(list, changes(name, q, p)...)
+		}
+	}
+	return list
+}
+
+// renames returns the new names of the options of before that after names
+// otherwise, keeping their long or short name.
+func renames(before, after *docopt.Pattern) map[string]string {
+	names := make(map[string]bool)
+	for _, opt := range after.Options() {
+		names[opt.Name] = true
+	}
+	renamed := make(map[string]string)
+	for _, old := range before.Options() {
+		if names[old.Name] {
+			continue
+		}
+		for _, opt := range after.Options() {
+			if old.Long != "" && old.Long == opt.Long || old.Short != "" && old.Short == opt.Short {
+				renamed[old.Name] = opt.Name
+				break
+			}
+		}
+	}
+	return renamed
+}
@@ -0,0 +1,49 @@
+package form
+
+import (
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestChanges(t *testing.T) {
+	parse := func(doc string) *docopt.Pattern {
+		pat, err := docopt.ParsePattern(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pat
+	}
+	before := parse(`Usage: rg [-i] [--jobs=<n>] [--mmap] <pattern> [<path>...]
+
+Options:
+  -i          Ignore case.
+  --jobs=<n>  Threads [default: 1].
+  --mmap      Map files.
+`)
+	after := parse(`Usage: rg [-i] [--jobs=<n>] [--json] <pattern> [<path>...]
+
+Options:
+  -i, --ignore-case  Ignore case.
+  --jobs=<n>         Threads [default: 4].
+  --json             Print JSON.
+`)
+	remote := parse("Usage: git remote [-v] add <name> <url>")
+	before.AttachSubcommand("remote", remote)
+	after.AttachSubcommand("remote", parse("Usage: git remote [-v] add [--fetch] <name> <url>"))
+
+	expected := []Change{
+		{Kind: Added, Name: "--json"},
+		{Kind: Default, Name: "--jobs", From: "1", To: "4"},
+		{Kind: Removed, Name: "--mmap"},
+		{Kind: Renamed, Name: "--ignore-case", From: "-i", To: "--ignore-case"},
+		{Kind: Added, Subcommand: "remote", Name: "--fetch"},
+	}
+	if result := Changes(before, after); !reflect.DeepEqual(result, expected) {
+		t.Errorf("result: %v expected: %v", result, expected)
+	}
+	if result := Changes(before, before); len(result) != 0 {
+		t.Errorf("result: %v expected: no changes", result)
+	}
+}
@@ -0,0 +1,162 @@
+package form
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gtoc/docopt"
+)
+
+// Check checks values, as given to docopt.Pattern.ToArgv, against the
+// fields of the form of pat and the constraints between them, see
+// docopt.Pattern.Constraints, counting the fields given as
+// docopt.Pattern.Validate does. It returns why the values of the fields that
+// don't fit don't, keyed by field ID, none if they all do. A field breaking
+// several rules is told about the first.
+func Check(pat *docopt.Pattern, values docopt.Opts) (map[string]string, error) {
+	spec, err := Build(pat)
+	if err != nil {
+		return nil, err
+	}
+	errs := make(map[string]string)
+	set := func(id, msg string) {
+		if _, ok := errs[id]; !ok {
+			errs[id] = msg
+		}
+	}
+	for _, f := range spec.Fields {
+		if msg := f.check(values[f.ID]); msg != "" {
+			set(f.ID, msg)
+		}
+	}
+	given := func(name string) bool { return pat.Given(values, name) }
+	for _, c := range pat.Constraints() {
+		switch c.Kind {
+		case docopt.ConstraintRequires:
+			if !given(c.Name) {
+				continue
+			}
+			for _, name := range c.Names {
+				if !given(name) {
+					set(c.Name, "requires "+name)
+					break
+				}
+			}
+		case docopt.ConstraintConflicts:
+			var used []string
+			for _, group := range c.Groups {
+				for _, name := range group {
+					if given(name) {
+						used = append(used, name)
+						break
+					}
+				}
+			}
+			for i, name := range used {
+				if i > 0 {
+					set(name, "can't be used with "+used[0])
+				} else if len(used) > 1 {
+					set(name, "can't be used with "+used[1])
+				}
+			}
+		case docopt.ConstraintOneOf:
+			any := false
+			for _, name := range c.Names {
+				any = any || given(name)
+			}
+			if !any {
+				for _, name := range c.Names {
+					set(name, "one of "+strings.Join(c.Names, ", ")+" is required")
+				}
+			}
+		}
+	}
+	return errs, nil
+}
+
+// check returns why v doesn't fit the field, "" if it does.
+func (f Field) check(v interface{}) string {
+	if !filled(v) {
+		if f.Required {
+			return "required"
+		}
+		return ""
+	}
+	switch f.Widget {
+	case Checkbox:
+		if _, ok := v.(bool); !ok {
+			return "must be true or false"
+		}
+		return ""
+	case Counter:
+		n, ok := v.(int)
+		switch {
+		case !ok || n < 0:
+			return "must be a count"
+		case f.Max >= 0 && n > f.Max:
+			return fmt.Sprintf("can be given at most %d times", f.Max)
+		}
+		return ""
+	}
+	list, ok := v.([]string)
+	if f.Multiple || f.Widget == Multiselect {
+		if !ok {
+			return "must be a list"
+		}
+		if len(list) < f.Min {
+			return fmt.Sprintf("takes at least %d values", f.Min)
+		}
+		if f.Max >= 0 && len(list) > f.Max {
+			return fmt.Sprintf("takes at most %d values", f.Max)
+		}
+	} else if ok {
+		return "takes a single value"
+	} else {
+		list = []string{fmt.Sprint(v)}
+	}
+	for _, item := range list {
+		if msg := f.checkValue(item); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// checkValue returns why a single value doesn't fit the field, "" if it
+// does.
+func (f Field) checkValue(s string) string {
+	switch f.Widget {
+	case Number:
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return "must be a number"
+		}
+	case Select, Multiselect:
+		for _, choice := range f.Choices {
+			if s == choice {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(f.Choices, ", ")
+	}
+	return ""
+}
+
+// filled tells whether v fills its field in, the checks of the value
+// applying only then. An option left at its default is filled in, though it
+// isn't given to the command, see docopt.Pattern.Given.
+func filled(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int:
+		return v > 0
+	case string:
+		return v != ""
+	case []string:
+		return len(v) > 0
+	}
+	return true
+}
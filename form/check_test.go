@@ -0,0 +1,99 @@
+package form
+
+import (
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestCheck(t *testing.T) {
+	pat, err := docopt.ParsePattern(`Usage:
+  prog add [-f] <x>
+  prog rm [-r] <x>
+  prog export (--json | --yaml) [(--user <u> --password <p>)]
+
+Options:
+  -f  Force. Cannot be used with --json.
+  -r  Recurse, requires --yaml.
+  --json
+  --yaml
+  --user <u>
+  --password <p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range []struct {
+		values   docopt.Opts
+		expected map[string]string
+	}{
+		{docopt.Opts{"add": true, "<x>": "1", "-f": true}, map[string]string{}},
+		{docopt.Opts{"add": true, "-f": true}, map[string]string{"add": "requires <x>", "-f": "requires <x>"}},
+		{docopt.Opts{"rm": true, "<x>": "1", "-r": true}, map[string]string{"-r": "requires --yaml"}},
+		{docopt.Opts{"add": true, "rm": true, "<x>": "1"}, map[string]string{"add": "can't be used with rm", "rm": "can't be used with add"}},
+		{docopt.Opts{"<x>": "1"}, map[string]string{
+			"add":    "one of add, rm, export is required",
+			"rm":     "one of add, rm, export is required",
+			"export": "one of add, rm, export is required",
+		}},
+	} {
+		result, err := Check(pat, tt.values)
+		if err != nil || !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("testcase: %d result: %v error: %v expected: %v", i, result, err, tt.expected)
+		}
+	}
+
+	// options left empty or at their default aren't given
+	if pat, err = docopt.ParsePattern(`Usage: prog [--format=<f>] [--json]
+
+Options:
+  --format=<f>  Output format. Cannot be used with --json. [default: text]
+  --json        Output JSON.`); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range []struct {
+		values   docopt.Opts
+		expected map[string]string
+	}{
+		{docopt.Opts{"--format": "text", "--json": true}, map[string]string{}},
+		{docopt.Opts{"--format": "", "--json": true}, map[string]string{}},
+		{docopt.Opts{"--format": "csv", "--json": true}, map[string]string{
+			"--format": "can't be used with --json",
+			"--json":   "can't be used with --format",
+		}},
+	} {
+		result, err := Check(pat, tt.values)
+		if err != nil || !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("testcase: %d result: %v error: %v expected: %v", i, result, err, tt.expected)
+		}
+		if err = pat.Validate(tt.values); (err == nil) != (len(tt.expected) == 0) {
+			t.Errorf("testcase: %d result: %v expected: the same as Check", i, err)
+		}
+	}
+
+	if pat, err = docopt.ParsePattern(usage); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range []struct {
+		values   docopt.Opts
+		expected map[string]string
+	}{
+		{docopt.Opts{"-v": 2, "--level": "4", "--mode": "fast", "<src>": []string{"a"}, "<dest-dir>": "d"}, map[string]string{}},
+		{docopt.Opts{"--level": "high", "--mode": "medium", "<src>": []string{}, "<dest-dir>": []string{"d", "e"}}, map[string]string{
+			"--level":    "must be a number",
+			"--mode":     "must be one of fast, slow",
+			"<src>":      "required",
+			"<dest-dir>": "takes a single value",
+		}},
+		{docopt.Opts{"-v": "x", "list": "yes", "<src>": "a", "<dest-dir>": 3}, map[string]string{
+			"-v":    "must be a count",
+			"list":  "must be true or false",
+			"<src>": "must be a list",
+		}},
+	} {
+		result, err := Check(pat, tt.values)
+		if err != nil || !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("testcase: %d result: %v error: %v expected: %v", i, result, err, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package form
+
+// Drop returns the field that paths dropped on the form go to: the field
+// focused if it takes them, and else the only list of paths of the form
+// that does, such as <file>.... Dir fields take directories only, which
+// dirs tells the paths all are, and fields that aren't lists take a single
+// path. It returns false if no field takes them.
+func Drop(fields []Field, focused string, paths int, dirs bool) (Field, bool) {
+	takes := func(f Field) bool {
+		switch {
+		case f.Widget != File && f.Widget != Dir, f.Widget == Dir && !dirs:
+			return false
+		}
+		return f.Multiple || paths == 1
+	}
+	for _, f := range fields {
+		if f.ID == focused && takes(f) {
+			return f, true
+		}
+	}
+	var lists []Field
+	for _, f := range fields {
+		if f.Multiple && takes(f) {
+			lists = append(lists, f)
+		}
+	}
+	if len(lists) == 1 {
+		return lists[0], true
+	}
+	return Field{}, false
+}
@@ -0,0 +1,65 @@
+package form
+
+import (
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestDrop(t *testing.T) {
+	pat, err := docopt.ParsePattern(`Usage: tar [--file=<file>] [--directory=<dir>] [--level=<n>] <input-file>...
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := Build(pat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range []struct {
+		focused  string
+		paths    int
+		dirs     bool
+		expected string
+	}{
+		{"--file", 1, false, "--file"},
+		{"--directory", 1, true, "--directory"},
+		// the list takes what the field focused doesn't
+		{"--file", 2, false, "<input-file>"},
+		{"--directory", 1, false, "<input-file>"},
+		{"--level", 3, false, "<input-file>"},
+		{"", 1, true, "<input-file>"},
+		{"<input-file>", 2, false, "<input-file>"},
+	} {
+		f, ok := Drop(spec.Fields, tt.focused, tt.paths, tt.dirs)
+		if !ok || f.ID != tt.expected {
+			t.Errorf("testcase: %d result: %s %v expected: %s", i, f.ID, ok, tt.expected)
+		}
+	}
+
+	// without a list, the paths go to the field focused only
+	pat, err = docopt.ParsePattern(`Usage: cp <src-file> <dest-dir>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err = Build(pat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range []struct {
+		focused string
+		paths   int
+		dirs    bool
+		ok      bool
+	}{
+		{"<src-file>", 1, false, true},
+		{"<src-file>", 2, false, false},
+		{"<dest-dir>", 1, false, false},
+		{"<dest-dir>", 1, true, true},
+		{"", 1, false, false},
+	} {
+		if f, ok := Drop(spec.Fields, tt.focused, tt.paths, tt.dirs); ok != tt.ok || ok && f.ID != tt.focused {
+			t.Errorf("testcase: %d result: %s %v expected: %v", i, f.ID, ok, tt.ok)
+		}
+	}
+}
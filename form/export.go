@@ -0,0 +1,54 @@
+package form
+
+import "gtoc/docopt"
+
+// FormatVersion is the version of the Document format, raised with every
+// change a frontend reading it would break on.
+const FormatVersion = 1
+
+// Document is the form of a command exported for frontends other than
+// gtoc's own, such as web apps, TUIs or editors, as printed by
+// "gtoc --form <command>". It's read as JSON:
+//
+//	{
+//	  "version": 1,
+//	  "command": "tar",
+//	  "form": {"fields": [...], "subcommands": {...}},
+//	  "values": {JSON Schema of the values}
+//	}
+//
+// The form is a Spec, one Field per argument, command and option. A
+// frontend renders each field with its widget and submits the values of
+// the form as one JSON object keyed by field ID, described by the JSON
+// Schema in "values":
+//
+//   - a checkbox is true or false
+//   - a counter is how many times it's given, from 0
+//   - a field with multiple set and a multiselect are arrays of strings
+//   - any other field is a string, numbers included, or null when it's
+//     left empty
+//
+// Those are the values gtoc's own frontend sends with a run, which
+// docopt.Pattern.ToArgv turns into a command line once decoded with
+// runner.DecodeValues. Fields left out of the object are as if left empty.
+type Document struct {
+	Version int    `json:"version"`
+	Command string `json:"command"`
+	Form    *Spec  `json:"form"`
+	// Values is the JSON Schema of the values object a frontend submits
+	Values *docopt.JSONSchema `json:"values"`
+}
+
+// Export returns the document of the form of command, whose pattern is
+// pat.
+func Export(command string, pat *docopt.Pattern) (*Document, error) {
+	spec, err := Build(pat)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := pat.JSONSchema(command)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Version: FormatVersion, Command: command, Form: spec, Values: schema}, nil
+}
@@ -0,0 +1,205 @@
+// Package form maps the pattern of a command to the form a GUI shows for
+// it: a field per argument, command and option, with the widget that fits
+// the value it takes.
+package form
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gtoc/docopt"
+)
+
+// Widget is the kind of input a field is shown as.
+type Widget string
+
+const (
+	// Checkbox is a flag or command, given or not.
+	Checkbox Widget = "checkbox"
+	// Counter is a flag or command that may repeat, such as -v for
+	// verbosity, given as many times as counted.
+	Counter Widget = "counter"
+	// Text is a value typed in.
+	Text Widget = "text"
+	// Number is a value that's a number.
+	Number Widget = "number"
+	// File is the path of a file, picked in a file dialog.
+	File Widget = "file"
+	// Dir is the path of a directory, picked in a directory dialog.
+	Dir Widget = "dir"
+	// Select is a value picked among choices.
+	Select Widget = "select"
+	// Multiselect is a list of values picked among choices.
+	Multiselect Widget = "multiselect"
+)
+
+// Field is the input of a value of the pattern.
+type Field struct {
+	// ID is the key of the value, as in the values of docopt.Pattern.Match
+	ID     string `json:"id"`
+	Label  string `json:"label"`
+	Widget Widget `json:"widget"`
+	// Multiple tells the field holds a list of values, each input with the
+	// widget, but for Multiselect which holds a list itself
+	Multiple bool `json:"multiple,omitempty"`
+	Required bool `json:"required,omitempty"`
+	// Min and Max bound how many values a list holds, or how many times a
+	// counter is given. Max is -1 without bound.
+	Min         int      `json:"min"`
+	Max         int      `json:"max"`
+	Choices     []string `json:"choices,omitempty"`
+	Default     string   `json:"default,omitempty"`
+	Placeholder string   `json:"placeholder,omitempty"`
+	// Description is the whole description of the value, for an expander,
+	// and Summary its first sentence, for a tooltip. Examples are the values
+	// the description gives as examples.
+	Description string   `json:"description,omitempty"`
+	Summary     string   `json:"summary,omitempty"`
+	Examples    []string `json:"examples,omitempty"`
+	Section     string   `json:"section,omitempty"`
+	EnvVar      string   `json:"envVar,omitempty"`
+	Deprecated  bool     `json:"deprecated,omitempty"`
+	// Stdin tells the value may be "-", for the standard input
+	Stdin bool `json:"stdin,omitempty"`
+}
+
+// Spec is the form of a command: its fields in the order of the usage, and
+// the forms of its subcommands, keyed by name.
+type Spec struct {
+	Fields      []Field          `json:"fields"`
+	Subcommands map[string]*Spec `json:"subcommands,omitempty"`
+}
+
+// Build returns the form of the pattern and of its subcommands.
+func Build(pat *docopt.Pattern) (*Spec, error) {
+	models, err := pat.ValueModels()
+	if err != nil {
+		return nil, err
+	}
+	leaves := make(map[string]*docopt.Pattern)
+	for _, list := range []docopt.PatternList{pat.Options(), pat.Positionals(), pat.Commands()} {
+		for _, leaf := range list {
+			leaves[leaf.Name] = leaf
+		}
+	}
+	spec := &Spec{Fields: []Field{}}
+	for _, m := range models {
+		leaf, ok := leaves[m.Name]
+		if !ok {
+			continue
+		}
+		spec.Fields = append(spec.Fields, field(leaf, m, pat.IsRequired(m.Name)))
+	}
+	names := make([]string, 0, len(pat.Subcommands))
+	for name := range pat.Subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sub, err := Build(pat.Subcommands[name])
+		if err != nil {
+			return nil, err
+		}
+		if spec.Subcommands == nil {
+			spec.Subcommands = make(map[string]*Spec)
+		}
+		spec.Subcommands[name] = sub
+	}
+	return spec, nil
+}
+
+// field returns the field of the leaf, whose value model is m.
+func field(leaf *docopt.Pattern, m docopt.ValueModel, required bool) Field {
+	f := Field{
+		ID:          leaf.Name,
+		Label:       label(leaf),
+		Required:    required,
+		Min:         m.Min,
+		Max:         m.Max,
+		Choices:     leaf.Choices,
+		Default:     leaf.Default,
+		Placeholder: leaf.Metavar,
+		Description: leaf.Description,
+		Summary:     summary(leaf.Description),
+		Examples:    examples(leaf.Description),
+		Section:     leaf.Section,
+		EnvVar:      leaf.EnvVar,
+		Deprecated:  leaf.Deprecated,
+		Stdin:       leaf.Stdin,
+	}
+	switch {
+	case m.Kind == docopt.ValueBool:
+		f.Widget = Checkbox
+	case m.Kind == docopt.ValueCount:
+		f.Widget = Counter
+	case len(leaf.Choices) > 0 && m.Kind == docopt.ValueStringList:
+		f.Widget = Multiselect
+	case len(leaf.Choices) > 0:
+		f.Widget = Select
+	default:
+		f.Widget = infer(leaf)
+		f.Multiple = m.Kind == docopt.ValueStringList
+	}
+	return f
+}
+
+// label returns what a field is called: the long name of an option if it
+// has one, an argument without its angle brackets.
+func label(leaf *docopt.Pattern) string {
+	switch {
+	case leaf.Long != "":
+		return leaf.Long
+	case leaf.Short != "":
+		return leaf.Short
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(leaf.Name, "<"), ">")
+}
+
+var (
+	fileWords = map[string]bool{"FILE": true, "FILES": true, "FILENAME": true, "PATH": true, "PATHS": true}
+	dirWords  = map[string]bool{"DIR": true, "DIRS": true, "DIRECTORY": true, "FOLDER": true}
+	// numberWords are metavars of numbers, such as --jobs=N
+	numberWords = map[string]bool{
+		"N": true, "NUM": true, "NUMBER": true, "COUNT": true, "INT": true, "INTEGER": true,
+		"SIZE": true, "PORT": true, "SECONDS": true, "SECS": true, "SEC": true, "MS": true,
+		"TIMEOUT": true, "DEPTH": true, "LEVEL": true, "LINES": true, "JOBS": true, "LIMIT": true,
+	}
+)
+
+// infer returns the widget of the value an argument or option takes, out of
+// its metavar or argument name and its default: Dir or File for words
+// naming them such as <src-dir> or FILE, Number for numbers such as N or a
+// numeric default, and Text otherwise. Standard input is a file.
+func infer(leaf *docopt.Pattern) Widget {
+	name := leaf.Metavar
+	if name == "" && leaf.Long == "" && leaf.Short == "" {
+		name = leaf.Name
+	}
+	words := strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if dirWords[w] || strings.HasSuffix(w, "DIR") {
+			return Dir
+		}
+	}
+	for _, w := range words {
+		if fileWords[w] || strings.HasSuffix(w, "FILE") {
+			return File
+		}
+	}
+	if leaf.Stdin {
+		return File
+	}
+	for _, w := range words {
+		if numberWords[w] {
+			return Number
+		}
+	}
+	if _, err := strconv.ParseFloat(leaf.Default, 64); err == nil {
+		return Number
+	}
+	return Text
+}
@@ -0,0 +1,122 @@
+package form
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+const usage = `Usage: tool [-v...] [--level=N] [--out=FILE] [--mode={fast,slow}] [--tag=T...] <src>... <dest-dir> [list|show]
+
+Options:
+  -v                  Verbose.
+  --level=N           Level [default: 3].
+  --out=FILE          Output file.
+  --mode={fast,slow}  Mode.
+  --tag=T             Tags.
+`
+
+func TestBuild(t *testing.T) {
+	pat, err := docopt.ParsePattern(usage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := Build(pat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type short struct {
+		ID       string
+		Label    string
+		Widget   Widget
+		Multiple bool
+		Required bool
+	}
+	expected := []short{
+		{"-v", "-v", Counter, false, false},
+		{"--level", "--level", Number, false, false},
+		{"--out", "--out", File, false, false},
+		{"--mode", "--mode", Select, false, false},
+		{"--tag", "--tag", Text, true, false},
+		{"<src>", "src", Text, true, true},
+		{"<dest-dir>", "dest-dir", Dir, false, true},
+		{"list", "list", Checkbox, false, false},
+		{"show", "show", Checkbox, false, false},
+	}
+	var result []short
+	for _, f := range spec.Fields {
+		result = append(result, short{f.ID, f.Label, f.Widget, f.Multiple, f.Required})
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("result: %+v expected: %+v", result, expected)
+	}
+	if f := spec.Fields[3]; !reflect.DeepEqual(f.Choices, []string{"fast", "slow"}) {
+		t.Errorf("result: %v expected: the choices of --mode", f.Choices)
+	}
+	if f := spec.Fields[5]; f.Min != 1 || f.Max != -1 {
+		t.Errorf("result: %d..%d expected: 1..-1", f.Min, f.Max)
+	}
+	if _, err := json.Marshal(spec); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInfer(t *testing.T) {
+	for i, tt := range []struct {
+		leaf     docopt.Pattern
+		expected Widget
+	}{
+		{docopt.Pattern{Name: "<file>"}, File},
+		{docopt.Pattern{Name: "<input-path>"}, File},
+		{docopt.Pattern{Name: "<logfile>"}, File},
+		{docopt.Pattern{Name: "<outdir>"}, Dir},
+		{docopt.Pattern{Name: "--jobs", Long: "--jobs", Metavar: "N"}, Number},
+		{docopt.Pattern{Name: "--depth", Long: "--depth", Metavar: "<depth>"}, Number},
+		{docopt.Pattern{Name: "--ratio", Long: "--ratio", Metavar: "R", Default: "0.5"}, Number},
+		{docopt.Pattern{Name: "--name", Long: "--name", Metavar: "NAME"}, Text},
+		{docopt.Pattern{Name: "--file-name", Long: "--file-name", Metavar: "S"}, Text},
+		{docopt.Pattern{Name: "<input>", Stdin: true}, File},
+	} {
+		if result := infer(&tt.leaf); result != tt.expected {
+			t.Errorf("testcase: %d result: %v expected: %v", i, result, tt.expected)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	pat, err := docopt.ParsePattern(usage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Export("tool", pat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read struct {
+		Version int
+		Command string
+		Form    struct{ Fields []struct{ ID string } }
+		Values  struct{ Properties map[string]interface{} }
+	}
+	if err = json.Unmarshal(data, &read); err != nil {
+		t.Fatal(err)
+	}
+	if read.Version != FormatVersion || read.Command != "tool" {
+		t.Errorf("result: %d %q expected: %d %q", read.Version, read.Command, FormatVersion, "tool")
+	}
+	// every field is a property of the values submitted
+	for _, f := range read.Form.Fields {
+		if _, ok := read.Values.Properties[f.ID]; !ok {
+			t.Errorf("result: %v expected: a property %s", read.Values.Properties, f.ID)
+		}
+	}
+	if len(read.Form.Fields) != len(read.Values.Properties) {
+		t.Errorf("result: %d fields expected: %d", len(read.Form.Fields), len(read.Values.Properties))
+	}
+}
@@ -0,0 +1,82 @@
+package form
+
+import (
+	"regexp"
+	"strings"
+
+	"gtoc/docopt"
+)
+
+var (
+	// reSentence ends the first sentence of a description, at a full stop
+	// that isn't part of an abbreviation such as "e.g."
+	reSentence = regexp.MustCompile(`[^.]\.\s+[A-Z(\[]`)
+	// reExamples finds what a description gives as examples, up to the end
+	// of the sentence or of the brackets holding it
+	reExamples = regexp.MustCompile(`(?i)(?:\be\.g\.|\bfor example\b|\bexamples?:)[,:]?\s*([^)\];]*?)(?:[)\];]|\.\s|\.?$)`)
+	reOr       = regexp.MustCompile(`\s*,\s*(?:or\s+)?|\s+or\s+`)
+)
+
+// summary returns the first sentence of the description, for a tooltip,
+// the whole description if it's a single one.
+func summary(description string) string {
+	if loc := reSentence.FindStringIndex(description); loc != nil {
+		return description[:loc[0]+2]
+	}
+	return description
+}
+
+// examples returns the values the description gives as examples, such as
+// "2006-01-02" for "The date format, e.g. 2006-01-02.", with their quotes
+// taken off.
+func examples(description string) []string {
+	var list []string
+	for _, m := range reExamples.FindAllStringSubmatch(description, -1) {
+		for _, ex := range reOr.Split(m[1], -1) {
+			if ex = strings.Trim(ex, "\"'`"); ex != "" {
+				list = append(list, ex)
+			}
+		}
+	}
+	return list
+}
+
+// Excerpt is a part of the help text a pattern was parsed from, such as the
+// entry describing an option, as it's printed there.
+type Excerpt struct {
+	Text string `json:"text"`
+	// Line is the line of the help text Text starts at, counted from 1
+	Line int `json:"line"`
+}
+
+// HelpExcerpt returns the lines of doc, the help text pat was parsed from,
+// describing the argument, command or option id: its entry in the options,
+// arguments or commands sections, or else the usage line it's in. It's false
+// if pat has no such node or doesn't know where it is in doc.
+func HelpExcerpt(doc string, pat *docopt.Pattern, id string) (Excerpt, bool) {
+	var span docopt.Span
+	for _, list := range []docopt.PatternList{pat.Positionals(), pat.Commands(), pat.Options()} {
+		for _, leaf := range list {
+			if leaf.Name != id {
+				continue
+			}
+			if !leaf.DescriptionSpan.IsZero() {
+				span = leaf.DescriptionSpan
+			} else if span.IsZero() {
+				span = leaf.Span
+			}
+		}
+	}
+	if span.IsZero() || span.End > len(doc) || span.Start > span.End {
+		return Excerpt{}, false
+	}
+	// whole lines, so that the excerpt keeps the indentation it has in doc
+	start := strings.LastIndex(doc[:span.Start], "\n") + 1
+	end := span.End
+	if i := strings.Index(doc[end:], "\n"); i >= 0 {
+		end += i
+	} else {
+		end = len(doc)
+	}
+	return Excerpt{Text: strings.TrimRight(doc[start:end], " \t\r"), Line: span.Line}, true
+}
@@ -0,0 +1,79 @@
+package form
+
+import (
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestSummary(t *testing.T) {
+	for i, tt := range []struct {
+		description string
+		summary     string
+		examples    []string
+	}{
+		{"", "", nil},
+		{"Talk more.", "Talk more.", nil},
+		{"Where to write. The directory is created if missing.", "Where to write.", nil},
+		{"The date format, e.g. 2006-01-02.", "The date format, e.g. 2006-01-02.", []string{"2006-01-02"}},
+		{"Output format (e.g. json, yaml or toml). Defaults to text.", "Output format (e.g. json, yaml or toml).", []string{"json", "yaml", "toml"}},
+		{"Files to skip, for example `*.log` or 'tmp/'.", "Files to skip, for example `*.log` or 'tmp/'.", []string{"*.log", "tmp/"}},
+		{"Host to use. Example: localhost:8080", "Host to use.", []string{"localhost:8080"}},
+	} {
+		if result := summary(tt.description); result != tt.summary {
+			t.Errorf("testcase: %d result: %v expected: %v", i, result, tt.summary)
+		}
+		if result := examples(tt.description); !reflect.DeepEqual(result, tt.examples) {
+			t.Errorf("testcase: %d result: %q expected: %q", i, result, tt.examples)
+		}
+	}
+}
+
+func TestHelpExcerpt(t *testing.T) {
+	doc := `Usage:
+  prog cp [-vf] [--out=<dir>] <src>...
+  prog rm [options] <src>
+
+Options:
+  -v, --verbose  Talk more.
+  -f             Force.
+  --out=<dir>    Where to, e.g. /tmp. The directory is
+                 created if missing.
+
+Arguments:
+  <src>  The sources.`
+	pat, err := docopt.ParsePattern(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range []struct {
+		id       string
+		expected Excerpt
+		ok       bool
+	}{
+		{"--verbose", Excerpt{"  -v, --verbose  Talk more.", 6}, true},
+		{"--out", Excerpt{"  --out=<dir>    Where to, e.g. /tmp. The directory is\n                 created if missing.", 8}, true},
+		{"<src>", Excerpt{"  <src>  The sources.", 12}, true},
+		{"cp", Excerpt{"  prog cp [-vf] [--out=<dir>] <src>...", 2}, true},
+		{"--nope", Excerpt{}, false},
+	} {
+		result, ok := HelpExcerpt(doc, pat, tt.id)
+		if result != tt.expected || ok != tt.ok {
+			t.Errorf("testcase: %d result: %v %v expected: %v %v", i, result, ok, tt.expected, tt.ok)
+		}
+	}
+	if _, ok := HelpExcerpt("Usage: prog", pat, "--out"); ok {
+		t.Errorf("result: an excerpt out of another help text")
+	}
+
+	spec, err := Build(pat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range spec.Fields {
+		if f.ID == "--out" && (f.Summary != "Where to, e.g. /tmp." || !reflect.DeepEqual(f.Examples, []string{"/tmp"})) {
+			t.Errorf("result: %q %q expected: the summary and examples of --out", f.Summary, f.Examples)
+		}
+	}
+}
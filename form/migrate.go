@@ -0,0 +1,89 @@
+package form
+
+import (
+	"sort"
+
+	"gtoc/docopt"
+)
+
+// Migrate returns the values of the form of before, as the frontend sends
+// them, that fit the form of after, the pattern of the command probed anew
+// once it changed, and the IDs of those that don't, sorted. The value of an
+// option renamed, such as -v getting --verbose as its long name, goes to the
+// option sharing a name with it. A value fits a field that takes it: a flag
+// a checkbox, a count a counter, choices a select of them, a list a list,
+// and text the other fields.
+func Migrate(values map[string]interface{}, before, after *docopt.Pattern) (map[string]interface{}, []string, error) {
+	spec, err := Build(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields := make(map[string]Field, len(spec.Fields))
+	for _, f := range spec.Fields {
+		fields[f.ID] = f
+	}
+	renamed := renames(before, after)
+	kept := make(map[string]interface{})
+	dropped := []string{}
+	for id, v := range values {
+		if to, ok := renamed[id]; ok {
+			id = to
+		}
+		f, ok := fields[id]
+		if !ok {
+			dropped = append(dropped, id)
+			continue
+		}
+		if v, ok = fit(f, v); !ok {
+			dropped = append(dropped, id)
+			continue
+		}
+		kept[id] = v
+	}
+	sort.Strings(dropped)
+	return kept, dropped, nil
+}
+
+// fit returns the value v as the field takes it, false if it doesn't.
+func fit(f Field, v interface{}) (interface{}, bool) {
+	list, isList := v.([]interface{})
+	switch f.Widget {
+	case Checkbox:
+		_, ok := v.(bool)
+		return v, ok
+	case Counter:
+		n, ok := v.(float64)
+		return v, ok && (f.Max < 0 || int(n) <= f.Max)
+	case Select:
+		s, ok := v.(string)
+		return v, ok && choice(f, s)
+	case Multiselect:
+		for _, item := range list {
+			if s, ok := item.(string); !ok || !choice(f, s) {
+				return nil, false
+			}
+		}
+		return v, isList
+	}
+	switch {
+	case f.Multiple && !isList:
+		s, ok := v.(string)
+		return []interface{}{s}, ok
+	case f.Multiple:
+		return v, true
+	case isList && len(list) == 1:
+		v = list[0]
+	}
+	_, ok := v.(string)
+	return v, ok
+}
+
+// choice tells whether s is a choice of the field.
+func choice(f Field, s string) bool {
+	for _, c := range f.Choices {
+		if c == s {
+			return true
+		}
+	}
+	return false
+}
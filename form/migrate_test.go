@@ -0,0 +1,58 @@
+package form
+
+import (
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestMigrate(t *testing.T) {
+	before, err := docopt.ParsePattern(`Usage: tool [-v] [-q...] [--mode=<m>] [--out=<file>] [--tag=<t>...] <src>...
+
+Options:
+  -v            Talk more.
+  -q            Talk less.
+  --mode=<m>    The mode (choices: fast, slow).
+  --out=<file>  The output.
+  --tag=<t>     A tag.
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := docopt.ParsePattern(`Usage: tool [-v] [-q] [--mode=<m>] [--tags=<t>] [--out=<file>...] <src>
+
+Options:
+  -v, --verbose  Talk more.
+  -q             Talk less.
+  --mode=<m>     The mode (choices: fast, safe).
+  --out=<file>   The outputs.
+  --tags=<t>     Tags.
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]interface{}{
+		"-v":     true,
+		"-q":     float64(2),
+		"--mode": "slow",
+		"--out":  "a.txt",
+		"--tag":  []interface{}{"x"},
+		"<src>":  []interface{}{"in.txt"},
+	}
+	kept, dropped, err := Migrate(values, before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"--verbose": true,
+		"--out":     []interface{}{"a.txt"},
+		"<src>":     "in.txt",
+	}
+	if !reflect.DeepEqual(kept, expected) {
+		t.Errorf("result: %v expected: %v", kept, expected)
+	}
+	if expected := []string{"--mode", "--tag", "-q"}; !reflect.DeepEqual(dropped, expected) {
+		t.Errorf("result: %v expected: %v", dropped, expected)
+	}
+}
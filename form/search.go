@@ -0,0 +1,138 @@
+package form
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"gtoc/docopt"
+)
+
+// Index is the names, aliases and descriptions of the arguments, commands
+// and options of a pattern, for a filter box of the form to find fields in
+// tools with hundreds of options.
+type Index struct {
+	entries []entry
+}
+
+type entry struct {
+	id          string
+	names       []string
+	description string
+}
+
+// NewIndex indexes the leaves of pat, in the order of the usage.
+func NewIndex(pat *docopt.Pattern) *Index {
+	x := &Index{}
+	seen := make(map[string]bool)
+	for _, list := range []docopt.PatternList{pat.Positionals(), pat.Commands(), pat.Options()} {
+		for _, leaf := range list {
+			if seen[leaf.Name] {
+				continue
+			}
+			seen[leaf.Name] = true
+			e := entry{id: leaf.Name, description: strings.ToLower(leaf.Description)}
+			for _, name := range []string{leaf.Name, leaf.Short, leaf.Long, leaf.Metavar, leaf.EnvVar} {
+				if name != "" {
+					e.names = append(e.names, strings.ToLower(name))
+				}
+			}
+			x.entries = append(x.entries, e)
+		}
+	}
+	return x
+}
+
+// Search returns the IDs of the fields matching query, the best matches
+// first and at most limit of them, all of them if limit isn't positive. A
+// name matches if the letters of query appear in it in order, such as "nv"
+// for --no-verify, ranked higher when they're together and at the start of
+// words; a description matches if it has every word of query.
+func (x *Index) Search(query string, limit int) []string {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return []string{}
+	}
+	type match struct {
+		id    string
+		score int
+		order int
+	}
+	var matches []match
+	for i, e := range x.entries {
+		best := describes(e.description, query)
+		for _, name := range e.names {
+			if score := fuzzy(name, query); score > best {
+				best = score
+			}
+		}
+		if best > 0 {
+			matches = append(matches, match{e.id, best, i})
+		}
+	}
+	sort.Slice(matches, func(i, k int) bool {
+		if matches[i].score != matches[k].score {
+			return matches[i].score > matches[k].score
+		}
+		return matches[i].order < matches[k].order
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = m.id
+	}
+	return ids
+}
+
+// fuzzy returns how well query matches name, 0 if the letters of query
+// aren't all in name in order.
+func fuzzy(name, query string) int {
+	bare := strings.TrimLeft(name, "-<")
+	switch {
+	case name == query || bare == query:
+		return 1000
+	case strings.HasPrefix(bare, query):
+		return 500 - len(bare)
+	}
+	score, q, last := 0, []rune(query), -2
+	i := 0
+	text := []rune(name)
+	for k, r := range text {
+		if i == len(q) {
+			break
+		}
+		if r != q[i] {
+			continue
+		}
+		score++
+		if k == last+1 {
+			score += 5
+		}
+		if k == 0 || !unicode.IsLetter(text[k-1]) && !unicode.IsDigit(text[k-1]) {
+			score += 8
+		}
+		last = k
+		i++
+	}
+	if i < len(q) {
+		return 0
+	}
+	if score -= len(text) / 4; score < 1 {
+		score = 1
+	}
+	return score
+}
+
+// describes returns how well the description matches query, 0 unless it
+// has every word of query.
+func describes(description, query string) int {
+	words := strings.Fields(query)
+	for _, w := range words {
+		if !strings.Contains(description, w) {
+			return 0
+		}
+	}
+	return 2 * len(words)
+}
@@ -0,0 +1,46 @@
+package form
+
+import (
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestSearch(t *testing.T) {
+	pat, err := docopt.ParsePattern(`Usage: git-commit [options] [--] [<pathspec>...]
+
+Options:
+  -a, --all             Commit all changed files.
+  -m, --message=<msg>   Use the given message as the commit message.
+  -n, --no-verify       Bypass the pre-commit and commit-msg hooks.
+  -v, --verbose         Show the diff in the commit message template.
+  --amend               Amend the previous commit.
+  --author=<author>     Override the commit author.
+  --dry-run             Show what would be committed.
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := NewIndex(pat)
+	for i, tt := range []struct {
+		query    string
+		limit    int
+		expected []string
+	}{
+		{"--amend", 0, []string{"--amend"}},
+		{"-m", 1, []string{"--message"}},
+		{"me", 0, []string{"--message", "--amend", "--verbose"}},
+		{"me", 2, []string{"--message", "--amend"}},
+		{"nv", 0, []string{"--no-verify"}},
+		{"pre-commit hooks", 0, []string{"--no-verify"}},
+		{"AUTH", 0, []string{"--author"}},
+		{"  ", 0, []string{}},
+		{"zzz", 0, []string{}},
+	} {
+		result := x.Search(tt.query, tt.limit)
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("testcase: %d result: %v expected: %v", i, result, tt.expected)
+		}
+	}
+}
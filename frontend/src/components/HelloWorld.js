@@ -15,9 +15,9 @@ class HelloWorld extends React.Component {
   handleOpenModal () {
     this.setState({ showModal: true });
 
-    window.backend.basic().then(result =>
+    window.go.main.Backend.Version().then(version =>
       this.setState({
-        result
+        result: `API version ${version}`
       })
     );
   }
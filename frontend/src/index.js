@@ -4,8 +4,4 @@ import 'core-js/stable';
 import './index.css';
 import App from './App';
 
-import * as Wails from '@wailsapp/runtime';
-
-Wails.Init(() => {
-  ReactDOM.render(<App />, document.getElementById('app'));
-});
+ReactDOM.render(<App />, document.getElementById('app'));
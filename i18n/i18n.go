@@ -0,0 +1,105 @@
+// Package i18n serves the strings of the GUI and the messages of the errors
+// of the backend in the language of the user, out of a message catalog.
+package i18n
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// Bundle is the strings of the GUI in a language, by key, for the frontend
+// to show. Locale is the language they're in, the one supported closest to
+// the one asked for.
+type Bundle struct {
+	Locale   string            `json:"locale"`
+	Messages map[string]string `json:"messages"`
+}
+
+// Catalog is the strings and messages of gtoc in every language they're
+// translated to, falling back to English for those that aren't.
+type Catalog struct {
+	builder *catalog.Builder
+	tags    []language.Tag
+	matcher language.Matcher
+}
+
+// New returns the catalog of the messages of gtoc.
+func New() *Catalog {
+	c := &Catalog{builder: catalog.NewBuilder(catalog.Fallback(language.English))}
+	for _, lang := range languages {
+		tag := language.MustParse(lang)
+		c.tags = append(c.tags, tag)
+		for key, texts := range messages {
+			if text, ok := texts[lang]; ok {
+				c.builder.SetString(tag, key, text)
+			}
+		}
+	}
+	c.matcher = language.NewMatcher(c.tags)
+	return c
+}
+
+// Languages returns the locales of the languages of the catalog, English
+// first.
+func (c *Catalog) Languages() []string {
+	return append([]string(nil), languages...)
+}
+
+// Match returns the locale of the catalog's language closest to locale,
+// English if none is close or locale isn't one.
+func (c *Catalog) Match(locale string) string {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return languages[0]
+	}
+	_, i, confidence := c.matcher.Match(tag)
+	if confidence == language.No {
+		return languages[0]
+	}
+	return languages[i]
+}
+
+// Bundle returns the strings of the GUI in the catalog's language closest
+// to locale. The messages are formats, as for Sprintf.
+func (c *Catalog) Bundle(locale string) Bundle {
+	locale = c.Match(locale)
+	b := Bundle{Locale: locale, Messages: make(map[string]string, len(messages))}
+	for key, texts := range messages {
+		text, ok := texts[locale]
+		if !ok {
+			text = texts[languages[0]]
+		}
+		b.Messages[key] = text
+	}
+	return b
+}
+
+// Sprintf formats the message key in the catalog's language closest to
+// locale, like fmt.Sprintf.
+func (c *Catalog) Sprintf(locale, key string, args ...interface{}) string {
+	p := message.NewPrinter(language.MustParse(c.Match(locale)), message.Catalog(c.builder))
+	return p.Sprintf(key, args...)
+}
+
+// System returns the locale of the desktop, as the environment tells it,
+// such as "de-DE" for LANG=de_DE.UTF-8. It's "" if there's none.
+func System() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := os.Getenv(name)
+		if i := strings.IndexAny(locale, ".@"); i >= 0 {
+			locale = locale[:i]
+		}
+		switch locale {
+		case "":
+			continue
+		case "C", "POSIX":
+			return ""
+		}
+		return strings.Replace(locale, "_", "-", -1)
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package i18n
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	c := New()
+	for i, tt := range []struct {
+		locale   string
+		expected string
+	}{
+		{"de", "de"},
+		{"de-AT", "de"},
+		{"fr_CA", "fr"},
+		{"ko-KR", "ko"},
+		{"en-GB", "en"},
+		{"ja", "en"},
+		{"", "en"},
+		{"not a locale", "en"},
+	} {
+		if result := c.Match(tt.locale); result != tt.expected {
+			t.Errorf("testcase: %d result: %v expected: %v", i, result, tt.expected)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	// every message is in English, the fallback
+	for key, texts := range messages {
+		if texts["en"] == "" {
+			t.Errorf("result: no English message for %s", key)
+		}
+		for lang := range texts {
+			found := false
+			for _, l := range languages {
+				found = found || l == lang
+			}
+			if !found {
+				t.Errorf("result: message %s in unknown language %s", key, lang)
+			}
+		}
+	}
+
+	c := New()
+	if b := c.Bundle("de-DE"); b.Locale != "de" || b.Messages["form.run"] != "Ausführen" || len(b.Messages) != len(messages) {
+		t.Errorf("result: %v expected: the German bundle", b)
+	}
+	for i, tt := range []struct {
+		locale   string
+		expected string
+	}{
+		{"en", "no session 3"},
+		{"fr", "aucune session 3"},
+		{"ja", "no session 3"},
+	} {
+		if result := c.Sprintf(tt.locale, "error.noSession", "3"); result != tt.expected {
+			t.Errorf("testcase: %d result: %v expected: %v", i, result, tt.expected)
+		}
+	}
+}
+
+func TestSystem(t *testing.T) {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+	for i, tt := range []struct {
+		all, lang string
+		expected  string
+	}{
+		{"", "de_DE.UTF-8", "de-DE"},
+		{"fr_FR@euro", "de_DE.UTF-8", "fr-FR"},
+		{"C", "de_DE.UTF-8", ""},
+		{"", "", ""},
+	} {
+		os.Setenv("LC_ALL", tt.all)
+		os.Setenv("LANG", tt.lang)
+		if result := System(); result != tt.expected {
+			t.Errorf("testcase: %d result: %v expected: %v", i, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package i18n
+
+// languages are the locales the messages are translated to, English first
+// as the fallback.
+var languages = []string{"en", "de", "fr", "ko"}
+
+// messages are the strings of the GUI and the messages of the errors of the
+// backend, by key and locale. Keys starting with "error." are formats of
+// errors, see Catalog.Sprintf.
+var messages = map[string]map[string]string{
+	"app.pickTarget": {
+		"en": "Pick a command",
+		"de": "Befehl auswählen",
+		"fr": "Choisir une commande",
+		"ko": "명령 선택",
+	},
+	"app.search": {
+		"en": "Search commands",
+		"de": "Befehle suchen",
+		"fr": "Rechercher des commandes",
+		"ko": "명령 검색",
+	},
+	"tabs.new": {
+		"en": "New tab",
+		"de": "Neuer Tab",
+		"fr": "Nouvel onglet",
+		"ko": "새 탭",
+	},
+	"tabs.close": {
+		"en": "Close tab",
+		"de": "Tab schließen",
+		"fr": "Fermer l'onglet",
+		"ko": "탭 닫기",
+	},
+	"form.run": {
+		"en": "Run",
+		"de": "Ausführen",
+		"fr": "Exécuter",
+		"ko": "실행",
+	},
+	"form.runTerminal": {
+		"en": "Run in a terminal",
+		"de": "Im Terminal ausführen",
+		"fr": "Exécuter dans un terminal",
+		"ko": "터미널에서 실행",
+	},
+	"form.preview": {
+		"en": "Command line",
+		"de": "Befehlszeile",
+		"fr": "Ligne de commande",
+		"ko": "명령줄",
+	},
+	"form.required": {
+		"en": "Required",
+		"de": "Erforderlich",
+		"fr": "Obligatoire",
+		"ko": "필수",
+	},
+	"form.workDir": {
+		"en": "Working directory",
+		"de": "Arbeitsverzeichnis",
+		"fr": "Répertoire de travail",
+		"ko": "작업 디렉터리",
+	},
+	"form.environment": {
+		"en": "Environment",
+		"de": "Umgebung",
+		"fr": "Environnement",
+		"ko": "환경 변수",
+	},
+	"jobs.title": {
+		"en": "Jobs",
+		"de": "Aufträge",
+		"fr": "Tâches",
+		"ko": "작업",
+	},
+	"jobs.cancel": {
+		"en": "Cancel",
+		"de": "Abbrechen",
+		"fr": "Annuler",
+		"ko": "취소",
+	},
+	"jobs.clear": {
+		"en": "Clear finished",
+		"de": "Beendete entfernen",
+		"fr": "Effacer les terminées",
+		"ko": "완료된 작업 지우기",
+	},
+	"history.title": {
+		"en": "History",
+		"de": "Verlauf",
+		"fr": "Historique",
+		"ko": "기록",
+	},
+	"history.replay": {
+		"en": "Run again",
+		"de": "Erneut ausführen",
+		"fr": "Exécuter à nouveau",
+		"ko": "다시 실행",
+	},
+	"recent.title": {
+		"en": "Recent",
+		"de": "Zuletzt verwendet",
+		"fr": "Récents",
+		"ko": "최근 항목",
+	},
+	"favorites.title": {
+		"en": "Favorites",
+		"de": "Favoriten",
+		"fr": "Favoris",
+		"ko": "즐겨찾기",
+	},
+	"profiles.title": {
+		"en": "Profiles",
+		"de": "Profile",
+		"fr": "Profils",
+		"ko": "프로필",
+	},
+	"profiles.apply": {
+		"en": "Apply",
+		"de": "Anwenden",
+		"fr": "Appliquer",
+		"ko": "적용",
+	},
+	"settings.title": {
+		"en": "Settings",
+		"de": "Einstellungen",
+		"fr": "Paramètres",
+		"ko": "설정",
+	},
+	"settings.theme": {
+		"en": "Theme",
+		"de": "Design",
+		"fr": "Thème",
+		"ko": "테마",
+	},
+	"settings.language": {
+		"en": "Language",
+		"de": "Sprache",
+		"fr": "Langue",
+		"ko": "언어",
+	},
+	"onboarding.pick": {
+		"en": "Pick the program to make a GUI of",
+		"de": "Wählen Sie das Programm für die GUI",
+		"fr": "Choisissez le programme dont faire une interface",
+		"ko": "GUI로 만들 프로그램을 선택하세요",
+	},
+	"onboarding.options": {
+		"en": "How does it print its help?",
+		"de": "Wie gibt es seine Hilfe aus?",
+		"fr": "Comment affiche-t-il son aide ?",
+		"ko": "도움말을 어떻게 출력합니까?",
+	},
+	"onboarding.preview": {
+		"en": "Is this what it takes?",
+		"de": "Ist es das, was es annimmt?",
+		"fr": "Est-ce bien ce qu'il accepte ?",
+		"ko": "이 인수가 맞습니까?",
+	},
+	"onboarding.confirm": {
+		"en": "Add to the library",
+		"de": "Zur Bibliothek hinzufügen",
+		"fr": "Ajouter à la bibliothèque",
+		"ko": "라이브러리에 추가",
+	},
+	"onboarding.skip": {
+		"en": "Skip",
+		"de": "Überspringen",
+		"fr": "Passer",
+		"ko": "건너뛰기",
+	},
+	"changes.title": {
+		"en": "What changed in %s %s",
+		"de": "Was sich in %s %s geändert hat",
+		"fr": "Ce qui a changé dans %s %s",
+		"ko": "%s %s의 변경 사항",
+	},
+	"changes.added": {
+		"en": "%s was added",
+		"de": "%s kam hinzu",
+		"fr": "%s a été ajouté",
+		"ko": "%s 추가됨",
+	},
+	"changes.removed": {
+		"en": "%s was removed",
+		"de": "%s wurde entfernt",
+		"fr": "%s a été retiré",
+		"ko": "%s 제거됨",
+	},
+	"changes.renamed": {
+		"en": "%s was renamed %s",
+		"de": "%s heißt jetzt %s",
+		"fr": "%s a été renommé %s",
+		"ko": "%s의 이름이 %s(으)로 바뀜",
+	},
+	"changes.default": {
+		"en": "the default of %s went from %q to %q",
+		"de": "der Standardwert von %s wechselte von %q zu %q",
+		"fr": "la valeur par défaut de %s est passée de %q à %q",
+		"ko": "%s의 기본값이 %q에서 %q(으)로 바뀜",
+	},
+	"error.noSession": {
+		"en": "no session %s",
+		"de": "keine Sitzung %s",
+		"fr": "aucune session %s",
+		"ko": "세션 %s 없음",
+	},
+	"error.noTarget": {
+		"en": "no target in session %s",
+		"de": "kein Befehl in Sitzung %s",
+		"fr": "aucune commande dans la session %s",
+		"ko": "세션 %s에 명령 없음",
+	},
+	"error.bakedFor": {
+		"en": "this app is for %s only",
+		"de": "diese App ist nur für %s",
+		"fr": "cette application est réservée à %s",
+		"ko": "이 앱은 %s 전용입니다",
+	},
+	"error.noHelp": {
+		"en": "the help text of %s is unknown",
+		"de": "der Hilfetext von %s ist unbekannt",
+		"fr": "le texte d'aide de %s est inconnu",
+		"ko": "%s의 도움말을 알 수 없음",
+	},
+	"error.noExcerpt": {
+		"en": "the help text doesn't describe %s",
+		"de": "der Hilfetext beschreibt %s nicht",
+		"fr": "le texte d'aide ne décrit pas %s",
+		"ko": "도움말에 %s에 대한 설명이 없음",
+	},
+	"error.noDropTarget": {
+		"en": "no field of the form takes what was dropped",
+		"de": "kein Feld des Formulars nimmt das Abgelegte auf",
+		"fr": "aucun champ du formulaire n'accepte ce qui a été déposé",
+		"ko": "놓은 항목을 받을 필드가 없음",
+	},
+	"error.noRegistry": {
+		"en": "no registry of patterns is set",
+		"de": "keine Musterregistry eingestellt",
+		"fr": "aucun registre de motifs n'est défini",
+		"ko": "패턴 레지스트리가 설정되지 않음",
+	},
+	"error.noVersion": {
+		"en": "the version of %s is unknown",
+		"de": "die Version von %s ist unbekannt",
+		"fr": "la version de %s est inconnue",
+		"ko": "%s의 버전을 알 수 없음",
+	},
+	"error.onboardingStep": {
+		"en": "the onboarding isn't at this step",
+		"de": "die Einrichtung ist nicht bei diesem Schritt",
+		"fr": "l'accueil n'en est pas à cette étape",
+		"ko": "설정이 이 단계에 있지 않습니다",
+	},
+}
@@ -0,0 +1,207 @@
+// Package keymap is the hotkeys of the actions of the GUI, which the user
+// may change in the settings. Hotkeys are written as accelerators such as
+// "CmdOrCtrl+Shift+R", CmdOrCtrl being Cmd on macOS and Ctrl elsewhere.
+package keymap
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Action is an action of the GUI a hotkey triggers.
+type Action string
+
+const (
+	Run    Action = "run"
+	Cancel Action = "cancel"
+	// FocusSearch focuses the filter box of the fields of the form
+	FocusSearch Action = "focusSearch"
+	// TogglePreview shows or hides the preview of the command line
+	TogglePreview Action = "togglePreview"
+)
+
+// Actions are the actions hotkeys trigger, in the order menus list them.
+var Actions = []Action{Run, Cancel, FocusSearch, TogglePreview}
+
+// Labels are how menus name the actions.
+var Labels = map[Action]string{
+	Run:           "Run",
+	Cancel:        "Cancel",
+	FocusSearch:   "Search fields",
+	TogglePreview: "Toggle preview",
+}
+
+// Keymap is the hotkey of every action, "" for none.
+type Keymap struct {
+	Run           string `json:"run"`
+	Cancel        string `json:"cancel"`
+	FocusSearch   string `json:"focusSearch"`
+	TogglePreview string `json:"togglePreview"`
+}
+
+// Default is the keymap until the user changes it.
+var Default = Keymap{
+	Run:           "CmdOrCtrl+Enter",
+	Cancel:        "CmdOrCtrl+.",
+	FocusSearch:   "CmdOrCtrl+F",
+	TogglePreview: "CmdOrCtrl+P",
+}
+
+// Get returns the hotkey of the action, "" if it has none.
+func (m Keymap) Get(a Action) string {
+	switch a {
+	case Run:
+		return m.Run
+	case Cancel:
+		return m.Cancel
+	case FocusSearch:
+		return m.FocusSearch
+	case TogglePreview:
+		return m.TogglePreview
+	}
+	return ""
+}
+
+// Check returns why the keymap can't be, nil if it can: a hotkey that
+// doesn't parse, or that two actions share.
+func (m Keymap) Check() error {
+	taken := make(map[string]Action)
+	for _, a := range Actions {
+		if m.Get(a) == "" {
+			continue
+		}
+		key, err := Parse(m.Get(a))
+		if err != nil {
+			return fmt.Errorf("hotkey of %s: %v", a, err)
+		}
+		if other, ok := taken[key.String()]; ok {
+			return fmt.Errorf("%s is the hotkey of both %s and %s", key, other, a)
+		}
+		taken[key.String()] = a
+	}
+	return nil
+}
+
+// Modifier is a key held down with the key of a hotkey.
+type Modifier string
+
+const (
+	// CmdOrCtrl is Cmd on macOS and Ctrl elsewhere
+	CmdOrCtrl Modifier = "CmdOrCtrl"
+	Ctrl      Modifier = "Ctrl"
+	// Alt is Option on macOS
+	Alt   Modifier = "Alt"
+	Shift Modifier = "Shift"
+)
+
+// modifiers are the modifiers by the names they're written with, in lower
+// case, in the order hotkeys are written in.
+var modifiers = []struct {
+	names    []string
+	modifier Modifier
+}{
+	{[]string{"cmdorctrl", "cmd", "command"}, CmdOrCtrl},
+	{[]string{"ctrl", "control"}, Ctrl},
+	{[]string{"alt", "option", "optionoralt"}, Alt},
+	{[]string{"shift"}, Shift},
+}
+
+// named are the keys that aren't characters, by the names they're written
+// with, in lower case.
+var named = map[string]string{
+	"enter": "enter", "return": "enter",
+	"tab": "tab", "space": "space",
+	"escape": "escape", "esc": "escape",
+	"backspace": "backspace", "delete": "delete", "del": "delete",
+	"up": "up", "down": "down", "left": "left", "right": "right",
+	"home": "home", "end": "end",
+	"pageup": "page up", "page up": "page up",
+	"pagedown": "page down", "page down": "page down",
+}
+
+// Key is a hotkey: its modifiers, in the order of the Modifier constants,
+// and its key, a character in lower case or a named key such as "enter" or
+// "f5".
+type Key struct {
+	Modifiers []Modifier
+	Key       string
+}
+
+// Parse parses the hotkey s, such as "CmdOrCtrl+Shift+R" or "Alt+F4", the
+// names of the keys in any case. The key of a hotkey is held with CmdOrCtrl,
+// Ctrl or Alt, not to take over typing, but for the function keys.
+func Parse(s string) (Key, error) {
+	parts := strings.Split(s, "+")
+	if strings.HasSuffix(s, "++") {
+		// the key is + itself
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	held := make(map[Modifier]bool)
+	for _, part := range parts[:len(parts)-1] {
+		m, ok := modifier(part)
+		if !ok {
+			return Key{}, fmt.Errorf("%q in %q isn't a modifier", part, s)
+		}
+		held[m] = true
+	}
+	name := strings.ToLower(strings.TrimSpace(parts[len(parts)-1]))
+	key, ok := named[name]
+	switch {
+	case ok:
+	case function(name):
+		key = name
+	case utf8.RuneCountInString(name) == 1:
+		key = name
+	default:
+		return Key{}, fmt.Errorf("%q isn't a key", s)
+	}
+	k := Key{Key: key}
+	for _, m := range modifiers {
+		if held[m.modifier] {
+			k.Modifiers = append(k.Modifiers, m.modifier)
+		}
+	}
+	if !held[CmdOrCtrl] && !held[Ctrl] && !held[Alt] && !function(key) {
+		return Key{}, fmt.Errorf("%q needs CmdOrCtrl, Ctrl or Alt", s)
+	}
+	return k, nil
+}
+
+// modifier returns the modifier named s.
+func modifier(s string) (Modifier, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, m := range modifiers {
+		for _, name := range m.names {
+			if s == name {
+				return m.modifier, true
+			}
+		}
+	}
+	return "", false
+}
+
+// function tells whether the key is a function key, F1 to F24.
+func function(key string) bool {
+	var n int
+	if _, err := fmt.Sscanf(key, "f%d", &n); err != nil {
+		return false
+	}
+	return n >= 1 && n <= 24 && key == fmt.Sprintf("f%d", n)
+}
+
+// String returns the hotkey as Parse reads it, the same way whichever way
+// it was written.
+func (k Key) String() string {
+	var parts []string
+	for _, m := range k.Modifiers {
+		parts = append(parts, string(m))
+	}
+	key := k.Key
+	if utf8.RuneCountInString(key) == 1 {
+		key = strings.ToUpper(key)
+	} else {
+		key = strings.Title(key)
+	}
+	return strings.Join(append(parts, key), "+")
+}
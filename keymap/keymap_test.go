@@ -0,0 +1,50 @@
+package keymap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for i, tt := range []struct {
+		hotkey   string
+		expected Key
+		written  string
+	}{
+		{"CmdOrCtrl+Enter", Key{[]Modifier{CmdOrCtrl}, "enter"}, "CmdOrCtrl+Enter"},
+		{"shift+cmd+r", Key{[]Modifier{CmdOrCtrl, Shift}, "r"}, "CmdOrCtrl+Shift+R"},
+		{"Option + Page Up", Key{[]Modifier{Alt}, "page up"}, "Alt+Page Up"},
+		{"Ctrl++", Key{[]Modifier{Ctrl}, "+"}, "Ctrl++"},
+		{"CmdOrCtrl+.", Key{[]Modifier{CmdOrCtrl}, "."}, "CmdOrCtrl+."},
+		{"F5", Key{nil, "f5"}, "F5"},
+		{"Shift+F12", Key{[]Modifier{Shift}, "f12"}, "Shift+F12"},
+	} {
+		result, err := Parse(tt.hotkey)
+		if err != nil || !reflect.DeepEqual(result, tt.expected) || result.String() != tt.written {
+			t.Errorf("testcase: %d result: %v %s %v expected: %v %s", i, result, result, err, tt.expected, tt.written)
+		}
+	}
+	for i, hotkey := range []string{"", "R", "Shift+R", "Hyper+R", "Ctrl+", "Ctrl+Enterprise", "F25", "F05"} {
+		if result, err := Parse(hotkey); err == nil {
+			t.Errorf("testcase: %d result: %v expected: an error", i, result)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for i, tt := range []struct {
+		keymap Keymap
+		ok     bool
+	}{
+		{Default, true},
+		{Keymap{}, true},
+		{Keymap{Run: "F5", Cancel: "Shift+F5"}, true},
+		{Keymap{Run: "Ctrl+R", Cancel: "Control+r"}, false},
+		{Keymap{Run: "CmdOrCtrl+Enter", TogglePreview: "Cmd+Return"}, false},
+		{Keymap{FocusSearch: "/"}, false},
+	} {
+		if err := tt.keymap.Check(); (err == nil) != tt.ok {
+			t.Errorf("testcase: %d result: %v expected: %v", i, err, tt.ok)
+		}
+	}
+}
@@ -1,20 +1,50 @@
 package main
 
 import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"gtoc/ansi"
+	"gtoc/bake"
+	"gtoc/curated"
+	"gtoc/diff"
 	"gtoc/docopt"
-	"github.com/leaanthony/mewn"
-	"github.com/wailsapp/wails"
+	"gtoc/filter"
+	"gtoc/form"
+	"gtoc/notify"
+	"gtoc/profile"
+	"gtoc/progress"
+	"gtoc/prompt"
+	"gtoc/recipe"
+	"gtoc/rpc"
+	"gtoc/runner"
+	"gtoc/schedule"
+	"gtoc/server"
+	"gtoc/store"
+	"gtoc/structured"
+	"gtoc/tui"
+	"github.com/wailsapp/wails/v2"
+	"github.com/wailsapp/wails/v2/pkg/options"
+	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 	"go.uber.org/zap"
 )
 
-func basic() string {
-	return "World!"
-}
-
 func Pretty_print(pat *docopt.Pattern) {
 	pretty_print(pat, "")
 }
@@ -33,29 +63,1274 @@ func pretty_print(pat *docopt.Pattern, tabs string) {
 	}
 }
 
+// The cancel function of the probe the GUI is waiting on, see start_parse,
+// and the ID of its task, see Backend.GetPattern.
+var (
+	parse_mu     sync.Mutex
+	cancel_parse context.CancelFunc = func() {}
+	parse_task   string
+	parse_tasks  int
+)
+
+// The help texts the commands printed when last probed, by command, that
+// the spans of their patterns point into, see Backend.HelpExcerpt.
+var (
+	help_mu    sync.Mutex
+	help_texts = make(map[string]string)
+)
+
+// get_pattern probes the command for its help text and parses it. It leaves
+// the probes in progress alone: the runs, the server and the other callers
+// probing meanwhile don't cancel each other, nor the probe the GUI waits on.
 func get_pattern(command string) (*docopt.Pattern, error) {
-	zap.S().Debug("Trying with --help option")
-	var output, err = exec.Command("sh", "-c", command, "--help").Output()
+	return get_pattern_in(command, nil)
+}
+
+// get_pattern_in probes the command in the container, or on the host if
+// container is nil, see get_pattern.
+func get_pattern_in(command string, container *runner.Container) (*docopt.Pattern, error) {
+	return get_pattern_context(context.Background(), command, container)
+}
+
+// start_parse cancels the probe the GUI was waiting on and returns the
+// context of the next one and the ID of its task, so the GUI can switch
+// commands without waiting for a slow one. Only the probes the GUI starts,
+// see Backend.GetPattern and Backend.OnboardingPreview, go through it.
+func start_parse() (context.Context, context.CancelFunc, string) {
+	parse_mu.Lock()
+	defer parse_mu.Unlock()
+	cancel_parse()
+	ctx, cancel := context.WithCancel(context.Background())
+	parse_tasks++
+	cancel_parse, parse_task = cancel, strconv.Itoa(parse_tasks)
+	return ctx, cancel, parse_task
+}
+
+// get_pattern_context runs the command with --help, or -h if that fails, and
+// parses what it prints. The command is split into words by runner.Split and
+// run without a shell, so nothing in it is expanded. It's run in the
+// container unless that's nil.
+func get_pattern_context(ctx context.Context, command string, container *runner.Container) (*docopt.Pattern, error) {
+	pat, _, err := probe_help(ctx, command, container, "", func(string) {})
+	return pat, err
+}
+
+// probe_with probes the command the way the options tell, see
+// store.ParseOptions, and its subcommands too if they tell so.
+func probe_with(ctx context.Context, command string, options store.ParseOptions) (*docopt.Pattern, error) {
+	pat, help, err := probe_help(ctx, command, nil, options.Help, func(string) {})
+	if err != nil || !options.Subcommands {
+		return pat, err
+	}
+	if err = discover_subcommands(ctx, command, pat, help, func(string, int, int) {}); err != nil {
+		return nil, err
+	}
+	return pat, nil
+}
+
+// probe_help is get_pattern_context, also returning the help text, which
+// runs the command with the help argument in place of --help and -h unless
+// it's empty, and calls stage with "probe" before running the command and
+// "parse" before parsing what it printed. The pattern of a program run on
+// the host with no arguments is taken from the curated ones if there's one
+// for its version, see curated_pattern, with no help text.
+func probe_help(ctx context.Context, command string, container *runner.Container, help string, stage func(stage string)) (*docopt.Pattern, string, error) {
+	words, err := runner.Split(command)
 	if err != nil {
-		zap.S().Warnf("Executing the command '%s --help' failed: %s", command, err)
-		zap.S().Debug("Trying with -h option")
-		output, err = exec.Command("sh", "-c", command, "-h").Output()
+		return nil, "", err
+	}
+	if container != nil {
+		if words, err = container.Wrap(words); err != nil {
+			return nil, "", err
+		}
+	}
+	stage("probe")
+	if container == nil && help == "" && len(words) == 1 {
+		if pat := curated_pattern(ctx, words[0]); pat != nil {
+			return pat, "", nil
+		}
+	}
+	var output []byte
+	if help != "" {
+		output, err = exec.CommandContext(ctx, words[0], append(words[1:], help)...).Output()
 		if err != nil {
-			return nil, fmt.Errorf("Executing the command '%s -h' failed: %s", command, err)
+			if ctx.Err() != nil {
+				return nil, "", ctx.Err()
+			}
+			return nil, "", exec_error(command, []string{help}, err)
 		}
+	} else if output, err = get_help(ctx, command, words); err != nil {
+		return nil, "", err
 	}
+	stage("parse")
 	var pat *docopt.Pattern
-	pat, err = docopt.ParsePattern(string(output))
+	pat, err = docopt.ParsePatternContext(ctx, string(output))
 	if err != nil {
-		return nil, fmt.Errorf("Parsing pattern failed:\n%s", err)
+		// err is a *docopt.ParseError, telling an unparseable help text
+		// apart from a command that has none
+		return nil, "", err
 	}
-	Pretty_print(pat)
-	return pat, err
+	pat.MarkProvenance(docopt.Provenance{Kind: docopt.SourceHelp, Source: command})
+	help_mu.Lock()
+	help_texts[command] = string(output)
+	help_mu.Unlock()
+	return pat, string(output), nil
+}
+
+// curated_pattern returns the curated pattern of the program for the
+// version it tells, nil if there's none, see package curated.
+func curated_pattern(ctx context.Context, program string) *docopt.Pattern {
+	tool := strings.TrimSuffix(filepath.Base(program), ".exe")
+	pat, version, err := curated.Find(tool, func(args []string) (string, error) {
+		output, err := exec.CommandContext(ctx, program, args...).Output()
+		return string(output), err
+	})
+	if err != nil {
+		zap.S().Warnf("The curated pattern of %s is broken: %s", tool, err)
+		return nil
+	}
+	if pat != nil {
+		zap.S().Debugf("Using the curated pattern of %s %s", tool, version)
+	}
+	return pat
+}
+
+// get_help runs the words of the command with --help, or -h if that fails,
+// and returns what it prints.
+func get_help(ctx context.Context, command string, words []string) ([]byte, error) {
+	zap.S().Debug("Trying with --help option")
+	output, err := exec.CommandContext(ctx, words[0], append(words[1:], "--help")...).Output()
+	if err == nil {
+		return output, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	zap.S().Warnf("Executing the command '%s --help' failed: %s", command, err)
+	zap.S().Debug("Trying with -h option")
+	output, err = exec.CommandContext(ctx, words[0], append(words[1:], "-h")...).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, exec_error(command, []string{"-h"}, err)
+	}
+	return output, nil
+}
+
+// max_subcommands bounds how many of the subcommands a help text lists are
+// probed, see discover_subcommands.
+const max_subcommands = 100
+
+// discover_subcommands probes the subcommands the help text of the command
+// lists for their own help texts, see docopt.Pattern.ListedSubcommands, and
+// attaches the patterns of those that have one to pat. progress is called
+// before every probe with the subcommand, how many were probed and how many
+// there are. It gives up with the context's error as soon as ctx is done.
+func discover_subcommands(ctx context.Context, command string, pat *docopt.Pattern, help string, progress func(sub string, done, total int)) error {
+	names := pat.ListedSubcommands(help)
+	if len(names) > max_subcommands {
+		names = names[:max_subcommands]
+	}
+	for i, name := range names {
+		sub := command + " " + runner.Quote([]string{name})
+		progress(sub, i, len(names))
+		subpat, _, err := probe_help(ctx, sub, nil, "", func(string) {})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			zap.S().Debugf("Probing the subcommand '%s' failed: %s", sub, err)
+			continue
+		}
+		pat.AttachSubcommand(name, subpat)
+	}
+	return nil
+}
+
+// exec_error wraps a failed help probe of command in a *docopt.ExecError,
+// keeping whatever the command printed on stderr.
+func exec_error(command string, args []string, err error) error {
+	var stderr string
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		stderr = string(exitErr.Stderr)
+	}
+	return &docopt.ExecError{Command: command, Args: args, Stderr: stderr, Err: err}
+}
+
+// Jobs runs the commands composed in the GUI, for the Backend. Their output is
+// emitted to the frontend as "run:output" events carrying a StyledChunk, or
+// "run:terminal" events carrying the raw runner.Chunk for jobs run on a
+// terminal, followed by a "run:exit" event carrying the runner.RunResult. What
+// jobs write to the files they follow is emitted as "run:file" events carrying
+// the raw runner.Chunk, for a panel of its own. The prompts jobs run on a
+// terminal wait for the answer of are emitted as "run:prompt" events carrying a
+// PromptEvent, see Answer. The progress a job reports in its output is emitted
+// as "run:progress" events carrying a ProgressEvent, and the progress of
+// batches as "run:batch" events carrying a runner.BatchStatus. Jobs run with
+// notify set are notified on the desktop when they end while the window isn't
+// focused, see SetFocused.
+type Jobs struct {
+	view      view
+	events    *job_events
+	runner    *runner.JobManager
+	history   *runner.History
+	workdirs  *store.WorkDirs
+	recent    *store.Recent
+	favorites *store.Favorites
+	profiles  *profile.Store
+	scheduler *schedule.Scheduler
+	recipes   *recipe.Store
+	// running cancels the runs of recipes going on, by run, see RunRecipe
+	running_mu sync.Mutex
+	running    map[string]context.CancelFunc
+	// parse_options tells how the commands of the library are probed, see
+	// probe_run, nil in an app baked
+	parse_options func(command string) (store.ParseOptions, bool)
+}
+
+// startup opens the stores and starts the scheduler once the app is up in
+// the view.
+func (j *Jobs) startup(v view) error {
+	j.view = v
+	dir, err := store.Dir()
+	if err != nil {
+		return err
+	}
+	j.events = new_job_events(v)
+	j.history, err = runner.OpenHistory(filepath.Join(dir, "history"), j.events)
+	if err != nil {
+		return err
+	}
+	j.runner = runner.NewJobManager(j.history)
+	j.workdirs, err = store.OpenWorkDirs(filepath.Join(dir, "workdirs.json"))
+	if err != nil {
+		return err
+	}
+	j.recent, err = store.OpenRecent(filepath.Join(dir, "recent.json"))
+	if err != nil {
+		return err
+	}
+	j.favorites, err = store.OpenFavorites(filepath.Join(dir, "favorites.json"))
+	if err != nil {
+		return err
+	}
+	j.profiles, err = profile.Open(filepath.Join(dir, "profiles.json"))
+	if err != nil {
+		return err
+	}
+	j.scheduler, err = schedule.Open(filepath.Join(dir, "schedule.json"), j.invoke)
+	if err != nil {
+		return err
+	}
+	j.recipes, err = recipe.Open(filepath.Join(dir, "recipes.json"))
+	if err != nil {
+		return err
+	}
+	j.running = make(map[string]context.CancelFunc)
+	j.scheduler.Start()
+	return nil
+}
+
+// Shells returns the shells a run may go through.
+func (j *Jobs) Shells() []string {
+	return runner.Shells()
+}
+
+// WorkDir returns the working directory runs of command default to, "" for
+// gtoc's own.
+func (j *Jobs) WorkDir(command string) string {
+	return j.workdirs.Get(command)
+}
+
+// SelectWorkDir lets the user pick the working directory of command and
+// keeps it as the command's default. It returns "" if the user gave up.
+func (j *Jobs) SelectWorkDir(command string) (string, error) {
+	dir, err := j.view.OpenDir()
+	if err != nil || dir == "" {
+		return "", err
+	}
+	return dir, j.workdirs.Set(command, dir)
+}
+
+// ListRecent returns the commands parsed or run lately, the pinned ones
+// first, for the "Recent" section of the GUI.
+func (j *Jobs) ListRecent() []store.RecentCommand {
+	return j.recent.List()
+}
+
+// PinRecent pins or unpins the recent command.
+func (j *Jobs) PinRecent(command string, pinned bool) error {
+	return j.recent.Pin(command, pinned)
+}
+
+// RemoveRecent forgets the recent command, pinned or not.
+func (j *Jobs) RemoveRecent(command string) error {
+	return j.recent.Remove(command)
+}
+
+// ClearRecent forgets the recent commands but the pinned ones.
+func (j *Jobs) ClearRecent() error {
+	return j.recent.Clear()
+}
+
+// ListFavorites returns the favorite commands in the order the user put
+// them.
+func (j *Jobs) ListFavorites() []store.Favorite {
+	return j.favorites.List()
+}
+
+// AddFavorite adds the command to the favorites, last, or changes its label
+// and icon if it's one already.
+func (j *Jobs) AddFavorite(fav store.Favorite) error {
+	if fav.Command == "" {
+		return errors.New("no command to add to the favorites")
+	}
+	return j.favorites.Add(fav)
+}
+
+// RemoveFavorite removes the command from the favorites.
+func (j *Jobs) RemoveFavorite(command string) error {
+	return j.favorites.Remove(command)
+}
+
+// MoveFavorite moves the favorite command to position to, 0 being the
+// first, as the user dragged it.
+func (j *Jobs) MoveFavorite(command string, to int) error {
+	return j.favorites.Move(command, to)
+}
+
+// used records command as recent. A list that can't be saved is no reason
+// to fail what the command was used for, so the error is dropped.
+func (j *Jobs) used(command string) {
+	j.recent.Use(command, time.Now())
+}
+
+// RunRequest is a run as the frontend asks for it: the command, the values
+// the user filled in keyed as in the pattern, the environment edits, the
+// working directory, the standard input and optionally a timeout.
+type RunRequest struct {
+	Command string `json:"command"`
+	// Shell is the shell the command runs through, see runner.Shells. The
+	// command is run directly if empty.
+	Shell  string                 `json:"shell"`
+	Values map[string]interface{} `json:"values"`
+	Env    runner.Env             `json:"env"`
+	// Dir is the working directory, the command's default if empty
+	Dir   string       `json:"dir"`
+	Stdin runner.Stdin `json:"stdin"`
+	// Timeout is in seconds, none if zero
+	Timeout float64 `json:"timeout"`
+	// AsRoot runs the command as root, with pkexec or sudo, see
+	// runner.DefaultElevation
+	AsRoot bool `json:"asRoot"`
+	// Container is the container the command runs in, the host if null
+	Container *runner.Container `json:"container"`
+	// Capture is the file the output is written to as well, if any
+	Capture *runner.Capture `json:"capture"`
+	// Follow are the files the command writes to show as they're written,
+	// each a path or the key of a value naming files, such as "--log"
+	Follow []string `json:"follow"`
+	// Retry is how the run is tried again if it fails, never if null
+	Retry *runner.Retry `json:"retry"`
+	// Notify notifies the user on the desktop when the run ends while the
+	// window isn't focused
+	Notify bool `json:"notify"`
+	// Filters are what the standard output goes through before it's
+	// emitted, in order, see filter.Filter
+	Filters []filter.Filter `json:"filters"`
+	// Pipe are the commands the output is piped through, see runner.Request
+	Pipe []RunRequest `json:"pipe"`
+
+	// pattern is the pattern of the command, that of the session it's run
+	// for, see Backend.for_session. The command is probed for it if nil.
+	pattern *docopt.Pattern
+}
+
+// request resolves the pattern of the command into a runner.Request.
+func (j *Jobs) request(req RunRequest) (runner.Request, error) {
+	pat := req.pattern
+	if pat == nil {
+		var err error
+		if pat, err = j.probe_run(req.Command, req.Container); err != nil {
+			return runner.Request{}, err
+		}
+	}
+	if _, err := filter.New(req.Filters); err != nil {
+		return runner.Request{}, err
+	}
+	dir := req.Dir
+	if dir == "" {
+		dir = j.WorkDir(req.Command)
+	}
+	var elevate runner.Elevation
+	if req.AsRoot {
+		if elevate = runner.DefaultElevation(); elevate == "" {
+			return runner.Request{}, errors.New("running as root needs pkexec or sudo")
+		}
+	}
+	values := runner.DecodeValues(req.Values)
+	var pipe []runner.Request
+	for _, stage := range req.Pipe {
+		r, err := j.request(stage)
+		if err != nil {
+			return runner.Request{}, err
+		}
+		pipe = append(pipe, r)
+	}
+	return runner.Request{
+		Program:   req.Command,
+		Shell:     req.Shell,
+		Pattern:   pat,
+		Values:    values,
+		Env:       req.Env,
+		Dir:       dir,
+		Stdin:     req.Stdin,
+		Timeout:   time.Duration(req.Timeout * float64(time.Second)),
+		Elevate:   elevate,
+		Container: req.Container,
+		Capture:   req.Capture,
+		Follow:    follow_paths(req.Follow, values),
+		Retry:     req.Retry,
+		Notify:    req.Notify,
+		Filters:   req.Filters,
+		Pipe:      pipe,
+	}, nil
+}
+
+// probe_run probes the command for a run in the container, or on the host
+// if container is nil. A command of the library is probed the way it was
+// onboarded, see store.ParseOptions, for its run to fit the pattern its form
+// was made of.
+func (j *Jobs) probe_run(command string, container *runner.Container) (*docopt.Pattern, error) {
+	if container == nil && j.parse_options != nil {
+		if options, ok := j.parse_options(command); ok {
+			return probe_with(context.Background(), command, options)
+		}
+	}
+	return get_pattern_in(command, container)
+}
+
+// follow_paths returns the paths of the files to follow, those of the values
+// keyed by an entry of follow or else the entry itself. Values unset name
+// none.
+func follow_paths(follow []string, values docopt.Opts) []string {
+	var paths []string
+	for _, entry := range follow {
+		v, ok := values[entry]
+		if !ok {
+			paths = append(paths, entry)
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				paths = append(paths, v)
+			}
+		case []string:
+			paths = append(paths, v...)
+		}
+	}
+	return paths
+}
+
+// Run starts the requested run and returns the ID of the job. A job running
+// past its timeout ends with the "timedout" state. The result of a pipeline
+// has the exit code of every stage in its stages. A run as root that fails
+// to authenticate either returns runner.ErrAuthFailed or ends with the
+// "authfailed" state, depending on how it's elevated. A run failing with a
+// retry policy is started again as a job of its own, the result of the
+// attempt before having retrying set.
+func (j *Jobs) Run(req RunRequest) (string, error) {
+	r, err := j.request(req)
+	if err != nil {
+		return "", err
+	}
+	id, err := j.runner.Start(r)
+	if err == nil {
+		j.used(req.Command)
+	}
+	return id, err
+}
+
+// Enqueue queues the requested runs as a batch, parallel of them at most at
+// a time, and returns the ID of the batch. The runs waiting are listed by
+// ListJobs as queued until they're started.
+func (j *Jobs) Enqueue(reqs []RunRequest, parallel int) (string, error) {
+	var rs []runner.Request
+	for _, req := range reqs {
+		r, err := j.request(req)
+		if err != nil {
+			return "", err
+		}
+		rs = append(rs, r)
+	}
+	id, err := j.runner.Enqueue(rs, parallel)
+	if err == nil {
+		for _, req := range reqs {
+			j.used(req.Command)
+		}
+	}
+	return id, err
+}
+
+// EnqueueEach queues the requested run once for every value of values, the
+// value keyed key set to it, such as the same command over many files. It's
+// like Enqueue, but probes the command for its pattern once.
+func (j *Jobs) EnqueueEach(req RunRequest, key string, values []interface{}, parallel int) (string, error) {
+	r, err := j.request(req)
+	if err != nil {
+		return "", err
+	}
+	var rs []runner.Request
+	for _, v := range values {
+		each := r
+		each.Values = runner.DecodeValues(req.Values)
+		each.Values[key] = runner.DecodeValues(map[string]interface{}{key: v})[key]
+		each.Follow = follow_paths(req.Follow, each.Values)
+		rs = append(rs, each)
+	}
+	id, err := j.runner.Enqueue(rs, parallel)
+	if err == nil {
+		j.used(req.Command)
+	}
+	return id, err
+}
+
+// ListBatches returns the status of the batches queued, for the jobs panel
+// to show how far each has come.
+func (j *Jobs) ListBatches() []runner.BatchStatus {
+	return j.runner.Batches()
+}
+
+// CancelBatch drops the runs of the batch batchID still queued and cancels
+// those running.
+func (j *Jobs) CancelBatch(batchID string) error {
+	return j.runner.CancelBatch(batchID)
+}
+
+// Preview returns the command line Run would run for the request, as an
+// argv and as a shell-quoted line, without running it.
+func (j *Jobs) Preview(req RunRequest) (runner.Preview, error) {
+	r, err := j.request(req)
+	if err != nil {
+		return runner.Preview{}, err
+	}
+	return runner.DryRun(r)
+}
+
+// RunTerminal is like Run, but runs the command on a terminal of rows by
+// cols characters, for the terminal widget of the frontend.
+func (j *Jobs) RunTerminal(req RunRequest, rows, cols int) (string, error) {
+	r, err := j.request(req)
+	if err != nil {
+		return "", err
+	}
+	id, err := j.runner.StartPTY(r, uint16(rows), uint16(cols))
+	if err == nil {
+		j.used(req.Command)
+	}
+	return id, err
+}
+
+// Result returns the result of the finished job jobID, as the "run:exit"
+// event carried it. Only the last jobs are kept.
+func (j *Jobs) Result(jobID string) (runner.RunResult, error) {
+	result, ok := j.runner.Result(jobID)
+	if !ok {
+		return result, fmt.Errorf("no result kept of job %s", jobID)
+	}
+	return result, nil
+}
+
+// Tail returns the end of the output of the job jobID, for the frontend to
+// show the output of a job without keeping all of it. Only the running and
+// the last jobs are kept.
+func (j *Jobs) Tail(jobID string) (string, error) {
+	tail, ok := j.runner.Tail(jobID)
+	if !ok {
+		return "", fmt.Errorf("no output kept of job %s", jobID)
+	}
+	return tail, nil
+}
+
+// CopyOutput copies the end of the output of the job jobID that Tail
+// returns to the clipboard, without its escape sequences, for the user to
+// paste in a ticket.
+func (j *Jobs) CopyOutput(jobID string) error {
+	tail, err := j.Tail(jobID)
+	if err != nil {
+		return err
+	}
+	return j.view.Copy(ansi.Strip(tail))
+}
+
+// Structured returns the standard output of the finished job jobID parsed,
+// if it's JSON, CSV or TSV, for the frontend to show as a table or a tree
+// along with the text. It returns null for output in none of them.
+func (j *Jobs) Structured(jobID string) (*structured.Data, error) {
+	stdout, truncated, ok := j.runner.Stdout(jobID)
+	if !ok {
+		return nil, fmt.Errorf("no output kept of job %s", jobID)
+	}
+	if truncated {
+		// a part of the data doesn't parse, or not as the whole
+		return nil, nil
+	}
+	data, _ := structured.Detect(stdout)
+	return data, nil
+}
+
+// ListJobs returns the status of the running jobs and the last finished
+// ones, for the jobs panel.
+func (j *Jobs) ListJobs() []runner.JobStatus {
+	return j.runner.Jobs()
+}
+
+// ClearJobs removes the finished jobs and batches from the jobs panel.
+func (j *Jobs) ClearJobs() {
+	j.runner.Clear()
+}
+
+// ListHistory returns the runs recorded in the history, the latest first.
+func (j *Jobs) ListHistory() []runner.HistoryEntry {
+	return j.history.List()
+}
+
+// SearchHistory returns the runs of the history whose command line contains
+// query, the latest first.
+func (j *Jobs) SearchHistory(query string) []runner.HistoryEntry {
+	return j.history.Search(query)
+}
+
+// DiffHistory returns the line diff of the output of the history entry
+// entryA to that of entryB, escape sequences left out, to show what changed
+// between two runs of the same command. The output of every run is recorded
+// up to a limit, see runner.HistoryEntry.
+func (j *Jobs) DiffHistory(entryA, entryB string) ([]diff.Line, error) {
+	var outputs [2]string
+	var programs [2]string
+	for i, id := range []string{entryA, entryB} {
+		entry, ok := j.history.Entry(id)
+		if !ok {
+			return nil, fmt.Errorf("no history entry %s", id)
+		}
+		if entry.Result == nil {
+			return nil, fmt.Errorf("run %s hasn't finished", id)
+		}
+		output, err := ioutil.ReadFile(entry.Output)
+		if err != nil {
+			return nil, err
+		}
+		outputs[i], programs[i] = ansi.Strip(string(output)), entry.Program
+	}
+	if programs[0] != programs[1] {
+		return nil, fmt.Errorf("the runs are of different commands, %s and %s", programs[0], programs[1])
+	}
+	return diff.Text(outputs[0], outputs[1]), nil
+}
+
+// Replay runs the history entry entryID again, with the same values,
+// environment, working directory and standard input, and returns the ID of
+// the job.
+func (j *Jobs) Replay(entryID string) (string, error) {
+	entry, ok := j.history.Entry(entryID)
+	if !ok {
+		return "", fmt.Errorf("no history entry %s", entryID)
+	}
+	return j.invoke(entry.Invocation)
+}
+
+// invoke runs the saved invocation, probing its program for its pattern
+// again. The probe runs aside of the GUI's, which it doesn't cancel.
+func (j *Jobs) invoke(inv runner.Invocation) (string, error) {
+	req, err := resolve(inv)
+	if err != nil {
+		return "", err
+	}
+	return j.runner.Start(req)
+}
+
+// resolve returns the request of the invocation, probing its program and
+// those of its pipeline for their patterns.
+func resolve(inv runner.Invocation) (runner.Request, error) {
+	pat, err := get_pattern_context(context.Background(), inv.Program, inv.Container)
+	if err != nil {
+		return runner.Request{}, err
+	}
+	req := inv.Request(pat)
+	for _, stage := range inv.Pipe {
+		r, err := resolve(stage)
+		if err != nil {
+			return runner.Request{}, err
+		}
+		req.Pipe = append(req.Pipe, r)
+	}
+	return req, nil
+}
+
+// ScheduleRequest is a run to schedule as the frontend asks for it: every
+// so many seconds, or on a cron expression.
+type ScheduleRequest struct {
+	Name string     `json:"name"`
+	Run  RunRequest `json:"run"`
+	// Every is in seconds, used when Cron is empty
+	Every   float64 `json:"every"`
+	Cron    string  `json:"cron"`
+	Enabled bool    `json:"enabled"`
+}
+
+// AddSchedule schedules the run and returns the new entry, with its next
+// run. A run whose values don't fit the command is refused.
+func (j *Jobs) AddSchedule(req ScheduleRequest) (schedule.Entry, error) {
+	r, err := j.request(req.Run)
+	if err != nil {
+		return schedule.Entry{}, err
+	}
+	if _, err = runner.DryRun(r); err != nil {
+		return schedule.Entry{}, err
+	}
+	return j.scheduler.Add(schedule.Entry{
+		Name:       req.Name,
+		Invocation: r.Invocation(),
+		Every:      time.Duration(req.Every * float64(time.Second)),
+		Cron:       req.Cron,
+		Enabled:    req.Enabled,
+	})
+}
+
+// ListSchedules returns the scheduled runs, with when they run next.
+func (j *Jobs) ListSchedules() []schedule.Entry {
+	return j.scheduler.List()
+}
+
+// EnableSchedule enables or disables the scheduled run id.
+func (j *Jobs) EnableSchedule(id string, enabled bool) (schedule.Entry, error) {
+	return j.scheduler.SetEnabled(id, enabled)
+}
+
+// RemoveSchedule removes the scheduled run id.
+func (j *Jobs) RemoveSchedule(id string) error {
+	return j.scheduler.Remove(id)
+}
+
+// SelectStdinFile lets the user pick a file to feed a run as its standard
+// input. It returns "" if the user gave up.
+func (j *Jobs) SelectStdinFile() (string, error) {
+	return j.view.OpenFile()
+}
+
+// Answer answers the prompt the job jobID, run on a terminal, waits on, as
+// if the answer was typed followed by Enter.
+func (j *Jobs) Answer(jobID string, answer string) error {
+	return j.runner.Input(jobID, answer+"\r")
+}
+
+// SetFocused tells whether the window is focused, for the frontend to call
+// whenever it gains or loses the focus, or is minimized or restored. Jobs
+// ending while it isn't are notified on the desktop if they asked to be.
+func (j *Jobs) SetFocused(focused bool) {
+	j.events.set_focused(focused)
+}
+
+// CancelRun stops the job jobID, interrupting it first and killing it if it
+// doesn't exit within the grace period. Its exit is emitted as usual. For a
+// finished job retrying, the attempt waiting for its backoff is dropped, and
+// a run queued is dropped from its batch.
+func (j *Jobs) CancelRun(jobID string) error {
+	return j.runner.Cancel(jobID)
+}
+
+// job_events emits what the jobs report to the frontend, see Jobs.
+type job_events struct {
+	view view
+
+	mu sync.Mutex
+	// streams keep the style and progress of every stream of the running
+	// jobs
+	streams map[job_stream]*stream_state
+	// notified are the programs of the running jobs to notify the end of,
+	// by job, and focused tells whether the window is focused
+	notified map[string]string
+	focused  bool
+	// retried is called with the job a retry is of and the retry's as it
+	// starts, and changed once a job has started or ended, if set
+	retried func(of, id string)
+	changed func()
+	// waits are the channels the results of jobs waited for are sent on,
+	// by job, see wait
+	waits map[string]chan runner.RunResult
+	// watches are the watches of the running jobs requested with one, see
+	// runner.Request
+	watches map[string]runner.StartSink
+}
+
+type job_stream struct {
+	job    string
+	stream runner.Stream
+}
+
+type stream_state struct {
+	parser   ansi.Parser
+	detector progress.Detector
+	prompter prompt.Detector
+	// filter is the chain the text of the standard output of a job run
+	// with filters goes through, nil otherwise
+	filter *filter.Chain
+}
+
+func new_job_events(v view) *job_events {
+	return &job_events{
+		view:     v,
+		streams:  make(map[job_stream]*stream_state),
+		notified: make(map[string]string),
+		focused:  true,
+		waits:    make(map[string]chan runner.RunResult),
+		watches:  make(map[string]runner.StartSink),
+	}
+}
+
+// wait returns the channel the result of the job id is sent on once it
+// ends, that of its last attempt if it's retried. unwait stops waiting.
+func (e *job_events) wait(id string) <-chan runner.RunResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, ok := e.waits[id]
+	if !ok {
+		c = make(chan runner.RunResult, 1)
+		e.waits[id] = c
+	}
+	return c
+}
+
+func (e *job_events) unwait(id string) {
+	e.mu.Lock()
+	delete(e.waits, id)
+	e.mu.Unlock()
+}
+
+// StyledChunk is a runner.Chunk of stdout or stderr parsed into styled
+// segments, escape sequences left out.
+type StyledChunk struct {
+	JobID    string         `json:"jobID"`
+	Stream   runner.Stream  `json:"stream"`
+	Segments []ansi.Segment `json:"segments"`
+}
+
+// PromptEvent is a question a job run on a terminal asks, waiting for the
+// answer.
+type PromptEvent struct {
+	JobID string `json:"jobID"`
+	prompt.Prompt
+}
+
+// ProgressEvent is the progress a job reported in its output.
+type ProgressEvent struct {
+	JobID string `json:"jobID"`
+	progress.Progress
+}
+
+func (e *job_events) Started(id string, req runner.Request) {
+	e.mu.Lock()
+	if req.Notify {
+		e.notified[id] = req.Program
+	}
+	if req.RetryOf != "" && e.retried != nil {
+		e.retried(req.RetryOf, id)
+	}
+	if c, ok := e.waits[req.RetryOf]; ok && req.RetryOf != "" {
+		delete(e.waits, req.RetryOf)
+		e.waits[id] = c
+	}
+	if len(req.Filters) > 0 {
+		// the filters were checked with the request
+		chain, _ := filter.New(req.Filters)
+		e.streams[job_stream{id, runner.Stdout}] = &stream_state{filter: chain}
+	}
+	if req.Watch != nil {
+		e.watches[id] = req.Watch
+	}
+	e.mu.Unlock()
+	if req.Watch != nil {
+		req.Watch.Started(id, req)
+	}
+	// the job manager tracks the job before it's passed on
+	if e.changed != nil {
+		e.changed()
+	}
+}
+
+func (e *job_events) Batch(status runner.BatchStatus) {
+	e.view.Emit("run:batch", status)
+}
+
+func (e *job_events) set_focused(focused bool) {
+	e.mu.Lock()
+	e.focused = focused
+	e.mu.Unlock()
+}
+
+func (e *job_events) Output(c runner.Chunk) {
+	e.mu.Lock()
+	watch := e.watches[c.JobID]
+	e.mu.Unlock()
+	if watch != nil {
+		watch.Output(c)
+	}
+	if c.Stream == runner.File {
+		e.view.Emit("run:file", c)
+		return
+	}
+	// the streams of a job are read concurrently
+	key := job_stream{c.JobID, c.Stream}
+	e.mu.Lock()
+	state, ok := e.streams[key]
+	if !ok {
+		state = &stream_state{}
+		e.streams[key] = state
+	}
+	e.mu.Unlock()
+	segments := state.parser.Feed(c.Data)
+	var text strings.Builder
+	for _, s := range segments {
+		text.WriteString(s.Text)
+	}
+	switch {
+	case c.Stream == runner.Terminal:
+		e.view.Emit("run:terminal", c)
+	case state.filter != nil:
+		// the styles are lost along with the lines filtered out
+		e.emit_filtered(c.JobID, state.filter.Write(text.String()))
+	default:
+		e.view.Emit("run:output", StyledChunk{c.JobID, c.Stream, segments})
+	}
+	if p, ok := state.detector.Feed(text.String()); ok {
+		e.view.Emit("run:progress", ProgressEvent{c.JobID, p})
+	}
+	if c.Stream != runner.Terminal {
+		// programs ask only when there's a terminal to answer on
+		return
+	}
+	if p, ok := state.prompter.Feed(text.String()); ok {
+		e.view.Emit("run:prompt", PromptEvent{c.JobID, p})
+	}
+}
+
+// emit_filtered emits the text a filter let through of the standard output
+// of the job, unstyled.
+func (e *job_events) emit_filtered(jobID string, text string) {
+	if text != "" {
+		e.view.Emit("run:output", StyledChunk{jobID, runner.Stdout, []ansi.Segment{{Text: text}}})
+	}
+}
+
+func (e *job_events) Exit(result runner.RunResult) {
+	e.mu.Lock()
+	var filtered *filter.Chain
+	for key, state := range e.streams {
+		if key.job == result.JobID {
+			delete(e.streams, key)
+			if state.filter != nil {
+				filtered = state.filter
+			}
+		}
+	}
+	program, notified := e.notified[result.JobID]
+	delete(e.notified, result.JobID)
+	watch := e.watches[result.JobID]
+	delete(e.watches, result.JobID)
+	if c, ok := e.waits[result.JobID]; ok && !result.Retrying {
+		delete(e.waits, result.JobID)
+		c <- result
+	}
+	// an attempt retried is notified with the last one
+	notified = notified && !e.focused && !result.Retrying
+	e.mu.Unlock()
+	if filtered != nil {
+		e.emit_filtered(result.JobID, filtered.Close())
+	}
+	e.view.Emit("run:exit", result)
+	if watch != nil {
+		watch.Exit(result)
+	}
+	if e.changed != nil {
+		e.changed()
+	}
+	if notified {
+		// the notification may take a while to show, on Windows above all
+		go func() {
+			if err := notify.Send(notification(program, result)); err != nil {
+				zap.S().Warnf("Notifying the end of job %s failed: %s", result.JobID, err)
+			}
+		}()
+	}
+}
+
+// notification returns the title and body of the notification of the job of
+// program ending in result: whether it succeeded, and how long it took.
+func notification(program string, result runner.RunResult) (string, string) {
+	took := result.Duration.Round(time.Millisecond)
+	switch result.State {
+	case runner.StateExited:
+		if result.Code == 0 {
+			return program + " succeeded", fmt.Sprintf("Finished after %v", took)
+		}
+		return program + " failed", fmt.Sprintf("Exited with code %d after %v", result.Code, took)
+	case runner.StateCanceled:
+		return program + " canceled", fmt.Sprintf("Canceled after %v", took)
+	case runner.StateTimedOut:
+		return program + " timed out", fmt.Sprintf("Stopped after %v", took)
+	case runner.StateAuthFailed:
+		return program + " failed", "Authentication failed"
+	}
+	if result.Signal != "" {
+		return program + " failed", fmt.Sprintf("Killed by %s after %v", result.Signal, took)
+	}
+	return program + " failed", fmt.Sprintf("%s after %v", result.Err, took)
+}
+
+const usage = `gtoc - a GUI for command line tools.
+
+Usage:
+  gtoc serve [<command>] [--addr=<addr>] [--grpc=<addr>]
+  gtoc tui <command>
+  gtoc [<command>]
+  gtoc --form <command>
+  gtoc bake <command> [--out=<file>] [--title=<title>] [--colour=<colour>]
+  gtoc -h | --help
+  gtoc --version
+
+Arguments:
+  <command>          The command to build the GUI of, probed with --help.
+                     It's $GTOC_COMMAND if not given, and else picked in
+                     the GUI. gtoc tui shows its form in the terminal
+                     instead, for when there's no desktop.
+
+Options:
+  --form             Print the form of the command as JSON, for other
+                     frontends to render, instead of opening the GUI. See
+                     form.Document.
+  --out=<file>       The app bake writes, the program of the command with
+                     -gui appended in the working directory by default.
+  --title=<title>    The title of the window of the app baked, the command
+                     by default.
+  --colour=<colour>  The background colour of the window of the app baked.
+  --addr=<addr>      The address gtoc serve serves the GUI to browsers on,
+                     instead of opening a window, and its REST API to
+                     scripts. Anyone who can reach it
+                     can run commands as you, hence the loopback address
+                     by default [default: localhost:8080].
+  --grpc=<addr>      The address gtoc serve serves the gRPC service of the
+                     core on as well, see package rpc, called with the
+                     token of the REST API. It's plain text, so anyone who
+                     can reach it can run commands as you too.`
+
+const version = "0.1.0"
+
+// frontend is the build of the frontend, served to the window by wails.
+//
+//go:embed all:frontend/build
+var frontend embed.FS
+
+// background returns the colour of the background of the window, given as
+// "#rrggbb" or "#rgb", the default dark grey if it isn't one.
+func background(colour string) *options.RGBA {
+	hex := strings.TrimPrefix(colour, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		zap.S().Warnf("Not a colour: %q", colour)
+		return options.NewRGB(0x24, 0x24, 0x24)
+	}
+	return options.NewRGB(uint8(rgb>>16), uint8(rgb>>8), uint8(rgb))
+}
+
+// log_level is the level of the global logger, changed with the settings.
+var log_level = zap.NewAtomicLevel()
+
+// print_form probes the command and prints the form.Document of its form.
+func print_form(command string) error {
+	pat, err := get_pattern(command)
+	if err != nil {
+		return err
+	}
+	doc, err := form.Export(command, pat)
+	if err != nil {
+		return err
+	}
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	return out.Encode(doc)
+}
+
+// serve serves the frontend in assets and the API of the backend to the
+// browsers on addr, along with the REST API of the backend for scripts, see
+// package server, and its gRPC service on grpc_addr if set, see package rpc,
+// until it fails.
+func serve(backend *Backend, assets fs.FS, addr, grpc_addr string) error {
+	srv := server.New(backend, rest_core{backend}, assets)
+	if err := backend.startup(web_view{srv.Hub}); err != nil {
+		return err
+	}
+	if grpc_addr != "" {
+		lis, err := net.Listen("tcp", grpc_addr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := rpc.New(rpc_core{backend}, srv.Token).Serve(lis); err != nil {
+				zap.S().Errorf("Serving the gRPC service failed: %s", err)
+			}
+		}()
+		zap.S().Infof("Serving the gRPC service on %s, called with the metadata %s", lis.Addr(), rpc.TokenKey)
+		warn_exposed(grpc_addr)
+	}
+	warn_exposed(addr)
+	// there's no window to close, the sessions are saved as gtoc is
+	// interrupted
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+		if err := backend.save(); err != nil {
+			zap.S().Warnf("Saving the sessions failed: %s", err)
+		}
+		if err := backend.swap_update(); err != nil {
+			zap.S().Warnf("Updating gtoc failed: %s", err)
+		}
+		os.Exit(0)
+	}()
+	// the token is given to the browser opening the address, for it to call
+	// the API with
+	open := addr
+	if host, port, err := net.SplitHostPort(addr); err == nil && (host == "" || net.ParseIP(host).IsUnspecified()) {
+		open = net.JoinHostPort("localhost", port)
+	}
+	zap.S().Infof("Serving the GUI on %s", srv.URL(open))
+	zap.S().Infof("Scripts call the REST API with the header %s: %s", server.TokenHeader, srv.Token)
+	return srv.ListenAndServe(addr)
+}
+
+// warn_exposed warns that gtoc serves on addr for anyone who can reach it,
+// unless it's a loopback address: the token travels in plain text, over HTTP
+// and gRPC alike, and whoever has it can run commands.
+func warn_exposed(addr string) {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			zap.S().Warnf("Serving on %s, anyone who can reach it can run commands", addr)
+		}
+	}
+}
+
+// run_tui probes the command and shows its form in the terminal, for when
+// there's no desktop for the GUI, see package tui.
+func run_tui(command string) error {
+	pat, err := get_pattern(command)
+	if err != nil {
+		return err
+	}
+	return tui.Run(command, pat)
+}
+
+// bake_app probes the command and bakes its GUI into a copy of gtoc written
+// to out, see package bake.
+func bake_app(command, out string, brand bake.Brand) error {
+	pat, err := get_pattern(command)
+	if err != nil {
+		return err
+	}
+	bundle, err := bake.New(command, pat, brand)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		// the pattern was had, so the command splits
+		words, _ := runner.Split(command)
+		out = filepath.Base(words[0]) + "-gui" + filepath.Ext(exe)
+	}
+	return bake.Write(out, exe, bundle)
 }
 
 func main() {
-	// Initializes the global logger
-	plain, err := zap.NewDevelopment()
+	// an app baked for a single command opens on it, whatever its
+	// arguments
+	var bundle *bake.Bundle
+	if exe, err := os.Executable(); err == nil {
+		if bundle, err = bake.Read(exe); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	var command, addr, grpc_addr string
+	if bundle != nil {
+		command = bundle.Command
+	} else {
+		opts, err := docopt.ParseArgs(usage, nil, version)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		command, _ = opts["<command>"].(string)
+		if opts["--form"] == true || opts["bake"] == true || opts["tui"] == true {
+			switch {
+			case opts["--form"] == true:
+				err = print_form(command)
+			case opts["tui"] == true:
+				err = run_tui(command)
+			default:
+				out, _ := opts["--out"].(string)
+				title, _ := opts["--title"].(string)
+				colour, _ := opts["--colour"].(string)
+				err = bake_app(command, out, bake.Brand{Title: title, Colour: colour})
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+		if opts["serve"] == true {
+			addr, _ = opts["--addr"].(string)
+			grpc_addr, _ = opts["--grpc"].(string)
+		}
+		if command == "" {
+			command = os.Getenv("GTOC_COMMAND")
+		}
+	}
+
+	dir, err := store.Dir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	settings, err := store.OpenSettings(filepath.Join(dir, "settings.json"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	layouts, err := store.OpenLayouts(filepath.Join(dir, "layouts.json"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	prefs := settings.Get()
+	// the window opens as it was last for the command
+	width, height := prefs.Width, prefs.Height
+	if layout, ok := layouts.Get(command); ok && layout.Width > 0 && layout.Height > 0 {
+		width, height = layout.Width, layout.Height
+	}
+
+	// Initializes the global logger, at the level of the settings
+	config := zap.NewDevelopmentConfig()
+	config.Level = log_level
+	log_level.UnmarshalText([]byte(prefs.LogLevel))
+	plain, err := config.Build()
 	if err != nil {
 		fmt.Printf("can't initialize zap logger: %v", err)
 		os.Exit(1)
@@ -63,11 +1338,16 @@ func main() {
 	defer plain.Sync()
 	zap.ReplaceGlobals(plain)
 
-	pat, err := get_pattern("./test.sh")
-	if err != nil {
-		zap.S().Errorf("Getting pattern failed: %s", err)
+	var pat *docopt.Pattern
+	if bundle != nil {
+		pat = bundle.Pattern
+	} else if command != "" {
+		if pat, err = get_pattern(command); err != nil {
+			zap.S().Errorf("Getting pattern failed: %s", err)
+		} else {
+			Pretty_print(pat)
+		}
 	}
-	Pretty_print(pat)
 
 	// if len(argv) == 0 {
 	// 	zap.S().Fatal("No command is entered. exiting...")
@@ -83,20 +1363,58 @@ func main() {
 	// 	zap.S().Fatal("Multiple commands are entered. exiting...")
 	// }
 
-	js := mewn.String("./frontend/build/static/js/main.js")
-	css := mewn.String("./frontend/build/static/css/main.css")
-
-	app := wails.CreateApp(&wails.AppConfig{
-		Width:  1024,
-		Height: 768,
-		Title:  "cli2gui",
-		JS:     js,
-		CSS:    css,
-		Colour: "#242424",
+	title, colour := "cli2gui", "#242424"
+	if bundle != nil {
+		title = bundle.Brand.Title
+		if bundle.Brand.Colour != "" {
+			colour = bundle.Brand.Colour
+		}
+	}
+	assets, err := fs.Sub(frontend, "frontend/build")
+	if err != nil {
+		zap.S().Fatal(err)
+	}
+	backend := NewBackend(command, pat)
+	backend.baked, backend.settings, backend.layouts = bundle, settings, layouts
+	if addr != "" {
+		if err = serve(backend, assets, addr, grpc_addr); err != nil {
+			zap.S().Fatal(err)
+		}
+		return
+	}
+	icon := &tray_icon{tooltip: title}
+	err = wails.Run(&options.App{
+		Width:            width,
+		Height:           height,
+		Title:            title,
+		AssetServer:      &assetserver.Options{Assets: assets},
+		BackgroundColour: background(colour),
+		DragAndDrop:      &options.DragAndDrop{EnableFileDrop: true},
+		OnStartup: func(ctx context.Context) {
+			if err := backend.startup(window_view{ctx}); err != nil {
+				zap.S().Fatalf("Starting up failed: %s", err)
+			}
+			icon.start(ctx, backend)
+			runtime.OnFileDrop(ctx, backend.dropped)
+		},
+		OnBeforeClose: icon.before_close,
+		OnShutdown: func(ctx context.Context) {
+			icon.set(false)
+			if err := backend.save_size(); err != nil {
+				zap.S().Warnf("Saving the size of the window failed: %s", err)
+			}
+			if err := backend.save(); err != nil {
+				zap.S().Warnf("Saving the sessions failed: %s", err)
+			}
+			if err := backend.swap_update(); err != nil {
+				zap.S().Warnf("Updating gtoc failed: %s", err)
+			}
+		},
+		Bind: []interface{}{backend},
 	})
-	app.Bind(basic)
-	app.Bind(get_pattern)
-	app.Run()
+	if err != nil {
+		zap.S().Fatal(err)
+	}
 
 	// // print after flat (flat seems to return leaves only)
 	// var patternList docopt.PatternList
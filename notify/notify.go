@@ -0,0 +1,27 @@
+// Package notify shows native desktop notifications, through the tool each
+// platform has for it: notify-send on Linux and the BSDs, osascript on macOS
+// and PowerShell on Windows.
+package notify
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// App is the name notifications are shown under.
+const App = "gtoc"
+
+// Send shows a notification with the title and body, returning once the
+// platform's tool has taken it.
+func Send(title, body string) error {
+	argv := command(title, body)
+	output, err := exec.Command(argv[0], argv[1:]...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%s: %v: %s", argv[0], err, msg)
+		}
+		return fmt.Errorf("%s: %v", argv[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package notify
+
+import "strings"
+
+// command returns the command line showing the notification with
+// osascript.
+func command(title, body string) []string {
+	return []string{"osascript", "-e",
+		"display notification " + quote(body) + " with title " + quote(App) + " subtitle " + quote(title)}
+}
+
+// quote quotes s as an AppleScript string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package notify
+
+// command returns the command line showing the notification with
+// notify-send, which takes the text as is.
+func command(title, body string) []string {
+	return []string{"notify-send", "--app-name=" + App, "--", title, body}
+}
@@ -0,0 +1,26 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package notify
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	for i, tt := range []struct {
+		title, body string
+		expected    []string
+	}{
+		{"make finished", "Exited with code 0 after 2s",
+			[]string{"notify-send", "--app-name=gtoc", "--", "make finished", "Exited with code 0 after 2s"}},
+		// the text isn't taken for options
+		{"-x", "'$HOME'",
+			[]string{"notify-send", "--app-name=gtoc", "--", "-x", "'$HOME'"}},
+	} {
+		if result := command(tt.title, tt.body); !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("testcase: %d result: %q expected: %q", i, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package notify
+
+import "strings"
+
+// toast is the PowerShell script showing a toast of two lines of text, the
+// title and body being set ahead of it.
+const toast = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($title)) > $null
+$text.Item(1).AppendChild($xml.CreateTextNode($body)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($app).Show([Windows.UI.Notifications.ToastNotification]::new($xml))
+`
+
+// command returns the command line showing the notification as a toast
+// with PowerShell.
+func command(title, body string) []string {
+	script := "$app = " + quote(App) + "; $title = " + quote(title) + "; $body = " + quote(body) + toast
+	return []string{"powershell", "-NoProfile", "-NonInteractive", "-Command", script}
+}
+
+// quote quotes s as a PowerShell string, which doubles single quotes
+// within.
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,158 @@
+// Package onboard is the flow gtoc takes the user through on its first run,
+// with nothing to make a GUI of yet: picking an executable, choosing how its
+// help text is probed, previewing the pattern detected in it and confirming
+// it, for it to become the first entry of the library.
+package onboard
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+
+	"gtoc/docopt"
+	"gtoc/runner"
+	"gtoc/store"
+)
+
+// Step is where the user is in the flow.
+type Step string
+
+const (
+	// Pick asks for the executable, from a file dialog or the PATH.
+	Pick Step = "pick"
+	// Options asks how its help text is probed.
+	Options Step = "options"
+	// Preview shows the pattern detected, for the user to confirm it or go
+	// back.
+	Preview Step = "preview"
+	// Done is the end of the flow, confirmed or skipped.
+	Done Step = "done"
+)
+
+// State is the step of the flow and what the user chose so far. Pattern is
+// the pattern detected, nil until it's previewed.
+type State struct {
+	Step    Step               `json:"step"`
+	Command string             `json:"command"`
+	Options store.ParseOptions `json:"options"`
+	Pattern *docopt.Pattern    `json:"-"`
+}
+
+// ErrStep is returned by the methods of a Flow called at a step they can't
+// be called at.
+var ErrStep = errors.New("not at this step of the onboarding")
+
+// Probe probes the command for its pattern, the way the options tell.
+type Probe func(command string, options store.ParseOptions) (*docopt.Pattern, error)
+
+// Flow is the onboarding of a user. Its methods may be called from several
+// goroutines.
+type Flow struct {
+	mu    sync.Mutex
+	state State
+}
+
+// New returns a flow at its first step.
+func New() *Flow {
+	return &Flow{state: State{Step: Pick}}
+}
+
+// State returns the state of the flow.
+func (f *Flow) State() State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+// Pick makes the command the one to make a GUI of, and goes on to the
+// options, reset. Its program must be an executable found in $PATH or at
+// the path it's given.
+func (f *Flow) Pick(command string) (State, error) {
+	words, err := runner.Split(command)
+	if err != nil {
+		return f.State(), err
+	}
+	if _, err = exec.LookPath(words[0]); err != nil {
+		return f.State(), fmt.Errorf("%s isn't an executable: %w", words[0], err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state.Step == Done {
+		return f.state, ErrStep
+	}
+	f.state = State{Step: Options, Command: command}
+	return f.state, nil
+}
+
+// Choose sets how the help text of the command is probed, and goes on to
+// the preview.
+func (f *Flow) Choose(options store.ParseOptions) (State, error) {
+	if err := options.Check(); err != nil {
+		return f.State(), err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state.Step != Options {
+		return f.state, ErrStep
+	}
+	f.state.Step, f.state.Options, f.state.Pattern = Preview, options, nil
+	return f.state, nil
+}
+
+// Preview probes the command with probe for the pattern to preview. The
+// pattern is dropped if the user went back while it was probed.
+func (f *Flow) Preview(probe Probe) (State, error) {
+	before := f.State()
+	if before.Step != Preview {
+		return before, ErrStep
+	}
+	pat, err := probe(before.Command, before.Options)
+	if err != nil {
+		return before, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state.Step != Preview || f.state.Command != before.Command || f.state.Options != before.Options {
+		return f.state, ErrStep
+	}
+	f.state.Pattern = pat
+	return f.state, nil
+}
+
+// Back goes back a step, but from the first and the last.
+func (f *Flow) Back() State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch f.state.Step {
+	case Options:
+		f.state.Step = Pick
+	case Preview:
+		f.state.Step, f.state.Pattern = Options, nil
+	}
+	return f.state
+}
+
+// Confirm ends the flow with the pattern previewed, returning the entry of
+// the library for it, labelled with label.
+func (f *Flow) Confirm(label string) (store.LibraryEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state.Step != Preview || f.state.Pattern == nil {
+		return store.LibraryEntry{}, ErrStep
+	}
+	data, err := docopt.MarshalPattern(f.state.Pattern)
+	if err != nil {
+		return store.LibraryEntry{}, err
+	}
+	f.state.Step = Done
+	return store.LibraryEntry{Command: f.state.Command, Label: label, Options: f.state.Options, Pattern: data}, nil
+}
+
+// Skip ends the flow without an entry.
+func (f *Flow) Skip() State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = State{Step: Done}
+	return f.state
+}
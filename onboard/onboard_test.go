@@ -0,0 +1,65 @@
+package onboard
+
+import (
+	"errors"
+	"testing"
+
+	"gtoc/docopt"
+	"gtoc/store"
+)
+
+func TestFlow(t *testing.T) {
+	probed := 0
+	probe := func(command string, options store.ParseOptions) (*docopt.Pattern, error) {
+		probed++
+		if options.Help != "--usage" {
+			return nil, errors.New("no help")
+		}
+		return docopt.ParsePattern("Usage: sh [-x] <file>")
+	}
+	f := New()
+	if _, err := f.Choose(store.ParseOptions{}); err != ErrStep {
+		t.Errorf("result: %v expected: %v", err, ErrStep)
+	}
+	for i, command := range []string{"", "'unterminated", "no-such-program-of-gtoc"} {
+		if s, err := f.Pick(command); err == nil || s.Step != Pick {
+			t.Errorf("testcase: %d result: %v %v expected: an error", i, s, err)
+		}
+	}
+	s, err := f.Pick("sh -e")
+	if err != nil || s.Step != Options || s.Command != "sh -e" {
+		t.Fatalf("result: %v %v", s, err)
+	}
+	if _, err = f.Choose(store.ParseOptions{Help: "--help all"}); err == nil {
+		t.Errorf("result: no error expected: an error")
+	}
+	if s, err = f.Choose(store.ParseOptions{Help: "-h"}); err != nil || s.Step != Preview {
+		t.Fatalf("result: %v %v", s, err)
+	}
+	if s, err = f.Preview(probe); err == nil || s.Pattern != nil {
+		t.Errorf("result: %v %v expected: an error", s, err)
+	}
+	if _, err = f.Confirm("Shell"); err != ErrStep {
+		t.Errorf("result: %v expected: %v", err, ErrStep)
+	}
+	if s = f.Back(); s.Step != Options || s.Options.Help != "-h" {
+		t.Errorf("result: %v", s)
+	}
+	f.Choose(store.ParseOptions{Help: "--usage"})
+	if s, err = f.Preview(probe); err != nil || s.Pattern == nil {
+		t.Fatalf("result: %v %v", s, err)
+	}
+	e, err := f.Confirm("Shell")
+	if err != nil || e.Command != "sh -e" || e.Label != "Shell" || e.Options.Help != "--usage" {
+		t.Fatalf("result: %v %v", e, err)
+	}
+	if pat, err := docopt.UnmarshalPattern(e.Pattern); err != nil || pat.FindOption("-x") == nil {
+		t.Errorf("result: %v %v", pat, err)
+	}
+	if s = f.Back(); s.Step != Done {
+		t.Errorf("result: %v expected: %v", s.Step, Done)
+	}
+	if _, err = f.Pick("sh"); err != ErrStep || probed != 2 {
+		t.Errorf("result: %v %d expected: %v 2", err, probed, ErrStep)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"gtoc/docopt"
+	"gtoc/onboard"
+	"gtoc/runner"
+	"gtoc/store"
+	"go.uber.org/zap"
+)
+
+// Onboarding is the state of the onboarding as the frontend reads it, with
+// the pattern previewed, see onboard.Flow.
+type Onboarding struct {
+	onboard.State
+	Pattern *PatternNode `json:"pattern,omitempty"`
+}
+
+// onboarding returns the state of the flow as an Onboarding.
+func onboarding(s onboard.State) *Onboarding {
+	o := &Onboarding{State: s}
+	if s.Pattern != nil {
+		o.Pattern = pattern_node(s.Pattern)
+	}
+	return o
+}
+
+// greet opens the first entry of the library in the session gtoc started
+// with, if it has no target and none was restored, or starts the onboarding
+// if the library is empty. The onboarding is emitted as an "onboarding"
+// event carrying an Onboarding, for the frontend to show its first step.
+func (b *Backend) greet() {
+	b.mu.Lock()
+	for _, s := range b.sessions {
+		if s.target != "" {
+			b.mu.Unlock()
+			return
+		}
+	}
+	b.mu.Unlock()
+	entries := b.library.List()
+	if len(entries) == 0 {
+		flow := onboard.New()
+		b.mu.Lock()
+		b.onboarding = flow
+		b.mu.Unlock()
+		b.view.Emit("onboarding", onboarding(flow.State()))
+		return
+	}
+	e := entries[0]
+	pat, err := docopt.UnmarshalPattern(e.Pattern)
+	if err != nil {
+		zap.S().Warnf("The pattern of %s in the library is unreadable, probing it anew: %s", e.Command, err)
+		ctx, cancel := context.WithTimeout(context.Background(), reparse_timeout)
+		defer cancel()
+		if pat, err = probe_with(ctx, e.Command, e.Options); err != nil {
+			zap.S().Warnf("Opening %s from the library failed: %s", e.Command, err)
+			return
+		}
+	}
+	b.set_first(e.Command, pat)
+	b.view.Emit("sessions", b.ListSessions())
+}
+
+// set_first makes the command, probed for pat, the target of the first
+// session with none, opened if there's none such, and returns its ID.
+func (b *Backend) set_first(command string, pat *docopt.Pattern) string {
+	b.mu.Lock()
+	id := ""
+	for sid, s := range b.sessions {
+		if s.target == "" && (id == "" || less_id(sid, id)) {
+			id = sid
+		}
+	}
+	if id == "" {
+		id = b.open(command, pat)
+	} else {
+		b.sessions[id].set(command, pat)
+	}
+	b.mu.Unlock()
+	b.watch()
+	return id
+}
+
+// less_id tells whether the session ID a was opened before b.
+func less_id(a, b string) bool {
+	m, _ := strconv.Atoi(a)
+	n, _ := strconv.Atoi(b)
+	return m < n
+}
+
+// flow returns the onboarding in progress, or an error if there's none.
+func (b *Backend) flow() (*onboard.Flow, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.onboarding == nil {
+		return nil, b.errorf("error.onboardingStep")
+	}
+	return b.onboarding, nil
+}
+
+// onboarded returns the state of the flow for the frontend, the error
+// translated if it's about the step.
+func (b *Backend) onboarded(s onboard.State, err error) (*Onboarding, error) {
+	if err == onboard.ErrStep {
+		err = b.errorf("error.onboardingStep")
+	}
+	return onboarding(s), err
+}
+
+// GetOnboarding returns the state of the onboarding, nil if the user isn't
+// onboarded, the library having entries or the onboarding being over.
+func (b *Backend) GetOnboarding() *Onboarding {
+	flow, err := b.flow()
+	if err != nil {
+		return nil
+	}
+	return onboarding(flow.State())
+}
+
+// OnboardingPick picks the command to make a GUI of, typed or picked from
+// the PATH, see SearchCommands, and goes on to how it's probed.
+func (b *Backend) OnboardingPick(command string) (*Onboarding, error) {
+	flow, err := b.flow()
+	if err != nil {
+		return nil, err
+	}
+	return b.onboarded(flow.Pick(command))
+}
+
+// OnboardingPickFile lets the user pick the executable in a dialog, see
+// OnboardingPick. The state is left as it is if the user gave up.
+func (b *Backend) OnboardingPickFile() (*Onboarding, error) {
+	flow, err := b.flow()
+	if err != nil {
+		return nil, err
+	}
+	path, err := b.view.OpenFile()
+	if err != nil || path == "" {
+		return onboarding(flow.State()), err
+	}
+	return b.onboarded(flow.Pick(runner.Quote([]string{path})))
+}
+
+// OnboardingChoose chooses how the help text of the command is probed, and
+// goes on to the preview, see OnboardingPreview.
+func (b *Backend) OnboardingChoose(options store.ParseOptions) (*Onboarding, error) {
+	flow, err := b.flow()
+	if err != nil {
+		return nil, err
+	}
+	return b.onboarded(flow.Choose(options))
+}
+
+// OnboardingPreview probes the command the way chosen and returns the state
+// with the pattern detected, for the user to confirm it or go back. Probing
+// anew cancels the probe in progress, see start_parse.
+func (b *Backend) OnboardingPreview() (*Onboarding, error) {
+	flow, err := b.flow()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel, _ := start_parse()
+	defer cancel()
+	return b.onboarded(flow.Preview(func(command string, options store.ParseOptions) (*docopt.Pattern, error) {
+		return probe_with(ctx, command, options)
+	}))
+}
+
+// OnboardingBack goes back a step of the onboarding.
+func (b *Backend) OnboardingBack() (*Onboarding, error) {
+	flow, err := b.flow()
+	if err != nil {
+		return nil, err
+	}
+	return onboarding(flow.Back()), nil
+}
+
+// OnboardingConfirm ends the onboarding with the pattern previewed: the
+// command is added to the library, labelled with label, and made the target
+// of the first session, which is returned. The target is emitted as a
+// "target" event carrying the session ID and the command, as SetTarget
+// does.
+func (b *Backend) OnboardingConfirm(label string) (Session, error) {
+	flow, err := b.flow()
+	if err != nil {
+		return Session{}, err
+	}
+	e, err := flow.Confirm(label)
+	if err != nil {
+		_, err = b.onboarded(flow.State(), err)
+		return Session{}, err
+	}
+	pat := flow.State().Pattern
+	if err = b.library.Add(e); err != nil {
+		return Session{}, err
+	}
+	b.mu.Lock()
+	b.onboarding = nil
+	b.mu.Unlock()
+	b.used(e.Command)
+	id := b.set_first(e.Command, pat)
+	b.view.Emit("target", id, e.Command)
+	b.lay_out(e.Command)
+	return b.GetSession(id)
+}
+
+// OnboardingSkip ends the onboarding without a command, for the user to
+// pick one later. It starts again on the next launch while the library is
+// empty.
+func (b *Backend) OnboardingSkip() {
+	if flow, err := b.flow(); err == nil {
+		flow.Skip()
+	}
+	b.mu.Lock()
+	b.onboarding = nil
+	b.mu.Unlock()
+}
@@ -0,0 +1,133 @@
+// Package profile keeps named presets of the values of a command, such as
+// "nightly backup" and "quick test" for the same tool, to fill its form
+// with again.
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gtoc/docopt"
+	"gtoc/runner"
+	"gtoc/store"
+)
+
+// Profile is a named preset of a command: the values of its form, keyed as
+// in its pattern, the environment edits and the working directory.
+type Profile struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	// Values are as the frontend sends them, see runner.DecodeValues
+	Values map[string]interface{} `json:"values"`
+	Env    runner.Env             `json:"env"`
+	// Dir is the working directory, the command's default if empty
+	Dir     string    `json:"dir"`
+	Updated time.Time `json:"updated"`
+}
+
+// Store is the profiles saved in a file. Its methods may be called from
+// several goroutines.
+type Store struct {
+	file *store.File
+
+	mu       sync.Mutex
+	profiles []Profile
+}
+
+// Open loads the profiles saved at path.
+func Open(path string) (*Store, error) {
+	s := &Store{file: &store.File{Path: path}}
+	if err := s.file.Load(&s.profiles); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns the profiles of command, all of them if it's "", by command
+// and name.
+func (s *Store) List(command string) []Profile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []Profile{}
+	for _, p := range s.profiles {
+		if command == "" || p.Command == command {
+			list = append(list, p)
+		}
+	}
+	sort.Slice(list, func(i, k int) bool {
+		if list[i].Command != list[k].Command {
+			return list[i].Command < list[k].Command
+		}
+		return list[i].Name < list[k].Name
+	})
+	return list
+}
+
+// Get returns the profile name of command.
+func (s *Store) Get(command, name string) (Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(command, name)
+	if i < 0 {
+		return Profile{}, fmt.Errorf("no profile %q of %s", name, command)
+	}
+	return s.profiles[i], nil
+}
+
+// Save saves the profile, replacing the one of its command by the same
+// name if any, and returns it as saved.
+func (s *Store) Save(p Profile) (Profile, error) {
+	if p.Name == "" || p.Command == "" {
+		return Profile{}, errors.New("a profile needs a name and a command")
+	}
+	p.Updated = time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.index(p.Command, p.Name); i >= 0 {
+		s.profiles[i] = p
+	} else {
+		s.profiles = append(s.profiles, p)
+	}
+	return p, s.file.Save(s.profiles)
+}
+
+// Delete removes the profile name of command.
+func (s *Store) Delete(command, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(command, name)
+	if i < 0 {
+		return fmt.Errorf("no profile %q of %s", name, command)
+	}
+	s.profiles = append(s.profiles[:i], s.profiles[i+1:]...)
+	return s.file.Save(s.profiles)
+}
+
+// Stale returns the profiles of command that use values pat doesn't have,
+// the pattern of a new build of its program, by name, each with the values,
+// see Profile.Unknown.
+func (s *Store) Stale(command string, pat *docopt.Pattern) (map[string][]string, error) {
+	stale := make(map[string][]string)
+	for _, p := range s.List(command) {
+		unknown, err := p.Unknown(pat)
+		if err != nil {
+			return nil, err
+		}
+		if len(unknown) > 0 {
+			stale[p.Name] = unknown
+		}
+	}
+	return stale, nil
+}
+
+func (s *Store) index(command, name string) int {
+	for i, p := range s.profiles {
+		if p.Command == command && p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
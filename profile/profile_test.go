@@ -0,0 +1,143 @@
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+	"gtoc/runner"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "profiles.json")
+	s, err := Open(path)
+	if err != nil || len(s.List("")) != 0 {
+		t.Fatalf("result: %v error: %v", s, err)
+	}
+	if _, err = s.Save(Profile{Name: "nameless"}); err == nil {
+		t.Errorf("saving a profile without a command: no error")
+	}
+	nightly := Profile{
+		Name:    "nightly backup",
+		Command: "restic",
+		Values:  map[string]interface{}{"backup": true, "<path>": []interface{}{"/home"}},
+		Env:     runner.Env{Set: map[string]string{"RESTIC_REPOSITORY": "/backup"}},
+		Dir:     "/",
+	}
+	for _, p := range []Profile{nightly, {Name: "quick test", Command: "restic"}, {Name: "all", Command: "make"}} {
+		if _, err = s.Save(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// saving again replaces
+	nightly.Dir = "/home"
+	if _, err = s.Save(nightly); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range s.List("restic") {
+		names = append(names, p.Name)
+	}
+	if expected := []string{"nightly backup", "quick test"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("result: %v expected: %v", names, expected)
+	}
+	p, err := s.Get("restic", "nightly backup")
+	if err != nil || p.Dir != "/home" || !reflect.DeepEqual(p.Values, nightly.Values) || !reflect.DeepEqual(p.Env, nightly.Env) {
+		t.Errorf("result: %+v error: %v expected: %+v", p, err, nightly)
+	}
+	if _, err = s.Get("make", "nightly backup"); err == nil {
+		t.Errorf("getting the profile of another command: no error")
+	}
+
+	if err = s.Delete("restic", "quick test"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Delete("restic", "quick test"); err == nil {
+		t.Errorf("deleting a deleted profile: no error")
+	}
+	if list := s.List(""); len(list) != 2 || list[0].Command != "make" {
+		t.Errorf("result: %v expected: the make and restic profiles", list)
+	}
+
+	// restic 0.17 renamed <path> <paths>
+	pat, err := docopt.ParsePattern("Usage: restic backup [--dry-run] <paths>...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := s.Stale("restic", pat)
+	if expected := map[string][]string{"nightly backup": {"<path>"}}; err != nil || !reflect.DeepEqual(stale, expected) {
+		t.Errorf("result: %v error: %v expected: %v", stale, err, expected)
+	}
+}
+
+func TestShare(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pat, err := docopt.ParsePattern("Usage: tool [--fast] <file>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Profile{Name: "quick", Command: "tool", Values: map[string]interface{}{"--fast": true, "<file>": "a"}}
+	path := filepath.Join(dir, "quick.json")
+	if err = Export(path, p, pat); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Read(path)
+	if err != nil || f.Version != FileVersion || !reflect.DeepEqual(f.Profile, p) {
+		t.Fatalf("result: %+v error: %v", f, err)
+	}
+
+	for i, tt := range []struct {
+		usage   string
+		unknown []string
+	}{
+		{"Usage: tool [--fast] <file>", nil},
+		// the values are still there
+		{"Usage: tool [--fast] [--slow] <file>", nil},
+		{"Usage: tool [--quick] <file>", []string{"--fast"}},
+		{"Usage: tool <path>", []string{"--fast", "<file>"}},
+	} {
+		pat, err := docopt.ParsePattern(tt.usage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = f.Check(pat)
+		var unknown []string
+		if e, ok := err.(*IncompatibleError); ok {
+			unknown = e.Unknown
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(unknown, tt.unknown) {
+			t.Errorf("testcase: %d result: %v expected: %v", i, unknown, tt.unknown)
+		}
+	}
+
+	for i, data := range []string{
+		"not json",
+		`{"version": 1, "profile": {"name": "nameless"}}`,
+		`{"version": 99, "profile": {"name": "n", "command": "tool"}}`,
+	} {
+		ioutil.WriteFile(path, []byte(data), 0644)
+		if _, err = Read(path); err == nil {
+			t.Errorf("testcase: %d reading %q: no error", i, data)
+		}
+	}
+}
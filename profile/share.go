@@ -0,0 +1,106 @@
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gtoc/docopt"
+)
+
+// FileVersion is the version of the format of the files profiles are
+// shared in, raised with every change older versions of gtoc couldn't read.
+const FileVersion = 1
+
+// File is a profile as it's shared between machines, in a JSON file of its
+// own. PatternHash is the docopt.Pattern.Hash of the command the profile was
+// built for, telling whether the command changed since.
+type File struct {
+	Version     int     `json:"version"`
+	PatternHash string  `json:"patternHash"`
+	Profile     Profile `json:"profile"`
+}
+
+// IncompatibleError tells a profile was built for a version of its command
+// whose arguments, commands or options aren't all there anymore. Unknown are
+// the keys of the values the command doesn't have.
+type IncompatibleError struct {
+	Command string
+	Unknown []string
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("the profile doesn't fit this version of %s, which has no %s",
+		e.Command, strings.Join(e.Unknown, ", "))
+}
+
+// Export writes the profile to the file at path, along with the hash of pat,
+// the pattern of its command.
+func Export(path string, p Profile, pat *docopt.Pattern) error {
+	data, err := json.MarshalIndent(File{Version: FileVersion, PatternHash: pat.Hash(), Profile: p}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Read reads the profile shared in the file at path.
+func Read(path string) (File, error) {
+	var f File
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	if err = json.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("%s isn't a profile: %v", path, err)
+	}
+	if f.Version > FileVersion {
+		return f, fmt.Errorf("%s is a profile of a newer gtoc", path)
+	}
+	if f.Profile.Command == "" || f.Profile.Name == "" {
+		return f, fmt.Errorf("%s isn't a profile: no command or name", path)
+	}
+	return f, nil
+}
+
+// Check checks the profile of the file fits pat, the pattern of its
+// command as it is here. It does if the pattern hashes the same as the one
+// the profile was built for, or else if every value of the profile is still
+// one of the pattern. A profile that doesn't fit is reported as an
+// *IncompatibleError.
+func (f File) Check(pat *docopt.Pattern) error {
+	if f.PatternHash == pat.Hash() {
+		return nil
+	}
+	unknown, err := f.Profile.Unknown(pat)
+	if err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		return &IncompatibleError{Command: f.Profile.Command, Unknown: unknown}
+	}
+	return nil
+}
+
+// Unknown returns the names of the values of the profile that aren't values
+// of pat anymore, sorted.
+func (p Profile) Unknown(pat *docopt.Pattern) ([]string, error) {
+	models, err := pat.ValueModels()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(models))
+	for _, m := range models {
+		known[m.Name] = true
+	}
+	var unknown []string
+	for name := range p.Values {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
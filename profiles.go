@@ -0,0 +1,85 @@
+package main
+
+import "gtoc/profile"
+
+// CreateProfile saves the profile, a named preset of the values, the
+// environment and the working directory of its command, replacing the one
+// by the same name. It returns the profile as saved.
+func (b *Backend) CreateProfile(p profile.Profile) (profile.Profile, error) {
+	return b.profiles.Save(p)
+}
+
+// ListProfiles returns the profiles of command, all of them if it's "".
+func (b *Backend) ListProfiles(command string) []profile.Profile {
+	return b.profiles.List(command)
+}
+
+// ApplyProfile fills the form of the session with the values of the profile
+// name of command, making command the target of the session first if it
+// isn't, see SetTarget. It returns the profile, for the frontend to set the
+// environment and working directory of the run as well.
+func (b *Backend) ApplyProfile(sessionID string, command, name string) (profile.Profile, error) {
+	p, err := b.profiles.Get(command, name)
+	if err != nil {
+		return profile.Profile{}, err
+	}
+	target, _, err := b.target(sessionID)
+	if err != nil {
+		return profile.Profile{}, err
+	}
+	if target != command {
+		if _, err = b.SetTarget(sessionID, command); err != nil {
+			return profile.Profile{}, err
+		}
+	}
+	return p, b.SetValues(sessionID, p.Values)
+}
+
+// DeleteProfile deletes the profile name of command.
+func (b *Backend) DeleteProfile(command, name string) error {
+	return b.profiles.Delete(command, name)
+}
+
+// ExportProfile writes the profile name of command to a JSON file the user
+// picks, along with the hash of the pattern of command, for the profile to
+// be shared with other machines, see ImportProfile. It returns the path of
+// the file, "" if the user gave up.
+func (b *Backend) ExportProfile(command, name string) (string, error) {
+	p, err := b.profiles.Get(command, name)
+	if err != nil {
+		return "", err
+	}
+	pat, err := get_pattern(command)
+	if err != nil {
+		return "", err
+	}
+	path, err := b.view.SaveFile(name + ".json")
+	if err != nil || path == "" {
+		return "", err
+	}
+	return path, profile.Export(path, p, pat)
+}
+
+// ImportProfile saves the profile of a JSON file the user picks, written by
+// ExportProfile, replacing the one by the same name. Its command is probed
+// first, and a profile whose values the command doesn't have anymore is
+// refused as a *profile.IncompatibleError. It returns the profile saved, a
+// zero one if the user gave up.
+func (b *Backend) ImportProfile() (profile.Profile, error) {
+	path, err := b.view.OpenFile()
+	if err != nil || path == "" {
+		return profile.Profile{}, err
+	}
+	f, err := profile.Read(path)
+	if err != nil {
+		return profile.Profile{}, err
+	}
+	pat, err := get_pattern(f.Profile.Command)
+	if err != nil {
+		return profile.Profile{}, err
+	}
+	if err = f.Check(pat); err != nil {
+		return profile.Profile{}, err
+	}
+	return b.profiles.Save(f.Profile)
+}
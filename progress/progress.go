@@ -0,0 +1,81 @@
+// Package progress spots the progress programs report in their output, such
+// as percentages, counts like "12/50" and the bars curl, wget or rsync redraw
+// with carriage returns, so that a frontend can show a progress bar.
+package progress
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Progress is how far a job has come. Percent is from 0 to 100. Current and
+// Total are the count it was told by, if it was, both 0 otherwise.
+type Progress struct {
+	Percent float64 `json:"percent"`
+	Current int64   `json:"current"`
+	Total   int64   `json:"total"`
+}
+
+var (
+	percentRe = regexp.MustCompile(`(?:^|[^\w.])(\d{1,3}(?:\.\d+)?) ?%`)
+	// a count is a pair of numbers out of paths, dates and versions
+	countRe = regexp.MustCompile(`(?:^|[^\w./:-])(\d+) ?(?:/|of) ?(\d+)(?:$|[^\w./:-])`)
+)
+
+// maxLine bounds the line a Detector waits for the end of.
+const maxLine = 4096
+
+// Detector spots progress in a stream of text fed to it piece by piece, the
+// escape sequences taken out.
+type Detector struct {
+	// line is the start of the line the last piece ended in
+	line string
+	last Progress
+	seen bool
+}
+
+// Feed reads the next piece of text and returns the latest progress it
+// tells, if it tells one different from the last. A line may be redrawn
+// with a carriage return, or end only in a later piece.
+func (d *Detector) Feed(text string) (Progress, bool) {
+	lines := strings.FieldsFunc(d.line+text, func(r rune) bool { return r == '\r' || r == '\n' })
+	d.line = ""
+	if n := len(text); n > 0 && text[n-1] != '\r' && text[n-1] != '\n' && len(lines) > 0 {
+		// the last line goes on in the next piece, it's read as far as it
+		// goes for now
+		d.line = lines[len(lines)-1]
+		if len(d.line) > maxLine {
+			d.line = d.line[len(d.line)-maxLine:]
+		}
+	}
+	found := false
+	var p Progress
+	for i := len(lines) - 1; i >= 0 && !found; i-- {
+		p, found = Parse(lines[i])
+	}
+	if !found || (d.seen && p == d.last) {
+		return Progress{}, false
+	}
+	d.last, d.seen = p, true
+	return p, true
+}
+
+// Parse returns the progress the line tells: its last percentage, or else
+// its last count of a total.
+func Parse(line string) (Progress, bool) {
+	if m := percentRe.FindAllStringSubmatch(line, -1); m != nil {
+		percent, err := strconv.ParseFloat(m[len(m)-1][1], 64)
+		if err == nil && percent <= 100 {
+			return Progress{Percent: percent}, true
+		}
+	}
+	if m := countRe.FindAllStringSubmatch(line, -1); m != nil {
+		current, err1 := strconv.ParseInt(m[len(m)-1][1], 10, 64)
+		total, err2 := strconv.ParseInt(m[len(m)-1][2], 10, 64)
+		if err1 == nil && err2 == nil && total > 0 && current <= total {
+			return Progress{Percent: float64(current) * 100 / float64(total), Current: current, Total: total}, true
+		}
+	}
+	return Progress{}, false
+}
@@ -0,0 +1,53 @@
+package progress
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		line     string
+		progress Progress
+		ok       bool
+	}{
+		{"Downloading... 42%", Progress{Percent: 42}, true},
+		{"  5 12.3M    5  640k    0     0   310k      0  0:00:40  0:00:02  0:00:38  310k", Progress{}, false},
+		{"file.iso   37%[=====>      ]  1.2G  10.5MB/s  eta 2m", Progress{Percent: 37}, true},
+		{"     1,234,567  45%  1.17MB/s    0:00:01", Progress{Percent: 45}, true},
+		{"50% then 75.5 %", Progress{Percent: 75.5}, true},
+		{"[12/50] Compiling foo.c", Progress{Percent: 24, Current: 12, Total: 50}, true},
+		{"Step 3 of 4", Progress{Percent: 75, Current: 3, Total: 4}, true},
+		{"see /usr/lib/1/2 and 2020/10/01 and v1.2/3", Progress{}, false},
+		{"done 7/0", Progress{}, false},
+		{"over 150%", Progress{}, false},
+		{"no progress", Progress{}, false},
+	}
+	for i, tt := range tests {
+		p, ok := Parse(tt.line)
+		if ok != tt.ok || p != tt.progress {
+			t.Errorf("testcase: %d result: %+v %v expected: %+v", i, p, ok, tt.progress)
+		}
+	}
+}
+
+func TestDetector(t *testing.T) {
+	var d Detector
+	var tests = []struct {
+		text     string
+		progress Progress
+		ok       bool
+	}{
+		{"starting\n", Progress{}, false},
+		{"\r 10%", Progress{Percent: 10}, true},
+		{"\r 10%", Progress{}, false},
+		{"\r 2", Progress{}, false},
+		{"0%\r 30%", Progress{Percent: 30}, true},
+		{" [===>  ]\n", Progress{}, false},
+		{"done\n", Progress{}, false},
+		{"[3/4] link\n", Progress{Percent: 75, Current: 3, Total: 4}, true},
+	}
+	for i, tt := range tests {
+		p, ok := d.Feed(tt.text)
+		if ok != tt.ok || p != tt.progress {
+			t.Errorf("testcase: %d result: %+v %v expected: %+v", i, p, ok, tt.progress)
+		}
+	}
+}
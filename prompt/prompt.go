@@ -0,0 +1,103 @@
+// Package prompt spots the questions programs ask on a terminal and wait for
+// the answer of, such as "Are you sure? [y/N]" or password prompts, so that
+// a frontend can ask the user and write the answer back.
+package prompt
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kind is what a prompt asks for.
+type Kind string
+
+const (
+	// Confirm asks to pick one of the choices, yes or no mostly.
+	Confirm Kind = "confirm"
+	// Secret asks for a password, a passphrase or a PIN, which the terminal
+	// doesn't echo.
+	Secret Kind = "secret"
+	// Text asks for any answer.
+	Text Kind = "text"
+)
+
+// Prompt is a question a program asks. Choices are those of a Confirm, in
+// lower case, and Default the one taken for an empty answer if the prompt
+// tells it in capitals as in "[y/N]".
+type Prompt struct {
+	Text    string   `json:"text"`
+	Kind    Kind     `json:"kind"`
+	Choices []string `json:"choices,omitempty"`
+	Default string   `json:"default,omitempty"`
+}
+
+var (
+	// choices are in brackets or parentheses, split with a slash
+	choicesRe = regexp.MustCompile(`[\[(]((?:[[:alpha:]]+/)+[[:alpha:]]+)[\])]\s*[:?]?\s*$`)
+	secretRe  = regexp.MustCompile(`(?i)(pass(word|phrase)|\bpin\b|token)[^:?]*[:?]\s*$`)
+	// any other question ends in a question mark, or a colon and a space
+	// for the answer unlike the lines of a listing such as "Status:"
+	textRe = regexp.MustCompile(`\S.*(\?\s*|: )$`)
+)
+
+// maxLine bounds the line a Detector waits for the end of.
+const maxLine = 4096
+
+// Detector spots prompts in a stream of terminal output fed to it piece by
+// piece, the escape sequences taken out. A prompt is a line a piece ends in
+// before its newline, the program waiting for the answer.
+type Detector struct {
+	// line is the start of the line the last piece ended in
+	line string
+	// asked is the line of the last prompt spotted
+	asked string
+}
+
+// Feed reads the next piece of output and returns the prompt the line it
+// ends in asks, if it asks one it hasn't asked already.
+func (d *Detector) Feed(text string) (Prompt, bool) {
+	line := d.line + text
+	if i := strings.LastIndexAny(line, "\r\n"); i >= 0 {
+		line = line[i+1:]
+		d.asked = ""
+	}
+	if len(line) > maxLine {
+		line = line[len(line)-maxLine:]
+	}
+	d.line = line
+	p, ok := Parse(line)
+	if !ok || line == d.asked {
+		return Prompt{}, false
+	}
+	d.asked = line
+	return p, true
+}
+
+// Parse returns the prompt the line asks, if it's one.
+func Parse(line string) (Prompt, bool) {
+	text := strings.TrimSpace(line)
+	if m := choicesRe.FindStringSubmatch(line); m != nil {
+		p := Prompt{Text: text, Kind: Confirm}
+		defaults := 0
+		for _, choice := range strings.Split(m[1], "/") {
+			lower := strings.ToLower(choice)
+			if choice == strings.ToUpper(choice) {
+				p.Default = lower
+				defaults++
+			}
+			p.Choices = append(p.Choices, lower)
+		}
+		if defaults != 1 {
+			// as in "[Y/N]"
+			p.Default = ""
+		}
+		return p, true
+	}
+	if secretRe.MatchString(line) {
+		return Prompt{Text: text, Kind: Secret}, true
+	}
+	if textRe.MatchString(line) {
+		return Prompt{Text: text, Kind: Text}, true
+	}
+	return Prompt{}, false
+}
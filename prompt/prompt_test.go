@@ -0,0 +1,52 @@
+package prompt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		line   string
+		prompt Prompt
+		ok     bool
+	}{
+		{"Are you sure? [y/N] ", Prompt{"Are you sure? [y/N]", Confirm, []string{"y", "n"}, "n"}, true},
+		{"Overwrite file.txt? (yes/no): ", Prompt{"Overwrite file.txt? (yes/no):", Confirm, []string{"yes", "no"}, ""}, true},
+		{"Continue [Y/n/q]?", Prompt{"Continue [Y/n/q]?", Confirm, []string{"y", "n", "q"}, "y"}, true},
+		{"Proceed [Y/N] ", Prompt{"Proceed [Y/N]", Confirm, []string{"y", "n"}, ""}, true},
+		{"[sudo] password for alice: ", Prompt{"[sudo] password for alice:", Secret, nil, ""}, true},
+		{"Enter passphrase for key '/home/alice/.ssh/id_rsa':", Prompt{"Enter passphrase for key '/home/alice/.ssh/id_rsa':", Secret, nil, ""}, true},
+		{"Username for 'https://github.com': ", Prompt{"Username for 'https://github.com':", Text, nil, ""}, true},
+		{"What is your name? ", Prompt{"What is your name?", Text, nil, ""}, true},
+		{"Status:", Prompt{}, false},
+		{"Downloading 42%", Prompt{}, false},
+		{"", Prompt{}, false},
+	}
+	for i, tt := range tests {
+		p, ok := Parse(tt.line)
+		if ok != tt.ok || !reflect.DeepEqual(p, tt.prompt) {
+			t.Errorf("testcase: %d result: %+v %v expected: %+v", i, p, ok, tt.prompt)
+		}
+	}
+}
+
+func TestDetector(t *testing.T) {
+	var d Detector
+	for i, tt := range []struct {
+		text string
+		ok   bool
+	}{
+		{"Installing...\nAre you", false},
+		{" sure? [y/N] ", true},
+		// the same prompt isn't asked twice
+		{"", false},
+		{"y\r\nDone.\n", false},
+		{"Password: ", true},
+		{"\nPassword: ", true},
+	} {
+		if _, ok := d.Feed(tt.text); ok != tt.ok {
+			t.Errorf("testcase: %d result: %v expected: %v", i, ok, tt.ok)
+		}
+	}
+}
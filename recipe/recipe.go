@@ -0,0 +1,148 @@
+// Package recipe chains saved invocations into workflows, such as "build,
+// then test, then deploy": the steps of a recipe run in order, each once the
+// one before has ended, stopping at the first that fails unless it's told to
+// go on, and some only if the step before exited with given codes.
+package recipe
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gtoc/runner"
+	"gtoc/store"
+)
+
+// Step is an invocation a recipe runs.
+type Step struct {
+	Name       string            `json:"name"`
+	Invocation runner.Invocation `json:"invocation"`
+	// ContinueOnError runs the steps after this one even if it fails
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+	// IfCodes runs the step only if the step run last exited with one of
+	// the codes, any step run last being fine if it's empty
+	IfCodes []int `json:"ifCodes,omitempty"`
+}
+
+// runs tells whether the step runs after a step that exited with code.
+func (s Step) runs(code int) bool {
+	if len(s.IfCodes) == 0 {
+		return true
+	}
+	for _, c := range s.IfCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Recipe is a named workflow of steps, run in order.
+type Recipe struct {
+	Name    string    `json:"name"`
+	Steps   []Step    `json:"steps"`
+	Updated time.Time `json:"updated"`
+}
+
+// Check returns why the recipe can't be run, nil if it can.
+func (r Recipe) Check() error {
+	if r.Name == "" {
+		return errors.New("a recipe needs a name")
+	}
+	if len(r.Steps) == 0 {
+		return fmt.Errorf("recipe %q has no steps", r.Name)
+	}
+	for i, s := range r.Steps {
+		if s.Invocation.Program == "" {
+			return fmt.Errorf("step %d of recipe %q runs no program", i+1, r.Name)
+		}
+	}
+	if len(r.Steps[0].IfCodes) > 0 {
+		return fmt.Errorf("the first step of recipe %q has no step before it to check the exit code of", r.Name)
+	}
+	return nil
+}
+
+// Store is the recipes saved in a file. Its methods may be called from
+// several goroutines.
+type Store struct {
+	file *store.File
+
+	mu      sync.Mutex
+	recipes []Recipe
+}
+
+// Open loads the recipes saved at path.
+func Open(path string) (*Store, error) {
+	s := &Store{file: &store.File{Path: path}}
+	if err := s.file.Load(&s.recipes); err != nil {
+		return nil, err
+	}
+	for _, r := range s.recipes {
+		for i := range r.Steps {
+			// JSON has no ints or string lists
+			r.Steps[i].Invocation.DecodeValues()
+		}
+	}
+	return s, nil
+}
+
+// List returns the recipes by name.
+func (s *Store) List() []Recipe {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := append([]Recipe{}, s.recipes...)
+	sort.Slice(list, func(i, k int) bool { return list[i].Name < list[k].Name })
+	return list
+}
+
+// Get returns the recipe name.
+func (s *Store) Get(name string) (Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(name)
+	if i < 0 {
+		return Recipe{}, fmt.Errorf("no recipe %q", name)
+	}
+	return s.recipes[i], nil
+}
+
+// Save checks the recipe and saves it, replacing the one by the same name
+// if any, and returns it as saved.
+func (s *Store) Save(r Recipe) (Recipe, error) {
+	if err := r.Check(); err != nil {
+		return Recipe{}, err
+	}
+	r.Updated = time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.index(r.Name); i >= 0 {
+		s.recipes[i] = r
+	} else {
+		s.recipes = append(s.recipes, r)
+	}
+	return r, s.file.Save(s.recipes)
+}
+
+// Delete removes the recipe name.
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(name)
+	if i < 0 {
+		return fmt.Errorf("no recipe %q", name)
+	}
+	s.recipes = append(s.recipes[:i], s.recipes[i+1:]...)
+	return s.file.Save(s.recipes)
+}
+
+func (s *Store) index(name string) int {
+	for i, r := range s.recipes {
+		if r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,66 @@
+package recipe
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+	"gtoc/runner"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-recipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "recipes.json")
+	s, err := Open(path)
+	if err != nil || len(s.List()) != 0 {
+		t.Fatalf("result: %v error: %v", s, err)
+	}
+	release := Recipe{Name: "release", Steps: []Step{
+		{Name: "build", Invocation: runner.Invocation{Program: "make", Values: docopt.Opts{"<target>": []string{"all"}, "-j": 4}}},
+		{Name: "test", Invocation: runner.Invocation{Program: "make test"}, ContinueOnError: true},
+		{Name: "deploy", Invocation: runner.Invocation{Program: "./deploy.sh"}, IfCodes: []int{0}},
+	}}
+	for i, r := range []Recipe{
+		{Steps: release.Steps},
+		{Name: "empty"},
+		{Name: "nothing", Steps: []Step{{Name: "nothing"}}},
+		{Name: "first", Steps: []Step{{Invocation: runner.Invocation{Program: "true"}, IfCodes: []int{1}}}},
+	} {
+		if _, err = s.Save(r); err == nil {
+			t.Errorf("testcase: %d result: no error", i)
+		}
+	}
+	for _, r := range []Recipe{release, {Name: "clean", Steps: release.Steps[:1]}} {
+		if _, err = s.Save(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if list := s.List(); len(list) != 2 || list[0].Name != "clean" || list[1].Name != "release" {
+		t.Errorf("result: %v expected: clean and release", list)
+	}
+	r, err := s.Get("release")
+	if err != nil || len(r.Steps) != 3 || !reflect.DeepEqual(r.Steps[0].Invocation.Values, release.Steps[0].Invocation.Values) || !reflect.DeepEqual(r.Steps[2].IfCodes, []int{0}) {
+		t.Errorf("result: %+v error: %v expected: %+v", r, err, release)
+	}
+	if err = s.Delete("clean"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Delete("clean"); err == nil {
+		t.Errorf("deleting a deleted recipe: no error")
+	}
+	if _, err = s.Get("clean"); err == nil {
+		t.Errorf("getting a deleted recipe: no error")
+	}
+}
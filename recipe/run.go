@@ -0,0 +1,140 @@
+package recipe
+
+import (
+	"context"
+
+	"gtoc/runner"
+)
+
+// State is where a run of a recipe, or of one of its steps, stands.
+type State string
+
+const (
+	// Pending is a step waiting for the steps before it.
+	Pending State = "pending"
+	Running State = "running"
+	// Done is a step whose program exited with code 0, or a recipe run to
+	// its end.
+	Done State = "done"
+	// Failed is a step whose program exited with another code, couldn't be
+	// started or didn't finish, or a recipe stopped by one.
+	Failed State = "failed"
+	// Skipped is a step that didn't run, its exit codes not met or the
+	// recipe having stopped before it.
+	Skipped  State = "skipped"
+	Canceled State = "canceled"
+)
+
+// StepStatus is how far a step of a run has come. JobID is the job it runs
+// in once it's started; Code is the code it exited with once it has, -1 if
+// it couldn't be started or didn't exit, and Err why.
+type StepStatus struct {
+	Name  string `json:"name"`
+	State State  `json:"state"`
+	JobID string `json:"jobID,omitempty"`
+	Code  int    `json:"code"`
+	Err   string `json:"err,omitempty"`
+}
+
+// Status is how far a run of a recipe has come, Step being the index of the
+// step running.
+type Status struct {
+	ID     string       `json:"id"`
+	Recipe string       `json:"recipe"`
+	State  State        `json:"state"`
+	Step   int          `json:"step"`
+	Steps  []StepStatus `json:"steps"`
+}
+
+// snapshot returns the status as it is.
+func (s Status) snapshot() Status {
+	s.Steps = append([]StepStatus(nil), s.Steps...)
+	return s
+}
+
+// Jobs runs the steps of a recipe as jobs.
+type Jobs interface {
+	// Start starts a job of the invocation and returns its ID.
+	Start(runner.Invocation) (string, error)
+	// Wait waits for the job id to end, its last attempt if it's retried,
+	// or for ctx to be done.
+	Wait(ctx context.Context, id string) (runner.RunResult, error)
+	// Cancel stops the job id.
+	Cancel(id string) error
+}
+
+// Run runs the steps of the recipe with jobs, in order, each once the one
+// before has ended, and returns the status of the run id once it's over.
+// Every change of the status is told to progress as it happens. A step that
+// fails stops the run, the steps after it skipped, unless ContinueOnError is
+// set; a step whose IfCodes the step run last didn't exit with is skipped.
+// Canceling ctx cancels the job running and skips the steps after it.
+func Run(ctx context.Context, id string, r Recipe, jobs Jobs, progress func(Status)) Status {
+	status := Status{ID: id, Recipe: r.Name, State: Running, Steps: make([]StepStatus, len(r.Steps))}
+	for i, s := range r.Steps {
+		status.Steps[i] = StepStatus{Name: s.Name, State: Pending}
+	}
+	progress(status.snapshot())
+	// the first step runs as if after one exiting with 0
+	last := 0
+	for i, s := range r.Steps {
+		step := &status.Steps[i]
+		if status.State == Running && ctx.Err() != nil {
+			status.State = Canceled
+		}
+		switch {
+		case status.State != Running:
+			step.State = Skipped
+			continue
+		case !s.runs(last):
+			step.State = Skipped
+			progress(status.snapshot())
+			continue
+		}
+		status.Step = i
+		step.State, step.Code = Running, -1
+		jobID, err := jobs.Start(s.Invocation)
+		if err != nil {
+			step.State, step.Err = Failed, err.Error()
+		} else {
+			step.JobID = jobID
+			progress(status.snapshot())
+			step.State, step.Code, step.Err = wait(ctx, jobs, jobID)
+		}
+		last = step.Code
+		switch {
+		case step.State == Canceled:
+			status.State = Canceled
+		case step.State == Failed && !s.ContinueOnError:
+			status.State = Failed
+		}
+		progress(status.snapshot())
+	}
+	if status.State == Running {
+		status.State = Done
+	}
+	progress(status.snapshot())
+	return status
+}
+
+// wait waits for the job of a step to end, canceling it if ctx is done, and
+// returns the state, code and error of the step.
+func wait(ctx context.Context, jobs Jobs, id string) (State, int, string) {
+	result, err := jobs.Wait(ctx, id)
+	if err != nil {
+		if ctx.Err() != nil {
+			jobs.Cancel(id)
+			return Canceled, -1, ""
+		}
+		return Failed, -1, err.Error()
+	}
+	switch {
+	case result.State == runner.StateCanceled:
+		return Canceled, -1, ""
+	case result.State != runner.StateExited:
+		return Failed, -1, result.Err
+	case result.Code != 0:
+		return Failed, result.Code, ""
+	}
+	return Done, 0, ""
+}
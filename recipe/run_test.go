@@ -0,0 +1,98 @@
+package recipe
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"gtoc/runner"
+)
+
+// jobs runs the invocations of programs as exiting with their codes, and
+// blocks until canceled those of programs that hang.
+type jobs struct {
+	codes    map[string]int
+	hang     map[string]bool
+	started  []string
+	canceled []string
+}
+
+func (j *jobs) Start(inv runner.Invocation) (string, error) {
+	if _, ok := j.codes[inv.Program]; !ok && !j.hang[inv.Program] {
+		return "", errors.New("no such program")
+	}
+	j.started = append(j.started, inv.Program)
+	return strconv.Itoa(len(j.started)), nil
+}
+
+func (j *jobs) Wait(ctx context.Context, id string) (runner.RunResult, error) {
+	n, _ := strconv.Atoi(id)
+	program := j.started[n-1]
+	if j.hang[program] {
+		<-ctx.Done()
+		return runner.RunResult{}, ctx.Err()
+	}
+	return runner.RunResult{JobID: id, State: runner.StateExited, Code: j.codes[program]}, nil
+}
+
+func (j *jobs) Cancel(id string) error {
+	j.canceled = append(j.canceled, id)
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	step := func(program string, cont bool, codes ...int) Step {
+		return Step{Name: program, Invocation: runner.Invocation{Program: program}, ContinueOnError: cont, IfCodes: codes}
+	}
+	for i, tt := range []struct {
+		steps    []Step
+		state    State
+		expected []State
+		started  []string
+	}{
+		{
+			[]Step{step("build", false), step("test", false), step("deploy", false)},
+			Done, []State{Done, Done, Done}, []string{"build", "test", "deploy"},
+		},
+		{
+			[]Step{step("build", false), step("lint", false), step("deploy", false)},
+			Failed, []State{Done, Failed, Skipped}, []string{"build", "lint"},
+		},
+		// the tests failing, the report is sent instead of deploying
+		{
+			[]Step{step("build", false), step("lint", true), step("deploy", false, 0), step("report", false, 1, 2)},
+			Done, []State{Done, Failed, Skipped, Done}, []string{"build", "lint", "report"},
+		},
+		{
+			[]Step{step("missing", true), step("deploy", false, 0), step("build", false)},
+			Done, []State{Failed, Skipped, Done}, []string{"build"},
+		},
+	} {
+		j := &jobs{codes: map[string]int{"build": 0, "test": 0, "deploy": 0, "report": 0, "lint": 1}}
+		var told []Status
+		status := Run(context.Background(), "r", Recipe{Name: "release", Steps: tt.steps}, j, func(s Status) { told = append(told, s) })
+		var states []State
+		for _, s := range status.Steps {
+			states = append(states, s.State)
+		}
+		if status.State != tt.state || !reflect.DeepEqual(states, tt.expected) || !reflect.DeepEqual(j.started, tt.started) {
+			t.Errorf("testcase: %d result: %s %v %v expected: %s %v %v", i, status.State, states, j.started, tt.state, tt.expected, tt.started)
+		}
+		if last := told[len(told)-1]; !reflect.DeepEqual(last, status) || told[0].Steps[0].State != Pending {
+			t.Errorf("testcase: %d result: %v expected: the progress to end with %v", i, told, status)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &jobs{codes: map[string]int{"deploy": 0}, hang: map[string]bool{"build": true}}
+	status := Run(ctx, "r", Recipe{Name: "release", Steps: []Step{step("build", false), step("deploy", false)}}, j, func(s Status) {
+		if s.State == Running && s.Steps[0].JobID != "" {
+			cancel()
+		}
+	})
+	if status.State != Canceled || status.Steps[0].State != Canceled || status.Steps[1].State != Skipped || !reflect.DeepEqual(j.canceled, []string{"1"}) {
+		t.Errorf("result: %+v %v expected: the build canceled", status, j.canceled)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gtoc/recipe"
+	"gtoc/runner"
+)
+
+// RecipeRequest is a recipe as the frontend asks for it, the runs of its
+// steps composed as for Run.
+type RecipeRequest struct {
+	Name  string              `json:"name"`
+	Steps []RecipeStepRequest `json:"steps"`
+}
+
+// RecipeStepRequest is a step of a RecipeRequest, see recipe.Step.
+type RecipeStepRequest struct {
+	Name            string     `json:"name"`
+	Run             RunRequest `json:"run"`
+	ContinueOnError bool       `json:"continueOnError"`
+	IfCodes         []int      `json:"ifCodes"`
+}
+
+// CreateRecipe saves the recipe, a workflow of runs one after the other,
+// replacing the one by the same name, and returns it as saved. A recipe
+// with a run whose values don't fit its command is refused.
+func (j *Jobs) CreateRecipe(req RecipeRequest) (recipe.Recipe, error) {
+	r := recipe.Recipe{Name: req.Name}
+	for i, step := range req.Steps {
+		run, err := j.request(step.Run)
+		if err != nil {
+			return recipe.Recipe{}, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		if _, err = runner.DryRun(run); err != nil {
+			return recipe.Recipe{}, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		r.Steps = append(r.Steps, recipe.Step{
+			Name:            step.Name,
+			Invocation:      run.Invocation(),
+			ContinueOnError: step.ContinueOnError,
+			IfCodes:         step.IfCodes,
+		})
+	}
+	return j.recipes.Save(r)
+}
+
+// ListRecipes returns the recipes, by name.
+func (j *Jobs) ListRecipes() []recipe.Recipe {
+	return j.recipes.List()
+}
+
+// DeleteRecipe deletes the recipe name.
+func (j *Jobs) DeleteRecipe(name string) error {
+	return j.recipes.Delete(name)
+}
+
+// RunRecipe runs the steps of the recipe name one after the other, see
+// recipe.Run, and returns the ID of the run at once. The jobs of the steps
+// are emitted as any others, and the progress of the run as
+// "recipe:progress" events carrying a recipe.Status, its last one once it's
+// over.
+func (j *Jobs) RunRecipe(name string) (string, error) {
+	r, err := j.recipes.Get(name)
+	if err != nil {
+		return "", err
+	}
+	id := strconv.FormatInt(time.Now().UnixNano(), 36)
+	ctx, cancel := context.WithCancel(context.Background())
+	j.running_mu.Lock()
+	j.running[id] = cancel
+	j.running_mu.Unlock()
+	go func() {
+		defer cancel()
+		recipe.Run(ctx, id, r, recipe_jobs{j}, func(s recipe.Status) {
+			j.view.Emit("recipe:progress", s)
+		})
+		j.running_mu.Lock()
+		delete(j.running, id)
+		j.running_mu.Unlock()
+	}()
+	return id, nil
+}
+
+// CancelRecipe cancels the run runID of a recipe: its job running is
+// canceled and the steps after it skipped.
+func (j *Jobs) CancelRecipe(runID string) error {
+	j.running_mu.Lock()
+	cancel, ok := j.running[runID]
+	j.running_mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no recipe running as %s", runID)
+	}
+	cancel()
+	return nil
+}
+
+// recipe_jobs runs the steps of recipes as the jobs of j.
+type recipe_jobs struct {
+	j *Jobs
+}
+
+func (rj recipe_jobs) Start(inv runner.Invocation) (string, error) {
+	return rj.j.invoke(inv)
+}
+
+func (rj recipe_jobs) Wait(ctx context.Context, id string) (runner.RunResult, error) {
+	c := rj.j.events.wait(id)
+	// the job may have ended before it was waited for
+	if result, ok := rj.j.runner.Result(id); ok && !result.Retrying {
+		rj.j.events.unwait(id)
+		return result, nil
+	}
+	select {
+	case result := <-c:
+		return result, nil
+	case <-ctx.Done():
+		rj.j.events.unwait(id)
+		return runner.RunResult{}, ctx.Err()
+	}
+}
+
+func (rj recipe_jobs) Cancel(id string) error {
+	return rj.j.runner.Cancel(id)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gtoc/docopt"
+	"gtoc/registry"
+	"gtoc/runner"
+)
+
+// registry_timeout bounds the requests to the registry of patterns.
+const registry_timeout = 30 * time.Second
+
+// version_re is a version in what a program prints with --version.
+var version_re = regexp.MustCompile(`\d+(?:\.\d+)+`)
+
+// tool_version returns the tool of the command as the registry names it, its
+// program without its directory followed by the rest of the command, and the
+// version of the program, "" if it doesn't tell.
+func tool_version(ctx context.Context, command string) (string, string, error) {
+	words, err := runner.Split(command)
+	if err != nil {
+		return "", "", err
+	}
+	tool := tool_of(words)
+	output, err := exec.CommandContext(ctx, words[0], "--version").Output()
+	if err != nil {
+		return tool, "", nil
+	}
+	return tool, version_re.FindString(string(output)), nil
+}
+
+// tool_of returns the tool of the words of a command, see tool_version.
+func tool_of(words []string) string {
+	return strings.Join(append([]string{strings.TrimSuffix(filepath.Base(words[0]), ".exe")}, words[1:]...), " ")
+}
+
+// registry_client returns the client of the registry of patterns of the
+// settings, or an error if there's none.
+func (b *Backend) registry_client() (*registry.Client, error) {
+	r := b.settings.Get().Registry
+	if r.URL == "" {
+		return nil, b.errorf("error.noRegistry")
+	}
+	key, err := base64.StdEncoding.DecodeString(r.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &registry.Client{URL: r.URL, Key: ed25519.PublicKey(key), Token: r.Token}, nil
+}
+
+// registry_target is the target of a session as it's fetched from and
+// published to the registry: the client of the registry, the command and
+// its pattern, and its tool and version, see tool_version.
+type registry_target struct {
+	client  *registry.Client
+	command string
+	pattern *docopt.Pattern
+	tool    string
+	version string
+}
+
+// for_registry returns the target of the session for the registry of the
+// settings.
+func (b *Backend) for_registry(ctx context.Context, sessionID string) (registry_target, error) {
+	var t registry_target
+	var err error
+	if t.client, err = b.registry_client(); err != nil {
+		return t, err
+	}
+	if t.command, t.pattern, err = b.target(sessionID); err != nil {
+		return t, err
+	}
+	if t.command == "" || t.pattern == nil {
+		return t, b.errorf("error.noTarget", sessionID)
+	}
+	if t.tool, t.version, err = tool_version(ctx, t.command); err != nil {
+		return t, err
+	}
+	if t.version == "" {
+		return t, b.errorf("error.noVersion", t.tool)
+	}
+	return t, nil
+}
+
+// FetchPattern fetches the pattern of the target of the session for the
+// version of its program from the registry of the settings, as someone
+// corrected it, and makes it the pattern of the sessions of the target.
+// Their values are migrated to it, and every session is emitted as a
+// "pattern:updated" event carrying a PatternUpdate, as reparse does.
+func (b *Backend) FetchPattern(sessionID string) (*PatternNode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), registry_timeout)
+	defer cancel()
+	t, err := b.for_registry(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	pat, err := t.client.Fetch(ctx, t.tool, t.version)
+	if err != nil {
+		return nil, err
+	}
+	b.repattern(t.command, pat)
+	return pattern_node(pat), nil
+}
+
+// PublishPattern publishes the pattern of the target of the session, as
+// corrected, to the registry of the settings, for the version of its
+// program.
+func (b *Backend) PublishPattern(sessionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), registry_timeout)
+	defer cancel()
+	t, err := b.for_registry(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	return t.client.Publish(ctx, t.tool, t.version, t.pattern)
+}
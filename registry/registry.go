@@ -0,0 +1,165 @@
+// Package registry is the client of a registry of patterns contributed by the
+// users of gtoc, for a tool whose help text gtoc gets wrong to be fetched as
+// someone corrected it, and for the patterns corrected locally to be
+// published in turn:
+//
+//	GET  <url>/patterns/<tool>/<version>   answers the Record of the tool for
+//	                                       the version, 404 if there's none
+//	POST <url>/patterns/<tool>/<version>   publishes the Record in the body,
+//	                                       authorized by a bearer token
+//
+// The registry signs the records it serves with its ed25519 key, over the
+// tool, the version and the SHA-256 of the pattern, see Message. A record
+// whose pattern doesn't have its hash, or whose signature isn't the
+// registry's, is refused.
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gtoc/docopt"
+)
+
+// Record is a pattern of the registry: that of the tool, named as its
+// program followed by its subcommands, such as "git remote", for its
+// version. Pattern is as docopt.MarshalPattern writes it, SHA256 its hex
+// SHA-256, and Signature the registry's signature of Message, which is
+// empty in the records published.
+type Record struct {
+	Tool      string          `json:"tool"`
+	Version   string          `json:"version"`
+	Pattern   json.RawMessage `json:"pattern"`
+	SHA256    string          `json:"sha256"`
+	Signature []byte          `json:"signature,omitempty"`
+}
+
+// ErrNotFound is returned by Fetch if the registry has no pattern of the
+// tool for the version.
+var ErrNotFound = errors.New("the registry has no such pattern")
+
+// maxRecord bounds the size of the records read, 8 MiB.
+const maxRecord = 8 << 20
+
+// Client is a client of the registry at URL, trusting the records signed
+// with Key. Token authorizes publishing, anonymous if it's empty. HTTP is
+// http.DefaultClient if it's nil.
+type Client struct {
+	URL   string
+	Key   ed25519.PublicKey
+	Token string
+	HTTP  *http.Client
+}
+
+// Message returns what the registry signs of the record: its tool, version
+// and hash, each on a line.
+func (r Record) Message() []byte {
+	return []byte(r.Tool + "\n" + r.Version + "\n" + r.SHA256 + "\n")
+}
+
+// Verify returns why the record can't be trusted with key, nil if it can.
+func (r Record) Verify(key ed25519.PublicKey) error {
+	sum := sha256.Sum256(r.Pattern)
+	if hex.EncodeToString(sum[:]) != strings.ToLower(r.SHA256) {
+		return fmt.Errorf("the pattern of %s %s doesn't match its hash", r.Tool, r.Version)
+	}
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, r.Message(), r.Signature) {
+		return fmt.Errorf("the pattern of %s %s isn't signed by the registry", r.Tool, r.Version)
+	}
+	return nil
+}
+
+// Fetch returns the pattern of the tool for the version, once its record is
+// verified.
+func (c *Client) Fetch(ctx context.Context, tool, version string) (*docopt.Pattern, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(tool, version), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := c.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case res.StatusCode != http.StatusOK:
+		return nil, status(res)
+	}
+	var r Record
+	if err = json.NewDecoder(io.LimitReader(res.Body, maxRecord)).Decode(&r); err != nil {
+		return nil, err
+	}
+	if r.Tool != tool || r.Version != version {
+		return nil, fmt.Errorf("the registry answered the pattern of %s %s", r.Tool, r.Version)
+	}
+	if err = r.Verify(c.Key); err != nil {
+		return nil, err
+	}
+	return docopt.UnmarshalPattern(r.Pattern)
+}
+
+// Publish publishes the pattern of the tool for the version, hashed for the
+// registry to check it.
+func (c *Client) Publish(ctx context.Context, tool, version string, pat *docopt.Pattern) error {
+	data, err := docopt.MarshalPattern(pat)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	body, err := json.Marshal(Record{Tool: tool, Version: version, Pattern: data, SHA256: hex.EncodeToString(sum[:])})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(tool, version), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	res, err := c.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return status(res)
+	}
+	return nil
+}
+
+func (c *Client) endpoint(tool, version string) string {
+	return strings.TrimRight(c.URL, "/") + "/patterns/" + url.PathEscape(tool) + "/" + url.PathEscape(version)
+}
+
+func (c *Client) client() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return http.DefaultClient
+}
+
+// status returns the error of an answer of the registry, with the message
+// it gave if any.
+func status(res *http.Response) error {
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+	if s := strings.TrimSpace(string(msg)); s != "" {
+		return fmt.Errorf("the registry answered %s: %s", res.Status, s)
+	}
+	return fmt.Errorf("the registry answered %s", res.Status)
+}
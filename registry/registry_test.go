@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+// registry is a registry keeping the records published, signed with key.
+type registry struct {
+	key     ed25519.PrivateKey
+	records map[string]Record
+	tamper  bool
+}
+
+func (reg *registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/patterns/")
+	switch r.Method {
+	case http.MethodGet:
+		rec, ok := reg.records[path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		rec.Signature = ed25519.Sign(reg.key, rec.Message())
+		if reg.tamper {
+			rec.Pattern = json.RawMessage(strings.Replace(string(rec.Pattern), "-v", "-x", 1))
+		}
+		json.NewEncoder(w).Encode(rec)
+	case http.MethodPost:
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "who are you?", http.StatusUnauthorized)
+			return
+		}
+		var rec Record
+		if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reg.records[path] = rec
+	}
+}
+
+func TestClient(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := &registry{key: private, records: make(map[string]Record)}
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+	c := &Client{URL: srv.URL + "/", Key: public, Token: "secret"}
+	ctx := context.Background()
+
+	if _, err = c.Fetch(ctx, "git remote", "2.43.0"); err != ErrNotFound {
+		t.Errorf("result: %v expected: %v", err, ErrNotFound)
+	}
+	pat, err := docopt.ParsePattern("Usage: git remote [-v] add <name> <url>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = (&Client{URL: srv.URL}).Publish(ctx, "git remote", "2.43.0", pat); err == nil || !strings.Contains(err.Error(), "who are you?") {
+		t.Errorf("result: %v expected: unauthorized", err)
+	}
+	if err = c.Publish(ctx, "git remote", "2.43.0", pat); err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := c.Fetch(ctx, "git remote", "2.43.0")
+	if err != nil || !fetched.Equal(pat) {
+		t.Errorf("result: %v %v expected: %v", fetched, err, pat)
+	}
+
+	reg.tamper = true
+	if _, err = c.Fetch(ctx, "git remote", "2.43.0"); err == nil || !strings.Contains(err.Error(), "hash") {
+		t.Errorf("result: %v expected: a hash mismatch", err)
+	}
+	reg.tamper = false
+	other, _, _ := ed25519.GenerateKey(nil)
+	if _, err = (&Client{URL: srv.URL, Key: other}).Fetch(ctx, "git remote", "2.43.0"); err == nil || !strings.Contains(err.Error(), "signed") {
+		t.Errorf("result: %v expected: a bad signature", err)
+	}
+	if _, err = (&Client{URL: srv.URL}).Fetch(ctx, "git remote", "2.43.0"); err == nil {
+		t.Errorf("result: no error expected: no key to trust")
+	}
+}
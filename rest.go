@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+
+	"gtoc/runner"
+)
+
+// rest_core is the server.Core of the backend, for the REST API of "gtoc
+// serve". Runs are requested as RunRequests.
+type rest_core struct {
+	b *Backend
+}
+
+// RunState is the state of a job as GET /runs/<id> answers it, with the
+// end of its output.
+type RunState struct {
+	runner.JobStatus
+	Output string `json:"output"`
+}
+
+func (c rest_core) Parse(command, help string) (interface{}, error) {
+	pat, err := rpc_core{c.b}.Parse(command, help)
+	if err != nil {
+		return nil, err
+	}
+	return pattern_node(pat), nil
+}
+
+func (c rest_core) Validate(body json.RawMessage) (interface{}, error) {
+	var req RunRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	return c.b.Validate(req)
+}
+
+func (c rest_core) Run(body json.RawMessage) (string, error) {
+	var req RunRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", err
+	}
+	return c.b.Jobs.Run(req)
+}
+
+func (c rest_core) Status(id string) (interface{}, bool) {
+	for _, job := range c.b.Jobs.ListJobs() {
+		if job.ID == id {
+			tail, _ := c.b.Tail(id)
+			return RunState{job, tail}, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"gtoc/docopt"
+	"gtoc/rpc"
+	"gtoc/runner"
+)
+
+// rpc_core is the rpc.Core of the backend, for the gRPC service of "gtoc
+// serve".
+type rpc_core struct {
+	b *Backend
+}
+
+// run_request returns the RunRequest of a run requested over gRPC.
+func run_request(req rpc.RunRequest) RunRequest {
+	return RunRequest{
+		Command: req.Command,
+		Shell:   req.Shell,
+		Values:  req.Values,
+		Env:     req.Env,
+		Dir:     req.Dir,
+		Timeout: req.Timeout.Seconds(),
+	}
+}
+
+func (c rpc_core) Parse(command, help string) (*docopt.Pattern, error) {
+	if command != "" {
+		pat, err := get_pattern(command)
+		if err != nil {
+			return nil, err
+		}
+		c.b.used(command)
+		return pat, nil
+	}
+	return docopt.ParsePattern(help)
+}
+
+func (c rpc_core) Validate(req rpc.RunRequest) (rpc.Validation, error) {
+	v, err := c.b.Validate(run_request(req))
+	return rpc.Validation{Valid: v.Valid, Error: v.Error, Line: v.Line, Fields: v.Fields}, err
+}
+
+func (c rpc_core) Preview(req rpc.RunRequest) (runner.Preview, error) {
+	return c.b.Preview(run_request(req))
+}
+
+// Run starts the run as Jobs.Run does, the watch passed along with it for
+// the job events to tell.
+func (c rpc_core) Run(req rpc.RunRequest, watch runner.StartSink) (string, error) {
+	r, err := c.b.request(run_request(req))
+	if err != nil {
+		return "", err
+	}
+	r.Watch = watch
+	id, err := c.b.runner.Start(r)
+	if err == nil {
+		c.b.used(req.Command)
+	}
+	return id, err
+}
+
+func (c rpc_core) Cancel(id string) error {
+	return c.b.CancelRun(id)
+}
@@ -0,0 +1,261 @@
+// The core of gtoc as a gRPC service, for clients in other languages and
+// IDE plugins to parse, check and run commands over a typed contract. It
+// mirrors the REST API of "gtoc serve", see package server.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: gtoc/v1/core.proto
+
+package gtocv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Core_Parse_FullMethodName     = "/gtoc.v1.Core/Parse"
+	Core_Validate_FullMethodName  = "/gtoc.v1.Core/Validate"
+	Core_BuildArgv_FullMethodName = "/gtoc.v1.Core/BuildArgv"
+	Core_Run_FullMethodName       = "/gtoc.v1.Core/Run"
+)
+
+// CoreClient is the client API for Core service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CoreClient interface {
+	// Parse returns the pattern of a command, probed for its help text, or of
+	// a help text given.
+	Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*Pattern, error)
+	// Validate checks the values of a run against the pattern of its command,
+	// without running it. Values that don't fit make an invalid Validation
+	// rather than an error, which tells the command couldn't be probed.
+	Validate(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Validation, error)
+	// BuildArgv returns the command line the values of a run make.
+	BuildArgv(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Argv, error)
+	// Run runs a command and streams its output as it's written, then its
+	// result, the stream ending with the run. Canceling the call cancels the
+	// run.
+	Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunEvent], error)
+}
+
+type coreClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCoreClient(cc grpc.ClientConnInterface) CoreClient {
+	return &coreClient{cc}
+}
+
+func (c *coreClient) Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*Pattern, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Pattern)
+	err := c.cc.Invoke(ctx, Core_Parse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) Validate(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Validation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, Core_Validate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) BuildArgv(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Argv, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Argv)
+	err := c.cc.Invoke(ctx, Core_BuildArgv_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Core_ServiceDesc.Streams[0], Core_Run_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RunRequest, RunEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Core_RunClient = grpc.ServerStreamingClient[RunEvent]
+
+// CoreServer is the server API for Core service.
+// All implementations must embed UnimplementedCoreServer
+// for forward compatibility.
+type CoreServer interface {
+	// Parse returns the pattern of a command, probed for its help text, or of
+	// a help text given.
+	Parse(context.Context, *ParseRequest) (*Pattern, error)
+	// Validate checks the values of a run against the pattern of its command,
+	// without running it. Values that don't fit make an invalid Validation
+	// rather than an error, which tells the command couldn't be probed.
+	Validate(context.Context, *RunRequest) (*Validation, error)
+	// BuildArgv returns the command line the values of a run make.
+	BuildArgv(context.Context, *RunRequest) (*Argv, error)
+	// Run runs a command and streams its output as it's written, then its
+	// result, the stream ending with the run. Canceling the call cancels the
+	// run.
+	Run(*RunRequest, grpc.ServerStreamingServer[RunEvent]) error
+	mustEmbedUnimplementedCoreServer()
+}
+
+// UnimplementedCoreServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCoreServer struct{}
+
+func (UnimplementedCoreServer) Parse(context.Context, *ParseRequest) (*Pattern, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Parse not implemented")
+}
+func (UnimplementedCoreServer) Validate(context.Context, *RunRequest) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Validate not implemented")
+}
+func (UnimplementedCoreServer) BuildArgv(context.Context, *RunRequest) (*Argv, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BuildArgv not implemented")
+}
+func (UnimplementedCoreServer) Run(*RunRequest, grpc.ServerStreamingServer[RunEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Run not implemented")
+}
+func (UnimplementedCoreServer) mustEmbedUnimplementedCoreServer() {}
+func (UnimplementedCoreServer) testEmbeddedByValue()              {}
+
+// UnsafeCoreServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CoreServer will
+// result in compilation errors.
+type UnsafeCoreServer interface {
+	mustEmbedUnimplementedCoreServer()
+}
+
+func RegisterCoreServer(s grpc.ServiceRegistrar, srv CoreServer) {
+	// If the following call pancis, it indicates UnimplementedCoreServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Core_ServiceDesc, srv)
+}
+
+func _Core_Parse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).Parse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Core_Parse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).Parse(ctx, req.(*ParseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_Validate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).Validate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Core_Validate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).Validate(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_BuildArgv_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).BuildArgv(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Core_BuildArgv_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).BuildArgv(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_Run_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RunRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CoreServer).Run(m, &grpc.GenericServerStream[RunRequest, RunEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Core_RunServer = grpc.ServerStreamingServer[RunEvent]
+
+// Core_ServiceDesc is the grpc.ServiceDesc for Core service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Core_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gtoc.v1.Core",
+	HandlerType: (*CoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Parse",
+			Handler:    _Core_Parse_Handler,
+		},
+		{
+			MethodName: "Validate",
+			Handler:    _Core_Validate_Handler,
+		},
+		{
+			MethodName: "BuildArgv",
+			Handler:    _Core_BuildArgv_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Run",
+			Handler:       _Core_Run_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "gtoc/v1/core.proto",
+}
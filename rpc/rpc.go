@@ -0,0 +1,9 @@
+// Package rpc serves the core of gtoc as the gRPC service defined in
+// proto/gtoc/v1/core.proto, for clients in other languages and IDE plugins,
+// see Server. Its Go code is generated into rpc/gtocv1 with protoc and the
+// protoc-gen-go and protoc-gen-go-grpc plugins, and checked in:
+//
+//	go generate ./rpc
+package rpc
+
+//go:generate protoc -I ../proto --go_out=.. --go_opt=module=gtoc --go-grpc_out=.. --go-grpc_opt=module=gtoc gtoc/v1/core.proto
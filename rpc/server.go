@@ -0,0 +1,341 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"gtoc/ansi"
+	"gtoc/docopt"
+	"gtoc/progress"
+	"gtoc/rpc/gtocv1"
+	"gtoc/runner"
+)
+
+// Core is what the service calls, the backend of gtoc.
+type Core interface {
+	// Parse returns the pattern of the command, probed for its help text,
+	// or of the help text given
+	Parse(command, help string) (*docopt.Pattern, error)
+	// Validate checks a requested run without running it, an error telling
+	// the run can't be checked rather than it's invalid
+	Validate(req RunRequest) (Validation, error)
+	// Preview returns the command line of a requested run
+	Preview(req RunRequest) (runner.Preview, error)
+	// Run starts a requested run and returns the ID of its job, watch being
+	// told what the job and those of its retries report, see
+	// runner.Request
+	Run(req RunRequest, watch runner.StartSink) (string, error)
+	// Cancel stops the job id
+	Cancel(id string) error
+}
+
+// RunRequest is a run as a client asks for it, see gtocv1.RunRequest.
+type RunRequest struct {
+	Command string
+	// Values are keyed as in the pattern, decoded from JSON
+	Values  map[string]interface{}
+	Shell   string
+	Env     runner.Env
+	Dir     string
+	Timeout time.Duration
+}
+
+// Validation is whether the values of a run fit the pattern of its command,
+// see gtocv1.Validation.
+type Validation struct {
+	Valid  bool
+	Error  string
+	Line   string
+	Fields map[string]string
+}
+
+// TokenKey is the metadata key calls carry the token of the server in.
+const TokenKey = "x-gtoc-token"
+
+// Server is the gtocv1.CoreServer of a Core.
+type Server struct {
+	gtocv1.UnimplementedCoreServer
+
+	core Core
+}
+
+// New returns the gRPC server of the service of core, answering only calls
+// made with token, see TokenKey, as anyone who can reach it can run
+// commands.
+func New(core Core, token string) *grpc.Server {
+	check := func(ctx context.Context) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, t := range md.Get(TokenKey) {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return nil
+			}
+		}
+		return status.Error(codes.Unauthenticated, "missing or wrong token")
+	}
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := check(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := check(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	gtocv1.RegisterCoreServer(s, &Server{core: core})
+	return s
+}
+
+// failed is the status of an error of the core: the command couldn't be
+// parsed, or its values don't make a command line.
+func failed(err error) error {
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func (s *Server) Parse(_ context.Context, req *gtocv1.ParseRequest) (*gtocv1.Pattern, error) {
+	if req.GetCommand() == "" && req.GetHelp() == "" {
+		return nil, status.Error(codes.InvalidArgument, "a command or a help text is required")
+	}
+	pat, err := s.core.Parse(req.GetCommand(), req.GetHelp())
+	if err != nil {
+		return nil, failed(err)
+	}
+	return pattern(pat)
+}
+
+// pattern returns the message of the pattern.
+func pattern(pat *docopt.Pattern) (*gtocv1.Pattern, error) {
+	value := pat.Value
+	if list, ok := value.([]string); ok {
+		items := make([]interface{}, len(list))
+		for i, item := range list {
+			items[i] = item
+		}
+		value = items
+	}
+	v, err := structpb.NewValue(value)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "value of %s: %s", pat.Name, err)
+	}
+	msg := &gtocv1.Pattern{
+		Type:        pat.T.String(),
+		Name:        pat.Name,
+		Short:       pat.Short,
+		Long:        pat.Long,
+		Argcount:    int32(pat.Argcount),
+		Value:       v,
+		Description: pat.Description,
+		Metavar:     pat.Metavar,
+		Default:     pat.Default,
+		Choices:     pat.Choices,
+		Section:     pat.Section,
+		EnvVar:      pat.EnvVar,
+		Deprecated:  pat.Deprecated,
+		Stdin:       pat.Stdin,
+		Source:      pat.Provenance.Kind.String(),
+	}
+	for _, child := range pat.Children {
+		c, err := pattern(child)
+		if err != nil {
+			return nil, err
+		}
+		msg.Children = append(msg.Children, c)
+	}
+	for name, sub := range pat.Subcommands {
+		if msg.Subcommands == nil {
+			msg.Subcommands = make(map[string]*gtocv1.Pattern)
+		}
+		c, err := pattern(sub)
+		if err != nil {
+			return nil, err
+		}
+		msg.Subcommands[name] = c
+	}
+	return msg, nil
+}
+
+// request returns the RunRequest of the message.
+func request(req *gtocv1.RunRequest) (RunRequest, error) {
+	if req.GetCommand() == "" {
+		return RunRequest{}, status.Error(codes.InvalidArgument, "a command is required")
+	}
+	env := req.GetEnv()
+	return RunRequest{
+		Command: req.GetCommand(),
+		Values:  req.GetValues().AsMap(),
+		Shell:   req.GetShell(),
+		Env:     runner.Env{Clean: env.GetClean(), Set: env.GetSet(), Unset: env.GetUnset()},
+		Dir:     req.GetDir(),
+		Timeout: req.GetTimeout().AsDuration(),
+	}, nil
+}
+
+func (s *Server) Validate(_ context.Context, msg *gtocv1.RunRequest) (*gtocv1.Validation, error) {
+	req, err := request(msg)
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.core.Validate(req)
+	if err != nil {
+		return nil, failed(err)
+	}
+	return &gtocv1.Validation{Valid: v.Valid, Error: v.Error, Line: v.Line, Fields: v.Fields}, nil
+}
+
+func (s *Server) BuildArgv(_ context.Context, msg *gtocv1.RunRequest) (*gtocv1.Argv, error) {
+	req, err := request(msg)
+	if err != nil {
+		return nil, err
+	}
+	preview, err := s.core.Preview(req)
+	if err != nil {
+		return nil, failed(err)
+	}
+	return &gtocv1.Argv{Argv: preview.Argv, Line: preview.Line}, nil
+}
+
+// Run starts the run and sends what its jobs report until it ends, or
+// cancels it when the call is.
+func (s *Server) Run(msg *gtocv1.RunRequest, stream gtocv1.Core_RunServer) error {
+	req, err := request(msg)
+	if err != nil {
+		return err
+	}
+	w := &watch{
+		stream:  stream,
+		streams: make(map[runner.Stream]*streamState),
+		done:    make(chan struct{}),
+	}
+	if _, err = s.core.Run(req, w); err != nil {
+		return failed(err)
+	}
+	select {
+	case <-w.done:
+	case <-stream.Context().Done():
+		// the job that's running, or whose retry is waiting. It fails only
+		// for a job that ended meanwhile, which needs no canceling.
+		s.core.Cancel(w.current())
+	}
+	// the stream can't be sent on once the call returns
+	return w.close()
+}
+
+// watch sends what the jobs of a run report on the stream of its call.
+type watch struct {
+	stream gtocv1.Core_RunServer
+
+	// mu serializes the sends, the streams of a job being read
+	// concurrently
+	mu      sync.Mutex
+	job     string
+	streams map[runner.Stream]*streamState
+	closed  bool
+	err     error
+	done    chan struct{}
+}
+
+// streamState is the progress of a stream of the job running.
+type streamState struct {
+	parser   ansi.Parser
+	detector progress.Detector
+}
+
+// send sends the event unless the call returned or a send failed, w.mu
+// being held.
+func (w *watch) send(event *gtocv1.RunEvent) {
+	if w.closed || w.err != nil {
+		return
+	}
+	w.err = w.stream.Send(event)
+}
+
+func (w *watch) current() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.job
+}
+
+// close stops the sends and returns the error of the call.
+func (w *watch) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if err := w.stream.Context().Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return w.err
+}
+
+func (w *watch) Started(id string, _ runner.Request) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.job = id
+	w.streams = make(map[runner.Stream]*streamState)
+	w.send(&gtocv1.RunEvent{Event: &gtocv1.RunEvent_JobId{JobId: id}})
+}
+
+func (w *watch) Output(c runner.Chunk) {
+	var stream gtocv1.Stream
+	switch c.Stream {
+	case runner.Stdout, runner.Terminal:
+		stream = gtocv1.Stream_STREAM_STDOUT
+	case runner.Stderr:
+		stream = gtocv1.Stream_STREAM_STDERR
+	default:
+		// the files followed aren't the output of the run
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.send(&gtocv1.RunEvent{Event: &gtocv1.RunEvent_Output_{Output: &gtocv1.RunEvent_Output{
+		Stream: stream,
+		Data:   []byte(c.Data),
+	}}})
+	state, ok := w.streams[c.Stream]
+	if !ok {
+		state = &streamState{}
+		w.streams[c.Stream] = state
+	}
+	var text string
+	for _, s := range state.parser.Feed(c.Data) {
+		text += s.Text
+	}
+	if p, ok := state.detector.Feed(text); ok {
+		w.send(&gtocv1.RunEvent{Event: &gtocv1.RunEvent_Progress{Progress: &gtocv1.Progress{
+			Percent: p.Percent,
+			Current: p.Current,
+			Total:   p.Total,
+		}}})
+	}
+}
+
+func (w *watch) Exit(result runner.RunResult) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.send(&gtocv1.RunEvent{Event: &gtocv1.RunEvent_Result{Result: &gtocv1.Result{
+		JobId:  result.JobID,
+		Argv:   result.Argv,
+		Start:  timestamppb.New(result.Start),
+		End:    timestamppb.New(result.End),
+		State:  string(result.State),
+		Code:   int32(result.Code),
+		Signal: result.Signal,
+		Err:    result.Err,
+	}}})
+	if !result.Retrying {
+		close(w.done)
+	}
+}
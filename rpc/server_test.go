@@ -0,0 +1,213 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"gtoc/docopt"
+	"gtoc/rpc/gtocv1"
+	"gtoc/runner"
+)
+
+// core parses help texts and runs "ls", which fails once and is retried,
+// and "sleep", which runs until it's canceled.
+type core struct {
+	canceled chan string
+}
+
+func (core) Parse(command, help string) (*docopt.Pattern, error) {
+	if command == "ls" {
+		help = "Usage: ls [-l] [<file>...]"
+	}
+	return docopt.ParsePattern(help)
+}
+
+func (c core) Validate(req RunRequest) (Validation, error) {
+	if _, err := c.Preview(req); err != nil {
+		return Validation{Error: err.Error(), Fields: map[string]string{"<file>": "missing"}}, nil
+	}
+	return Validation{Valid: true, Line: "ls -l"}, nil
+}
+
+func (core) Preview(req RunRequest) (runner.Preview, error) {
+	if req.Values["-l"] != true {
+		return runner.Preview{}, errors.New("-l is required")
+	}
+	return runner.Preview{Argv: []string{"ls", "-l"}, Line: "ls -l"}, nil
+}
+
+func (c core) Run(req RunRequest, watch runner.StartSink) (string, error) {
+	switch req.Command {
+	case "ls":
+		watch.Started("1", runner.Request{})
+		go func() {
+			watch.Output(runner.Chunk{JobID: "1", Stream: runner.Stderr, Data: "no such file\n"})
+			watch.Exit(runner.RunResult{JobID: "1", State: runner.StateExited, Code: 2, Retrying: true})
+			watch.Started("2", runner.Request{})
+			watch.Output(runner.Chunk{JobID: "2", Stream: runner.File, Data: "log"})
+			watch.Output(runner.Chunk{JobID: "2", Stream: runner.Stdout, Data: "\x1b[1m50%\x1b[0m\n"})
+			watch.Exit(runner.RunResult{JobID: "2", State: runner.StateExited})
+		}()
+		return "1", nil
+	case "sleep":
+		watch.Started("3", runner.Request{})
+		return "3", nil
+	}
+	return "", errors.New("no command " + req.Command)
+}
+
+func (c core) Cancel(id string) error {
+	c.canceled <- id
+	return nil
+}
+
+// dial serves the service of c on a buffer and returns a client of it.
+func dial(t *testing.T, c Core) gtocv1.CoreClient {
+	lis := bufconn.Listen(1 << 20)
+	s := New(c, "secret")
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///buf",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return gtocv1.NewCoreClient(conn)
+}
+
+func authorized() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), TokenKey, "secret")
+}
+
+func TestServer(t *testing.T) {
+	client := dial(t, core{})
+	ctx := authorized()
+
+	pat, err := client.Parse(ctx, &gtocv1.ParseRequest{Source: &gtocv1.ParseRequest_Command{Command: "ls"}})
+	if err != nil || pat.Type != "required" || len(pat.Children[0].Children) != 2 {
+		t.Fatalf("result: %v %v expected: the pattern of ls", pat, err)
+	}
+	long := pat.Children[0].Children[0].Children[0]
+	if _, flag := long.Value.GetKind().(*structpb.Value_BoolValue); long.Name != "-l" || !flag || long.Value.GetBoolValue() {
+		t.Errorf("result: %v expected: -l false", long)
+	}
+	files := pat.Children[0].Children[1].Children[0].Children[0]
+	if files.Type != "argument" || files.Name != "<file>" {
+		t.Errorf("result: %v expected: the argument <file>", files)
+	}
+	_, err = client.Parse(ctx, &gtocv1.ParseRequest{Source: &gtocv1.ParseRequest_Help{Help: "no usage"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("result: %v expected: an invalid argument", err)
+	}
+	if _, err = client.Parse(ctx, &gtocv1.ParseRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("result: %v expected: an invalid argument", err)
+	}
+
+	values, _ := structpb.NewStruct(map[string]interface{}{"-l": true})
+	v, err := client.Validate(ctx, &gtocv1.RunRequest{Command: "ls"})
+	if err != nil || v.Valid || v.Fields["<file>"] != "missing" {
+		t.Errorf("result: %v %v expected: an invalid run", v, err)
+	}
+	v, err = client.Validate(ctx, &gtocv1.RunRequest{Command: "ls", Values: values})
+	if err != nil || !v.Valid || v.Line != "ls -l" {
+		t.Errorf("result: %v %v expected: a valid run", v, err)
+	}
+	argv, err := client.BuildArgv(ctx, &gtocv1.RunRequest{Command: "ls", Values: values})
+	if err != nil || !reflect.DeepEqual(argv.Argv, []string{"ls", "-l"}) {
+		t.Errorf("result: %v %v expected: ls -l", argv, err)
+	}
+	if _, err = client.BuildArgv(ctx, &gtocv1.RunRequest{Command: "ls"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("result: %v expected: an invalid argument", err)
+	}
+	if _, err = client.Validate(ctx, &gtocv1.RunRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("result: %v expected: a command required", err)
+	}
+
+	// calls without the token are refused
+	_, err = client.Parse(context.Background(), &gtocv1.ParseRequest{Source: &gtocv1.ParseRequest_Command{Command: "ls"}})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("result: %v expected: unauthenticated", err)
+	}
+	stream, err := client.Run(metadata.AppendToOutgoingContext(context.Background(), TokenKey, "guess"), &gtocv1.RunRequest{Command: "ls"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("result: %v expected: unauthenticated", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	c := core{make(chan string, 1)}
+	client := dial(t, c)
+
+	stream, err := client.Run(authorized(), &gtocv1.RunRequest{Command: "ls"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			break
+		}
+		switch e := event.Event.(type) {
+		case *gtocv1.RunEvent_JobId:
+			events = append(events, "job "+e.JobId)
+		case *gtocv1.RunEvent_Output_:
+			events = append(events, e.Output.Stream.String()+" "+strings.TrimSpace(string(e.Output.Data)))
+		case *gtocv1.RunEvent_Progress:
+			events = append(events, fmt.Sprintf("progress %v%%", e.Progress.Percent))
+		case *gtocv1.RunEvent_Result:
+			events = append(events, "result "+e.Result.JobId+" "+e.Result.State)
+		}
+	}
+	expected := []string{
+		"job 1", "STREAM_STDERR no such file", "result 1 exited",
+		"job 2", "STREAM_STDOUT \x1b[1m50%\x1b[0m", "progress 50%", "result 2 exited",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("result: %q expected: %q", events, expected)
+	}
+
+	stream, err = client.Run(authorized(), &gtocv1.RunRequest{Command: "rm"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("result: %v expected: an invalid argument", err)
+	}
+
+	// canceling the call cancels the job
+	ctx, cancel := context.WithCancel(authorized())
+	stream, err = client.Run(ctx, &gtocv1.RunRequest{Command: "sleep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event, err := stream.Recv(); err != nil || event.GetJobId() != "3" {
+		t.Fatalf("result: %v %v expected: job 3", event, err)
+	}
+	cancel()
+	if id := <-c.canceled; id != "3" {
+		t.Errorf("result: %s expected: job 3 canceled", id)
+	}
+}
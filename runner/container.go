@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Container is a container a Request runs its program in, with docker or
+// podman.
+type Container struct {
+	// Engine is "docker" or "podman", docker if empty
+	Engine string `json:"engine"`
+	// Name is the running container the program is exec'd in. If it's empty
+	// a container is run from Image instead, and removed once the program
+	// exits.
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// Wrap returns the command line running words in the container, for
+// probing a program in it for its help text.
+func (c Container) Wrap(words []string) ([]string, error) {
+	return c.argv(words, nil, nil, "", false)
+}
+
+// argv returns the command line running words in the container with the
+// variables of env set. A container run from an image has the directories
+// of mounts bound at the same paths, and dir as working directory. A
+// terminal is allocated if tty is set.
+func (c Container) argv(words []string, env map[string]string, mounts []string, dir string, tty bool) ([]string, error) {
+	engine := c.Engine
+	if engine == "" {
+		engine = "docker"
+	}
+	if engine != "docker" && engine != "podman" {
+		return nil, fmt.Errorf("unknown container engine %s", engine)
+	}
+	args := []string{engine}
+	if c.Name != "" {
+		args = append(args, "exec", "-i")
+	} else if c.Image != "" {
+		// the init process passes Cancel's interrupt on to the program
+		args = append(args, "run", "--rm", "-i", "--init")
+	} else {
+		return nil, fmt.Errorf("no container nor image to run %s in", words[0])
+	}
+	if tty {
+		args = append(args, "-t")
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-e", name+"="+env[name])
+	}
+	if c.Name != "" {
+		return append(append(args, c.Name), words...), nil
+	}
+	for _, m := range mounts {
+		args = append(args, "-v", m+":"+m)
+	}
+	if dir != "" {
+		args = append(args, "-w", dir)
+	}
+	return append(append(args, c.Image), words...), nil
+}
+
+// mounts returns the directories a container run for the request binds:
+// the working directory, and those of the values naming files elsewhere, so
+// that the program finds the files at the paths it's given. A value names a
+// file if one exists at its path, or if it holds a separator and its parent
+// directory exists, for files the program writes. Paths holding a colon
+// can't be bound and are left out.
+func mounts(req Request, dir string) []string {
+	bound := []string{}
+	covered := func(path string) bool {
+		for _, m := range bound {
+			if path == m || strings.HasPrefix(path, m+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+	bind := func(path string) {
+		if !covered(path) && !strings.Contains(path, ":") {
+			bound = append(bound, path)
+		}
+	}
+	if dir != "" {
+		bind(dir)
+	}
+	names := make([]string, 0, len(req.Values))
+	for name := range req.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var values []string
+		switch v := req.Values[name].(type) {
+		case string:
+			values = []string{v}
+		case []string:
+			values = v
+		}
+		for _, v := range values {
+			if v == "" {
+				continue
+			}
+			path := v
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			path = filepath.Clean(path)
+			if covered(path) {
+				continue
+			}
+			if info, err := os.Stat(path); err == nil {
+				if !info.IsDir() {
+					path = filepath.Dir(path)
+				}
+				bind(path)
+			} else if strings.ContainsRune(v, filepath.Separator) {
+				if info, err := os.Stat(filepath.Dir(path)); err == nil && info.IsDir() {
+					bind(filepath.Dir(path))
+				}
+			}
+		}
+	}
+	sort.Strings(bound)
+	return bound
+}
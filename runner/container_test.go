@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestContainerCommand(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: cat <file>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		container Container
+		argv      []string
+	}{
+		{
+			Container{Name: "web"},
+			[]string{"docker", "exec", "-i", "-e", "A=1", "web", "cat", "f"},
+		},
+		{
+			Container{Engine: "podman", Image: "alpine"},
+			[]string{"podman", "run", "--rm", "-i", "--init", "-e", "A=1", "-v", "/work:/work", "-w", "/work", "alpine", "cat", "f"},
+		},
+	}
+	for i, tt := range tests {
+		tt := tt
+		preview, err := DryRun(Request{
+			Program: "cat", Pattern: pat, Values: docopt.Opts{"<file>": "f"},
+			Env: Env{Set: map[string]string{"A": "1"}}, Dir: "/work", Container: &tt.container,
+		})
+		if err != nil || !reflect.DeepEqual(preview.Argv, tt.argv) {
+			t.Errorf("testcase: %d result: %q %v expected: %q", i, preview.Argv, err, tt.argv)
+		}
+	}
+
+	for i, c := range []Container{{}, {Engine: "lxc", Name: "web"}} {
+		c := c
+		if _, err := DryRun(Request{Program: "cat", Pattern: pat, Values: docopt.Opts{"<file>": "f"}, Container: &c}); err == nil {
+			t.Errorf("testcase: %d container accepted: %+v", i, c)
+		}
+	}
+
+	argv, err := Container{Image: "alpine"}.Wrap([]string{"ls", "--help"})
+	if expected := []string{"docker", "run", "--rm", "-i", "--init", "alpine", "ls", "--help"}; err != nil || !reflect.DeepEqual(argv, expected) {
+		t.Errorf("result: %q %v expected: %q", argv, err, expected)
+	}
+}
+
+func TestMounts(t *testing.T) {
+	root, err := ioutil.TempDir("", "gtoc-mounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"work/sub", "data", "out", "a:b"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+	}
+	ioutil.WriteFile(filepath.Join(root, "data", "in.txt"), nil, 0644)
+	work := filepath.Join(root, "work")
+
+	values := docopt.Opts{
+		"<in>":    filepath.Join(root, "data", "in.txt"),
+		"<out>":   filepath.Join(root, "out", "new.txt"),
+		"<rel>":   []string{"sub", "../out/other.txt", "word"},
+		"--colon": filepath.Join(root, "a:b"),
+		"--gone":  "/nonexistent/dir/file",
+		"-v":      true,
+	}
+	result := mounts(Request{Values: values}, work)
+	expected := []string{filepath.Join(root, "data"), filepath.Join(root, "out"), work}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("result: %q expected: %q", result, expected)
+	}
+}
@@ -0,0 +1,148 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Elevation is how a run gets the rights of root, see Request.
+type Elevation string
+
+const (
+	// Pkexec asks the polkit agent of the desktop session for the password.
+	Pkexec Elevation = "pkexec"
+	// Sudo asks for the password with the askpass program of SUDO_ASKPASS,
+	// or one of askpassPrograms.
+	Sudo Elevation = "sudo"
+)
+
+// ErrAuthFailed tells that the user couldn't be authenticated to run a
+// program as root, or declined to.
+var ErrAuthFailed = errors.New("authentication failed")
+
+// askpassPrograms are the askpass programs sudo is given when SUDO_ASKPASS
+// isn't set, the first one found is used.
+var askpassPrograms = []string{
+	"ssh-askpass",
+	"ksshaskpass",
+	"lxqt-openssh-askpass",
+	"/usr/lib/ssh/ssh-askpass",
+	"/usr/libexec/openssh/ssh-askpass",
+	"/usr/lib/openssh/gnome-ssh-askpass",
+}
+
+// DefaultElevation returns pkexec if it's installed, sudo otherwise, or ""
+// if neither is.
+func DefaultElevation() Elevation {
+	for _, e := range []Elevation{Pkexec, Sudo} {
+		if _, err := exec.LookPath(string(e)); err == nil {
+			return e
+		}
+	}
+	return ""
+}
+
+// elevated returns the command running words as root. The elevation program
+// runs in gtoc's environment, the request's one being set up for the program
+// with env, as neither pkexec nor sudo pass it on.
+func elevated(req Request, words []string) (*exec.Cmd, error) {
+	opts := []string{}
+	if req.Env.Clean {
+		opts = append(opts, "-i")
+	}
+	for _, name := range req.Env.Unset {
+		opts = append(opts, "-u", name)
+	}
+	var env []string
+	var args []string
+	switch req.Elevate {
+	case Pkexec:
+		// pkexec runs the program in the home directory of root
+		dir := req.Dir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		if dir != "" {
+			opts = append(opts, "--chdir="+dir)
+		}
+		env = os.Environ()
+		args = []string{"pkexec"}
+	case Sudo:
+		askpass, err := askpassEnv()
+		if err != nil {
+			return nil, err
+		}
+		env = append(os.Environ(), askpass...)
+		args = []string{"sudo", "-A", "--"}
+	default:
+		return nil, fmt.Errorf("unknown elevation %s", req.Elevate)
+	}
+	names := make([]string, 0, len(req.Env.Set))
+	for name := range req.Env.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		opts = append(opts, name+"="+req.Env.Set[name])
+	}
+	if len(opts) > 0 {
+		args = append(append(args, "env"), opts...)
+	}
+	args = append(args, words...)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = env
+	cmd.Dir = req.Dir
+	return cmd, nil
+}
+
+// askpassEnv returns the environment telling sudo its askpass program: none
+// if SUDO_ASKPASS is set already.
+func askpassEnv() ([]string, error) {
+	if os.Getenv("SUDO_ASKPASS") != "" {
+		return nil, nil
+	}
+	for _, program := range askpassPrograms {
+		if path, err := exec.LookPath(program); err == nil {
+			return []string{"SUDO_ASKPASS=" + path}, nil
+		}
+	}
+	return nil, errors.New("sudo needs an askpass program to ask for the password, set SUDO_ASKPASS")
+}
+
+// authenticate has the user authenticate for runs through sudo before they
+// start, so that a failure isn't taken for the program's own. Failures are
+// reported as ErrAuthFailed. Pkexec can't authenticate apart from running
+// the program, its failures are told by the exit code, see authFailed.
+func authenticate(req Request) error {
+	if req.Elevate != Sudo {
+		return nil
+	}
+	askpass, err := askpassEnv()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("sudo", "-A", "-v")
+	cmd.Env = append(os.Environ(), askpass...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", ErrAuthFailed, msg)
+		}
+		return ErrAuthFailed
+	}
+	return nil
+}
+
+// authFailed tells whether the job, run as root the elevation way, failed to
+// authenticate. Pkexec exits with 126 when the user dismisses the dialog and
+// 127 when authentication fails, which a program it ran can hardly be told
+// apart from.
+func authFailed(elevate Elevation, result RunResult) bool {
+	return elevate == Pkexec && result.State == StateExited && (result.Code == 126 || result.Code == 127)
+}
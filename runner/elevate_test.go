@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+// fakeElevation puts pkexec and sudo scripts first in PATH, which run the
+// program they're given unless GTOC_TEST_DENY is set. It returns a function
+// undoing it.
+func fakeElevation(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "gtoc-elevate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts := map[string]string{
+		"pkexec": `[ -n "$GTOC_TEST_DENY" ] && exit $GTOC_TEST_DENY; exec "$@"`,
+		"sudo":   `[ "$2" = -v ] && exit ${GTOC_TEST_DENY:-0}; shift 2; exec "$@"`,
+	}
+	for name, script := range scripts {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	os.Setenv("SUDO_ASKPASS", "/bin/false")
+	return func() {
+		os.Setenv("PATH", path)
+		os.Unsetenv("SUDO_ASKPASS")
+		os.Unsetenv("GTOC_TEST_DENY")
+		os.RemoveAll(dir)
+	}
+}
+
+func TestElevatedCommand(t *testing.T) {
+	defer fakeElevation(t)()
+	pat, err := docopt.ParsePattern("Usage: echo <word>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		req  Request
+		argv []string
+	}{
+		{
+			Request{Elevate: Pkexec, Dir: "/tmp", Env: Env{Set: map[string]string{"B": "2", "A": "1"}}},
+			[]string{"pkexec", "env", "--chdir=/tmp", "A=1", "B=2", "echo", "hi"},
+		},
+		{
+			Request{Elevate: Sudo},
+			[]string{"sudo", "-A", "--", "echo", "hi"},
+		},
+		{
+			Request{Elevate: Sudo, Env: Env{Clean: true, Unset: []string{"X"}}},
+			[]string{"sudo", "-A", "--", "env", "-i", "-u", "X", "echo", "hi"},
+		},
+	}
+	for i, tt := range tests {
+		tt.req.Program, tt.req.Pattern, tt.req.Values = "echo", pat, docopt.Opts{"<word>": "hi"}
+		preview, err := DryRun(tt.req)
+		if err != nil || !reflect.DeepEqual(preview.Argv, tt.argv) {
+			t.Errorf("testcase: %d result: %q %v expected: %q", i, preview.Argv, err, tt.argv)
+		}
+	}
+}
+
+func TestElevatedRun(t *testing.T) {
+	defer fakeElevation(t)()
+	pat, err := docopt.ParsePattern("Usage: echo <word>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(elevate Elevation) (*recorder, string, error) {
+		rec := newRecorder()
+		id, err := New(rec).Start(Request{Program: "echo", Pattern: pat, Values: docopt.Opts{"<word>": "hi"}, Elevate: elevate})
+		if err == nil {
+			<-rec.done
+		}
+		return rec, id, err
+	}
+
+	for i, elevate := range []Elevation{Pkexec, Sudo} {
+		rec, id, err := run(elevate)
+		if err != nil || rec.output[Stdout] != "hi\n" || statusOf(rec.exit) != (status{JobID: id, State: StateExited}) {
+			t.Errorf("testcase: %d result: %q %+v %v", i, rec.output, rec.exit, err)
+		}
+	}
+
+	os.Setenv("GTOC_TEST_DENY", "126")
+	rec, id, err := run(Pkexec)
+	expect := status{JobID: id, State: StateAuthFailed, Code: 126, Err: ErrAuthFailed.Error()}
+	if err != nil || statusOf(rec.exit) != expect {
+		t.Errorf("result: %+v %v", rec.exit, err)
+	}
+	if _, _, err = run(Sudo); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("result: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"gtoc/docopt"
+)
+
+// Env is the environment of a run: gtoc's own, unless Clean is set, with the
+// variables of Unset removed and those of Set added or overridden.
+type Env struct {
+	Clean bool              `json:"clean"`
+	Set   map[string]string `json:"set"`
+	Unset []string          `json:"unset"`
+}
+
+// Environ returns the environment as "key=value" strings, for exec.Cmd.Env.
+func (e Env) Environ() []string {
+	env := []string{}
+	if !e.Clean {
+		env = os.Environ()
+	}
+	unset := make(map[string]bool)
+	for _, name := range e.Unset {
+		unset[name] = true
+	}
+	for name := range e.Set {
+		// overridden, set again below
+		unset[name] = true
+	}
+	kept := env[:0]
+	for _, kv := range env {
+		name := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			name = kv[:i]
+		}
+		if !unset[name] {
+			kept = append(kept, kv)
+		}
+	}
+	names := make([]string, 0, len(e.Set))
+	for name := range e.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		kept = append(kept, name+"="+e.Set[name])
+	}
+	return kept
+}
+
+// EnvHint is an environment variable the help text of a program mentions, for
+// the environment editor to offer.
+type EnvHint struct {
+	Name string `json:"name"`
+	// Leaf is the argument or option the variable stands for
+	Leaf        string `json:"leaf"`
+	Description string `json:"description"`
+	// Value is the variable's value in gtoc's environment, if Inherited
+	Value     string `json:"value"`
+	Inherited bool   `json:"inherited"`
+}
+
+// EnvHints returns the environment variables the leaves of pat can be set
+// with, in the order of the usage.
+func EnvHints(pat *docopt.Pattern) []EnvHint {
+	hints := []EnvHint{}
+	for _, leaf := range pat.EnvLeaves() {
+		value, ok := os.LookupEnv(leaf.EnvVar)
+		hints = append(hints, EnvHint{
+			Name:        leaf.EnvVar,
+			Leaf:        leaf.Name,
+			Description: leaf.Description,
+			Value:       value,
+			Inherited:   ok,
+		})
+	}
+	return hints
+}
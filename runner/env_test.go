@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestEnviron(t *testing.T) {
+	os.Setenv("GTOC_TEST_KEEP", "keep")
+	os.Setenv("GTOC_TEST_UNSET", "unset")
+	os.Setenv("GTOC_TEST_OVERRIDE", "old")
+	defer func() {
+		for _, name := range []string{"GTOC_TEST_KEEP", "GTOC_TEST_UNSET", "GTOC_TEST_OVERRIDE"} {
+			os.Unsetenv(name)
+		}
+	}()
+	env := Env{
+		Set:   map[string]string{"GTOC_TEST_OVERRIDE": "new", "GTOC_TEST_ADD": "a=b"},
+		Unset: []string{"GTOC_TEST_UNSET"},
+	}
+	var found []string
+	for _, kv := range env.Environ() {
+		if strings.HasPrefix(kv, "GTOC_TEST_") {
+			found = append(found, kv)
+		}
+	}
+	expect := []string{"GTOC_TEST_KEEP=keep", "GTOC_TEST_ADD=a=b", "GTOC_TEST_OVERRIDE=new"}
+	if !reflect.DeepEqual(found, expect) {
+		t.Errorf("result: %v", found)
+	}
+
+	env.Clean = true
+	if result := env.Environ(); !reflect.DeepEqual(result, []string{"GTOC_TEST_ADD=a=b", "GTOC_TEST_OVERRIDE=new"}) {
+		t.Errorf("result: %v", result)
+	}
+}
+
+func TestEnvHints(t *testing.T) {
+	pat, err := docopt.ParsePattern(`Usage: aws [--profile=<p>] [--region=<r>] <cmd>
+
+Options:
+  --profile=<p>  Profile to use [env: AWS_PROFILE].
+  --region=<r>   Region [env: GTOC_TEST_REGION].`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Unsetenv("AWS_PROFILE")
+	os.Setenv("GTOC_TEST_REGION", "eu-west-1")
+	defer os.Unsetenv("GTOC_TEST_REGION")
+	expect := []EnvHint{
+		{Name: "AWS_PROFILE", Leaf: "--profile", Description: "Profile to use [env: AWS_PROFILE]."},
+		{Name: "GTOC_TEST_REGION", Leaf: "--region", Description: "Region [env: GTOC_TEST_REGION].", Value: "eu-west-1", Inherited: true},
+	}
+	if hints := EnvHints(pat); !reflect.DeepEqual(hints, expect) {
+		t.Errorf("result: %+v", hints)
+	}
+
+	// the variables reach the program
+	pat, _ = docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	rec := newRecorder()
+	_, err = New(rec).Start(Request{
+		Program: "sh",
+		Pattern: pat,
+		Values:  docopt.Opts{"-c": "echo $AWS_PROFILE ${GTOC_TEST_REGION-none}"},
+		Env:     Env{Set: map[string]string{"AWS_PROFILE": "dev"}, Unset: []string{"GTOC_TEST_REGION"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rec.done
+	if rec.output[Stdout] != "dev none\n" {
+		t.Errorf("result: %q", rec.output)
+	}
+}
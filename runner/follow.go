@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// followPoll is how often the files followed are read, along with whenever
+// they're notified to change: notifications may not come on every file
+// system.
+const followPoll = 250 * time.Millisecond
+
+// follower tails a file a job writes, see Request.Follow.
+type follower struct {
+	path string
+	// info is of the file read last, nil if there was none, and offset how
+	// far it was read
+	info   os.FileInfo
+	offset int64
+}
+
+// watch returns the followers of the files of the request, from the end of
+// those there are already, before the program is started.
+func watch(req Request) []*follower {
+	var list []*follower
+	for _, path := range req.Follow {
+		if !filepath.IsAbs(path) && req.Dir != "" {
+			path = filepath.Join(req.Dir, path)
+		}
+		f := &follower{path: filepath.Clean(path)}
+		if info, err := os.Stat(f.path); err == nil {
+			f.info, f.offset = info, info.Size()
+		}
+		list = append(list, f)
+	}
+	return list
+}
+
+// follow reports what's written to the files of the followers as chunks of
+// the job id until the returned function is called, which reads them a last
+// time before it returns.
+func (r *Runner) follow(id string, followers []*follower) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, f := range followers {
+		wg.Add(1)
+		go func(f *follower) {
+			defer wg.Done()
+			f.run(done, func(data string) {
+				r.sink.Output(Chunk{JobID: id, Stream: File, Path: f.path, Data: data})
+			})
+		}(f)
+	}
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (f *follower) run(done <-chan struct{}, emit func(string)) {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	// the directory is watched, for the file to be seen when it's created
+	if w, err := fsnotify.NewWatcher(); err == nil {
+		defer w.Close()
+		if w.Add(filepath.Dir(f.path)) == nil {
+			events, errs = w.Events, w.Errors
+		}
+	}
+	tick := time.NewTicker(followPoll)
+	defer tick.Stop()
+	f.read(emit)
+	for {
+		select {
+		case <-done:
+			f.read(emit)
+			return
+		case e := <-events:
+			if filepath.Clean(e.Name) == f.path {
+				f.read(emit)
+			}
+		case <-errs:
+		case <-tick.C:
+			f.read(emit)
+		}
+	}
+}
+
+// read emits what's been written to the file since it was read last. A file
+// truncated, or replaced by another, is read from its start.
+func (f *follower) read(emit func(string)) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		// not there yet, or removed for another to come
+		return
+	}
+	if f.info != nil && !os.SameFile(f.info, info) || info.Size() < f.offset {
+		f.offset = 0
+	}
+	f.info = info
+	file, err := os.Open(f.path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := file.ReadAt(buf, f.offset)
+		if n > 0 {
+			f.offset += int64(n)
+			emit(string(buf[:n]))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
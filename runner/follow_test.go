@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gtoc/docopt"
+)
+
+// follows is a Sink keeping the chunks of the files followed, and telling
+// whether any came after the exit.
+type follows struct {
+	mu     sync.Mutex
+	data   map[string]string
+	exited bool
+	late   bool
+	done   chan struct{}
+}
+
+func (f *follows) Output(c Chunk) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c.Stream == File {
+		f.data[c.Path] += c.Data
+		f.late = f.late || f.exited
+	}
+}
+
+func (f *follows) Exit(RunResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.exited = true
+	close(f.done)
+}
+
+func TestFollow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-follow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pat, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(dir, "log")
+	if err = ioutil.WriteFile(log, []byte("before\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the log is written to, truncated, and written again; the other file
+	// is created and replaced
+	script := "echo one >> log; sleep 0.4; : > log; sleep 0.4; echo two >> log; " +
+		"echo a > new; sleep 0.4; echo b > tmp; mv tmp new"
+	f := &follows{data: map[string]string{}, done: make(chan struct{})}
+	r := New(f)
+	_, err = r.Start(Request{
+		Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": script}, Dir: dir,
+		Follow: []string{"log", "new", "missing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-f.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("job not finished")
+	}
+	for path, expected := range map[string]string{"log": "one\ntwo\n", "new": "a\nb\n", "missing": ""} {
+		if result := f.data[filepath.Join(dir, path)]; result != expected {
+			t.Errorf("file: %s result: %q expected: %q", path, result, expected)
+		}
+	}
+	if f.late {
+		t.Errorf("file followed after the exit")
+	}
+}
@@ -0,0 +1,183 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gtoc/store"
+)
+
+// historySize bounds the entries of a History, the oldest are dropped first
+// along with their output.
+const historySize = 1000
+
+// historyOutputSize bounds the output file of a run in a History, the output
+// past it isn't recorded.
+const historyOutputSize = 16 << 20
+
+// HistoryEntry is a run recorded in a History: what was asked for, how it
+// went, and the file holding its output.
+type HistoryEntry struct {
+	ID string `json:"id"`
+	Invocation
+	// Attempt is which attempt of its Retry the run was, 1 for the first.
+	// Every attempt has an entry of its own.
+	Attempt int `json:"attempt"`
+	// Result is nil for runs that haven't finished, or were cut short by
+	// gtoc exiting
+	Result *RunResult `json:"result,omitempty"`
+	// Output is the path of the file holding the output of the run, its
+	// streams interleaved as they came, up to historyOutputSize bytes
+	Output string `json:"output"`
+}
+
+// History records every run in a directory: the entries in history.json and
+// the output of every run in a file of its own. It's a StartSink and a
+// BatchSink passing everything on to the next sink.
+type History struct {
+	next Sink
+	dir  string
+	file *store.File
+
+	mu      sync.Mutex
+	entries []*HistoryEntry
+	// running are the entries and output files of the running jobs
+	running map[string]*recording
+}
+
+type recording struct {
+	entry  *HistoryEntry
+	output *outputFile
+}
+
+// OpenHistory loads the history kept in dir, creating it if needed, to
+// record the runs reported to it before passing them on to next.
+func OpenHistory(dir string, next Sink) (*History, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "output"), 0755); err != nil {
+		return nil, err
+	}
+	h := &History{
+		next:    next,
+		dir:     dir,
+		file:    &store.File{Path: filepath.Join(dir, "history.json")},
+		running: make(map[string]*recording),
+	}
+	if err := h.file.Load(&h.entries); err != nil {
+		return nil, err
+	}
+	for _, e := range h.entries {
+		// JSON has no ints or string lists
+		e.DecodeValues()
+	}
+	return h, nil
+}
+
+// Started records a new entry for the job id.
+func (h *History) Started(id string, req Request) {
+	e := &HistoryEntry{
+		// job IDs start over with every session
+		ID:         strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + id,
+		Invocation: req.Invocation(),
+		Attempt:    req.attempt(),
+	}
+	e.Output = filepath.Join(h.dir, "output", e.ID+".log")
+	// a run goes on without its output recorded rather than not at all
+	output, _ := Capture{Path: e.Output, MaxSize: historyOutputSize}.open()
+
+	h.mu.Lock()
+	h.running[id] = &recording{e, output}
+	h.entries = append(h.entries, e)
+	var dropped []*HistoryEntry
+	if len(h.entries) > historySize {
+		dropped = h.entries[:len(h.entries)-historySize]
+		h.entries = h.entries[len(h.entries)-historySize:]
+	}
+	h.save()
+	h.mu.Unlock()
+	for _, e := range dropped {
+		os.Remove(e.Output)
+	}
+	if s, ok := h.next.(StartSink); ok {
+		s.Started(id, req)
+	}
+}
+
+// Output writes the chunk to the output file of its job, unless it's of a
+// file followed, and passes it on.
+func (h *History) Output(c Chunk) {
+	h.mu.Lock()
+	if rec, ok := h.running[c.JobID]; ok && rec.output != nil && c.Stream != File {
+		rec.output.Write([]byte(c.Data))
+	}
+	h.mu.Unlock()
+	h.next.Output(c)
+}
+
+// Exit records the result of the job and passes it on.
+func (h *History) Exit(result RunResult) {
+	h.mu.Lock()
+	if rec, ok := h.running[result.JobID]; ok {
+		delete(h.running, result.JobID)
+		if rec.output != nil {
+			rec.output.close()
+		}
+		rec.entry.Result = &result
+		h.save()
+	}
+	h.mu.Unlock()
+	h.next.Exit(result)
+}
+
+// Batch passes the status of a batch on, if the next sink wants to know.
+func (h *History) Batch(status BatchStatus) {
+	if s, ok := h.next.(BatchSink); ok {
+		s.Batch(status)
+	}
+}
+
+// save writes the entries, h.mu being held. Failing to is no reason to stop
+// a run, the entries are saved again with the next change.
+func (h *History) save() {
+	h.file.Save(h.entries)
+}
+
+// List returns the entries, the latest first.
+func (h *History) List() []HistoryEntry {
+	return h.Search("")
+}
+
+// Search returns the entries whose program, command line or working
+// directory contain query, ignoring case, the latest first.
+func (h *History) Search(query string) []HistoryEntry {
+	query = strings.ToLower(query)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	found := []HistoryEntry{}
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		e := h.entries[i]
+		text := e.Program + " " + e.Dir
+		if e.Result != nil {
+			text += " " + strings.Join(e.Result.Argv, " ")
+		}
+		if strings.Contains(strings.ToLower(text), query) {
+			found = append(found, *e)
+		}
+	}
+	return found
+}
+
+// Entry returns the entry id.
+func (h *History) Entry(id string) (HistoryEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, e := range h.entries {
+		if e.ID == id {
+			return *e, true
+		}
+	}
+	return HistoryEntry{}, false
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pat, err := docopt.ParsePattern("Usage: sh -c <script> [<args>...]\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := newRecorder()
+	h, err := OpenHistory(dir, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewJobManager(h)
+	values := docopt.Opts{"-c": "echo $0 $GREETING; echo oops >&2", "<args>": []string{"hello"}}
+	req := Request{Program: "sh", Pattern: pat, Values: values, Env: Env{Set: map[string]string{"GREETING": "hi"}}, Dir: dir}
+	if _, err = m.Start(req); err != nil {
+		t.Fatal(err)
+	}
+	<-rec.done
+	if rec.output[Stdout] != "hello hi\n" {
+		t.Errorf("result: %q", rec.output)
+	}
+
+	// the history survives a restart
+	if h, err = OpenHistory(dir, rec); err != nil {
+		t.Fatal(err)
+	}
+	entries := h.List()
+	if len(entries) != 1 {
+		t.Fatalf("result: %v", entries)
+	}
+	e := entries[0]
+	if e.Program != "sh" || !reflect.DeepEqual(e.Values, values) || e.Env.Set["GREETING"] != "hi" || e.Dir != dir ||
+		e.Result == nil || e.Result.State != StateExited {
+		t.Errorf("result: %+v", e)
+	}
+	if output, err := ioutil.ReadFile(e.Output); err != nil || len(output) != len("hello hi\noops\n") {
+		t.Errorf("result: %q error: %v", output, err)
+	}
+
+	for i, c := range []struct {
+		query string
+		found int
+	}{
+		{"", 1},
+		{"HELLO", 1},
+		{"gtoc-history", 1},
+		{"bye", 0},
+	} {
+		if found := h.Search(c.query); len(found) != c.found {
+			t.Errorf("testcase: %d result: %v", i, found)
+		}
+	}
+
+	// replay
+	rec = newRecorder()
+	h.next = rec
+	found, ok := h.Entry(e.ID)
+	if !ok {
+		t.Fatal("entry not found")
+	}
+	if _, err = NewJobManager(h).Start(found.Request(pat)); err != nil {
+		t.Fatal(err)
+	}
+	<-rec.done
+	if rec.output[Stdout] != "hello hi\n" || len(h.List()) != 2 || h.List()[1].ID != e.ID {
+		t.Errorf("result: %q %v", rec.output, h.List())
+	}
+}
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"time"
+
+	"gtoc/docopt"
+	"gtoc/filter"
+)
+
+// Invocation is a Request as it's saved, without its pattern, to be run
+// again later.
+type Invocation struct {
+	Program   string          `json:"program"`
+	Shell     string          `json:"shell,omitempty"`
+	Values    docopt.Opts     `json:"values"`
+	Env       Env             `json:"env"`
+	Dir       string          `json:"dir"`
+	Stdin     Stdin           `json:"stdin"`
+	Timeout   time.Duration   `json:"timeout"`
+	Elevate   Elevation       `json:"elevate,omitempty"`
+	Container *Container      `json:"container,omitempty"`
+	Capture   *Capture        `json:"capture,omitempty"`
+	Follow    []string        `json:"follow,omitempty"`
+	Retry     *Retry          `json:"retry,omitempty"`
+	Notify    bool            `json:"notify,omitempty"`
+	Filters   []filter.Filter `json:"filters,omitempty"`
+	// Pipe are the stages of the pipeline, see Request
+	Pipe []Invocation `json:"pipe,omitempty"`
+}
+
+// Invocation returns the request as it's saved.
+func (r Request) Invocation() Invocation {
+	var pipe []Invocation
+	for _, stage := range r.Pipe {
+		pipe = append(pipe, stage.Invocation())
+	}
+	return Invocation{
+		Program:   r.Program,
+		Shell:     r.Shell,
+		Values:    r.Values,
+		Env:       r.Env,
+		Dir:       r.Dir,
+		Stdin:     r.Stdin,
+		Timeout:   r.Timeout,
+		Elevate:   r.Elevate,
+		Container: r.Container,
+		Capture:   r.Capture,
+		Follow:    r.Follow,
+		Retry:     r.Retry,
+		Notify:    r.Notify,
+		Filters:   r.Filters,
+		Pipe:      pipe,
+	}
+}
+
+// Request returns the request that runs the invocation again, pat being the
+// pattern of its program. The stages of its pipeline are left out, they have
+// patterns of their own.
+func (i Invocation) Request(pat *docopt.Pattern) Request {
+	return Request{
+		Program:   i.Program,
+		Shell:     i.Shell,
+		Pattern:   pat,
+		Values:    i.Values,
+		Env:       i.Env,
+		Dir:       i.Dir,
+		Stdin:     i.Stdin,
+		Timeout:   i.Timeout,
+		Elevate:   i.Elevate,
+		Container: i.Container,
+		Capture:   i.Capture,
+		Follow:    i.Follow,
+		Retry:     i.Retry,
+		Notify:    i.Notify,
+		Filters:   i.Filters,
+	}
+}
+
+// DecodeValues converts the values of the invocation and of the stages of
+// its pipeline, as they're decoded from JSON, see DecodeValues.
+func (i *Invocation) DecodeValues() {
+	i.Values = DecodeValues(i.Values)
+	for k := range i.Pipe {
+		i.Pipe[k].DecodeValues()
+	}
+}
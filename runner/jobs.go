@@ -0,0 +1,181 @@
+package runner
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JobState is where a job tracked by a JobManager stands.
+type JobState string
+
+const (
+	// JobQueued is a job waiting to be started.
+	JobQueued  JobState = "queued"
+	JobRunning JobState = "running"
+	// JobDone is a job whose program exited with code 0.
+	JobDone JobState = "done"
+	// JobFailed is a job whose program exited with another code, couldn't
+	// finish, or timed out.
+	JobFailed JobState = "failed"
+	// JobCanceled is a job stopped with Cancel.
+	JobCanceled JobState = "canceled"
+)
+
+// JobStatus is the status of a job in a jobs panel. Result is set once the
+// job has finished.
+type JobStatus struct {
+	ID      string     `json:"id"`
+	Program string     `json:"program"`
+	State   JobState   `json:"state"`
+	Start   time.Time  `json:"start"`
+	Result  *RunResult `json:"result,omitempty"`
+}
+
+// JobManager runs several jobs at once and tracks their states, reporting
+// their output and results to its sink like a Runner. The finished jobs are
+// listed until cleared, or until there are too many of them.
+type JobManager struct {
+	*Runner
+
+	sink Sink
+
+	mu   sync.Mutex
+	jobs map[string]*JobStatus
+	// batches are the batches queued, by ID, and nextBatch the number of
+	// the last one
+	batches   map[string]*batch
+	nextBatch int
+	// batchOf are the batches of the running jobs queued in one, and
+	// retrying those of the jobs waiting for their next attempt
+	batchOf  map[string]*batch
+	retrying map[string]*batch
+}
+
+// NewJobManager returns a job manager reporting to sink, which is told
+// about the jobs started too if it's a StartSink.
+func NewJobManager(sink Sink) *JobManager {
+	m := &JobManager{
+		sink:     sink,
+		jobs:     make(map[string]*JobStatus),
+		batches:  make(map[string]*batch),
+		batchOf:  make(map[string]*batch),
+		retrying: make(map[string]*batch),
+	}
+	m.Runner = New(m)
+	return m
+}
+
+// Started tracks the job id as running and passes it on to the sink.
+func (m *JobManager) Started(id string, req Request) {
+	m.mu.Lock()
+	m.jobs[id] = &JobStatus{ID: id, Program: req.Program, State: JobRunning, Start: time.Now()}
+	if b, ok := m.batches[req.batch]; ok {
+		m.batchOf[id] = b
+		delete(m.retrying, req.RetryOf)
+	}
+	m.mu.Unlock()
+	if s, ok := m.sink.(StartSink); ok {
+		s.Started(id, req)
+	}
+}
+
+// Jobs returns the status of the tracked jobs, the oldest first, followed by
+// the requests of the batches still queued in the order they'll start.
+func (m *JobManager) Jobs() []JobStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.list()
+	for _, b := range m.batchList() {
+		for _, q := range b.queue {
+			list = append(list, JobStatus{ID: q.id, Program: q.req.Program, State: JobQueued})
+		}
+	}
+	return list
+}
+
+// list returns the tracked jobs in order, m.mu being held.
+func (m *JobManager) list() []JobStatus {
+	list := make([]JobStatus, 0, len(m.jobs))
+	for _, status := range m.jobs {
+		list = append(list, *status)
+	}
+	sort.Slice(list, func(i, j int) bool { return jobNumber(list[i].ID) < jobNumber(list[j].ID) })
+	return list
+}
+
+// Clear stops tracking the finished jobs and batches.
+func (m *JobManager) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, status := range m.jobs {
+		if status.Result != nil {
+			delete(m.jobs, id)
+		}
+	}
+	for id, b := range m.batches {
+		if b.finished() {
+			delete(m.batches, id)
+		}
+	}
+}
+
+// Output passes the output of a job on to the sink.
+func (m *JobManager) Output(c Chunk) {
+	m.sink.Output(c)
+}
+
+// Exit records the result of a job and passes it on to the sink.
+func (m *JobManager) Exit(result RunResult) {
+	m.mu.Lock()
+	status := m.jobs[result.JobID]
+	status.Start = result.Start
+	status.Result = &result
+	status.State = stateOf(result)
+	m.forget()
+	b := m.batchOf[result.JobID]
+	delete(m.batchOf, result.JobID)
+	if b != nil {
+		if result.Retrying {
+			m.retrying[result.JobID] = b
+		} else {
+			b.ended(status.State)
+		}
+	}
+	m.mu.Unlock()
+	m.sink.Exit(result)
+	if b != nil {
+		m.advance(b)
+	}
+}
+
+// forget stops tracking the oldest finished jobs beyond those whose results
+// the runner keeps. It's called with m.mu held.
+func (m *JobManager) forget() {
+	finished := 0
+	list := m.list()
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].Result == nil {
+			continue
+		}
+		if finished++; finished > retainedJobs {
+			delete(m.jobs, list[i].ID)
+		}
+	}
+}
+
+func stateOf(result RunResult) JobState {
+	switch {
+	case result.State == StateCanceled:
+		return JobCanceled
+	case result.State == StateExited && result.Code == 0:
+		return JobDone
+	}
+	return JobFailed
+}
+
+func jobNumber(id string) int {
+	n, _ := strconv.Atoi(id)
+	return n
+}
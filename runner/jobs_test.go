@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"gtoc/docopt"
+)
+
+// counter is a Sink counting the jobs that exited.
+type counter struct {
+	exited chan string
+}
+
+func (c *counter) Output(Chunk) {}
+
+func (c *counter) Exit(r RunResult) {
+	c.exited <- r.JobID
+}
+
+func TestJobManager(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &counter{exited: make(chan string, 10)}
+	m := NewJobManager(c)
+	ids := []string{}
+	for _, script := range []string{"exit 0", "exit 1", "sleep 10", "sleep 10"} {
+		id, err := m.Start(Request{Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": script}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	<-c.exited
+	<-c.exited
+	if err = m.Cancel(ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-c.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job not canceled")
+	}
+
+	expect := []JobState{JobDone, JobFailed, JobCanceled, JobRunning}
+	jobs := m.Jobs()
+	if len(jobs) != len(expect) {
+		t.Fatalf("result: %v", jobs)
+	}
+	for i, status := range jobs {
+		if status.ID != ids[i] || status.Program != "sh" || status.State != expect[i] ||
+			(status.Result == nil) != (status.State == JobRunning) || status.Start.IsZero() {
+			t.Errorf("testcase: %d result: %+v", i, status)
+		}
+	}
+
+	m.Clear()
+	if jobs = m.Jobs(); len(jobs) != 1 || jobs[0].ID != ids[3] {
+		t.Errorf("result: %v", jobs)
+	}
+	m.Cancel(ids[3])
+	<-c.exited
+
+	// only the last finished jobs are listed
+	for i := 0; i < retainedJobs+2; i++ {
+		m.Start(Request{Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": "exit 0"}})
+		<-c.exited
+	}
+	if jobs = m.Jobs(); len(jobs) != retainedJobs || jobs[0].ID == ids[3] {
+		t.Errorf("result: %d jobs, first %v", len(jobs), jobs[0])
+	}
+}
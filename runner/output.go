@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Capture is a file the output of a run is written to, its streams
+// interleaved as they come.
+type Capture struct {
+	Path string `json:"path"`
+	// MaxSize bounds the file in bytes, unbounded if 0
+	MaxSize int64 `json:"maxSize"`
+	// Rotate is how many full files are kept besides Path, renamed Path.1,
+	// Path.2 and so on, the oldest last, when the file reaches MaxSize. If
+	// it's 0 the output past MaxSize is dropped.
+	Rotate int `json:"rotate"`
+}
+
+// DefaultTail is how many bytes of the output of every job a new Runner
+// keeps in memory, see Runner.Tail.
+const DefaultTail = 64 << 10
+
+// outputFile writes the output of a job to a Capture's file.
+type outputFile struct {
+	c Capture
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	// dropped counts the bytes written past MaxSize without rotation
+	dropped int64
+	err     error
+}
+
+// open creates the file, replacing any older one.
+func (c Capture) open() (*outputFile, error) {
+	if c.MaxSize < 0 || c.Rotate < 0 {
+		return nil, fmt.Errorf("bad capture limits for %s", c.Path)
+	}
+	f, err := os.Create(c.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &outputFile{c: c, f: f}, nil
+}
+
+// Write writes p, rotating the file as often as needed. It never fails, so
+// that a full disk doesn't stop the job, the first error is kept instead.
+func (o *outputFile) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := len(p)
+	for len(p) > 0 && o.err == nil {
+		chunk := p
+		if o.c.MaxSize > 0 {
+			room := o.c.MaxSize - o.size
+			if room <= 0 {
+				if o.c.Rotate == 0 {
+					o.dropped += int64(len(p))
+					break
+				}
+				o.err = o.rotate()
+				continue
+			}
+			if int64(len(chunk)) > room {
+				chunk = chunk[:room]
+			}
+		}
+		var written int
+		written, o.err = o.f.Write(chunk)
+		o.size += int64(written)
+		p = p[written:]
+	}
+	return n, nil
+}
+
+// rotate shifts the full files by one, dropping the oldest, and starts over
+// with an empty file, o.mu being held.
+func (o *outputFile) rotate() error {
+	if err := o.f.Close(); err != nil {
+		return err
+	}
+	for i := o.c.Rotate - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", o.c.Path, i), fmt.Sprintf("%s.%d", o.c.Path, i+1))
+	}
+	if err := os.Rename(o.c.Path, o.c.Path+".1"); err != nil {
+		return err
+	}
+	f, err := os.Create(o.c.Path)
+	if err != nil {
+		return err
+	}
+	o.f, o.size = f, 0
+	return nil
+}
+
+// close closes the file and returns the first error writing it met.
+func (o *outputFile) close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.f.Close(); o.err == nil {
+		o.err = err
+	}
+	return o.err
+}
+
+// tail keeps the last limit bytes written to it.
+type tail struct {
+	limit int
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	// dropping the head only once it's as long as the tail keeps the copies
+	// few
+	if len(t.buf) >= 2*t.limit {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.limit:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.buf) > t.limit {
+		return string(t.buf[len(t.buf)-t.limit:])
+	}
+	return string(t.buf)
+}
+
+// openCapture opens the file of c, if it's not nil.
+func openCapture(c *Capture) (*outputFile, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return c.open()
+}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var tests = []struct {
+		capture Capture
+		files   map[string]string
+		dropped int64
+	}{
+		{Capture{}, map[string]string{"": "abcdefghij"}, 0},
+		{Capture{MaxSize: 4}, map[string]string{"": "abcd", ".1": ""}, 6},
+		{Capture{MaxSize: 4, Rotate: 1}, map[string]string{"": "ij", ".1": "efgh", ".2": ""}, 0},
+		{Capture{MaxSize: 3, Rotate: 2}, map[string]string{"": "j", ".1": "ghi", ".2": "def", ".3": ""}, 0},
+	}
+	for i, tt := range tests {
+		tt.capture.Path = filepath.Join(dir, strings.Repeat("x", i+1))
+		o, err := tt.capture.open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range []string{"abc", "defgh", "ij"} {
+			if n, err := o.Write([]byte(s)); n != len(s) || err != nil {
+				t.Errorf("testcase: %d write: %d %v", i, n, err)
+			}
+		}
+		if err = o.close(); err != nil || o.dropped != tt.dropped {
+			t.Errorf("testcase: %d result: %v dropped: %d", i, err, o.dropped)
+		}
+		for suffix, expected := range tt.files {
+			data, _ := ioutil.ReadFile(tt.capture.Path + suffix)
+			if string(data) != expected {
+				t.Errorf("testcase: %d file%s: %q expected: %q", i, suffix, data, expected)
+			}
+		}
+	}
+	if _, err = (Capture{Path: filepath.Join(dir, "bad"), MaxSize: -1}).open(); err == nil {
+		t.Errorf("negative size accepted")
+	}
+}
+
+func TestTail(t *testing.T) {
+	tl := &tail{limit: 4}
+	for i, tt := range []struct {
+		write  string
+		result string
+	}{
+		{"ab", "ab"},
+		{"cde", "bcde"},
+		{"fghijklm", "jklm"},
+		{"n", "klmn"},
+	} {
+		tl.Write([]byte(tt.write))
+		if result := tl.String(); result != tt.result {
+			t.Errorf("testcase: %d result: %q expected: %q", i, result, tt.result)
+		}
+	}
+}
+
+func TestStartCapture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pat, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := newRecorder()
+	r := New(rec)
+	r.TailSize = 8
+	path := filepath.Join(dir, "out.log")
+	id, err := r.Start(Request{
+		Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": "echo 0123456789; echo abcdef >&2"},
+		Capture: &Capture{Path: path, MaxSize: 15},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rec.done
+	data, _ := ioutil.ReadFile(path)
+	if len(data) != 15 || rec.exit.Dropped != 3 || rec.exit.CaptureErr != "" {
+		t.Errorf("result: %q %+v", data, rec.exit)
+	}
+	tl, ok := r.Tail(id)
+	if !ok || len(tl) != 8 || !strings.HasSuffix(rec.output[Stdout]+rec.output[Stderr], tl) && !strings.HasSuffix(rec.output[Stderr]+rec.output[Stdout], tl) {
+		t.Errorf("result: %q", tl)
+	}
+	if _, ok = r.Tail("unknown"); ok {
+		t.Errorf("tail of an unknown job")
+	}
+
+	_, err = r.Start(Request{
+		Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": "true"},
+		Capture: &Capture{Path: filepath.Join(dir, "missing", "out.log")},
+	})
+	if err == nil {
+		t.Errorf("capture to a missing directory accepted")
+	}
+}
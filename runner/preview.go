@@ -0,0 +1,32 @@
+package runner
+
+// Preview is the command line a request would run, see DryRun.
+type Preview struct {
+	// Argv is the command line, the program first
+	Argv []string `json:"argv"`
+	// Line is Argv quoted for a POSIX shell, followed by the stages of the
+	// pipeline if any, separated by pipes
+	Line string `json:"line"`
+	// Pipe are the previews of the stages of the pipeline
+	Pipe []Preview `json:"pipe,omitempty"`
+}
+
+// DryRun returns the command line Start would run for the request, without
+// running anything. Values that don't fit the pattern are reported as a
+// *docopt.UsageError, as Start does.
+func DryRun(req Request) (Preview, error) {
+	cmd, err := command(req, false)
+	if err != nil {
+		return Preview{}, err
+	}
+	preview := Preview{Argv: cmd.Args, Line: Quote(cmd.Args)}
+	for _, stage := range req.Pipe {
+		p, err := DryRun(stage)
+		if err != nil {
+			return Preview{}, err
+		}
+		preview.Pipe = append(preview.Pipe, p)
+		preview.Line += " | " + p.Line
+	}
+	return preview, nil
+}
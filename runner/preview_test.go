@@ -0,0 +1,28 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestDryRun(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: cp [-r] <src>... <dst>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview, err := DryRun(Request{Program: "cp", Pattern: pat, Values: docopt.Opts{
+		"-r": true, "<src>": []string{"a b", "-c"}, "<dst>": "d",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Preview{Argv: []string{"cp", "-r", "a b", "--", "-c", "d"}, Line: "cp -r 'a b' -- -c d"}
+	if !reflect.DeepEqual(preview, expected) {
+		t.Errorf("result: %#v expected: %#v", preview, expected)
+	}
+	if _, err := DryRun(Request{Program: "cp", Pattern: pat, Values: docopt.Opts{}}); err == nil {
+		t.Errorf("missing values accepted")
+	}
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+
+	"github.com/creack/pty"
+)
+
+// StartPTY is like Start, but runs the program on a pseudo-terminal of rows
+// by cols characters, so that programs checking for a terminal keep their
+// progress bars, colors and prompts. Stdout and stderr are merged into a
+// single Terminal stream of raw bytes, for a terminal widget to render. The
+// program reads the terminal, so the request can't give it a Stdin, and its
+// output isn't kept.
+func (r *Runner) StartPTY(req Request, rows, cols uint16) (string, error) {
+	if !req.Stdin.isZero() {
+		return "", errors.New("standard input can't be fed to a run on a terminal")
+	}
+	if len(req.Pipe) > 0 {
+		return "", errors.New("a pipeline can't run on a terminal")
+	}
+	cmd, err := command(req, true)
+	if err != nil {
+		return "", err
+	}
+	if err = authenticate(req); err != nil {
+		return "", err
+	}
+	if _, ok := req.Env.Set["TERM"]; !ok {
+		// gtoc itself may run without a terminal, the widget is an xterm
+		cmd.Env = append(cmd.Env, "TERM=xterm-256color")
+	}
+	out, err := openCapture(req.Capture)
+	if err != nil {
+		return "", err
+	}
+	followers := watch(req)
+	tty, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: rows, Cols: cols})
+	if err != nil {
+		if out != nil {
+			out.close()
+		}
+		return "", err
+	}
+	track(cmd)
+
+	id, j := r.add([]*exec.Cmd{cmd}, req.Timeout, nil, Terminal)
+	j.elevate, j.capture, j.terminal = []Elevation{req.Elevate}, out, tty
+	r.started(id, req)
+	unfollow := r.follow(id, followers)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		// reading fails with EIO once the program and its children have
+		// closed the terminal, which ends the stream like EOF
+		r.stream(&wg, j, id, Terminal, tty)
+		err := cmd.Wait()
+		untrack(cmd)
+		tty.Close()
+		unfollow()
+		r.finish(id, []error{err})
+	}()
+	return id, nil
+}
+
+// Input writes data to the terminal of the job id, run by StartPTY, as if it
+// was typed, such as the answer to a prompt ended with a carriage return.
+func (r *Runner) Input(id, data string) error {
+	r.mu.Lock()
+	j, ok := r.jobs[id]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no running job %s", id)
+	}
+	if j.terminal == nil {
+		return fmt.Errorf("job %s isn't run on a terminal", id)
+	}
+	_, err := io.WriteString(j.terminal, data)
+	return err
+}
@@ -0,0 +1,247 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BatchStatus is how far a batch of requests queued together has come. The
+// counts of the requests add up to Total, Failed counting those that
+// couldn't be started, and Canceled those dropped from the queue.
+type BatchStatus struct {
+	ID string `json:"id"`
+	// Parallel is how many jobs of the batch run at most at a time
+	Parallel int `json:"parallel"`
+	Total    int `json:"total"`
+	Queued   int `json:"queued"`
+	Running  int `json:"running"`
+	Done     int `json:"done"`
+	Failed   int `json:"failed"`
+	Canceled int `json:"canceled"`
+	// Percent is the share of the requests finished, from 0 to 100
+	Percent float64 `json:"percent"`
+	// Errors tell why the requests that couldn't be started couldn't
+	Errors []string `json:"errors,omitempty"`
+}
+
+// BatchSink is a Sink that's told about the batches of a JobManager whenever
+// a job of one is started or ends, or a request is dropped from one.
+type BatchSink interface {
+	Sink
+	Batch(BatchStatus)
+}
+
+// batch is a batch of requests queued together.
+type batch struct {
+	status BatchStatus
+	// queue are the requests waiting to be started, in order
+	queue []queued
+}
+
+// queued is a request of a batch waiting to be started, with an ID of its own
+// until it's started.
+type queued struct {
+	id  string
+	req Request
+}
+
+// finished tells whether every request of the batch has finished.
+func (b *batch) finished() bool {
+	return b.status.Queued == 0 && b.status.Running == 0
+}
+
+// ended counts a job of the batch ending in state.
+func (b *batch) ended(state JobState) {
+	b.status.Running--
+	switch state {
+	case JobDone:
+		b.status.Done++
+	case JobCanceled:
+		b.status.Canceled++
+	default:
+		b.status.Failed++
+	}
+}
+
+// snapshot returns the status of the batch as it is.
+func (b *batch) snapshot() BatchStatus {
+	status := b.status
+	status.Errors = append([]string(nil), b.status.Errors...)
+	finished := status.Done + status.Failed + status.Canceled
+	status.Percent = 100 * float64(finished) / float64(status.Total)
+	return status
+}
+
+// Enqueue queues the requests to run as a batch, parallel of them at most at
+// a time in the order given, and returns the ID of the batch at once. The
+// requests waiting are listed by Jobs as queued, under IDs Cancel takes.
+// Values that don't fit the pattern of a request are reported as by DryRun
+// and nothing is queued.
+func (m *JobManager) Enqueue(reqs []Request, parallel int) (string, error) {
+	if len(reqs) == 0 {
+		return "", errors.New("no requests to queue")
+	}
+	if parallel < 1 {
+		return "", fmt.Errorf("%d jobs at a time is too few", parallel)
+	}
+	for i, req := range reqs {
+		if _, err := DryRun(req); err != nil {
+			return "", fmt.Errorf("request %d: %w", i+1, err)
+		}
+	}
+	m.mu.Lock()
+	m.nextBatch++
+	id := "b" + strconv.Itoa(m.nextBatch)
+	b := &batch{status: BatchStatus{ID: id, Parallel: parallel, Total: len(reqs), Queued: len(reqs)}}
+	for i, req := range reqs {
+		req.batch = id
+		b.queue = append(b.queue, queued{id + "." + strconv.Itoa(i+1), req})
+	}
+	m.batches[id] = b
+	m.mu.Unlock()
+	m.advance(b)
+	return id, nil
+}
+
+// advance starts the requests of the batch waiting while it has fewer jobs
+// running than it may, and tells the sink about the batch.
+func (m *JobManager) advance(b *batch) {
+	for {
+		m.mu.Lock()
+		if len(b.queue) == 0 || b.status.Running >= b.status.Parallel {
+			m.mu.Unlock()
+			break
+		}
+		next := b.queue[0]
+		b.queue = b.queue[1:]
+		b.status.Queued--
+		b.status.Running++
+		m.mu.Unlock()
+		if _, err := m.Runner.Start(next.req); err != nil {
+			m.mu.Lock()
+			b.status.Running--
+			b.status.Failed++
+			b.status.Errors = append(b.status.Errors, next.id+": "+err.Error())
+			m.mu.Unlock()
+		}
+	}
+	m.report(b)
+}
+
+// report tells the sink about the batch, if it wants to know.
+func (m *JobManager) report(b *batch) {
+	if s, ok := m.sink.(BatchSink); ok {
+		m.mu.Lock()
+		status := b.snapshot()
+		m.mu.Unlock()
+		s.Batch(status)
+	}
+}
+
+// gaveUp counts the job id of a batch as failed when its next attempt
+// couldn't be started.
+func (m *JobManager) gaveUp(id string, err error) {
+	m.mu.Lock()
+	b, ok := m.retrying[id]
+	delete(m.retrying, id)
+	if ok {
+		b.ended(JobFailed)
+		b.status.Errors = append(b.status.Errors, id+": "+err.Error())
+	}
+	m.mu.Unlock()
+	if ok {
+		m.advance(b)
+	}
+}
+
+// Batches returns the status of the batches, the oldest first.
+func (m *JobManager) Batches() []BatchStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []BatchStatus
+	for _, b := range m.batchList() {
+		list = append(list, b.snapshot())
+	}
+	return list
+}
+
+// batchList returns the batches in order, m.mu being held.
+func (m *JobManager) batchList() []*batch {
+	list := make([]*batch, 0, len(m.batches))
+	for _, b := range m.batches {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return batchNumber(list[i].status.ID) < batchNumber(list[j].status.ID) })
+	return list
+}
+
+func batchNumber(id string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(id, "b"))
+	return n
+}
+
+// Cancel stops the job id as Runner.Cancel does, or drops it from its batch
+// if it's a request still queued.
+func (m *JobManager) Cancel(id string) error {
+	m.mu.Lock()
+	for _, b := range m.batches {
+		for i, q := range b.queue {
+			if q.id == id {
+				b.queue = append(b.queue[:i:i], b.queue[i+1:]...)
+				b.status.Queued--
+				b.status.Canceled++
+				m.mu.Unlock()
+				m.report(b)
+				return nil
+			}
+		}
+	}
+	m.mu.Unlock()
+	if err := m.Runner.Cancel(id); err != nil {
+		return err
+	}
+	// the job was done with but for an attempt that's dropped now
+	m.mu.Lock()
+	b, ok := m.retrying[id]
+	delete(m.retrying, id)
+	if ok {
+		b.ended(JobCanceled)
+	}
+	m.mu.Unlock()
+	if ok {
+		m.advance(b)
+	}
+	return nil
+}
+
+// CancelBatch drops the requests of the batch id still queued and cancels
+// its running jobs.
+func (m *JobManager) CancelBatch(id string) error {
+	m.mu.Lock()
+	b, ok := m.batches[id]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("no batch %s", id)
+	}
+	b.status.Canceled += len(b.queue)
+	b.status.Queued = 0
+	b.queue = nil
+	var jobs []string
+	for _, of := range []map[string]*batch{m.batchOf, m.retrying} {
+		for job, jb := range of {
+			if jb == b {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	m.mu.Unlock()
+	for _, job := range jobs {
+		// a job may end meanwhile
+		m.Cancel(job)
+	}
+	m.report(b)
+	return nil
+}
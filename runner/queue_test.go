@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gtoc/docopt"
+)
+
+// batches is a BatchSink keeping the last status of every batch and
+// checking none runs more jobs than it may.
+type batches struct {
+	counter
+	mu      sync.Mutex
+	last    map[string]BatchStatus
+	tooMany bool
+}
+
+func (b *batches) Batch(status BatchStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.last[status.ID] = status
+	b.tooMany = b.tooMany || status.Running > status.Parallel
+}
+
+func (b *batches) status(id string) BatchStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last[id]
+}
+
+func TestEnqueue(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh := func(script string) Request {
+		return Request{Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": script}}
+	}
+	b := &batches{counter: counter{exited: make(chan string, 20)}, last: map[string]BatchStatus{}}
+	m := NewJobManager(b)
+
+	var reqs []Request
+	for _, script := range []string{"sleep 0.1", "sleep 0.1", "exit 1", "sleep 0.1", "exit 0"} {
+		reqs = append(reqs, sh(script))
+	}
+	id, err := m.Enqueue(reqs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs := m.Jobs(); len(jobs) != 5 || jobs[2].State != JobQueued || jobs[2].ID != id+".3" {
+		t.Errorf("result: %+v", jobs)
+	}
+	for range reqs {
+		select {
+		case <-b.exited:
+		case <-time.After(5 * time.Second):
+			t.Fatal("batch not run")
+		}
+	}
+	expected := BatchStatus{ID: id, Parallel: 2, Total: 5, Done: 4, Failed: 1, Percent: 100}
+	if result := b.status(id); result.ID != expected.ID || result.Done != expected.Done ||
+		result.Failed != expected.Failed || result.Running != 0 || result.Percent != expected.Percent {
+		t.Errorf("result: %+v expected: %+v", result, expected)
+	}
+	if b.tooMany {
+		t.Errorf("more jobs run at a time than allowed")
+	}
+
+	// the requests queued are dropped one by one or along with the batch
+	id, err = m.Enqueue([]Request{sh("sleep 10"), sh("sleep 10"), sh("sleep 10")}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Cancel(id + ".3"); err != nil {
+		t.Errorf("cancel: %v", err)
+	}
+	if result := b.status(id); result.Running != 1 || result.Queued != 1 || result.Canceled != 1 {
+		t.Errorf("result: %+v", result)
+	}
+	if err = m.CancelBatch(id); err != nil {
+		t.Errorf("cancel batch: %v", err)
+	}
+	select {
+	case <-b.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job not canceled")
+	}
+	if result := b.status(id); result.Running != 0 || result.Queued != 0 || result.Canceled != 3 {
+		t.Errorf("result: %+v", result)
+	}
+	m.Clear()
+	if list := m.Batches(); len(list) != 0 {
+		t.Errorf("result: %+v", list)
+	}
+
+	if _, err = m.Enqueue([]Request{sh("exit 0"), {Program: "sh", Pattern: pat}}, 1); err == nil {
+		t.Errorf("request without values queued")
+	}
+	if _, err = m.Enqueue(reqs, 0); err == nil {
+		t.Errorf("batch without jobs at a time queued")
+	}
+}
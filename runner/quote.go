@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"errors"
+	"strings"
+)
+
+// Quote joins args into a line a POSIX shell splits back into args. Words
+// made only of characters the shell takes literally are left as they are,
+// the others are single-quoted.
+func Quote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteWord(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quoteWord(s string) string {
+	if s == "" {
+		return "''"
+	}
+	plain := true
+	for _, c := range s {
+		if !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./,:=+%@", c) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// Split splits a command, such as "git remote add", into words the way a
+// POSIX shell does, minding quotes and backslashes, but without expanding
+// variables, globs or anything else: characters the shell would take for
+// syntax are kept as they are. An unterminated quote or a command of no
+// words is an error.
+func Split(command string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	// inWord is set once the word has begun, "" being a word of its own
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, c := range command {
+		switch {
+		case escaped:
+			escaped = false
+			if quote == '"' && !strings.ContainsRune("\\\"$`\n", c) {
+				word.WriteRune('\\')
+			}
+			if c != '\n' {
+				word.WriteRune(c)
+				inWord = true
+			}
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\\' && quote != '\'':
+			escaped = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote in command: " + command)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	if len(words) == 0 {
+		return nil, errors.New("no command given")
+	}
+	return words, nil
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	var tests = []struct {
+		args   []string
+		result string
+	}{
+		{[]string{"ls", "-l", "--color=auto"}, "ls -l --color=auto"},
+		{[]string{"echo", ""}, "echo ''"},
+		{[]string{"echo", "a b", "$HOME", "*"}, "echo 'a b' '$HOME' '*'"},
+		{[]string{"echo", "it's"}, `echo 'it'\''s'`},
+		{[]string{"echo", "a\nb"}, "echo 'a\nb'"},
+	}
+	for i, tt := range tests {
+		result := Quote(tt.args)
+		if result != tt.result {
+			t.Errorf("testcase: %d result: %q expected: %q", i, result, tt.result)
+		}
+	}
+}
+
+func TestQuoteRoundTrip(t *testing.T) {
+	args := []string{"printf", `%s|`, "a b", "it's", `"x"`, `\n`, "$(false)", "", "~"}
+	out, err := exec.Command("sh", "-c", Quote(args)).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `a b|it's|"x"|\n|$(false)||~|`; string(out) != expected {
+		t.Errorf("result: %q expected: %q", out, expected)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	var tests = []struct {
+		command string
+		result  []string
+		err     bool
+	}{
+		{"git remote add", []string{"git", "remote", "add"}, false},
+		{"  ls\t-l  ", []string{"ls", "-l"}, false},
+		{`grep 'a b' "c d" e\ f`, []string{"grep", "a b", "c d", "e f"}, false},
+		{`echo '' ""`, []string{"echo", "", ""}, false},
+		{`echo $HOME ; rm * | x`, []string{"echo", "$HOME", ";", "rm", "*", "|", "x"}, false},
+		{`echo "a\"b\c" 'a\b'`, []string{"echo", `a"b\c`, `a\b`}, false},
+		{"echo a\\\n b", []string{"echo", "a", "b"}, false},
+		{"echo 'a", nil, true},
+		{`echo a\`, nil, true},
+		{"  ", nil, true},
+	}
+	for i, tt := range tests {
+		result, err := Split(tt.command)
+		if (err != nil) != tt.err || !reflect.DeepEqual(result, tt.result) {
+			t.Errorf("testcase: %d result: %q %v expected: %q", i, result, err, tt.result)
+		}
+	}
+}
+
+func TestSplitQuote(t *testing.T) {
+	args := []string{"a b", "it's", `"x"`, `\n`, "$(false)", "", "~"}
+	result, err := Split(Quote(args))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, args) {
+		t.Errorf("result: %q expected: %q", result, args)
+	}
+}
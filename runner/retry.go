@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"math"
+	"time"
+)
+
+// Retry is how a run failing is tried again. Every attempt is a job of its
+// own, started after a backoff once the one before has finished.
+type Retry struct {
+	// MaxAttempts is how many times the program runs at most, the first
+	// run included
+	MaxAttempts int `json:"maxAttempts"`
+	// Backoff is the wait before the second attempt, doubled for every
+	// attempt after it up to MaxBackoff, if that's positive, and up to
+	// the longest duration otherwise. Both are in nanoseconds in JSON
+	Backoff    time.Duration `json:"backoff"`
+	MaxBackoff time.Duration `json:"maxBackoff"`
+	// Codes are the exit codes tried again, every one but 0 if empty. Runs
+	// that couldn't finish or timed out are tried again whatever the codes,
+	// runs canceled or that failed to authenticate never are.
+	Codes []int `json:"codes"`
+}
+
+// again tells whether a run ending in result, the attempt-th one, is tried
+// again.
+func (rt *Retry) again(result RunResult, attempt int) bool {
+	if rt == nil || attempt >= rt.MaxAttempts {
+		return false
+	}
+	switch result.State {
+	case StateFailed, StateTimedOut:
+		return true
+	case StateExited:
+		if len(rt.Codes) == 0 {
+			return result.Code != 0
+		}
+		for _, code := range rt.Codes {
+			if code == result.Code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// longest is the longest wait, which doubling the backoff doesn't go past.
+const longest = time.Duration(math.MaxInt64)
+
+// backoff returns the wait before the attempt following the attempt-th.
+func (rt *Retry) backoff(attempt int) time.Duration {
+	wait := rt.Backoff
+	for i := 1; i < attempt; i++ {
+		if wait > longest/2 {
+			// doubling it would overflow, going negative
+			wait = longest
+			break
+		}
+		wait *= 2
+		if rt.MaxBackoff > 0 && wait > rt.MaxBackoff {
+			break
+		}
+	}
+	if rt.MaxBackoff > 0 && wait > rt.MaxBackoff {
+		wait = rt.MaxBackoff
+	}
+	return wait
+}
+
+// retry starts the next attempt of req, whose last attempt was the job id,
+// after the backoff. An attempt that can't be started is given up, telling
+// the sink if it wants to know.
+func (r *Runner) retry(id string, req Request) {
+	attempt := req.attempt()
+	req.Attempt, req.RetryOf = attempt+1, id
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending[id] = time.AfterFunc(req.Retry.backoff(attempt), func() {
+		r.mu.Lock()
+		_, ok := r.pending[id]
+		delete(r.pending, id)
+		r.mu.Unlock()
+		if !ok {
+			return
+		}
+		if _, err := r.Start(req); err != nil {
+			if s, ok := r.sink.(interface{ gaveUp(id string, err error) }); ok {
+				s.gaveUp(id, err)
+			}
+		}
+	})
+}
+
+// attempt returns which attempt the request is, 1 for the first.
+func (req Request) attempt() int {
+	if req.Attempt < 1 {
+		return 1
+	}
+	return req.Attempt
+}
@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"gtoc/docopt"
+)
+
+func TestRetryAgain(t *testing.T) {
+	rt := &Retry{MaxAttempts: 3, Codes: []int{2}}
+	for i, tt := range []struct {
+		retry    *Retry
+		result   RunResult
+		attempt  int
+		expected bool
+	}{
+		{nil, RunResult{State: StateExited, Code: 1}, 1, false},
+		{&Retry{MaxAttempts: 2}, RunResult{State: StateExited, Code: 1}, 1, true},
+		{&Retry{MaxAttempts: 2}, RunResult{State: StateExited, Code: 1}, 2, false},
+		{&Retry{MaxAttempts: 2}, RunResult{State: StateExited}, 1, false},
+		{rt, RunResult{State: StateExited, Code: 1}, 1, false},
+		{rt, RunResult{State: StateExited, Code: 2}, 2, true},
+		{rt, RunResult{State: StateTimedOut}, 1, true},
+		{rt, RunResult{State: StateFailed}, 1, true},
+		{rt, RunResult{State: StateCanceled}, 1, false},
+		{rt, RunResult{State: StateAuthFailed}, 1, false},
+	} {
+		if result := tt.retry.again(tt.result, tt.attempt); result != tt.expected {
+			t.Errorf("testcase: %d result: %v expected: %v", i, result, tt.expected)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	rt := &Retry{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if result := rt.backoff(i + 1); result != expected {
+			t.Errorf("testcase: %d result: %v expected: %v", i, result, expected)
+		}
+	}
+	unbounded := &Retry{Backoff: time.Second}
+	for i, tt := range []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{3, 4 * time.Second},
+		{34, time.Second << 33},
+		{35, longest},
+		{1000, longest},
+	} {
+		if result := unbounded.backoff(tt.attempt); result != tt.expected {
+			t.Errorf("testcase: %d result: %v expected: %v", i, result, tt.expected)
+		}
+	}
+	if result := rt.backoff(1000); result != rt.MaxBackoff {
+		t.Errorf("result: %v expected: %v", result, rt.MaxBackoff)
+	}
+}
+
+// exits is a Sink passing on the results of the jobs.
+type exits chan RunResult
+
+func (e exits) Output(Chunk) {}
+
+func (e exits) Exit(r RunResult) {
+	e <- r
+}
+
+func TestRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pat, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := make(exits, 10)
+	r := New(e)
+	// fails with 3 until the third attempt
+	script := "echo >> count; [ $(wc -l < count) -ge 3 ] || exit 3"
+	id, err := r.Start(Request{
+		Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": script}, Dir: dir,
+		Retry: &Retry{MaxAttempts: 5, Backoff: 10 * time.Millisecond, Codes: []int{3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []RunResult{
+		{State: StateExited, Code: 3, Attempt: 1, Retrying: true},
+		{State: StateExited, Code: 3, Attempt: 2, Retrying: true},
+		{State: StateExited, Code: 0, Attempt: 3},
+	} {
+		var result RunResult
+		select {
+		case result = <-e:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("testcase: %d no attempt", i)
+		}
+		if i == 0 && result.JobID != id {
+			t.Errorf("testcase: %d job: %s expected: %s", i, result.JobID, id)
+		}
+		if result.State != expected.State || result.Code != expected.Code ||
+			result.Attempt != expected.Attempt || result.Retrying != expected.Retrying {
+			t.Errorf("testcase: %d result: %+v expected: %+v", i, result, expected)
+		}
+	}
+
+	// canceling the job of an attempt drops the next one
+	id, err = r.Start(Request{
+		Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": "exit 1"},
+		Retry: &Retry{MaxAttempts: 2, Backoff: time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := <-e; !result.Retrying {
+		t.Errorf("result: %+v", result)
+	}
+	if err = r.Cancel(id); err != nil {
+		t.Errorf("cancel: %v", err)
+	}
+	if err = r.Cancel(id); err == nil {
+		t.Errorf("canceled twice")
+	}
+}
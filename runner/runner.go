@@ -0,0 +1,677 @@
+// Package runner runs the commands gtoc composes from a pattern and the
+// values the user filled in, streaming their output as it comes.
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"gtoc/docopt"
+	"gtoc/filter"
+)
+
+// Stream names the output a Chunk comes from.
+type Stream string
+
+const (
+	Stdout Stream = "stdout"
+	Stderr Stream = "stderr"
+	// Terminal is the raw output of a job run on a pseudo-terminal, escape
+	// sequences and all, see StartPTY.
+	Terminal Stream = "terminal"
+	// File is what a job writes to a file it's followed in, see
+	// Request.Follow.
+	File Stream = "file"
+)
+
+// Chunk is a piece of the output of a job, in the order it was read from the
+// stream.
+type Chunk struct {
+	JobID  string `json:"jobID"`
+	Stream Stream `json:"stream"`
+	// Path is the file of a chunk of the File stream
+	Path string `json:"path,omitempty"`
+	Data string `json:"data"`
+}
+
+// State tells how a job ended.
+type State string
+
+const (
+	// StateExited is a job whose program exited by itself, whatever its
+	// exit code.
+	StateExited State = "exited"
+	// StateFailed is a job whose program couldn't finish, such as one killed
+	// by a signal it wasn't sent by the runner.
+	StateFailed State = "failed"
+	// StateCanceled is a job stopped with Cancel.
+	StateCanceled State = "canceled"
+	// StateTimedOut is a job stopped for running longer than its timeout.
+	StateTimedOut State = "timedout"
+	// StateAuthFailed is a job run as root whose user couldn't be
+	// authenticated, see Elevation.
+	StateAuthFailed State = "authfailed"
+)
+
+// RunResult is how a job went. Code is the exit code of the process, -1 if
+// it didn't exit normally, Signal the signal that ended it if so, and Err
+// tells why it couldn't run or finish.
+type RunResult struct {
+	JobID string `json:"jobID"`
+	// Argv is the command line run, the program first
+	Argv  []string  `json:"argv"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Duration is in nanoseconds in JSON
+	Duration time.Duration `json:"duration"`
+	State    State         `json:"state"`
+	Code     int           `json:"code"`
+	Signal   string        `json:"signal,omitempty"`
+	Err      string        `json:"err,omitempty"`
+	// Bytes counts the output of every stream of the job
+	Bytes map[Stream]int64 `json:"bytes"`
+	// Truncated is set when the standard output kept of the job is cut
+	// short, see Stdin
+	Truncated bool `json:"truncated"`
+	// Stages are the results of the stages of a pipeline, in order, the
+	// fields above telling about the last one as a shell does
+	Stages []StageResult `json:"stages,omitempty"`
+	// Attempt is which attempt of its Retry the job is, 1 for the first,
+	// and Retrying is set when another one follows
+	Attempt  int  `json:"attempt"`
+	Retrying bool `json:"retrying"`
+	// Dropped counts the bytes of output left out of the Capture file for
+	// being past its MaxSize, and CaptureErr tells why the file couldn't
+	// be written, if it couldn't
+	Dropped    int64  `json:"dropped,omitempty"`
+	CaptureErr string `json:"captureErr,omitempty"`
+}
+
+// StageResult is how a stage of a pipeline went, see RunResult.
+type StageResult struct {
+	Argv   []string `json:"argv"`
+	State  State    `json:"state"`
+	Code   int      `json:"code"`
+	Signal string   `json:"signal,omitempty"`
+	Err    string   `json:"err,omitempty"`
+}
+
+// Request is a run of a program with the values the user filled in for its
+// pattern.
+type Request struct {
+	// Program is the program to run along with any leading arguments, such
+	// as "git remote", split into words as by Split, unless Shell is set
+	Program string
+	// Shell, if set, is the shell the command line is run through, one of
+	// Shells or a path to one. Program is then written as is at the head of
+	// the line, so it may use variables, redirections and the rest of the
+	// shell's syntax, the values following it quoted.
+	Shell   string
+	Pattern *docopt.Pattern
+	Values  docopt.Opts
+	Env     Env
+	// Dir is the working directory of the program, gtoc's own if empty
+	Dir   string
+	Stdin Stdin
+	// Timeout, if positive, is how long the job may run before it's stopped
+	// the way Cancel does.
+	Timeout time.Duration
+	// Elevate, if set, runs the program as root. Jobs run as root may not
+	// be stopped by Cancel, or their timeout.
+	Elevate Elevation
+	// Container, if set, is the container the program runs in. The
+	// variables Env sets are passed into it, its own environment is kept
+	// otherwise. Dir is bound in a container run from an image, along with
+	// the files the values name, see Container.
+	Container *Container
+	// Capture, if set, is the file the output is written to as well.
+	Capture *Capture
+	// Follow are files the program writes, relative to Dir, reported as
+	// they're written as the File stream, from their end if they're there
+	// already. They're followed as tail -F does, while the job runs.
+	Follow []string
+	// Retry, if set, is how a run failing is tried again. It's ignored by
+	// StartPTY.
+	Retry *Retry
+	// Attempt is which attempt of Retry the request is, 1 for the first,
+	// and RetryOf the ID of the job of the attempt before. The runner sets
+	// them for the attempts it starts.
+	Attempt int
+	RetryOf string
+	// Notify asks for the user to be notified when the job ends, and
+	// Filters for the standard output to go through them before it's
+	// shown. Watch, if set, is told what the job reports as well, and so
+	// are the jobs of its retries. They're left to the sink, the runner
+	// ignores them.
+	Notify  bool
+	Filters []filter.Filter
+	Watch   StartSink
+	// Pipe are the stages of a pipeline the output of the program goes
+	// through, each one reading what the one before writes. They can't
+	// have a Stdin, nor a Pipe of their own, and their Timeout is the
+	// request's.
+	Pipe []Request
+
+	// batch is the ID of the batch of a JobManager the request is queued
+	// in, if any
+	batch string
+}
+
+// Sink receives what jobs report. Output is called for every chunk of
+// output, and Exit once per job with its result after all of its output.
+type Sink interface {
+	Output(Chunk)
+	Exit(RunResult)
+}
+
+// StartSink is a Sink that's told about every job started, before any of
+// its output.
+type StartSink interface {
+	Sink
+	Started(id string, req Request)
+}
+
+// chunkSize bounds the bytes of a Chunk.
+const chunkSize = 4096
+
+// DefaultGrace is the grace period of a new Runner.
+const DefaultGrace = 5 * time.Second
+
+// Runner starts jobs and reports their output to its sink.
+type Runner struct {
+	// Grace is how long Cancel lets a job handle the interrupt before
+	// killing it.
+	Grace time.Duration
+	// Retain is how many bytes of the standard output of every job are
+	// kept, for later runs to read as their standard input.
+	Retain int
+	// TailSize is how many bytes of the output of every job are kept in
+	// memory, see Tail.
+	TailSize int
+
+	sink Sink
+
+	mu   sync.Mutex
+	next int
+	jobs map[string]*job
+	// finished are the results and outputs kept of the jobs in retained
+	finished map[string]*finished
+	retained []string
+	// pending are the attempts waiting for their backoff, by the ID of the
+	// job of the attempt before
+	pending map[string]*time.Timer
+}
+
+// job is a running command, or pipeline.
+type job struct {
+	// cmds are the stages of the pipeline in order, or the single command
+	cmds []*exec.Cmd
+	// done is closed once the command has exited
+	done chan struct{}
+	// stopped is the state of a job the runner stopped, empty otherwise
+	stopped State
+	// elevate is how each of cmds was run as root, if it was
+	elevate []Elevation
+	// release frees the context of the timeout
+	release context.CancelFunc
+	// stdout captures the output to keep, nil for jobs on a terminal
+	stdout *capture
+	// tail keeps the end of the output of every stream
+	tail *tail
+	// capture is the file of the request's Capture, if any
+	capture *outputFile
+	// terminal is the pseudo-terminal of a job run by StartPTY, nil
+	// otherwise
+	terminal io.Writer
+	// req is the request of the job, for its Retry
+	req   Request
+	start time.Time
+	// bytes counts the output of every stream, each written by the goroutine
+	// reading the stream
+	bytes map[Stream]*int64
+}
+
+// finished is what's kept of a finished job.
+type finished struct {
+	result RunResult
+	stdout *capture
+	tail   *tail
+}
+
+// New returns a runner reporting to sink.
+func New(sink Sink) *Runner {
+	return &Runner{
+		Grace:    DefaultGrace,
+		Retain:   DefaultRetain,
+		TailSize: DefaultTail,
+		sink:     sink,
+		jobs:     make(map[string]*job),
+		finished: make(map[string]*finished),
+		pending:  make(map[string]*time.Timer),
+	}
+}
+
+// Start builds the command line of the request's program from its values
+// with Pattern.ToArgv, starts it and returns the ID of the job at once. The
+// output and the exit status are reported to the sink as they come, and the
+// standard output is kept for later runs to read, see Stdin. The standard
+// error of every stage of a pipeline is reported as the job's. Values that
+// don't fit the pattern are reported as a *docopt.UsageError and nothing is
+// started.
+func (r *Runner) Start(req Request) (string, error) {
+	stages := append([]Request{req}, req.Pipe...)
+	cmds := make([]*exec.Cmd, len(stages))
+	elevate := make([]Elevation, len(stages))
+	for i, stage := range stages {
+		if i > 0 && (!stage.Stdin.isZero() || len(stage.Pipe) > 0) {
+			return "", fmt.Errorf("stage %d of the pipeline has a standard input or a pipe of its own", i+1)
+		}
+		cmd, err := command(stage, false)
+		if err != nil {
+			return "", err
+		}
+		cmds[i], elevate[i] = cmd, stage.Elevate
+	}
+	for _, stage := range stages {
+		if err := authenticate(stage); err != nil {
+			return "", err
+		}
+	}
+	stdin, release, err := r.stdin(req.Stdin)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	out, err := openCapture(req.Capture)
+	if err != nil {
+		return "", err
+	}
+	followers := watch(req)
+	stdout, stderr, err := startPipeline(cmds, stdin)
+	if err != nil {
+		if out != nil {
+			out.close()
+		}
+		return "", err
+	}
+
+	kept := &capture{limit: r.Retain}
+	id, j := r.add(cmds, req.Timeout, kept, Stdout, Stderr)
+	j.elevate, j.capture, j.req = elevate, out, req
+	r.started(id, req)
+	unfollow := r.follow(id, followers)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go r.stream(&wg, j, id, Stdout, io.TeeReader(stdout, kept))
+	go r.stream(&wg, j, id, Stderr, stderr)
+	go func() {
+		wg.Wait()
+		stdout.Close()
+		stderr.Close()
+		errs := make([]error, len(cmds))
+		for i, cmd := range cmds {
+			errs[i] = cmd.Wait()
+			untrack(cmd)
+		}
+		unfollow()
+		r.finish(id, errs)
+	}()
+	return id, nil
+}
+
+// startPipeline starts the commands, each one reading the standard output
+// of the one before, the first one reading stdin. It returns the pipes the
+// last command writes its standard output to, and all of them their
+// standard error. If a command can't be started, those started already are
+// killed.
+func startPipeline(cmds []*exec.Cmd, stdin io.Reader) (stdout, stderr *os.File, err error) {
+	// the ends the commands hold are closed once they're started
+	var held []*os.File
+	defer func() {
+		for _, f := range held {
+			f.Close()
+		}
+	}()
+	stderr, stderrW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	held = append(held, stderrW)
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		stderr.Close()
+		return nil, nil, err
+	}
+	held = append(held, stdoutW)
+	for i, cmd := range cmds {
+		cmd.Stdin = stdin
+		cmd.Stdout = stdoutW
+		cmd.Stderr = stderrW
+		if i < len(cmds)-1 {
+			var pr, pw *os.File
+			if pr, pw, err = os.Pipe(); err == nil {
+				held = append(held, pr, pw)
+				cmd.Stdout, stdin = pw, pr
+			}
+		}
+		// Cancel signals the process group, or job object, reaching what
+		// the program starts
+		setProcessGroup(cmd)
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err == nil {
+			track(cmd)
+		}
+		if err != nil {
+			for _, started := range cmds[:i] {
+				kill(started)
+				started.Wait()
+				untrack(started)
+			}
+			stdout.Close()
+			stderr.Close()
+			return nil, nil, err
+		}
+	}
+	return stdout, stderr, nil
+}
+
+// command returns the command running the request's program with the
+// command line its values make, through its shell if any, in its container
+// if any, in the request's environment and directory. Tty tells the program
+// runs on a terminal.
+func command(req Request, tty bool) (*exec.Cmd, error) {
+	argv, err := req.Pattern.ToArgv(req.Values)
+	if err != nil {
+		return nil, err
+	}
+	var words []string
+	if req.Shell != "" {
+		sh, ok := shellOf(req.Shell)
+		if !ok {
+			return nil, fmt.Errorf("unknown shell %s", req.Shell)
+		}
+		words = append(append([]string{req.Shell}, sh.flags...), sh.script(req.Program, argv))
+	} else {
+		if words, err = Split(req.Program); err != nil {
+			return nil, err
+		}
+		words = append(words, argv...)
+	}
+	env := req.Env.Environ()
+	if req.Container != nil {
+		dir := req.Dir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		if words, err = req.Container.argv(words, req.Env.Set, mounts(req, dir), dir, tty); err != nil {
+			return nil, err
+		}
+		// the engine's client runs in gtoc's environment
+		env = os.Environ()
+	}
+	if req.Elevate != "" {
+		return elevated(req, words)
+	}
+	cmd := exec.Command(words[0], words[1:]...)
+	cmd.Env = env
+	cmd.Dir = req.Dir
+	return cmd, nil
+}
+
+// add records the started cmds as a job reading streams, and returns its ID.
+// The job is stopped once timeout has passed, if it's positive, and stdout
+// is kept once it has finished, if not nil.
+func (r *Runner) add(cmds []*exec.Cmd, timeout time.Duration, stdout *capture, streams ...Stream) (string, *job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	id := strconv.Itoa(r.next)
+	j := &job{
+		cmds:    cmds,
+		done:    make(chan struct{}),
+		release: func() {},
+		stdout:  stdout,
+		tail:    &tail{limit: r.TailSize},
+		start:   time.Now(),
+		bytes:   make(map[Stream]*int64),
+	}
+	for _, s := range streams {
+		j.bytes[s] = new(int64)
+	}
+	r.jobs[id] = j
+	if timeout > 0 {
+		var ctx context.Context
+		ctx, j.release = context.WithTimeout(context.Background(), timeout)
+		go func() {
+			<-ctx.Done()
+			if ctx.Err() == context.DeadlineExceeded {
+				r.stop(j, StateTimedOut)
+			}
+		}()
+	}
+	return id, j
+}
+
+// started tells the sink about the job id, if it wants to know.
+func (r *Runner) started(id string, req Request) {
+	if s, ok := r.sink.(StartSink); ok {
+		s.Started(id, req)
+	}
+}
+
+// finish reports the result of job id, errs being what Wait returned for
+// each of its commands. It's called once the streams of the job are read.
+func (r *Runner) finish(id string, errs []error) {
+	r.mu.Lock()
+	j := r.jobs[id]
+	delete(r.jobs, id)
+	stages := make([]StageResult, len(j.cmds))
+	for i, cmd := range j.cmds {
+		res := resultOf(id, errs[i])
+		if authFailed(j.elevate[i], res) {
+			res.State = StateAuthFailed
+			res.Err = ErrAuthFailed.Error()
+		}
+		stages[i] = StageResult{cmd.Args, res.State, res.Code, res.Signal, res.Err}
+	}
+	last := stages[len(stages)-1]
+	result := RunResult{JobID: id, Argv: last.Argv, State: last.State, Code: last.Code, Signal: last.Signal, Err: last.Err}
+	if j.stopped != "" {
+		result.State = j.stopped
+	}
+	if len(stages) > 1 {
+		result.Stages = stages
+	}
+	result.Start, result.End = j.start, time.Now()
+	result.Duration = result.End.Sub(result.Start)
+	result.Bytes = make(map[Stream]int64, len(j.bytes))
+	for s, n := range j.bytes {
+		result.Bytes[s] = *n
+	}
+	result.Truncated = j.stdout != nil && j.stdout.truncated
+	if j.capture != nil {
+		if err := j.capture.close(); err != nil {
+			result.CaptureErr = err.Error()
+		}
+		result.Dropped = j.capture.dropped
+	}
+	result.Attempt = j.req.attempt()
+	result.Retrying = j.req.Retry.again(result, result.Attempt)
+	r.keep(id, &finished{result, j.stdout, j.tail})
+	r.mu.Unlock()
+	j.release()
+	close(j.done)
+	r.sink.Exit(result)
+	if result.Retrying {
+		r.retry(id, j.req)
+	}
+}
+
+// Tail returns the end of the output of the job id, its streams interleaved
+// as they came, up to TailSize bytes. The output of running jobs and of the
+// last finished ones is kept, so that a frontend needn't keep all of it.
+func (r *Runner) Tail(id string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if j, ok := r.jobs[id]; ok {
+		return j.tail.String(), true
+	}
+	if f, ok := r.finished[id]; ok {
+		return f.tail.String(), true
+	}
+	return "", false
+}
+
+// Stdout returns the standard output kept of the finished job id, for the
+// last jobs only, and whether it's cut short, see Retain.
+func (r *Runner) Stdout(id string) (string, bool, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.finished[id]
+	if !ok || f.stdout == nil {
+		return "", false, false
+	}
+	return f.stdout.buf.String(), f.stdout.truncated, true
+}
+
+// Result returns the result of the finished job id, for the last jobs only.
+func (r *Runner) Result(id string) (RunResult, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.finished[id]
+	if !ok {
+		return RunResult{}, false
+	}
+	return f.result, true
+}
+
+// Cancel stops the job id: it's interrupted with SIGINT at once, and killed
+// if it's still running after the grace period. Cancel doesn't wait for the
+// job to exit, which is reported to the sink as usual. A finished job whose
+// next attempt waits for its backoff has the attempt dropped.
+func (r *Runner) Cancel(id string) error {
+	r.mu.Lock()
+	j, ok := r.jobs[id]
+	if timer, pending := r.pending[id]; pending {
+		timer.Stop()
+		delete(r.pending, id)
+		r.mu.Unlock()
+		return nil
+	}
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no running job %s", id)
+	}
+	return r.stop(j, StateCanceled)
+}
+
+// stop interrupts the job and kills it after the grace period, the job
+// ending in state unless it was stopped already.
+func (r *Runner) stop(j *job, state State) error {
+	r.mu.Lock()
+	if j.stopped == "" {
+		j.stopped = state
+	}
+	grace := r.Grace
+	r.mu.Unlock()
+	select {
+	case <-j.done:
+		return nil
+	default:
+	}
+	// stages of a pipeline that exited already can't be interrupted
+	var err error
+	interrupted := false
+	for _, cmd := range j.cmds {
+		if e := interrupt(cmd); e != nil {
+			err = e
+		} else {
+			interrupted = true
+		}
+	}
+	if !interrupted {
+		return err
+	}
+	go func() {
+		select {
+		case <-j.done:
+		case <-time.After(grace):
+			for _, cmd := range j.cmds {
+				kill(cmd)
+			}
+		}
+	}()
+	return nil
+}
+
+// stream reports what's read from pipe as chunks of s of the job id,
+// counting its bytes and keeping its tail, and writes it to the job's
+// capture file if any.
+func (r *Runner) stream(wg *sync.WaitGroup, j *job, id string, s Stream, pipe io.Reader) {
+	defer wg.Done()
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := pipe.Read(buf)
+		*j.bytes[s] += int64(n)
+		if n > 0 {
+			j.tail.Write(buf[:n])
+			if j.capture != nil {
+				j.capture.Write(buf[:n])
+			}
+			r.sink.Output(Chunk{JobID: id, Stream: s, Data: string(buf[:n])})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func resultOf(id string, err error) RunResult {
+	e := RunResult{JobID: id, State: StateExited}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		e.Code = exitErr.ExitCode()
+		if e.Code < 0 {
+			e.State = StateFailed
+			e.Signal = signalOf(exitErr)
+			e.Err = err.Error()
+		}
+	default:
+		e.State = StateFailed
+		e.Code = -1
+		e.Err = err.Error()
+	}
+	return e
+}
+
+// DecodeValues converts values decoded from JSON, as the frontend sends them,
+// to the types Match returns: whole numbers to int and arrays to []string.
+func DecodeValues(values map[string]interface{}) docopt.Opts {
+	opts := make(docopt.Opts, len(values))
+	for name, v := range values {
+		switch v := v.(type) {
+		case float64:
+			opts[name] = int(v)
+		case []interface{}:
+			list := make([]string, len(v))
+			for i, item := range v {
+				list[i], _ = item.(string)
+			}
+			opts[name] = list
+		default:
+			opts[name] = v
+		}
+	}
+	return opts
+}
@@ -0,0 +1,326 @@
+package runner
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gtoc/docopt"
+)
+
+// recorder is a Sink keeping what it receives, done is closed on Exit.
+type recorder struct {
+	mu     sync.Mutex
+	output map[Stream]string
+	exit   RunResult
+	done   chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{output: map[Stream]string{}, done: make(chan struct{})}
+}
+
+func (r *recorder) Output(c Chunk) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.output[c.Stream] += c.Data
+}
+
+func (r *recorder) Exit(e RunResult) {
+	r.exit = e
+	close(r.done)
+}
+
+// status is the part of a RunResult that doesn't vary between runs.
+type status struct {
+	JobID  string
+	State  State
+	Code   int
+	Signal string
+	Err    string
+}
+
+func statusOf(r RunResult) status {
+	return status{r.JobID, r.State, r.Code, r.Signal, r.Err}
+}
+
+func TestStart(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := newRecorder()
+	r := New(rec)
+	script := "echo out; echo err >&2; echo more; exit 3"
+	id, err := r.Start(Request{Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": script}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rec.done
+	expect := map[Stream]string{Stdout: "out\nmore\n", Stderr: "err\n"}
+	if !reflect.DeepEqual(rec.output, expect) || statusOf(rec.exit) != (status{JobID: id, State: StateExited, Code: 3}) {
+		t.Errorf("result: %v %v", rec.output, rec.exit)
+	}
+	result := rec.exit
+	if !reflect.DeepEqual(result.Argv, []string{"sh", "-c", script}) ||
+		!reflect.DeepEqual(result.Bytes, map[Stream]int64{Stdout: 9, Stderr: 4}) ||
+		result.Start.IsZero() || result.Duration != result.End.Sub(result.Start) || result.Duration < 0 ||
+		result.Truncated {
+		t.Errorf("result: %+v", result)
+	}
+	if kept, ok := r.Result(id); !ok || !reflect.DeepEqual(kept, result) {
+		t.Errorf("result: %+v", kept)
+	}
+	if _, ok := r.Result("unknown"); ok {
+		t.Errorf("result of an unknown job")
+	}
+
+	_, err = r.Start(Request{Program: "sh", Pattern: pat, Values: docopt.Opts{}})
+	if _, ok := err.(*docopt.UsageError); !ok {
+		t.Errorf("result: %#v", err)
+	}
+	_, err = r.Start(Request{Program: "/nonexistent/program", Pattern: pat, Values: docopt.Opts{"-c": ""}})
+	if err == nil || !strings.Contains(err.Error(), "nonexistent") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestDecodeValues(t *testing.T) {
+	values := DecodeValues(map[string]interface{}{
+		"-v": float64(2), "<file>": []interface{}{"a", "b"}, "--out": "x", "-q": true, "<y>": nil,
+	})
+	expect := docopt.Opts{"-v": 2, "<file>": []string{"a", "b"}, "--out": "x", "-q": true, "<y>": nil}
+	if !reflect.DeepEqual(values, expect) {
+		t.Errorf("result: %v", values)
+	}
+}
+
+func TestStartPTY(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := newRecorder()
+	r := New(rec)
+	script := `if [ -t 1 ]; then echo tty; fi; stty size; echo err >&2; exit 2`
+	id, err := r.StartPTY(Request{Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": script}}, 30, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rec.done
+	// the terminal turns newlines into CRLF
+	expect := map[Stream]string{Terminal: "tty\r\n30 100\r\nerr\r\n"}
+	if !reflect.DeepEqual(rec.output, expect) || statusOf(rec.exit) != (status{JobID: id, State: StateExited, Code: 2}) ||
+		rec.exit.Bytes[Terminal] != int64(len(expect[Terminal])) {
+		t.Errorf("result: %q %v", rec.output, rec.exit)
+	}
+	if err = r.Input(id, "late\r"); err == nil {
+		t.Errorf("input to a finished job")
+	}
+
+	// the answer to a prompt is read from the terminal
+	rec = newRecorder()
+	r = New(rec)
+	id, err = r.StartPTY(Request{Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": "read x; echo got $x"}}, 30, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Input(id, "yes\r"); err != nil {
+		t.Errorf("input: %v", err)
+	}
+	<-rec.done
+	if !strings.Contains(rec.output[Terminal], "got yes") {
+		t.Errorf("result: %q", rec.output)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range []struct {
+		script string
+		output string
+	}{
+		// exits on the interrupt
+		{"echo started; sleep 10", "started\n"},
+		// ignores it and has to be killed
+		{"trap 'echo interrupted' INT; echo started; while :; do sleep 0.1; done", "started\ninterrupted\n"},
+	} {
+		rec := newRecorder()
+		r := New(rec)
+		r.Grace = 200 * time.Millisecond
+		id, err := r.Start(Request{Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": c.script}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// wait for the shell to be ready for the signal
+		for {
+			rec.mu.Lock()
+			started := rec.output[Stdout] != ""
+			rec.mu.Unlock()
+			if started {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		start := time.Now()
+		if err = r.Cancel(id); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-rec.done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("testcase: %d job not stopped", i)
+		}
+		if rec.output[Stdout] != c.output || rec.exit.State != StateCanceled || rec.exit.Code != -1 || time.Since(start) > 3*time.Second {
+			t.Errorf("testcase: %d result: %q %v", i, rec.output, rec.exit)
+		}
+		if err = r.Cancel(id); err == nil {
+			t.Errorf("testcase: %d canceling a finished job succeeded", i)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range []struct {
+		script string
+		status status
+	}{
+		{"sleep 10", status{State: StateTimedOut, Code: -1, Signal: "interrupt", Err: "signal: interrupt"}},
+		{"exit 1", status{State: StateExited, Code: 1}},
+	} {
+		rec := newRecorder()
+		r := New(rec)
+		start := time.Now()
+		id, err := r.Start(Request{Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": c.script}, Timeout: 100 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-rec.done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("testcase: %d job not stopped", i)
+		}
+		c.status.JobID = id
+		if statusOf(rec.exit) != c.status || time.Since(start) > 3*time.Second {
+			t.Errorf("testcase: %d result: %v", i, rec.exit)
+		}
+	}
+}
+
+func TestStartDir(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := newRecorder()
+	r := New(rec)
+	if _, err = r.Start(Request{Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": "pwd"}, Dir: "/"}); err != nil {
+		t.Fatal(err)
+	}
+	<-rec.done
+	if rec.output[Stdout] != "/\n" {
+		t.Errorf("result: %q", rec.output)
+	}
+
+	_, err = r.Start(Request{Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": "pwd"}, Dir: "/nonexistent"})
+	if err == nil {
+		t.Errorf("started in a missing directory")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	sh, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort, err := docopt.ParsePattern("Usage: sort [-r]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := func(s string) Request {
+		return Request{Program: "sh", Pattern: sh, Values: docopt.Opts{"-c": s}}
+	}
+	req := script("printf 'b\\na\\nc\\n'; echo first >&2")
+	req.Stdin = Stdin{Text: "unread"}
+	req.Pipe = []Request{
+		{Program: "sort", Pattern: sort, Values: docopt.Opts{"-r": true}},
+		script("cat; echo last >&2; exit 4"),
+	}
+
+	preview, err := DryRun(req)
+	if expected := `sh -c 'printf '\''b\na\nc\n'\''; echo first >&2' | sort -r | sh -c 'cat; echo last >&2; exit 4'`; err != nil || preview.Line != expected || len(preview.Pipe) != 2 {
+		t.Errorf("result: %q %v expected: %q", preview.Line, err, expected)
+	}
+
+	rec := newRecorder()
+	r := New(rec)
+	id, err := r.Start(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rec.done
+	if rec.output[Stdout] != "c\nb\na\n" || !strings.Contains(rec.output[Stderr], "first\n") || !strings.Contains(rec.output[Stderr], "last\n") {
+		t.Errorf("result: %q", rec.output)
+	}
+	result := rec.exit
+	if statusOf(result) != (status{JobID: id, State: StateExited, Code: 4}) || len(result.Stages) != 3 ||
+		!reflect.DeepEqual(result.Argv, result.Stages[2].Argv) || result.Bytes[Stdout] != 6 {
+		t.Errorf("result: %+v", result)
+	}
+	for i, code := range []int{0, 0, 4} {
+		if len(result.Stages) == 3 && (result.Stages[i].Code != code || result.Stages[i].State != StateExited) {
+			t.Errorf("stage: %d result: %+v", i, result.Stages[i])
+		}
+	}
+	if !reflect.DeepEqual(result.Stages[1].Argv, []string{"sort", "-r"}) {
+		t.Errorf("result: %q", result.Stages[1].Argv)
+	}
+
+	// canceling stops every stage
+	rec = newRecorder()
+	r = New(rec)
+	req = script("echo started; sleep 10")
+	req.Pipe = []Request{script("cat; sleep 10")}
+	if id, err = r.Start(req); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		rec.mu.Lock()
+		started := rec.output[Stdout] != ""
+		rec.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err = r.Cancel(id); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rec.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("pipeline not stopped")
+	}
+	if rec.exit.State != StateCanceled || len(rec.exit.Stages) != 2 {
+		t.Errorf("result: %+v", rec.exit)
+	}
+
+	req.Pipe[0].Stdin = Stdin{Text: "x"}
+	if _, err = r.Start(req); err == nil {
+		t.Errorf("stage with a standard input accepted")
+	}
+	req.Pipe[0].Stdin = Stdin{}
+	req.Pipe[0].Program = "/nonexistent/program"
+	if _, err = r.Start(req); err == nil {
+		t.Errorf("pipeline with a missing program started")
+	}
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// shell is how a command line is handed over to a shell.
+type shell struct {
+	// flags come before the script
+	flags []string
+	quote func(string) string
+}
+
+// shells are the shells a Request may run through, by name.
+var shells = map[string]shell{
+	"sh":   {[]string{"-c"}, quoteWord},
+	"bash": {[]string{"-c"}, quoteWord},
+	"zsh":  {[]string{"-c"}, quoteWord},
+	"fish": {[]string{"-c"}, quoteFish},
+	"pwsh": {[]string{"-NoProfile", "-Command"}, quotePwsh},
+}
+
+// Shells returns the names of the shells a Request may run through.
+func Shells() []string {
+	return []string{"sh", "bash", "zsh", "fish", "pwsh"}
+}
+
+// shellOf returns the shell name stands for. Name may be a path, such as
+// /usr/local/bin/bash, the shell being told by its base name.
+func shellOf(name string) (shell, bool) {
+	base := strings.TrimSuffix(filepath.Base(name), ".exe")
+	if base == "powershell" {
+		base = "pwsh"
+	}
+	sh, ok := shells[base]
+	return sh, ok
+}
+
+// script is the command line the shell runs for the request: the program
+// as written, shell syntax and all, then the values quoted.
+func (sh shell) script(program string, argv []string) string {
+	line := program
+	for _, arg := range argv {
+		line += " " + sh.quote(arg)
+	}
+	return line
+}
+
+// quoteFish quotes s for fish, which takes backslashes to escape quotes and
+// backslashes within single quotes.
+func quoteFish(s string) string {
+	if s != "" && quoteWord(s) == s {
+		return s
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// quotePwsh quotes s for PowerShell, which doubles single quotes within
+// single quotes.
+func quotePwsh(s string) string {
+	if s != "" && quoteWord(s) == s && !strings.ContainsAny(s, ",@") {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestShellQuote(t *testing.T) {
+	var tests = []struct {
+		shell  string
+		arg    string
+		result string
+	}{
+		{"sh", "plain", "plain"},
+		{"sh", `it's \ `, `'it'\''s \ '`},
+		{"fish", "plain", "plain"},
+		{"fish", `it's \ `, `'it\'s \\ '`},
+		{"fish", "", "''"},
+		{"pwsh", "plain", "plain"},
+		{"pwsh", "a,b", "'a,b'"},
+		{"pwsh", `it's \ `, `'it''s \ '`},
+	}
+	for i, tt := range tests {
+		sh, ok := shellOf(tt.shell)
+		if !ok {
+			t.Fatalf("testcase: %d unknown shell %s", i, tt.shell)
+		}
+		if result := sh.quote(tt.arg); result != tt.result {
+			t.Errorf("testcase: %d result: %q expected: %q", i, result, tt.result)
+		}
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: echo <word>...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := docopt.Opts{"<word>": []string{"a b", "$HOME"}}
+
+	preview, err := DryRun(Request{Program: "echo", Pattern: pat, Values: values})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"echo", "a b", "$HOME"}; !reflect.DeepEqual(preview.Argv, expected) {
+		t.Errorf("direct result: %q expected: %q", preview.Argv, expected)
+	}
+
+	preview, err = DryRun(Request{Program: "X=1; echo $X", Shell: "/bin/sh", Pattern: pat, Values: values})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"/bin/sh", "-c", "X=1; echo $X 'a b' '$HOME'"}; !reflect.DeepEqual(preview.Argv, expected) {
+		t.Errorf("shell result: %q expected: %q", preview.Argv, expected)
+	}
+	out, err := exec.Command(preview.Argv[0], preview.Argv[1:]...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "1 a b $HOME\n"; string(out) != expected {
+		t.Errorf("output: %q expected: %q", out, expected)
+	}
+
+	if _, err = DryRun(Request{Program: "echo", Shell: "csh", Pattern: pat, Values: values}); err == nil {
+		t.Errorf("unknown shell accepted")
+	}
+}
@@ -0,0 +1,131 @@
+//go:build !windows
+// +build !windows
+
+package runner
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// setProcessGroup makes the command the leader of a new process group.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// trees are the process groups signalTree found for the commands, which
+// are signaled again even when the processes that would lead to them are
+// gone.
+var trees = struct {
+	sync.Mutex
+	m map[*exec.Cmd]map[int]bool
+}{m: make(map[*exec.Cmd]map[int]bool)}
+
+// track does nothing, the process group holds the tree of the command but
+// for the descendants that leave it, which are looked up by signalTree.
+func track(cmd *exec.Cmd) {}
+
+// untrack forgets the process groups found for the command, once it has
+// exited.
+func untrack(cmd *exec.Cmd) {
+	trees.Lock()
+	delete(trees.m, cmd)
+	trees.Unlock()
+}
+
+// interrupt sends SIGINT to the tree of processes the command started, see
+// signalTree. Commands run on a terminal lead a process group too.
+func interrupt(cmd *exec.Cmd) error {
+	return signalTree(cmd, syscall.SIGINT)
+}
+
+func kill(cmd *exec.Cmd) error {
+	return signalTree(cmd, syscall.SIGKILL)
+}
+
+// signalTree sends sig to the process group the command leads, and to the
+// groups of its descendants that left it, such as the jobs of a shell with
+// job control or programs started with setsid. Descendants are looked up in
+// /proc, on systems that have one. The groups found are kept, so that
+// killing the command after interrupting it reaches those orphaned since.
+func signalTree(cmd *exec.Cmd, sig syscall.Signal) error {
+	pgid := cmd.Process.Pid
+	found := descendantGroups(pgid)
+	trees.Lock()
+	groups := trees.m[cmd]
+	if groups == nil {
+		groups = make(map[int]bool)
+		trees.m[cmd] = groups
+	}
+	for _, group := range found {
+		if group != pgid {
+			groups[group] = true
+		}
+	}
+	others := make([]int, 0, len(groups))
+	for group := range groups {
+		others = append(others, group)
+	}
+	trees.Unlock()
+	err := syscall.Kill(-pgid, sig)
+	for _, group := range others {
+		syscall.Kill(-group, sig)
+	}
+	return err
+}
+
+// descendantGroups returns the process groups of the descendants of pid.
+func descendantGroups(pid int) []int {
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	children := make(map[int][]int)
+	groups := make(map[int]int)
+	for _, path := range stats {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// pid (comm) state ppid pgrp ..., comm may hold spaces and parens
+		stat := string(data)
+		i := strings.LastIndexByte(stat, ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(stat[i+1:])
+		if len(fields) < 3 {
+			continue
+		}
+		p, _ := strconv.Atoi(strings.Fields(stat[:i])[0])
+		ppid, _ := strconv.Atoi(fields[1])
+		pgrp, _ := strconv.Atoi(fields[2])
+		children[ppid] = append(children[ppid], p)
+		groups[p] = pgrp
+	}
+	var found []int
+	seen := make(map[int]bool)
+	queue := children[pid]
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = append(queue[1:], children[p]...)
+		if g := groups[p]; !seen[g] {
+			seen[g] = true
+			found = append(found, g)
+		}
+	}
+	return found
+}
+
+// signalOf returns the name of the signal that ended the process, if any.
+func signalOf(err *exec.ExitError) string {
+	if status, ok := err.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal().String()
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+//go:build !windows
+// +build !windows
+
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gtoc/docopt"
+)
+
+// alive tells whether the process pid runs, zombies being dead.
+func alive(pid int) bool {
+	stat, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	s := string(stat)
+	fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+	return fields[0] != "Z"
+}
+
+func TestCancelTree(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("no /proc")
+	}
+	pat, err := docopt.ParsePattern("Usage: sh -c <script>\n\nOptions:\n  -c <script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := newRecorder()
+	r := New(rec)
+	r.Grace = 200 * time.Millisecond
+	// the second sleep leaves the process group of the job
+	script := "sleep 30 & echo $!; setsid sleep 30 & echo $!; wait"
+	id, err := r.Start(Request{Program: "sh", Pattern: pat, Values: docopt.Opts{"-c": script}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pids []int
+	for len(pids) < 2 {
+		time.Sleep(10 * time.Millisecond)
+		rec.mu.Lock()
+		pids = pids[:0]
+		for _, f := range strings.Fields(rec.output[Stdout]) {
+			pid, _ := strconv.Atoi(f)
+			pids = append(pids, pid)
+		}
+		rec.mu.Unlock()
+	}
+	// let setsid take effect
+	time.Sleep(100 * time.Millisecond)
+	if err = r.Cancel(id); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rec.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("job not stopped")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for _, pid := range pids {
+		for alive(pid) && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if alive(pid) {
+			t.Errorf("process %d left running", pid)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	procCreateJobObject          = kernel32.NewProc("CreateJobObjectW")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+	procTerminateJobObject       = kernel32.NewProc("TerminateJobObject")
+)
+
+// access rights track needs on a process
+const processSetQuota, processTerminate = 0x0100, 0x0001
+
+// jobObjects are the job objects holding the trees of the tracked commands.
+var jobObjects = struct {
+	sync.Mutex
+	m map[*exec.Cmd]syscall.Handle
+}{m: make(map[*exec.Cmd]syscall.Handle)}
+
+// setProcessGroup does nothing, there are no process groups to signal, see
+// track.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// track puts the started command in a job object of its own, which the
+// processes it starts join, for interrupt and kill to end them all. The
+// command is left alone if that fails, only its own process is ended then.
+func track(cmd *exec.Cmd) {
+	job, _, _ := procCreateJobObject.Call(0, 0)
+	if job == 0 {
+		return
+	}
+	process, err := syscall.OpenProcess(processSetQuota|processTerminate, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		syscall.CloseHandle(syscall.Handle(job))
+		return
+	}
+	defer syscall.CloseHandle(process)
+	if ok, _, _ := procAssignProcessToJobObject.Call(job, uintptr(process)); ok == 0 {
+		syscall.CloseHandle(syscall.Handle(job))
+		return
+	}
+	jobObjects.Lock()
+	jobObjects.m[cmd] = syscall.Handle(job)
+	jobObjects.Unlock()
+}
+
+// untrack releases the job object of the command, once it has exited.
+func untrack(cmd *exec.Cmd) {
+	jobObjects.Lock()
+	defer jobObjects.Unlock()
+	if job, ok := jobObjects.m[cmd]; ok {
+		syscall.CloseHandle(job)
+		delete(jobObjects.m, cmd)
+	}
+}
+
+// interrupt ends the processes of the command's job object, they can't be
+// sent SIGINT.
+func interrupt(cmd *exec.Cmd) error {
+	jobObjects.Lock()
+	job, ok := jobObjects.m[cmd]
+	jobObjects.Unlock()
+	if ok {
+		if r, _, err := procTerminateJobObject.Call(uintptr(job), 1); r == 0 {
+			return err
+		}
+		return nil
+	}
+	return cmd.Process.Kill()
+}
+
+func kill(cmd *exec.Cmd) error {
+	return interrupt(cmd)
+}
+
+// signalOf returns "", processes aren't ended by signals.
+func signalOf(err *exec.ExitError) string {
+	return ""
+}
@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Stdin is where the standard input of a run comes from: a text, a file, or
+// the output of a job that has finished. At most one of them may be set, the
+// program reads nothing if none is.
+type Stdin struct {
+	Text string `json:"text"`
+	File string `json:"file"`
+	// Job is the ID of the job whose standard output is fed
+	Job string `json:"job"`
+}
+
+func (s Stdin) isZero() bool {
+	return s == Stdin{}
+}
+
+// DefaultRetain is how many bytes of the standard output of every job a new
+// Runner keeps to feed later runs.
+const DefaultRetain = 1 << 20
+
+// retainedJobs is how many finished jobs the result and output are kept of,
+// the oldest are forgotten first.
+const retainedJobs = 16
+
+// capture keeps what's written to it up to limit bytes.
+type capture struct {
+	limit     int
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	if room := c.limit - c.buf.Len(); room < len(p) {
+		c.truncated = true
+		if room > 0 {
+			c.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return c.buf.Write(p)
+}
+
+// stdin opens the standard input s stands for. The returned function
+// releases it, once the program has started.
+func (r *Runner) stdin(s Stdin) (io.Reader, func(), error) {
+	none := func() {}
+	set := 0
+	for _, source := range []string{s.Text, s.File, s.Job} {
+		if source != "" {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		return nil, none, fmt.Errorf("standard input from more than one source")
+	case s.Text != "":
+		return strings.NewReader(s.Text), none, nil
+	case s.File != "":
+		f, err := os.Open(s.File)
+		if err != nil {
+			return nil, none, err
+		}
+		return f, func() { f.Close() }, nil
+	case s.Job != "":
+		r.mu.Lock()
+		f, ok := r.finished[s.Job]
+		r.mu.Unlock()
+		if !ok || f.stdout == nil {
+			return nil, none, fmt.Errorf("no output kept of job %s", s.Job)
+		}
+		c := f.stdout
+		if c.truncated {
+			return nil, none, fmt.Errorf("output of job %s is longer than the %d bytes kept", s.Job, c.limit)
+		}
+		return bytes.NewReader(c.buf.Bytes()), none, nil
+	}
+	return nil, none, nil
+}
+
+// keep retains what's kept of the finished job id, forgetting the oldest
+// job if there are too many. It's called with r.mu held.
+func (r *Runner) keep(id string, f *finished) {
+	r.finished[id] = f
+	r.retained = append(r.retained, id)
+	if len(r.retained) > retainedJobs {
+		delete(r.finished, r.retained[0])
+		r.retained = r.retained[1:]
+	}
+}
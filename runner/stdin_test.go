@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"gtoc/docopt"
+)
+
+func TestStdin(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: sort [-r]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ioutil.TempFile("", "gtoc-stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("b\nc\na\n")
+	f.Close()
+
+	r := New(nil)
+	run := func(stdin Stdin) (string, string, error) {
+		rec := newRecorder()
+		r.sink = rec
+		id, err := r.Start(Request{Program: "sort", Pattern: pat, Values: docopt.Opts{"-r": false}, Stdin: stdin})
+		if err != nil {
+			return "", "", err
+		}
+		<-rec.done
+		return id, rec.output[Stdout], nil
+	}
+
+	id, out, err := run(Stdin{Text: "2\n3\n1\n"})
+	if err != nil || out != "1\n2\n3\n" {
+		t.Errorf("result: %q error: %v", out, err)
+	}
+	if _, out, err = run(Stdin{File: f.Name()}); err != nil || out != "a\nb\nc\n" {
+		t.Errorf("result: %q error: %v", out, err)
+	}
+	// the output of the first run
+	if _, out, err = run(Stdin{Job: id}); err != nil || out != "1\n2\n3\n" {
+		t.Errorf("result: %q error: %v", out, err)
+	}
+	if _, out, err = run(Stdin{}); err != nil || out != "" {
+		t.Errorf("result: %q error: %v", out, err)
+	}
+
+	for i, stdin := range []Stdin{
+		{Text: "a", File: f.Name()},
+		{File: "/nonexistent"},
+		{Job: "unknown"},
+	} {
+		if _, _, err := run(stdin); err == nil {
+			t.Errorf("testcase: %d expected an error", i)
+		}
+	}
+
+	// outputs longer than kept can't be fed
+	r.Retain = 4
+	id, _, _ = run(Stdin{Text: strings.Repeat("x\n", 10)})
+	if _, _, err = run(Stdin{Job: id}); err == nil || !strings.Contains(err.Error(), "longer") {
+		t.Errorf("result: %v", err)
+	}
+	if result, _ := r.Result(id); !result.Truncated || result.Bytes[Stdout] != 20 {
+		t.Errorf("result: %+v", result)
+	}
+	if stdout, truncated, ok := r.Stdout(id); stdout != "x\nx\n" || !truncated || !ok {
+		t.Errorf("result: %q %v %v", stdout, truncated, ok)
+	}
+
+	// only the outputs of the last jobs are kept
+	for i := 0; i < retainedJobs; i++ {
+		run(Stdin{})
+	}
+	if _, _, err = run(Stdin{Job: "1"}); err == nil {
+		t.Errorf("the output of job 1 is still kept")
+	}
+
+	if _, err = r.StartPTY(Request{Program: "sort", Pattern: pat, Stdin: Stdin{Text: "a"}}, 24, 80); err == nil {
+		t.Errorf("fed stdin to a terminal")
+	}
+}
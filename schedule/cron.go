@@ -0,0 +1,164 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron expression, see ParseCron.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set for fields given as "*", a day then
+	// matching if the other day field does
+	domAny, dowAny bool
+}
+
+type cronField struct {
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{0, 59, nil},
+	{0, 23, nil},
+	{1, 31, nil},
+	{1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a cron expression of five fields, the minute, hour, day
+// of the month, month and day of the week, each one a "*" or a list of
+// values, ranges and steps such as "1,15", "9-17" or "*/10". Months and days
+// of the week may be named by their first three letters, and Sunday is 0 or
+// 7. The macros @hourly, @daily, @weekly, @monthly and @yearly stand for
+// their usual expressions.
+func ParseCron(expr string) (*Cron, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q has %d fields, not 5", expr, len(fields))
+	}
+	sets := make([]uint64, len(fields))
+	for i, field := range fields {
+		set, err := cronFields[i].parse(strings.ToLower(field))
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %s", expr, err)
+		}
+		sets[i] = set
+	}
+	c := &Cron{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: fields[2] == "*", dowAny: fields[4] == "*",
+	}
+	// Sunday is 7 as well as 0
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+func (f cronField) parse(field string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		span, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			span, step = part[:i], n
+		}
+		lo, hi := f.min, f.max
+		if span != "*" {
+			bounds := strings.SplitN(span, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// "5/10" runs from 5 to the end
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("bad range %q", span)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if s == name {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%q isn't a value from %d to %d", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first time after t the expression matches, in t's
+// location, or the zero time if it matches none within five years, as
+// "0 0 30 2 *" does.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		var next time.Time
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			next = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			next = t.Add(time.Minute)
+		default:
+			return t
+		}
+		if !next.After(t) {
+			// the clock was turned back, the hour repeats
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+// dayMatches tells whether the day of t matches. As in cron, when both day
+// fields are restricted a day matching either one does.
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,48 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2020, 1, 15, 10, 30, 0, 0, time.UTC)
+	var tests = []struct {
+		expr string
+		next string
+	}{
+		{"* * * * *", "2020-01-15 10:31"},
+		{"*/20 * * * *", "2020-01-15 10:40"},
+		{"5 * * * *", "2020-01-15 11:05"},
+		{"0 9-17/4 * * *", "2020-01-15 13:00"},
+		{"0 0 * * *", "2020-01-16 00:00"},
+		{"@daily", "2020-01-16 00:00"},
+		{"@hourly", "2020-01-15 11:00"},
+		{"0 12 * * sat,sun", "2020-01-18 12:00"},
+		{"0 12 * * 7", "2020-01-19 12:00"},
+		{"0 0 1 * *", "2020-02-01 00:00"},
+		{"0 0 29 feb *", "2020-02-29 00:00"},
+		{"0 0 13 * fri", "2020-01-17 00:00"},
+		{"30 10 15 jan *", "2021-01-15 10:30"},
+		{"0 0 30 2 *", "0001-01-01 00:00"},
+	}
+	for i, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("testcase: %d error: %v", i, err)
+			continue
+		}
+		if next := c.Next(from).Format("2006-01-02 15:04"); next != tt.next {
+			t.Errorf("testcase: %d result: %s expected: %s", i, next, tt.next)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for i, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("testcase: %d %q accepted", i, expr)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Package schedule runs saved invocations at an interval or on a cron
+// expression, so that gtoc can serve as a small cron of its own.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"gtoc/runner"
+	"gtoc/store"
+)
+
+// Entry is an invocation run on a schedule.
+type Entry struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Invocation runner.Invocation `json:"invocation"`
+	// Every is the interval between runs, when Cron is empty. It's in
+	// nanoseconds in JSON
+	Every time.Duration `json:"every"`
+	// Cron is a cron expression telling when to run, see ParseCron
+	Cron    string `json:"cron"`
+	Enabled bool   `json:"enabled"`
+	// Next is when the entry runs next, zero while it's disabled
+	Next time.Time `json:"next"`
+	// LastRun is when the entry last ran, LastJob the ID of the job started
+	// then, and LastErr why none could be, if so
+	LastRun time.Time `json:"lastRun"`
+	LastJob string    `json:"lastJob,omitempty"`
+	LastErr string    `json:"lastErr,omitempty"`
+}
+
+// after returns the first time after t the entry runs at.
+func (e *Entry) after(t time.Time) (time.Time, error) {
+	if e.Cron == "" {
+		if e.Every <= 0 {
+			return time.Time{}, errors.New("a schedule needs an interval or a cron expression")
+		}
+		return t.Add(e.Every), nil
+	}
+	c, err := ParseCron(e.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := c.Next(t)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never matches", e.Cron)
+	}
+	return next, nil
+}
+
+// Fire starts a run of the invocation and returns the ID of its job.
+type Fire func(runner.Invocation) (string, error)
+
+// idle is how long the scheduler sleeps when no entry is enabled, until
+// it's woken up by a change.
+const idle = time.Hour
+
+// Scheduler runs the entries saved in a file when they're due, with its
+// Fire function. Runs missed while the scheduler wasn't running are skipped.
+type Scheduler struct {
+	fire Fire
+	file *store.File
+
+	mu      sync.Mutex
+	entries []*Entry
+	// wake tells the loop the entries changed
+	wake chan struct{}
+	stop chan struct{}
+}
+
+// Open loads the entries saved at path, to be run with fire once the
+// scheduler is started.
+func Open(path string, fire Fire) (*Scheduler, error) {
+	s := &Scheduler{
+		fire: fire,
+		file: &store.File{Path: path},
+		wake: make(chan struct{}, 1),
+	}
+	if err := s.file.Load(&s.entries); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, e := range s.entries {
+		// JSON has no ints or string lists
+		e.Invocation.DecodeValues()
+		if e.Enabled && e.Next.Before(now) {
+			e.Next, _ = e.after(now)
+		}
+	}
+	return s, nil
+}
+
+// Start runs the entries when they're due, until Stop is called.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		s.stop = make(chan struct{})
+		go s.loop(s.stop)
+	}
+}
+
+// Stop stops running the entries. Jobs already started go on.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+func (s *Scheduler) loop(stop chan struct{}) {
+	for {
+		due, wait := s.due(time.Now())
+		for _, e := range due {
+			id, err := s.fire(e.Invocation)
+			s.mu.Lock()
+			e.LastJob, e.LastErr = id, ""
+			if err != nil {
+				e.LastErr = err.Error()
+			}
+			s.save()
+			s.mu.Unlock()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		case <-stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// due returns the entries due at now, scheduling their next run, and how
+// long to wait for the next entry due after them.
+func (s *Scheduler) due(now time.Time) ([]*Entry, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*Entry
+	wait := idle
+	for _, e := range s.entries {
+		if !e.Enabled {
+			continue
+		}
+		if !e.Next.After(now) {
+			due = append(due, e)
+			e.LastRun = now
+			e.Next, _ = e.after(now)
+		}
+		if d := e.Next.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if len(due) > 0 {
+		s.save()
+	}
+	return due, wait
+}
+
+// save writes the entries, s.mu being held. Failing to isn't reported, the
+// entries are saved again with the next change.
+func (s *Scheduler) save() {
+	s.file.Save(s.entries)
+}
+
+// changed saves the entries, s.mu being held, and wakes the loop up to
+// reconsider when to run next.
+func (s *Scheduler) changed() error {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return s.file.Save(s.entries)
+}
+
+// Add adds the entry and returns it with its ID and next run set. An entry
+// whose schedule is invalid is refused.
+func (s *Scheduler) Add(e Entry) (Entry, error) {
+	next, err := e.after(time.Now())
+	if err != nil {
+		return Entry{}, err
+	}
+	e.ID = strconv.FormatInt(time.Now().UnixNano(), 36)
+	e.Next = time.Time{}
+	if e.Enabled {
+		e.Next = next
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, &e)
+	return e, s.changed()
+}
+
+// Remove removes the entry id.
+func (s *Scheduler) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if e.ID == id {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return s.changed()
+		}
+	}
+	return fmt.Errorf("no schedule %s", id)
+}
+
+// SetEnabled enables or disables the entry id, and returns it. An entry
+// enabled again runs next as its schedule says from now on.
+func (s *Scheduler) SetEnabled(id string, enabled bool) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.ID != id {
+			continue
+		}
+		if enabled && !e.Enabled {
+			next, err := e.after(time.Now())
+			if err != nil {
+				return Entry{}, err
+			}
+			e.Next = next
+		} else if !enabled {
+			e.Next = time.Time{}
+		}
+		e.Enabled = enabled
+		return *e, s.changed()
+	}
+	return Entry{}, fmt.Errorf("no schedule %s", id)
+}
+
+// List returns the entries, in the order they were added.
+func (s *Scheduler) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]Entry, len(s.entries))
+	for i, e := range s.entries {
+		entries[i] = *e
+	}
+	return entries
+}
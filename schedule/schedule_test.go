@@ -0,0 +1,91 @@
+package schedule
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gtoc/docopt"
+	"gtoc/runner"
+)
+
+func TestScheduler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schedule.json")
+
+	fired := make(chan runner.Invocation, 10)
+	fire := func(inv runner.Invocation) (string, error) {
+		fired <- inv
+		if inv.Program == "fail" {
+			return "", errors.New("failed")
+		}
+		return "7", nil
+	}
+	s, err := Open(path, fire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Add(Entry{Name: "none", Enabled: true}); err == nil {
+		t.Errorf("entry without a schedule accepted")
+	}
+	inv := runner.Invocation{Program: "ls", Values: docopt.Opts{"-v": 2}}
+	e, err := s.Add(Entry{Name: "ls", Invocation: inv, Every: 30 * time.Millisecond, Enabled: true})
+	if err != nil || e.ID == "" || e.Next.IsZero() {
+		t.Fatalf("result: %+v %v", e, err)
+	}
+	off, err := s.Add(Entry{Name: "off", Invocation: runner.Invocation{Program: "fail"}, Cron: "@hourly"})
+	if err != nil || !off.Next.IsZero() {
+		t.Fatalf("result: %+v %v", off, err)
+	}
+
+	s.Start()
+	for i := 0; i < 2; i++ {
+		select {
+		case got := <-fired:
+			if got.Program != "ls" {
+				t.Errorf("fired: %+v", got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("run %d not fired", i)
+		}
+	}
+	if _, err = s.SetEnabled(e.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	s.Stop()
+	// a run may have been fired while disabling
+	time.Sleep(50 * time.Millisecond)
+	for len(fired) > 0 {
+		<-fired
+	}
+
+	entries := s.List()
+	if len(entries) != 2 || entries[0].Enabled || !entries[0].Next.IsZero() || entries[0].LastJob != "7" || entries[0].LastRun.IsZero() {
+		t.Errorf("result: %+v", entries)
+	}
+
+	reopened, err := Open(path, fire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = reopened.List()
+	if len(entries) != 2 || entries[0].Name != "ls" || entries[0].Invocation.Values["-v"] != 2 || entries[1].Cron != "@hourly" {
+		t.Errorf("result: %+v", entries)
+	}
+	if err = reopened.Remove(off.ID); err != nil || len(reopened.List()) != 1 {
+		t.Errorf("result: %v %+v", err, reopened.List())
+	}
+	if err = reopened.Remove(off.ID); err == nil {
+		t.Errorf("removed twice")
+	}
+	if _, err = reopened.SetEnabled("unknown", true); err == nil {
+		t.Errorf("unknown entry enabled")
+	}
+}
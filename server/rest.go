@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Core is the part of the backend the REST API drives, for scripts and
+// other tools to parse, check and run commands without the GUI. Requests of
+// runs are passed on as the JSON they're posted as, for the backend to
+// decode.
+type Core interface {
+	// Parse returns the pattern of the command, probed for its help text,
+	// or of the help text given
+	Parse(command, help string) (interface{}, error)
+	// Validate checks a requested run without running it, an error telling
+	// the run can't be checked rather than it's invalid
+	Validate(req json.RawMessage) (interface{}, error)
+	// Run starts a requested run and returns the ID of its job
+	Run(req json.RawMessage) (string, error)
+	// Status returns the state of the job id, false if there's no such job
+	Status(id string) (interface{}, bool)
+}
+
+// The REST API, of JSON bodies, errors being {"error": "..."}:
+//
+//	POST /parse      {"command": "ls"} or {"help": "Usage: ..."}, answers the
+//	                 pattern of the command or of the help text
+//	POST /validate   a run request, answers whether its values fit the
+//	                 pattern of its command, and the command line they make
+//	POST /runs       a run request, starts it and answers 201 with {"id": ...}
+//	                 and the Location of the run
+//	GET  /runs/<id>  answers the state of the run, with its result once it
+//	                 ended
+//
+// Bodies that can't be decoded are answered 400, commands that can't be
+// parsed or run 422, and unknown runs 404. Requests are made with the token
+// of the server in the TokenHeader, and POSTed as application/json, see
+// Server.
+func (s *Server) rest(w http.ResponseWriter, r *http.Request) bool {
+	switch {
+	case r.URL.Path == "/parse":
+		s.post(w, r, func(body []byte) (int, interface{}, error) {
+			var req struct {
+				Command string `json:"command"`
+				Help    string `json:"help"`
+			}
+			if err := json.Unmarshal(body, &req); err != nil {
+				return http.StatusBadRequest, nil, err
+			}
+			if (req.Command == "") == (req.Help == "") {
+				return http.StatusBadRequest, nil, fmt.Errorf("give either a command or a help text")
+			}
+			pat, err := s.core.Parse(req.Command, req.Help)
+			return http.StatusOK, pat, err
+		})
+	case r.URL.Path == "/validate":
+		s.post(w, r, func(body []byte) (int, interface{}, error) {
+			if err := object(body); err != nil {
+				return http.StatusBadRequest, nil, err
+			}
+			v, err := s.core.Validate(body)
+			return http.StatusOK, v, err
+		})
+	case r.URL.Path == "/runs":
+		s.post(w, r, func(body []byte) (int, interface{}, error) {
+			if err := object(body); err != nil {
+				return http.StatusBadRequest, nil, err
+			}
+			id, err := s.core.Run(body)
+			if err == nil {
+				w.Header().Set("Location", "/runs/"+id)
+			}
+			return http.StatusCreated, map[string]string{"id": id}, err
+		})
+	case strings.HasPrefix(r.URL.Path, "/runs/"):
+		if r.Method != http.MethodGet {
+			answer(w, http.StatusMethodNotAllowed, "error", "runs are read with GET")
+			break
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/runs/")
+		status, ok := s.core.Status(id)
+		if !ok {
+			answer(w, http.StatusNotFound, "error", fmt.Sprintf("no run %s", id))
+			break
+		}
+		reply(w, http.StatusOK, status)
+	default:
+		return false
+	}
+	return true
+}
+
+// post answers a POST with what handle makes of its body: the status and
+// the value if the error is nil, 422 and the error otherwise, but for
+// bodies handle answers 400 to.
+func (s *Server) post(w http.ResponseWriter, r *http.Request, handle func(body []byte) (int, interface{}, error)) {
+	if r.Method != http.MethodPost {
+		answer(w, http.StatusMethodNotAllowed, "error", fmt.Sprintf("%s is POSTed", r.URL.Path))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		answer(w, http.StatusBadRequest, "error", err.Error())
+		return
+	}
+	status, v, err := handle(body)
+	switch {
+	case err != nil && status == http.StatusBadRequest:
+		answer(w, status, "error", err.Error())
+	case err != nil:
+		answer(w, http.StatusUnprocessableEntity, "error", err.Error())
+	default:
+		reply(w, status, v)
+	}
+}
+
+// object returns why body isn't a JSON object, nil if it is.
+func object(body []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return fmt.Errorf("the body isn't a JSON object: %s", err)
+	}
+	return nil
+}
+
+// reply writes v as the JSON body of the response.
+func reply(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// core parses "Usage: ..." help texts and the "ls" command, and runs "ls".
+type core struct{}
+
+func (core) Parse(command, help string) (interface{}, error) {
+	if command == "ls" || strings.HasPrefix(help, "Usage:") {
+		return map[string]string{"type": "required"}, nil
+	}
+	return nil, errors.New("unparseable")
+}
+
+func (core) Validate(req json.RawMessage) (interface{}, error) {
+	var r struct{ Command string }
+	json.Unmarshal(req, &r)
+	if r.Command != "ls" {
+		return nil, errors.New("no command " + r.Command)
+	}
+	return map[string]bool{"valid": true}, nil
+}
+
+func (core) Run(req json.RawMessage) (string, error) {
+	if _, err := (core{}).Validate(req); err != nil {
+		return "", err
+	}
+	return "7", nil
+}
+
+func (core) Status(id string) (interface{}, bool) {
+	return map[string]string{"id": id, "state": "running"}, id == "7"
+}
+
+func TestREST(t *testing.T) {
+	srv := New(api{}, core{}, fstest.MapFS{})
+	s := serve(srv)
+	defer s.Close()
+	for i, tt := range []struct {
+		method, path, body string
+		status             int
+		expected           string
+	}{
+		{"POST", "/parse", `{"command": "ls"}`, 200, `{"type":"required"}`},
+		{"POST", "/parse", `{"help": "Usage: prog"}`, 200, `{"type":"required"}`},
+		{"POST", "/parse", `{"command": "rm"}`, 422, `{"error":"unparseable"}`},
+		{"POST", "/parse", `{}`, 400, `{"error":"give either a command or a help text"}`},
+		{"POST", "/parse", `{"command": "ls", "help": "Usage: prog"}`, 400, ""},
+		{"POST", "/parse", `nope`, 400, ""},
+		{"GET", "/parse", ``, 405, ""},
+		{"POST", "/validate", `{"command": "ls"}`, 200, `{"valid":true}`},
+		{"POST", "/validate", `{"command": "rm"}`, 422, `{"error":"no command rm"}`},
+		{"POST", "/validate", `[]`, 400, ""},
+		{"POST", "/runs", `{"command": "ls"}`, 201, `{"id":"7"}`},
+		{"POST", "/runs", `{"command": "rm"}`, 422, `{"error":"no command rm"}`},
+		{"GET", "/runs/7", ``, 200, `{"id":"7","state":"running"}`},
+		{"GET", "/runs/8", ``, 404, `{"error":"no run 8"}`},
+		{"POST", "/runs/7", ``, 405, ""},
+	} {
+		req, _ := http.NewRequest(tt.method, s.URL+tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(TokenHeader, srv.Token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		result := strings.TrimSpace(string(body))
+		if resp.StatusCode != tt.status || tt.expected != "" && result != tt.expected {
+			t.Errorf("testcase: %d result: %d %s expected: %d %s", i, resp.StatusCode, result, tt.status, tt.expected)
+		}
+		if resp.StatusCode == http.StatusCreated && resp.Header.Get("Location") != "/runs/7" {
+			t.Errorf("testcase: %d result: %s expected: /runs/7", i, resp.Header.Get("Location"))
+		}
+	}
+}
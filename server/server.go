@@ -0,0 +1,433 @@
+// Package server serves the frontend of gtoc and the API of its backend over
+// HTTP, for gtoc to run on a headless machine and be used from a browser.
+// The methods of the API are called as in the native window, the bridge
+// script giving the frontend the window.go and window.runtime it has there.
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Server is the HTTP handler of the frontend, the API bound to it and the
+// REST API of the core, see Core:
+//
+//	POST /api/call/<Method>   calls the method of the API with the JSON array
+//	                          of its arguments, answering {"result": ...} or
+//	                          {"error": "..."}
+//	GET  /api/events          streams the events of the Hub, as server-sent
+//	                          events of {"name": ..., "data": [...]}
+//	GET  /api/bridge.js       the bridge script, which index.html loads
+//	     /parse, /validate,   the REST API, see Core
+//	     /runs, /runs/<id>
+//	GET  /...                 the files of the frontend
+//
+// The server answers only requests for the hosts it's reached at, see Allow,
+// from pages it served if they come from a browser, so that other pages the
+// user visits can't call it, DNS rebinding included. Every request carries
+// the Token of the server, scripts in the TokenHeader and browsers in the
+// TokenCookie. The browser is given the cookie once, opening the URL of the
+// server, see URL, and is then sent on without the token in the address. The
+// POSTs are of JSON bodies.
+type Server struct {
+	*Hub
+
+	// Token is the secret the API is called with, new for every server
+	Token string
+
+	api    reflect.Value
+	core   Core
+	assets fs.FS
+	files  http.Handler
+
+	mu    sync.Mutex
+	hosts map[string]bool
+}
+
+// TokenHeader is the header of requests of the API carrying the Token.
+const TokenHeader = "X-Gtoc-Token"
+
+// TokenCookie is the cookie browsers carry the Token in. It's HttpOnly, for
+// the pages to not read it, and SameSite, for other sites to not send it.
+const TokenCookie = "gtoc_token"
+
+// New returns the server of the exported methods of api, the REST API of
+// core and the frontend files in assets, with a token of its own.
+func New(api interface{}, core Core, assets fs.FS) *Server {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		panic(err)
+	}
+	return &Server{
+		Hub:    NewHub(),
+		Token:  hex.EncodeToString(token),
+		api:    reflect.ValueOf(api),
+		core:   core,
+		assets: assets,
+		files:  http.FileServer(http.FS(assets)),
+		hosts:  make(map[string]bool),
+	}
+}
+
+// Allow allows requests for the hosts, with their port, such as
+// "localhost:8080".
+func (s *Server) Allow(hosts ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range hosts {
+		s.hosts[strings.ToLower(h)] = true
+	}
+}
+
+func (s *Server) allowed(host string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hosts[strings.ToLower(host)]
+}
+
+// URL returns the address the browser opens the server at, reached at host,
+// carrying the token for the server to give it the TokenCookie.
+func (s *Server) URL(host string) string {
+	return "http://" + host + "/?token=" + s.Token
+}
+
+// ListenAndServe serves on the TCP address addr, such as ":8080", until it
+// fails, allowing the hosts it's reached at, see Hosts.
+func (s *Server) ListenAndServe(addr string) error {
+	hosts, err := Hosts(addr)
+	if err != nil {
+		return err
+	}
+	s.Allow(hosts...)
+	return (&http.Server{Addr: addr, Handler: s}).ListenAndServe()
+}
+
+// Hosts returns the hosts, with their port, a server listening on the TCP
+// address addr is reached at: the loopback names for a loopback address,
+// those and the name and addresses of the machine for one of every
+// interface, and the host of addr otherwise.
+func Hosts(addr string) ([]string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{host}
+	ip := net.ParseIP(host)
+	if host == "" || host == "localhost" || ip != nil && (ip.IsLoopback() || ip.IsUnspecified()) {
+		names = []string{"localhost", "127.0.0.1", "::1"}
+	}
+	if host == "" || ip != nil && ip.IsUnspecified() {
+		if name, err := os.Hostname(); err == nil {
+			names = append(names, name)
+		}
+		addrs, _ := net.InterfaceAddrs()
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok {
+				names = append(names, n.IP.String())
+			}
+		}
+	}
+	var hosts []string
+	for _, name := range names {
+		host := net.JoinHostPort(name, port)
+		hosts = append(hosts, host)
+		if port == "80" {
+			// browsers leave the default port out of the Host
+			hosts = append(hosts, strings.TrimSuffix(host, ":80"))
+		}
+	}
+	return hosts, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.guard(w, r) {
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/call/"):
+		s.call(w, r, strings.TrimPrefix(r.URL.Path, "/api/call/"))
+	case r.URL.Path == "/api/events":
+		s.Hub.ServeHTTP(w, r)
+	case r.URL.Path == "/api/bridge.js":
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprint(w, bridge)
+	case r.URL.Path == "/" || r.URL.Path == "/index.html":
+		s.index(w, r)
+	case s.rest(w, r):
+	default:
+		s.files.ServeHTTP(w, r)
+	}
+}
+
+// guard answers the request with an error and returns false if it's for a
+// host the server isn't reached at, from a page of another origin, without
+// the token, or a POST that isn't of JSON. A GET with the token as its
+// "token" parameter, opening the URL of the server, is answered with the
+// TokenCookie and sent on to the same address without it.
+func (s *Server) guard(w http.ResponseWriter, r *http.Request) bool {
+	if !s.allowed(r.Host) {
+		answer(w, http.StatusForbidden, "error", fmt.Sprintf("gtoc isn't served as %s", r.Host))
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && !strings.EqualFold(origin, "http://"+r.Host) {
+		answer(w, http.StatusForbidden, "error", fmt.Sprintf("pages of %s can't call gtoc", origin))
+		return false
+	}
+	query := r.URL.Query()
+	if token := query.Get("token"); token != "" && r.Method == http.MethodGet && s.valid(token) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     TokenCookie,
+			Value:    s.Token,
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		query.Del("token")
+		to := *r.URL
+		to.RawQuery = query.Encode()
+		http.Redirect(w, r, to.RequestURI(), http.StatusSeeOther)
+		return false
+	}
+	token := r.Header.Get(TokenHeader)
+	if c, err := r.Cookie(TokenCookie); err == nil && token == "" {
+		token = c.Value
+	}
+	if !s.valid(token) {
+		answer(w, http.StatusUnauthorized, "error", "the token of gtoc is missing or wrong, open the address gtoc logged")
+		return false
+	}
+	if r.Method == http.MethodPost {
+		if t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || t != "application/json" {
+			answer(w, http.StatusUnsupportedMediaType, "error", "the body isn't application/json")
+			return false
+		}
+	}
+	return true
+}
+
+// valid tells whether token is the token of the server.
+func (s *Server) valid(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+// index serves the index.html of the frontend, loading the bridge script
+// before anything else.
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	page, err := fs.ReadFile(s.assets, "index.html")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	script := []byte(`<script src="/api/bridge.js"></script>`)
+	if i := bytes.Index(page, []byte("<head>")); i >= 0 {
+		i += len("<head>")
+		page = append(page[:i:i], append(script, page[i:]...)...)
+	} else {
+		page = append(script, page...)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+// call calls the method name of the API with the arguments in the body of
+// the request.
+func (s *Server) call(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		answer(w, http.StatusMethodNotAllowed, "error", "calls are POSTed")
+		return
+	}
+	method := s.api.MethodByName(name)
+	if name == "" || !method.IsValid() {
+		answer(w, http.StatusNotFound, "error", fmt.Sprintf("no method %s", name))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		answer(w, http.StatusBadRequest, "error", err.Error())
+		return
+	}
+	var raw []json.RawMessage
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &raw); err != nil {
+			answer(w, http.StatusBadRequest, "error", fmt.Sprintf("the arguments aren't a JSON array: %s", err))
+			return
+		}
+	}
+	t := method.Type()
+	if len(raw) != t.NumIn() {
+		answer(w, http.StatusBadRequest, "error", fmt.Sprintf("%s takes %d arguments, not %d", name, t.NumIn(), len(raw)))
+		return
+	}
+	args := make([]reflect.Value, len(raw))
+	for i, arg := range raw {
+		v := reflect.New(t.In(i))
+		if err := json.Unmarshal(arg, v.Interface()); err != nil {
+			answer(w, http.StatusBadRequest, "error", fmt.Sprintf("argument %d of %s: %s", i+1, name, err))
+			return
+		}
+		args[i] = v.Elem()
+	}
+	outs := method.Call(args)
+	if n := len(outs); n > 0 && t.Out(n-1) == errorType {
+		if err, _ := outs[n-1].Interface().(error); err != nil {
+			answer(w, http.StatusInternalServerError, "error", err.Error())
+			return
+		}
+		outs = outs[:n-1]
+	}
+	var result interface{}
+	if len(outs) > 0 {
+		result = outs[0].Interface()
+	}
+	answer(w, http.StatusOK, "result", result)
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// answer writes {key: value} as the JSON body of the response.
+func answer(w http.ResponseWriter, status int, key string, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{key: value})
+}
+
+// Hub sends the events of the backend to every browser listening, as
+// server-sent events.
+type Hub struct {
+	mu      sync.Mutex
+	clients map[chan []byte]bool
+}
+
+// NewHub returns a hub without listeners.
+func NewHub() *Hub {
+	return &Hub{clients: make(map[chan []byte]bool)}
+}
+
+// event is an event as the bridge script reads it.
+type event struct {
+	Name string        `json:"name"`
+	Data []interface{} `json:"data"`
+}
+
+// Emit sends the event name with its data to the browsers listening. A
+// browser too slow to keep up misses the events it has no room left for.
+func (h *Hub) Emit(name string, data ...interface{}) {
+	if data == nil {
+		data = []interface{}{}
+	}
+	msg, err := json.Marshal(event{name, data})
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		select {
+		case c <- msg:
+		default:
+		}
+	}
+}
+
+func (h *Hub) subscribe() chan []byte {
+	c := make(chan []byte, 1024)
+	h.mu.Lock()
+	h.clients[c] = true
+	h.mu.Unlock()
+	return c
+}
+
+func (h *Hub) unsubscribe(c chan []byte) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+}
+
+// ServeHTTP streams the events emitted from now on, until the browser goes
+// away.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	c := h.subscribe()
+	defer h.unsubscribe(c)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case msg := <-c:
+			fmt.Fprintf(w, "data: %s\n\n", msg)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// bridge gives the frontend in a browser the window.go.main.Backend and the
+// window.runtime events wails gives it in the native window, on top of the
+// API of the server, which the browser calls with the TokenCookie.
+const bridge = `(function () {
+  function call(name, args) {
+    return fetch('/api/call/' + name, {
+      method: 'POST',
+      headers: {'Content-Type': 'application/json'},
+      body: JSON.stringify(args)
+    }).then(function (response) {
+      return response.json().then(function (body) {
+        if (!response.ok) {
+          throw body.error;
+        }
+        return body.result;
+      });
+    });
+  }
+  var backend = new Proxy({}, {
+    get: function (target, name) {
+      return function () {
+        return call(name, Array.prototype.slice.call(arguments));
+      };
+    }
+  });
+  window.go = {main: {Backend: backend}};
+
+  var listeners = {};
+  new EventSource('/api/events').onmessage = function (e) {
+    var event = JSON.parse(e.data);
+    (listeners[event.name] || []).slice().forEach(function (f) {
+      f.apply(null, event.data);
+    });
+  };
+  window.runtime = {
+    EventsOn: function (name, f) {
+      (listeners[name] = listeners[name] || []).push(f);
+      return function () {
+        listeners[name] = (listeners[name] || []).filter(function (g) {
+          return g !== f;
+        });
+      };
+    },
+    EventsOff: function (name) {
+      delete listeners[name];
+    }
+  };
+})();
+`
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type api struct{}
+
+func (api) Add(a, b int) int { return a + b }
+
+func (api) Join(words []string, sep string) (string, error) {
+	if len(words) == 0 {
+		return "", errors.New("no words")
+	}
+	return strings.Join(words, sep), nil
+}
+
+func (api) Ping() {}
+
+// serve serves srv as it's reached at by the test server, returned.
+func serve(srv *Server) *httptest.Server {
+	s := httptest.NewServer(srv)
+	srv.Allow(strings.TrimPrefix(s.URL, "http://"))
+	return s
+}
+
+// get GETs the url with the token of srv.
+func get(srv *Server, url string) (*http.Response, error) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set(TokenHeader, srv.Token)
+	return http.DefaultClient.Do(req)
+}
+
+// post POSTs the body as JSON with the token of srv.
+func post(srv *Server, url, body string) (*http.Response, error) {
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TokenHeader, srv.Token)
+	return http.DefaultClient.Do(req)
+}
+
+func TestCall(t *testing.T) {
+	srv := New(api{}, core{}, fstest.MapFS{})
+	s := serve(srv)
+	defer s.Close()
+	for i, tt := range []struct {
+		method, body string
+		status       int
+		expected     string
+	}{
+		{"Add", `[1, 2]`, 200, `{"result":3}`},
+		{"Join", `[["a", "b"], "-"]`, 200, `{"result":"a-b"}`},
+		{"Join", `[[], "-"]`, 500, `{"error":"no words"}`},
+		{"Ping", ``, 200, `{"result":null}`},
+		{"Add", `[1]`, 400, `{"error":"Add takes 2 arguments, not 1"}`},
+		{"Add", `[1, "2"]`, 400, ""},
+		{"Add", `{}`, 400, ""},
+		{"Nope", `[]`, 404, `{"error":"no method Nope"}`},
+		{"", `[]`, 404, `{"error":"no method "}`},
+	} {
+		resp, err := post(srv, s.URL+"/api/call/"+tt.method, tt.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		result := strings.TrimSpace(string(body))
+		if resp.StatusCode != tt.status || tt.expected != "" && result != tt.expected {
+			t.Errorf("testcase: %d result: %d %s expected: %d %s", i, resp.StatusCode, result, tt.status, tt.expected)
+		}
+	}
+	if resp, err := get(srv, s.URL+"/api/call/Ping"); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("result: %v expected: GET refused", resp.Status)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	srv := New(api{}, core{}, fstest.MapFS{
+		"index.html":     {Data: []byte("<html><head><title>gtoc</title></head></html>")},
+		"static/main.js": {Data: []byte("main()")},
+	})
+	s := serve(srv)
+	defer s.Close()
+	for i, tt := range []struct {
+		path     string
+		expected string
+	}{
+		{"/", `<html><head><script src="/api/bridge.js"></script><title>gtoc</title></head></html>`},
+		{"/static/main.js", "main()"},
+		{"/api/bridge.js", bridge},
+	} {
+		resp, err := get(srv, s.URL+tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != tt.expected {
+			t.Errorf("testcase: %d result: %s expected: %s", i, body, tt.expected)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	srv := New(api{}, core{}, fstest.MapFS{})
+	s := serve(srv)
+	defer s.Close()
+	resp, err := get(srv, s.URL+"/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("result: %s expected: text/event-stream", ct)
+	}
+	// the headers come once the browser is listening
+	srv.Emit("run:exit", map[string]int{"code": 0})
+	srv.Emit("target", "1", "ls")
+	lines := bufio.NewScanner(resp.Body)
+	for i, expected := range []string{
+		`data: {"name":"run:exit","data":[{"code":0}]}`,
+		"",
+		`data: {"name":"target","data":["1","ls"]}`,
+	} {
+		if !lines.Scan() || lines.Text() != expected {
+			t.Errorf("testcase: %d result: %s expected: %s", i, lines.Text(), expected)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	srv := New(api{}, core{}, fstest.MapFS{"index.html": {Data: []byte("<html></html>")}})
+	s := serve(srv)
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+	if len(srv.Token) != 64 || New(api{}, core{}, nil).Token == srv.Token {
+		t.Errorf("result: %s expected: a token of its own", srv.Token)
+	}
+	for i, tt := range []struct {
+		method, path, host, origin, token, contentType string
+		status                                         int
+	}{
+		{"POST", "/api/call/Ping", host, "", srv.Token, "application/json", 200},
+		{"POST", "/api/call/Ping", host, "http://" + host, srv.Token, "application/json; charset=utf-8", 200},
+		{"POST", "/api/call/Ping", host, "", "", "application/json", 401},
+		{"POST", "/api/call/Ping", host, "", "nope", "application/json", 401},
+		// a form posted by another page
+		{"POST", "/api/call/Ping", host, "https://evil.example", srv.Token, "application/json", 403},
+		{"POST", "/api/call/Ping", host, "", srv.Token, "text/plain", 415},
+		// DNS rebinding
+		{"POST", "/api/call/Ping", "evil.example:80", "", srv.Token, "application/json", 403},
+		{"GET", "/api/bridge.js", "evil.example:80", "", "", "", 403},
+		{"GET", "/", "evil.example:80", "", "", "", 403},
+		{"GET", "/", host, "", "", "", 401},
+		{"GET", "/", host, "", srv.Token, "", 200},
+		{"GET", "/api/bridge.js", host, "", "", "", 401},
+		{"GET", "/api/bridge.js", host, "", srv.Token, "", 200},
+		{"GET", "/api/events", host, "", "", "", 401},
+		{"GET", "/?token=nope", host, "", "", "", 401},
+		{"POST", "/parse", host, "", "", "application/json", 401},
+		{"POST", "/validate", host, "", srv.Token, "text/plain", 415},
+		{"POST", "/runs", host, "", "", "application/json", 401},
+		{"POST", "/runs", host, "http://evil.example", srv.Token, "application/json", 403},
+		{"GET", "/runs/7", host, "", "", "", 401},
+		{"GET", "/runs/7", host, "", srv.Token, "", 200},
+	} {
+		req, _ := http.NewRequest(tt.method, s.URL+tt.path, strings.NewReader(`[]`))
+		req.Host = tt.host
+		for name, value := range map[string]string{"Origin": tt.origin, TokenHeader: tt.token, "Content-Type": tt.contentType} {
+			if value != "" {
+				req.Header.Set(name, value)
+			}
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("testcase: %d result: %d expected: %d", i, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestCookie(t *testing.T) {
+	srv := New(api{}, core{}, fstest.MapFS{"index.html": {Data: []byte("<html></html>")}})
+	s := serve(srv)
+	defer s.Close()
+	if strings.Contains(bridge, srv.Token) {
+		t.Errorf("result: %s expected: no token in the bridge", bridge)
+	}
+	// the browser opens the URL of the server, and is sent on without the
+	// token
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(srv.URL(strings.TrimPrefix(s.URL, "http://")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	cookies := resp.Cookies()
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/" || len(cookies) != 1 {
+		t.Fatalf("result: %d %s %v expected: a redirection to / with the cookie", resp.StatusCode, resp.Header.Get("Location"), cookies)
+	}
+	if c := cookies[0]; c.Name != TokenCookie || c.Value != srv.Token || !c.HttpOnly || c.SameSite != http.SameSiteStrictMode {
+		t.Errorf("result: %v expected: an HttpOnly SameSite cookie of the token", c)
+	}
+	// the page, its bridge and what the bridge calls carry the cookie
+	for i, tt := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/", "", 200},
+		{"GET", "/api/bridge.js", "", 200},
+		{"POST", "/api/call/Ping", `[]`, 200},
+		{"POST", "/runs", `{"command": "ls"}`, 201},
+	} {
+		req, _ := http.NewRequest(tt.method, s.URL+tt.path, strings.NewReader(tt.body))
+		if tt.method == "POST" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.AddCookie(cookies[0])
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("testcase: %d result: %d expected: %d", i, resp.StatusCode, tt.status)
+		}
+	}
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/api/events", nil)
+	req.AddCookie(&http.Cookie{Name: TokenCookie, Value: "nope"})
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("result: %v %v expected: a wrong cookie refused", resp.Status, err)
+	}
+}
+
+func TestHosts(t *testing.T) {
+	for i, tt := range []struct {
+		addr     string
+		expected []string
+	}{
+		{"127.0.0.1:8080", []string{"localhost:8080", "127.0.0.1:8080", "[::1]:8080"}},
+		{"localhost:80", []string{"localhost:80", "localhost", "127.0.0.1:80", "127.0.0.1", "[::1]:80", "[::1]"}},
+		{"gtoc.lan:8080", []string{"gtoc.lan:8080"}},
+	} {
+		result, err := Hosts(tt.addr)
+		if err != nil || !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("testcase: %d result: %v %v expected: %v", i, result, err, tt.expected)
+		}
+	}
+	all, err := Hosts(":8080")
+	if err != nil || len(all) < 4 || all[0] != "localhost:8080" {
+		t.Errorf("result: %v %v expected: the loopback names and those of the machine", all, err)
+	}
+	if _, err = Hosts("8080"); err == nil {
+		t.Errorf("result: no error expected: no port")
+	}
+}
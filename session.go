@@ -0,0 +1,683 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gtoc/docopt"
+	"gtoc/form"
+	"gtoc/runner"
+	"gtoc/store"
+	"go.uber.org/zap"
+)
+
+// Session is a tab of the GUI as the frontend reads it: the command it's
+// built for, the values of its form and its layout, see SetLayout. CanUndo
+// and CanRedo tell whether Undo and Redo have values to go back and forth
+// to.
+type Session struct {
+	ID      string                 `json:"id"`
+	Target  string                 `json:"target"`
+	Values  map[string]interface{} `json:"values"`
+	Layout  map[string]interface{} `json:"layout"`
+	CanUndo bool                   `json:"canUndo"`
+	CanRedo bool                   `json:"canRedo"`
+}
+
+// max_undo is how many values of its form a session keeps to undo.
+const max_undo = 100
+
+// session is the state of a tab, kept apart from the others': its target
+// and pattern, the index of the fields of its form, the values of its form
+// along with the ones before and after them to undo and redo, its layout,
+// and the jobs and batches it started.
+type session struct {
+	target  string
+	pattern *docopt.Pattern
+	index   *form.Index
+	values  map[string]interface{}
+	undo    []map[string]interface{}
+	redo    []map[string]interface{}
+	layout  map[string]interface{}
+	jobs    map[string]bool
+}
+
+// set makes command, whose pattern is pat, the target of the session,
+// indexing its fields. The values of the form are dropped, with those to
+// undo and redo, as they were for the previous target.
+func (s *session) set(command string, pat *docopt.Pattern) {
+	s.target, s.pattern, s.index = command, pat, nil
+	if pat != nil {
+		s.index = form.NewIndex(pat)
+	}
+	s.values = make(map[string]interface{})
+	s.undo, s.redo = nil, nil
+}
+
+// change makes values the values of the form, the ones they replace kept
+// to undo, unless they're the same.
+func (s *session) change(values map[string]interface{}) {
+	if reflect.DeepEqual(values, s.values) {
+		return
+	}
+	s.undo = append(s.undo, s.values)
+	if len(s.undo) > max_undo {
+		s.undo = s.undo[len(s.undo)-max_undo:]
+	}
+	s.redo = nil
+	s.values = values
+}
+
+// back puts back the values the form had before they were last changed,
+// the ones they replace kept to redo, and tells whether there were any.
+func (s *session) back() bool {
+	n := len(s.undo)
+	if n == 0 {
+		return false
+	}
+	s.redo = append(s.redo, s.values)
+	s.values, s.undo = s.undo[n-1], s.undo[:n-1]
+	return true
+}
+
+// forward puts back the values back replaced, the ones they replace kept
+// to undo, and tells whether there were any.
+func (s *session) forward() bool {
+	n := len(s.redo)
+	if n == 0 {
+		return false
+	}
+	s.undo = append(s.undo, s.values)
+	s.values, s.redo = s.redo[n-1], s.redo[:n-1]
+	return true
+}
+
+// open adds a session of the target, already probed for pat, and returns
+// its ID. b.mu must be held.
+func (b *Backend) open(target string, pat *docopt.Pattern) string {
+	b.opened++
+	id := strconv.Itoa(b.opened)
+	s := &session{layout: make(map[string]interface{}), jobs: make(map[string]bool)}
+	s.set(target, pat)
+	b.sessions[id] = s
+	return id
+}
+
+// session returns the session sessionID. b.mu must be held.
+func (b *Backend) session(sessionID string) (*session, error) {
+	s, ok := b.sessions[sessionID]
+	if !ok {
+		return nil, b.errorf("error.noSession", sessionID)
+	}
+	return s, nil
+}
+
+// target returns the target of the session sessionID and its pattern, nil
+// if it has none.
+func (b *Backend) target(sessionID string) (string, *docopt.Pattern, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, err := b.session(sessionID)
+	if err != nil {
+		return "", nil, err
+	}
+	return s.target, s.pattern, nil
+}
+
+// snapshot returns the DTO of the session. b.mu must be held.
+func (s *session) snapshot(id string) Session {
+	return Session{
+		ID:      id,
+		Target:  s.target,
+		Values:  copy_map(s.values),
+		Layout:  copy_map(s.layout),
+		CanUndo: len(s.undo) > 0,
+		CanRedo: len(s.redo) > 0,
+	}
+}
+
+// copy_map returns a shallow copy of m.
+func copy_map(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// owns tells whether the job or batch id was started by the session, a job
+// of a batch being owned with its batch.
+func (s *session) owns(id string) bool {
+	if s.jobs[id] {
+		return true
+	}
+	i := strings.IndexByte(id, '.')
+	return i > 0 && s.jobs[id[:i]]
+}
+
+// retried makes the job id, a retry of the job of, owned by the session of
+// that one. It's called as the retry starts.
+func (b *Backend) retried(of, id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.sessions {
+		if s.owns(of) {
+			s.jobs[id] = true
+		}
+	}
+}
+
+// started records the job or batch id the session sessionID started, unless
+// starting it failed with err. It returns id and err, for the bindings to
+// return as is.
+func (b *Backend) started(sessionID string, id string, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// a session closed meanwhile leaves the job to the others
+	if s, ok := b.sessions[sessionID]; ok {
+		s.jobs[id] = true
+	}
+	return id, nil
+}
+
+// OpenSession opens a session for a new tab and returns it. Its target is
+// probed for its pattern unless it's "", left for the user to pick, see
+// SetTarget, and the window is laid out as it was last for it.
+func (b *Backend) OpenSession(target string) (Session, error) {
+	var pat *docopt.Pattern
+	if target != "" {
+		var err error
+		if pat, err = b.probe(target); err != nil {
+			return Session{}, err
+		}
+		b.used(target)
+	}
+	b.mu.Lock()
+	id := b.open(target, pat)
+	session := b.sessions[id].snapshot(id)
+	b.mu.Unlock()
+	b.watch()
+	if target != "" {
+		b.lay_out(target)
+	}
+	return session, nil
+}
+
+// CloseSession closes the session of a tab, canceling the jobs and batches
+// it started that are still running.
+func (b *Backend) CloseSession(sessionID string) error {
+	b.mu.Lock()
+	s, err := b.session(sessionID)
+	if err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	delete(b.sessions, sessionID)
+	b.mu.Unlock()
+	b.watch()
+	for id := range s.jobs {
+		// the finished ones can't be canceled, which is fine
+		if strings.HasPrefix(id, "b") {
+			b.runner.CancelBatch(id)
+		} else {
+			b.runner.Cancel(id)
+		}
+	}
+	return nil
+}
+
+// ListSessions returns the open sessions, in the order they were opened.
+func (b *Backend) ListSessions() []Session {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	list := make([]Session, 0, len(b.sessions))
+	for id, s := range b.sessions {
+		list = append(list, s.snapshot(id))
+	}
+	sort.Slice(list, func(i, k int) bool {
+		a, _ := strconv.Atoi(list[i].ID)
+		b, _ := strconv.Atoi(list[k].ID)
+		return a < b
+	})
+	return list
+}
+
+// GetSession returns the session sessionID.
+func (b *Backend) GetSession(sessionID string) (Session, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, err := b.session(sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+	return s.snapshot(sessionID), nil
+}
+
+// SessionPattern returns the pattern of the target of the session, as it
+// was probed when the target was set, nil if there's no target yet.
+func (b *Backend) SessionPattern(sessionID string) (*PatternNode, error) {
+	_, pat, err := b.target(sessionID)
+	if err != nil || pat == nil {
+		return nil, err
+	}
+	return pattern_node(pat), nil
+}
+
+// SetValues keeps the values of the form of the session, keyed as in the
+// pattern, for the tab to get them back when it's shown again. The values
+// they replace can be had back with Undo.
+func (b *Backend) SetValues(sessionID string, values map[string]interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, err := b.session(sessionID)
+	if err != nil {
+		return err
+	}
+	kept := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		kept[k] = v
+	}
+	s.change(kept)
+	return nil
+}
+
+// SetLayout keeps the layout of the tab of the session, such as the panels
+// it shows, for the frontend to lay it out again when it's shown, after a
+// restart too, see restore. What it holds is up to the frontend.
+func (b *Backend) SetLayout(sessionID string, layout map[string]interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, err := b.session(sessionID)
+	if err != nil {
+		return err
+	}
+	s.layout = copy_map(layout)
+	return nil
+}
+
+// restore opens the sessions saved as gtoc last exited, after the one it was
+// started with, which is closed if it has no target. Their targets are
+// probed anew, those whose pattern can't be had anymore left for the user
+// to pick again, and the values that don't fit their pattern are dropped.
+// Their patterns are kept as snapshots of the builds of their programs, for
+// those updated while gtoc was closed, see snapshot.
+// The sessions are emitted as a "sessions" event carrying them all, for the
+// frontend to open their tabs.
+func (b *Backend) restore() {
+	saved := b.saved.List()
+	if len(saved) == 0 {
+		return
+	}
+	b.mu.Lock()
+	for id, s := range b.sessions {
+		if s.target == "" {
+			delete(b.sessions, id)
+		}
+	}
+	b.mu.Unlock()
+	for _, ss := range saved {
+		var pat *docopt.Pattern
+		values := ss.Values
+		if ss.Target != "" {
+			var err error
+			if pat, err = get_pattern_context(context.Background(), ss.Target, nil); err != nil {
+				zap.S().Warnf("Restoring the session of %s failed: %s", ss.Target, err)
+				ss.Target, values = "", nil
+			} else {
+				go b.snapshot(ss.Target, pat)
+				if values, _, err = form.Migrate(values, pat, pat); err != nil {
+					values = nil
+				}
+			}
+		}
+		b.mu.Lock()
+		s := b.sessions[b.open(ss.Target, pat)]
+		if values != nil {
+			s.values = values
+		}
+		if ss.Layout != nil {
+			s.layout = ss.Layout
+		}
+		b.mu.Unlock()
+	}
+	b.view.Emit("sessions", b.ListSessions())
+}
+
+// save saves the open sessions as gtoc exits, for restore to open them
+// again on the next launch.
+func (b *Backend) save() error {
+	if b.saved == nil {
+		return nil
+	}
+	var saved []store.SavedSession
+	for _, s := range b.ListSessions() {
+		saved = append(saved, store.SavedSession{Target: s.Target, Values: s.Values, Layout: s.Layout})
+	}
+	return b.saved.Save(saved)
+}
+
+// Undo puts back the values the form of the session had before they were
+// last set, and returns the session with them, for the form to show. The
+// session keeps the last 100 values of its form, for as long as it's open
+// and its target stays the same, so a reload of the frontend doesn't lose
+// them. With nothing to undo the session is returned as is.
+func (b *Backend) Undo(sessionID string) (Session, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, err := b.session(sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+	s.back()
+	return s.snapshot(sessionID), nil
+}
+
+// Redo puts back the values of the form of the session that Undo replaced,
+// and returns the session with them. Setting values in between drops what
+// there was to redo. With nothing to redo the session is returned as is.
+func (b *Backend) Redo(sessionID string) (Session, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, err := b.session(sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+	s.forward()
+	return s.snapshot(sessionID), nil
+}
+
+// SearchFields returns the IDs of the fields of the form of the session
+// whose names, aliases or descriptions match query, the best first and at
+// most limit of them, for the filter box of the form. The fields are indexed
+// as the target is set.
+func (b *Backend) SearchFields(sessionID string, query string, limit int) ([]string, error) {
+	b.mu.Lock()
+	s, err := b.session(sessionID)
+	var index *form.Index
+	if err == nil {
+		index = s.index
+	}
+	b.mu.Unlock()
+	if index == nil {
+		return []string{}, err
+	}
+	return index.Search(query, limit), nil
+}
+
+// CommandPreview is the command line the values of the form of a session
+// make, see PreviewCommand. Error tells why there's none when the values
+// don't fit the pattern of the target.
+type CommandPreview struct {
+	Argv  []string `json:"argv,omitempty"`
+	Line  string   `json:"line,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// PreviewCommand returns the command line the values make for the target of
+// the session, as an argv and as a shell-quoted line, for the frontend to
+// show what will run as the form changes. The pattern probed with the
+// target is used, so it's cheap enough to call on every change. The values
+// are kept as the form of the session, as with SetValues. Values that don't
+// fit make a preview with an Error, rather than an error, which tells the
+// session has no target.
+func (b *Backend) PreviewCommand(sessionID string, values map[string]interface{}) (CommandPreview, error) {
+	if err := b.SetValues(sessionID, values); err != nil {
+		return CommandPreview{}, err
+	}
+	target, pat, err := b.target(sessionID)
+	if err != nil {
+		return CommandPreview{}, err
+	}
+	if pat == nil {
+		return CommandPreview{}, b.errorf("error.noTarget", sessionID)
+	}
+	preview, err := runner.DryRun(runner.Request{
+		Program: target,
+		Pattern: pat,
+		Values:  runner.DecodeValues(values),
+	})
+	if err != nil {
+		return CommandPreview{Error: err.Error()}, nil
+	}
+	return CommandPreview{Argv: preview.Argv, Line: preview.Line}, nil
+}
+
+// CopyCommandLine copies the command line the values make for the target of
+// the session to the clipboard, shell-quoted as PreviewCommand makes it, for
+// the user to paste in a terminal. It returns the line copied.
+func (b *Backend) CopyCommandLine(sessionID string, values map[string]interface{}) (string, error) {
+	preview, err := b.dry_run(sessionID, values)
+	if err != nil {
+		return "", err
+	}
+	return preview.Line, b.view.Copy(preview.Line)
+}
+
+// CopyArgv is like CopyCommandLine, but copies the argv of the command line
+// as a JSON array, the program first, for scripts and tickets.
+func (b *Backend) CopyArgv(sessionID string, values map[string]interface{}) (string, error) {
+	preview, err := b.dry_run(sessionID, values)
+	if err != nil {
+		return "", err
+	}
+	argv, err := json.Marshal(preview.Argv)
+	if err != nil {
+		return "", err
+	}
+	return string(argv), b.view.Copy(string(argv))
+}
+
+// dry_run returns the command line the values make for the target of the
+// session, see runner.DryRun.
+func (b *Backend) dry_run(sessionID string, values map[string]interface{}) (runner.Preview, error) {
+	target, pat, err := b.target(sessionID)
+	if err != nil {
+		return runner.Preview{}, err
+	}
+	if pat == nil {
+		return runner.Preview{}, b.errorf("error.noTarget", sessionID)
+	}
+	return runner.DryRun(runner.Request{
+		Program: target,
+		Pattern: pat,
+		Values:  runner.DecodeValues(values),
+	})
+}
+
+// for_session returns the request run for the session, with the pattern of
+// its target if that's the command run on the host: the run then fits the
+// form the user filled in, even once its pattern was corrected or fetched,
+// see FetchPattern, rather than the command probed anew.
+func (b *Backend) for_session(sessionID string, req RunRequest) (RunRequest, error) {
+	target, pat, err := b.target(sessionID)
+	if err != nil {
+		return RunRequest{}, err
+	}
+	if req.Command == target && req.Container == nil {
+		req.pattern = pat
+	}
+	return req, nil
+}
+
+// Run is Jobs.Run for the session, which owns the job.
+func (b *Backend) Run(sessionID string, req RunRequest) (string, error) {
+	req, err := b.for_session(sessionID, req)
+	if err != nil {
+		return "", err
+	}
+	id, err := b.Jobs.Run(req)
+	return b.started(sessionID, id, err)
+}
+
+// RunTerminal is Jobs.RunTerminal for the session, which owns the job.
+func (b *Backend) RunTerminal(sessionID string, req RunRequest, rows, cols int) (string, error) {
+	req, err := b.for_session(sessionID, req)
+	if err != nil {
+		return "", err
+	}
+	id, err := b.Jobs.RunTerminal(req, rows, cols)
+	return b.started(sessionID, id, err)
+}
+
+// Enqueue is Jobs.Enqueue for the session, which owns the batch.
+func (b *Backend) Enqueue(sessionID string, reqs []RunRequest, parallel int) (string, error) {
+	if _, err := b.GetSession(sessionID); err != nil {
+		return "", err
+	}
+	runs := make([]RunRequest, len(reqs))
+	for i, req := range reqs {
+		var err error
+		if runs[i], err = b.for_session(sessionID, req); err != nil {
+			return "", err
+		}
+	}
+	id, err := b.Jobs.Enqueue(runs, parallel)
+	return b.started(sessionID, id, err)
+}
+
+// EnqueueEach is Jobs.EnqueueEach for the session, which owns the batch.
+func (b *Backend) EnqueueEach(sessionID string, req RunRequest, key string, values []interface{}, parallel int) (string, error) {
+	req, err := b.for_session(sessionID, req)
+	if err != nil {
+		return "", err
+	}
+	id, err := b.Jobs.EnqueueEach(req, key, values, parallel)
+	return b.started(sessionID, id, err)
+}
+
+// ListJobs returns the jobs the session started, queued ones included, for
+// the jobs panel of its tab.
+func (b *Backend) ListJobs(sessionID string) ([]runner.JobStatus, error) {
+	all := b.Jobs.ListJobs()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, err := b.session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	list := []runner.JobStatus{}
+	for _, job := range all {
+		if s.owns(job.ID) {
+			list = append(list, job)
+		}
+	}
+	return list, nil
+}
+
+// ListBatches returns the status of the batches the session queued.
+func (b *Backend) ListBatches(sessionID string) ([]runner.BatchStatus, error) {
+	all := b.Jobs.ListBatches()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, err := b.session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	list := []runner.BatchStatus{}
+	for _, status := range all {
+		if s.owns(status.ID) {
+			list = append(list, status)
+		}
+	}
+	return list, nil
+}
+
+// focus is a field of the form of a session, the ID of the field "" for none.
+type focus struct {
+	session, field string
+}
+
+// FocusField tells the field of the form of the session the user is on, ""
+// for none, for the frontend to call whenever a field gains the focus or
+// another tab is shown. The files dropped on the window go to it, or to the
+// list of files of the form, see form.Drop.
+func (b *Backend) FocusField(sessionID, fieldID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, err := b.session(sessionID); err != nil {
+		return err
+	}
+	b.focus = focus{sessionID, fieldID}
+	return nil
+}
+
+// DropEvent is the session whose form got the files dropped on the window,
+// with the values of its form, and the ID of the field they went to.
+type DropEvent struct {
+	Session Session `json:"session"`
+	Field   string  `json:"field"`
+}
+
+// dropped is called with the paths of the files dropped on the window. It
+// puts them in the form of the tab the user is on and emits the session as a
+// "drop" event carrying a DropEvent, or why they couldn't as a "drop:error"
+// event. The values they replace can be had back with Undo.
+func (b *Backend) dropped(x, y int, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	event, err := b.drop(paths)
+	if err != nil {
+		b.view.Emit("drop:error", err.Error())
+		return
+	}
+	b.view.Emit("drop", event)
+}
+
+// drop puts the paths, made absolute, in the field they go to, appended to
+// the paths a list holds already.
+func (b *Backend) drop(paths []string) (DropEvent, error) {
+	abs := make([]interface{}, len(paths))
+	dirs := true
+	for i, path := range paths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return DropEvent{}, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return DropEvent{}, err
+		}
+		abs[i], dirs = path, dirs && info.IsDir()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.focus.session
+	s, err := b.session(id)
+	if err != nil {
+		return DropEvent{}, err
+	}
+	if s.pattern == nil {
+		return DropEvent{}, b.errorf("error.noTarget", id)
+	}
+	spec, err := form.Build(s.pattern)
+	if err != nil {
+		return DropEvent{}, err
+	}
+	f, ok := form.Drop(spec.Fields, b.focus.field, len(abs), dirs)
+	if !ok {
+		return DropEvent{}, b.errorf("error.noDropTarget")
+	}
+	values := make(map[string]interface{}, len(s.values)+1)
+	for k, v := range s.values {
+		values[k] = v
+	}
+	if f.Multiple {
+		// lists come from the frontend as JSON arrays
+		list, _ := values[f.ID].([]interface{})
+		values[f.ID] = append(append([]interface{}{}, list...), abs...)
+	} else {
+		values[f.ID] = abs[0]
+	}
+	s.change(values)
+	return DropEvent{s.snapshot(id), f.ID}, nil
+}
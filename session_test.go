@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"gtoc/docopt"
+	"gtoc/runner"
+)
+
+func TestUndo(t *testing.T) {
+	s := &session{}
+	s.set("ls", nil)
+	a := map[string]interface{}{"-l": true}
+	b := map[string]interface{}{"-l": true, "<file>": "a"}
+	s.change(a)
+	s.change(b)
+	s.change(map[string]interface{}{"-l": true, "<file>": "a"})
+	if len(s.undo) != 2 {
+		t.Errorf("result: %d expected: the same values not kept to undo", len(s.undo))
+	}
+
+	if !s.back() || !reflect.DeepEqual(s.values, a) {
+		t.Errorf("result: %v expected: %v", s.values, a)
+	}
+	if !s.back() || len(s.values) != 0 || s.back() {
+		t.Errorf("result: %v expected: the empty form, with nothing more to undo", s.values)
+	}
+	if !s.forward() || !reflect.DeepEqual(s.values, a) || len(s.redo) != 1 {
+		t.Errorf("result: %v expected: %v", s.values, a)
+	}
+
+	// setting values drops what there is to redo
+	c := map[string]interface{}{"-a": true}
+	s.change(c)
+	if s.forward() || !reflect.DeepEqual(s.values, c) || !s.snapshot("1").CanUndo || s.snapshot("1").CanRedo {
+		t.Errorf("result: %v expected: nothing to redo", s.values)
+	}
+
+	// so does setting the target
+	s.back()
+	s.set("ls", nil)
+	if s.back() || s.forward() {
+		t.Errorf("result: %d %d expected: nothing to undo or redo", len(s.undo), len(s.redo))
+	}
+
+	for i := 0; i <= max_undo+10; i++ {
+		s.change(map[string]interface{}{"<n>": i})
+	}
+	if len(s.undo) != max_undo || s.undo[0]["<n>"] != 10 {
+		t.Errorf("result: %d kept from %v expected: the last %d", len(s.undo), s.undo[0], max_undo)
+	}
+	for s.back() {
+	}
+	if !reflect.DeepEqual(s.values, map[string]interface{}{"<n>": 10}) {
+		t.Errorf("result: %v expected: the oldest values kept", s.values)
+	}
+}
+
+func TestForSession(t *testing.T) {
+	pat, err := docopt.ParsePattern("Usage: ls [-l] [<file>...]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBackend("ls", pat)
+	for i, tt := range []struct {
+		req      RunRequest
+		expected *docopt.Pattern
+	}{
+		{RunRequest{Command: "ls"}, pat},
+		{RunRequest{Command: "cat"}, nil},
+		{RunRequest{Command: "ls", Container: &runner.Container{Image: "alpine"}}, nil},
+	} {
+		req, err := b.for_session("1", tt.req)
+		if err != nil || req.pattern != tt.expected {
+			t.Errorf("testcase: %d result: %v %v expected: %v", i, req.pattern, err, tt.expected)
+		}
+	}
+}
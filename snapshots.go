@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gtoc/docopt"
+	"gtoc/form"
+	"gtoc/runner"
+	"gtoc/store"
+	"gtoc/watch"
+	"go.uber.org/zap"
+)
+
+// snapshot_timeout bounds asking the program of a command for its version,
+// for a snapshot.
+const snapshot_timeout = 10 * time.Second
+
+// StaleProfiles are the profiles of a command that use values the pattern
+// of a new build of its program doesn't have, by name, each with the values.
+type StaleProfiles struct {
+	Command  string              `json:"command"`
+	Version  string              `json:"version"`
+	Profiles map[string][]string `json:"profiles"`
+}
+
+// snapshot keeps the pattern of the command for the build of its program,
+// its path, version and hash, unless it's kept already, see store.Snapshots.
+// The snapshots of the builds before are kept as they were. The profiles of
+// the command that use values the pattern doesn't have are flagged in the
+// snapshot, and emitted as a "profiles:stale" event carrying a StaleProfiles
+// if it's new. What changed since the snapshot before is emitted as a
+// "pattern:changed" event carrying a ChangeReport, if anything did.
+func (b *Backend) snapshot(command string, pat *docopt.Pattern) {
+	if b.snapshots == nil {
+		return
+	}
+	if err := b.take_snapshot(command, pat); err != nil {
+		zap.S().Warnf("Taking a snapshot of the pattern of %s failed: %s", command, err)
+	}
+}
+
+func (b *Backend) take_snapshot(command string, pat *docopt.Pattern) error {
+	path, err := watch.Program(command)
+	if err != nil {
+		return err
+	}
+	hash, err := watch.Sum(path)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), snapshot_timeout)
+	defer cancel()
+	_, version, err := tool_version(ctx, command)
+	if err != nil {
+		return err
+	}
+	data, err := docopt.MarshalPattern(pat)
+	if err != nil {
+		return err
+	}
+	stale, err := b.profiles.Stale(command, pat)
+	if err != nil {
+		return err
+	}
+	snap := store.Snapshot{Command: command, Path: path, Version: version, Hash: hash, Taken: time.Now(), Pattern: data}
+	if len(stale) > 0 {
+		snap.Stale = stale
+	}
+	previous, had := b.snapshots.Latest(command)
+	added, err := b.snapshots.Add(snap)
+	if err != nil || !added {
+		return err
+	}
+	if len(stale) > 0 {
+		b.view.Emit("profiles:stale", StaleProfiles{command, version, stale})
+	}
+	if had {
+		report, err := b.change_report(previous, snap)
+		if err != nil {
+			return err
+		}
+		if report != nil {
+			b.view.Emit("pattern:changed", report)
+		}
+	}
+	return nil
+}
+
+// ListSnapshots returns the snapshots of the pattern of command, one for
+// every build of its program it was parsed for, the oldest first.
+func (b *Backend) ListSnapshots(command string) []store.Snapshot {
+	if b.snapshots == nil {
+		return nil
+	}
+	return b.snapshots.List(command)
+}
+
+// ChangeReport is what changed in the pattern of a command from a build of
+// its program to the next, From and To being the versions they told. The
+// title and the text of the changes are in the language of the settings.
+type ChangeReport struct {
+	Command string           `json:"command"`
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	Title   string           `json:"title"`
+	Changes []ReportedChange `json:"changes"`
+}
+
+// ReportedChange is a change, see form.Change, told in words.
+type ReportedChange struct {
+	form.Change
+	Text string `json:"text"`
+}
+
+// change_report returns what changed from the snapshot before to after, nil
+// if nothing did.
+func (b *Backend) change_report(before, after store.Snapshot) (*ChangeReport, error) {
+	old, err := docopt.UnmarshalPattern(before.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	now, err := docopt.UnmarshalPattern(after.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	changes := form.Changes(old, now)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	words, err := runner.Split(after.Command)
+	if err != nil {
+		return nil, err
+	}
+	locale := b.locale()
+	r := &ChangeReport{
+		Command: after.Command,
+		From:    before.Version,
+		To:      after.Version,
+		Title:   strings.TrimSpace(b.catalog.Sprintf(locale, "changes.title", tool_of(words), after.Version)),
+	}
+	for _, c := range changes {
+		var text string
+		switch c.Kind {
+		case form.Renamed:
+			text = b.catalog.Sprintf(locale, "changes.renamed", c.From, c.To)
+		case form.Default:
+			text = b.catalog.Sprintf(locale, "changes.default", c.Name, c.From, c.To)
+		default:
+			text = b.catalog.Sprintf(locale, "changes."+string(c.Kind), c.Name)
+		}
+		if c.Subcommand != "" {
+			text = c.Subcommand + ": " + text
+		}
+		r.Changes = append(r.Changes, ReportedChange{c, text})
+	}
+	return r, nil
+}
+
+// GetChangeReport returns what changed in the pattern of command between the
+// last two builds of its program snapshotted, nil if it has fewer snapshots
+// or nothing changed, see snapshot.
+func (b *Backend) GetChangeReport(command string) (*ChangeReport, error) {
+	list := b.ListSnapshots(command)
+	if len(list) < 2 {
+		return nil, nil
+	}
+	return b.change_report(list[len(list)-2], list[len(list)-1])
+}
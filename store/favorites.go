@@ -0,0 +1,91 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Favorite is a command the user keeps at hand.
+type Favorite struct {
+	Command string `json:"command"`
+	// Label is shown instead of the command if set
+	Label string `json:"label"`
+	// Icon is the name of an icon of the frontend, its default if empty
+	Icon string `json:"icon"`
+}
+
+// Favorites are the favorite commands, in the order the user put them.
+type Favorites struct {
+	file *File
+
+	mu        sync.Mutex
+	favorites []Favorite
+}
+
+// OpenFavorites loads the favorites saved at path.
+func OpenFavorites(path string) (*Favorites, error) {
+	f := &Favorites{file: &File{Path: path}}
+	if err := f.file.Load(&f.favorites); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// List returns the favorites in order.
+func (f *Favorites) List() []Favorite {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Favorite{}, f.favorites...)
+}
+
+// Add appends the favorite and saves the favorites. If its command is a
+// favorite already, the label and icon of that one are replaced in place.
+func (f *Favorites) Add(fav Favorite) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i := f.index(fav.Command); i >= 0 {
+		f.favorites[i] = fav
+	} else {
+		f.favorites = append(f.favorites, fav)
+	}
+	return f.file.Save(f.favorites)
+}
+
+// Remove removes the favorite of command and saves the favorites.
+func (f *Favorites) Remove(command string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i := f.index(command); i >= 0 {
+		f.favorites = append(f.favorites[:i], f.favorites[i+1:]...)
+	}
+	return f.file.Save(f.favorites)
+}
+
+// Move moves the favorite of command to position to, counted from 0 and
+// clamped to the list, and saves the favorites.
+func (f *Favorites) Move(command string, to int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := f.index(command)
+	if i < 0 {
+		return fmt.Errorf("%q isn't a favorite", command)
+	}
+	fav := f.favorites[i]
+	f.favorites = append(f.favorites[:i], f.favorites[i+1:]...)
+	if to < 0 {
+		to = 0
+	} else if to > len(f.favorites) {
+		to = len(f.favorites)
+	}
+	f.favorites = append(f.favorites[:to], append([]Favorite{fav}, f.favorites[to:]...)...)
+	return f.file.Save(f.favorites)
+}
+
+func (f *Favorites) index(command string) int {
+	for i, fav := range f.favorites {
+		if fav.Command == command {
+			return i
+		}
+	}
+	return -1
+}
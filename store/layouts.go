@@ -0,0 +1,68 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Layout is how the window is laid out for a command: its size, 0 by 0 if
+// it's unknown, the positions of the splits between its panels, as fractions
+// of the window by split, and the groups of options collapsed, by section.
+// The splits and groups are named by the frontend.
+type Layout struct {
+	Width     int                `json:"width"`
+	Height    int                `json:"height"`
+	Splits    map[string]float64 `json:"splits,omitempty"`
+	Collapsed []string           `json:"collapsed,omitempty"`
+}
+
+// Check returns why the layout can't be, nil if it can.
+func (l Layout) Check() error {
+	if (l.Width != 0 || l.Height != 0) && (l.Width < MinWidth || l.Height < MinHeight) {
+		return fmt.Errorf("a window of %dx%d is too small", l.Width, l.Height)
+	}
+	for name, at := range l.Splits {
+		if at < 0 || at > 1 {
+			return fmt.Errorf("split %s at %v is out of the window", name, at)
+		}
+	}
+	return nil
+}
+
+// Layouts are the layouts of the window for the commands, as it was last
+// laid out for them.
+type Layouts struct {
+	file *File
+
+	mu      sync.Mutex
+	layouts map[string]Layout
+}
+
+// OpenLayouts loads the layouts saved at path.
+func OpenLayouts(path string) (*Layouts, error) {
+	l := &Layouts{file: &File{Path: path}, layouts: map[string]Layout{}}
+	if err := l.file.Load(&l.layouts); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Get returns the layout of command, false if it has none.
+func (l *Layouts) Get(command string) (Layout, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	layout, ok := l.layouts[command]
+	return layout, ok
+}
+
+// Set checks the layout, makes it the layout of command and saves the
+// layouts.
+func (l *Layouts) Set(command string, layout Layout) error {
+	if err := layout.Check(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.layouts[command] = layout
+	return l.file.Save(l.layouts)
+}
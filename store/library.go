@@ -0,0 +1,109 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ParseOptions are how the help text of a command is probed.
+type ParseOptions struct {
+	// Help is the argument the command prints its help text with, such as
+	// "--help", "-h" or "help"; --help then -h are tried if it's empty
+	Help string `json:"help,omitempty"`
+	// Subcommands probes the subcommands the help text lists for theirs
+	Subcommands bool `json:"subcommands,omitempty"`
+}
+
+// Check returns why the options can't be, nil if they can.
+func (o ParseOptions) Check() error {
+	if strings.TrimSpace(o.Help) != o.Help || strings.ContainsAny(o.Help, " \t\n") {
+		return fmt.Errorf("the help argument %q isn't a single word", o.Help)
+	}
+	return nil
+}
+
+// LibraryEntry is a command the user made a GUI of, with how its help text
+// was probed and the pattern detected in it, as docopt.MarshalPattern
+// writes it.
+type LibraryEntry struct {
+	Command string `json:"command"`
+	// Label is shown instead of the command if set
+	Label   string          `json:"label,omitempty"`
+	Options ParseOptions    `json:"options"`
+	Pattern json.RawMessage `json:"pattern,omitempty"`
+}
+
+// Library are the commands the user made a GUI of, in the order they were
+// added.
+type Library struct {
+	file *File
+
+	mu      sync.Mutex
+	entries []LibraryEntry
+}
+
+// OpenLibrary loads the library saved at path.
+func OpenLibrary(path string) (*Library, error) {
+	l := &Library{file: &File{Path: path}}
+	if err := l.file.Load(&l.entries); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// List returns the entries in order.
+func (l *Library) List() []LibraryEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LibraryEntry{}, l.entries...)
+}
+
+// Get returns the entry of command, false if it has none.
+func (l *Library) Get(command string) (LibraryEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i := l.index(command); i >= 0 {
+		return l.entries[i], true
+	}
+	return LibraryEntry{}, false
+}
+
+// Add checks the entry, appends it and saves the library. If its command has
+// an entry already, that one is replaced in place.
+func (l *Library) Add(e LibraryEntry) error {
+	if e.Command == "" {
+		return fmt.Errorf("an entry of no command")
+	}
+	if err := e.Options.Check(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i := l.index(e.Command); i >= 0 {
+		l.entries[i] = e
+	} else {
+		l.entries = append(l.entries, e)
+	}
+	return l.file.Save(l.entries)
+}
+
+// Remove removes the entry of command and saves the library.
+func (l *Library) Remove(command string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i := l.index(command); i >= 0 {
+		l.entries = append(l.entries[:i], l.entries[i+1:]...)
+	}
+	return l.file.Save(l.entries)
+}
+
+func (l *Library) index(command string) int {
+	for i, e := range l.entries {
+		if e.Command == command {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxRecent is how many commands that aren't pinned Recent keeps, the ones
+// used last.
+const MaxRecent = 30
+
+// RecentCommand is a command parsed or run lately.
+type RecentCommand struct {
+	Command string    `json:"command"`
+	Used    time.Time `json:"used"`
+	// Pinned commands are listed first and never dropped for newer ones
+	Pinned bool `json:"pinned"`
+}
+
+// Recent are the commands used lately, for the GUI to offer on launch.
+type Recent struct {
+	file *File
+
+	mu       sync.Mutex
+	commands []RecentCommand
+}
+
+// OpenRecent loads the recent commands saved at path.
+func OpenRecent(path string) (*Recent, error) {
+	r := &Recent{file: &File{Path: path}}
+	if err := r.file.Load(&r.commands); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// List returns the recent commands, the pinned ones first, each the latest
+// used first.
+func (r *Recent) List() []RecentCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecentCommand{}, r.commands...)
+}
+
+// Use records command as used at now and saves the commands, dropping the
+// oldest ones not pinned past MaxRecent.
+func (r *Recent) Use(command string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := RecentCommand{Command: command}
+	if i := r.index(command); i >= 0 {
+		c = r.commands[i]
+		r.commands = append(r.commands[:i], r.commands[i+1:]...)
+	}
+	c.Used = now
+	r.commands = append(r.commands, c)
+	r.sort()
+	kept, unpinned := r.commands[:0], 0
+	for _, c := range r.commands {
+		if !c.Pinned {
+			if unpinned++; unpinned > MaxRecent {
+				continue
+			}
+		}
+		kept = append(kept, c)
+	}
+	r.commands = kept
+	return r.file.Save(r.commands)
+}
+
+// Pin pins or unpins command and saves the commands. It's a no-op for a
+// command that isn't recent.
+func (r *Recent) Pin(command string, pinned bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(command)
+	if i < 0 {
+		return nil
+	}
+	r.commands[i].Pinned = pinned
+	r.sort()
+	return r.file.Save(r.commands)
+}
+
+// Remove forgets command and saves the commands.
+func (r *Recent) Remove(command string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.index(command); i >= 0 {
+		r.commands = append(r.commands[:i], r.commands[i+1:]...)
+	}
+	return r.file.Save(r.commands)
+}
+
+// Clear forgets the commands that aren't pinned and saves the rest.
+func (r *Recent) Clear() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pinned := []RecentCommand{}
+	for _, c := range r.commands {
+		if c.Pinned {
+			pinned = append(pinned, c)
+		}
+	}
+	r.commands = pinned
+	return r.file.Save(r.commands)
+}
+
+func (r *Recent) index(command string) int {
+	for i, c := range r.commands {
+		if c.Command == command {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *Recent) sort() {
+	sort.SliceStable(r.commands, func(i, k int) bool {
+		a, b := r.commands[i], r.commands[k]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		return a.Used.After(b.Used)
+	})
+}
@@ -0,0 +1,45 @@
+package store
+
+import "sync"
+
+// SavedSession is a tab of the GUI as it was when gtoc exited: its target,
+// "" if none was picked, and the values of its form and its layout, as the
+// frontend sent them.
+type SavedSession struct {
+	Target string                 `json:"target"`
+	Values map[string]interface{} `json:"values"`
+	Layout map[string]interface{} `json:"layout,omitempty"`
+}
+
+// Sessions are the tabs of the GUI saved as gtoc exits, for it to open them
+// again on launch.
+type Sessions struct {
+	file *File
+
+	mu       sync.Mutex
+	sessions []SavedSession
+}
+
+// OpenSessions loads the sessions saved at path.
+func OpenSessions(path string) (*Sessions, error) {
+	s := &Sessions{file: &File{Path: path}}
+	if err := s.file.Load(&s.sessions); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns the sessions, in the order of their tabs.
+func (s *Sessions) List() []SavedSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SavedSession{}, s.sessions...)
+}
+
+// Save saves the sessions in place of those saved before.
+func (s *Sessions) Save(sessions []SavedSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = append([]SavedSession{}, sessions...)
+	return s.file.Save(s.sessions)
+}
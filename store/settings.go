@@ -0,0 +1,174 @@
+package store
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"gtoc/keymap"
+	"gtoc/update"
+)
+
+// Theme is the colour scheme of the GUI.
+type Theme string
+
+const (
+	ThemeLight Theme = "light"
+	ThemeDark  Theme = "dark"
+	// ThemeSystem follows the scheme of the desktop
+	ThemeSystem Theme = "system"
+)
+
+// Preferences are the settings of gtoc the user picks.
+type Preferences struct {
+	Theme Theme `json:"theme"`
+	// Language is the locale of the GUI, such as "de" or "fr-CA", the
+	// desktop's if empty
+	Language string `json:"language"`
+	// Shell and Timeout, in seconds, are what the form of a run starts
+	// with. An empty shell runs commands directly, a zero timeout never
+	// stops them.
+	Shell   string  `json:"shell"`
+	Timeout float64 `json:"timeout"`
+	// LogLevel is "debug", "info", "warn" or "error"
+	LogLevel string `json:"logLevel"`
+	// Width and Height are the size of the window when gtoc starts, but
+	// for a command it was laid out for before, see Layouts
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Tray keeps gtoc in the tray of the desktop when its window is
+	// closed, its jobs running, until it's quit from the tray
+	Tray bool `json:"tray"`
+	// Keys are the hotkeys of the actions of the GUI
+	Keys keymap.Keymap `json:"keys"`
+	// Registry is the registry of patterns the user fetches from and
+	// publishes to
+	Registry Registry `json:"registry"`
+	// Update is whether gtoc updates itself, see package update
+	Update Update `json:"update"`
+}
+
+// Update is whether gtoc checks for new releases of the channel as it
+// starts, for the user to download them, see package update. The channel is
+// the first of update.Channels if it's empty.
+type Update struct {
+	Enabled bool   `json:"enabled"`
+	Channel string `json:"channel"`
+}
+
+// Check returns why the update can't be, nil if it can.
+func (u Update) Check() error {
+	if u.Channel == "" {
+		return nil
+	}
+	for _, c := range update.Channels {
+		if u.Channel == c {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown channel of releases %q", u.Channel)
+}
+
+// Registry is a registry of patterns, see package registry: its URL, none if
+// it's empty, the base64 of the ed25519 public key it signs the patterns it
+// serves with, and the token publishing is authorized with, if any.
+type Registry struct {
+	URL   string `json:"url"`
+	Key   string `json:"key"`
+	Token string `json:"token"`
+}
+
+// Check returns why the registry can't be, nil if it can.
+func (r Registry) Check() error {
+	if r.URL == "" {
+		return nil
+	}
+	if u, err := url.Parse(r.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("the registry %q isn't an HTTP URL", r.URL)
+	}
+	if key, err := base64.StdEncoding.DecodeString(r.Key); err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("the key of the registry isn't the base64 of an ed25519 public key")
+	}
+	return nil
+}
+
+// MinWidth and MinHeight are the smallest size of the window.
+const (
+	MinWidth  = 400
+	MinHeight = 300
+)
+
+// DefaultPreferences are the preferences until the user changes them. Those
+// missing from a settings file are the default ones too.
+var DefaultPreferences = Preferences{
+	Theme:    ThemeSystem,
+	LogLevel: "info",
+	Width:    1024,
+	Height:   768,
+	Keys:     keymap.Default,
+	Update:   Update{Channel: "stable"},
+}
+
+// Check returns why the preferences can't be, nil if they can.
+func (p Preferences) Check() error {
+	switch p.Theme {
+	case ThemeLight, ThemeDark, ThemeSystem:
+	default:
+		return fmt.Errorf("unknown theme %q", p.Theme)
+	}
+	switch p.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("unknown log level %q", p.LogLevel)
+	}
+	if p.Timeout < 0 {
+		return fmt.Errorf("negative timeout %v", p.Timeout)
+	}
+	if p.Width < MinWidth || p.Height < MinHeight {
+		return fmt.Errorf("a window of %dx%d is too small", p.Width, p.Height)
+	}
+	if err := p.Registry.Check(); err != nil {
+		return err
+	}
+	if err := p.Update.Check(); err != nil {
+		return err
+	}
+	return p.Keys.Check()
+}
+
+// Settings are the preferences saved in a file.
+type Settings struct {
+	file *File
+
+	mu    sync.Mutex
+	prefs Preferences
+}
+
+// OpenSettings loads the preferences saved at path.
+func OpenSettings(path string) (*Settings, error) {
+	s := &Settings{file: &File{Path: path}, prefs: DefaultPreferences}
+	if err := s.file.Load(&s.prefs); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the preferences.
+func (s *Settings) Get() Preferences {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prefs
+}
+
+// Set checks the preferences and saves them.
+func (s *Settings) Set(p Preferences) error {
+	if err := p.Check(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prefs = p
+	return s.file.Save(s.prefs)
+}
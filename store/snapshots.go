@@ -0,0 +1,102 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MaxSnapshots is how many snapshots of a command Snapshots keeps, the ones
+// taken last.
+const MaxSnapshots = 10
+
+// Snapshot is the pattern of a command as it was parsed for a build of its
+// program: where the program was, the version it told and the hex SHA-256
+// of its binary. The pattern is as docopt.MarshalPattern writes it.
+type Snapshot struct {
+	Command string          `json:"command"`
+	Path    string          `json:"path"`
+	Version string          `json:"version,omitempty"`
+	Hash    string          `json:"hash"`
+	Taken   time.Time       `json:"taken"`
+	Pattern json.RawMessage `json:"pattern"`
+	// Stale are the profiles of the command that use options the pattern
+	// doesn't have anymore, by name, each with the options
+	Stale map[string][]string `json:"stale,omitempty"`
+}
+
+// Same tells whether the snapshots are of the same build of the program of
+// the same command.
+func (s Snapshot) Same(o Snapshot) bool {
+	return s.Command == o.Command && s.Path == o.Path && s.Version == o.Version && s.Hash == o.Hash
+}
+
+// Snapshots are the patterns of the commands parsed, one for every build of
+// their program, for what a build changed to be told once it's updated.
+type Snapshots struct {
+	file *File
+
+	mu        sync.Mutex
+	snapshots []Snapshot
+}
+
+// OpenSnapshots loads the snapshots saved at path.
+func OpenSnapshots(path string) (*Snapshots, error) {
+	s := &Snapshots{file: &File{Path: path}}
+	if err := s.file.Load(&s.snapshots); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns the snapshots of command, the oldest first.
+func (s *Snapshots) List(command string) []Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []Snapshot
+	for _, snap := range s.snapshots {
+		if snap.Command == command {
+			list = append(list, snap)
+		}
+	}
+	return list
+}
+
+// Latest returns the snapshot of command taken last, false if it has none.
+func (s *Snapshots) Latest(command string) (Snapshot, bool) {
+	list := s.List(command)
+	if len(list) == 0 {
+		return Snapshot{}, false
+	}
+	return list[len(list)-1], true
+}
+
+// Add appends the snapshot and saves the snapshots, dropping the oldest of
+// its command past MaxSnapshots, unless one of the same build is kept
+// already. It tells whether it added it.
+func (s *Snapshots) Add(snap Snapshot) (bool, error) {
+	if snap.Command == "" || snap.Hash == "" {
+		return false, fmt.Errorf("a snapshot of no command or build")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, o := range s.snapshots {
+		if o.Same(snap) {
+			return false, nil
+		}
+		if o.Command == snap.Command {
+			count++
+		}
+	}
+	s.snapshots = append(s.snapshots, snap)
+	for i := 0; count >= MaxSnapshots; i++ {
+		if s.snapshots[i].Command == snap.Command {
+			s.snapshots = append(s.snapshots[:i], s.snapshots[i+1:]...)
+			i--
+			count--
+		}
+	}
+	return true, s.file.Save(s.snapshots)
+}
@@ -0,0 +1,74 @@
+// Package store keeps gtoc's state across sessions, as JSON files in its
+// configuration directory.
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Dir returns gtoc's configuration directory, creating it if needed.
+func Dir() (string, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(config, "gtoc")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// File is a JSON document saved at Path. Its methods may be called from
+// several goroutines.
+type File struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Load decodes the document into v. A document that was never saved leaves v
+// untouched.
+func (f *File) Load(v interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Save encodes v as the document. It's written to a temporary file first
+// and renamed over the old one, so a crash never leaves half a document.
+func (f *File) Save(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tmp, err := ioutil.TempFile(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
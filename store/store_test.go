@@ -0,0 +1,367 @@
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gtoc/keymap"
+)
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := &File{Path: filepath.Join(dir, "doc.json")}
+	v := map[string]int{"kept": 1}
+	if err := f.Load(&v); err != nil || !reflect.DeepEqual(v, map[string]int{"kept": 1}) {
+		t.Errorf("result: %v error: %v", v, err)
+	}
+	if err := f.Save(map[string]int{"a": 2}); err != nil {
+		t.Fatal(err)
+	}
+	v = nil
+	if err := f.Load(&v); err != nil || !reflect.DeepEqual(v, map[string]int{"a": 2}) {
+		t.Errorf("result: %v error: %v", v, err)
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary files left: %v", entries)
+	}
+}
+
+func TestWorkDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "workdirs.json")
+	w, err := OpenWorkDirs(path)
+	if err != nil || w.Get("make") != "" {
+		t.Fatalf("result: %v error: %v", w, err)
+	}
+	if err = w.Set("make", "/src/project"); err != nil {
+		t.Fatal(err)
+	}
+	w.Set("ls", "/tmp")
+	w.Set("ls", "")
+	if w, err = OpenWorkDirs(path); err != nil || w.Get("make") != "/src/project" || w.Get("ls") != "" {
+		t.Errorf("result: %v error: %v", w, err)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "recent.json")
+	r, err := OpenRecent(path)
+	if err != nil || len(r.List()) != 0 {
+		t.Fatalf("result: %v error: %v", r, err)
+	}
+	at := func(s int) time.Time { return time.Unix(int64(s), 0).UTC() }
+	for i := 0; i < MaxRecent+2; i++ {
+		if err = r.Use(fmt.Sprintf("cmd%d", i), at(i)); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			r.Pin("cmd0", true)
+		}
+	}
+	r.Use("cmd5", at(100))
+	r.Pin("missing", true)
+	r, err = OpenRecent(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := r.List()
+	if len(list) != MaxRecent+1 {
+		t.Fatalf("result: %d commands expected: %d", len(list), MaxRecent+1)
+	}
+	for i, expected := range []RecentCommand{
+		{Command: "cmd0", Used: at(0), Pinned: true},
+		{Command: "cmd5", Used: at(100)},
+		{Command: fmt.Sprintf("cmd%d", MaxRecent+1), Used: at(MaxRecent + 1)},
+	} {
+		if !reflect.DeepEqual(list[i], expected) {
+			t.Errorf("testcase: %d result: %v expected: %v", i, list[i], expected)
+		}
+	}
+	if last := list[len(list)-1]; last.Command != "cmd2" {
+		t.Errorf("result: %v expected: cmd2 last, cmd1 dropped", last)
+	}
+
+	r.Remove("cmd5")
+	if err = r.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = OpenRecent(path)
+	if list = r.List(); len(list) != 1 || list[0].Command != "cmd0" {
+		t.Errorf("result: %v expected: only the pinned cmd0", list)
+	}
+}
+
+func TestFavorites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "favorites.json")
+	f, err := OpenFavorites(path)
+	if err != nil || len(f.List()) != 0 {
+		t.Fatalf("result: %v error: %v", f, err)
+	}
+	for _, command := range []string{"ls", "make", "git", "docker"} {
+		if err = f.Add(Favorite{Command: command}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Add(Favorite{Command: "make", Label: "Build", Icon: "hammer"})
+	f.Remove("ls")
+	if err = f.Move("docker", 0); err != nil {
+		t.Fatal(err)
+	}
+	f.Move("make", 10)
+	if err = f.Move("ls", 0); err == nil {
+		t.Errorf("moving a command that isn't a favorite: no error")
+	}
+	f, err = OpenFavorites(path)
+	expected := []Favorite{{Command: "docker"}, {Command: "git"}, {Command: "make", Label: "Build", Icon: "hammer"}}
+	if result := f.List(); err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("result: %v error: %v expected: %v", result, err, expected)
+	}
+}
+
+func TestLayouts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "layouts.json")
+	l, err := OpenLayouts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.Get("ls"); ok {
+		t.Errorf("result: a layout expected: none")
+	}
+	ls := Layout{Width: 800, Height: 600, Splits: map[string]float64{"output": 0.6}, Collapsed: []string{"Advanced"}}
+	if err = l.Set("ls", ls); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Set("tar", Layout{Splits: map[string]float64{"output": 0.3}}); err != nil {
+		t.Fatal(err)
+	}
+	for i, layout := range []Layout{
+		{Width: 100, Height: 100},
+		{Width: 800},
+		{Splits: map[string]float64{"output": 1.5}},
+	} {
+		if err = l.Set("ls", layout); err == nil {
+			t.Errorf("testcase: %d result: no error", i)
+		}
+	}
+	l, err = OpenLayouts(path)
+	if result, ok := l.Get("ls"); err != nil || !ok || !reflect.DeepEqual(result, ls) {
+		t.Errorf("result: %v %v expected: %v", result, err, ls)
+	}
+}
+
+func TestLibrary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "library.json")
+	l, err := OpenLibrary(path)
+	if err != nil || len(l.List()) != 0 {
+		t.Fatalf("result: %v error: %v", l, err)
+	}
+	tar := LibraryEntry{Command: "tar", Options: ParseOptions{Help: "--usage"}, Pattern: []byte(`{}`)}
+	git := LibraryEntry{Command: "git", Label: "Git", Options: ParseOptions{Subcommands: true}}
+	for _, e := range []LibraryEntry{tar, git, {Command: "tar", Label: "Tape archiver", Options: tar.Options, Pattern: tar.Pattern}} {
+		if err = l.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, e := range []LibraryEntry{
+		{},
+		{Command: "ls", Options: ParseOptions{Help: "--help all"}},
+		{Command: "ls", Options: ParseOptions{Help: " -h"}},
+	} {
+		if err = l.Add(e); err == nil {
+			t.Errorf("testcase: %d result: no error", i)
+		}
+	}
+	tar.Label = "Tape archiver"
+	expected := []LibraryEntry{tar, git}
+	if l, err = OpenLibrary(path); err != nil || !reflect.DeepEqual(l.List(), expected) {
+		t.Errorf("result: %v error: %v expected: %v", l.List(), err, expected)
+	}
+	if result, ok := l.Get("git"); !ok || !reflect.DeepEqual(result, git) {
+		t.Errorf("result: %v expected: %v", result, git)
+	}
+	if err = l.Remove("tar"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.Get("tar"); ok || len(l.List()) != 1 {
+		t.Errorf("result: %v expected: git only", l.List())
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshots.json")
+	s, err := OpenSnapshots(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Latest("tar"); ok {
+		t.Errorf("result: a snapshot expected: none")
+	}
+	taken := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	snap := func(command, version, hash string) Snapshot {
+		return Snapshot{Command: command, Path: "/usr/bin/" + command, Version: version, Hash: hash, Taken: taken, Pattern: []byte(`{}`)}
+	}
+	old, git := snap("tar", "1.34", "aa"), snap("git", "2.43.0", "cc")
+	updated := snap("tar", "1.35", "bb")
+	updated.Stale = map[string][]string{"backup": {"--old-archive"}}
+	for i, tt := range []struct {
+		snap     Snapshot
+		expected bool
+	}{
+		{old, true},
+		{git, true},
+		{old, false},
+		{updated, true},
+		{snap("tar", "1.35", "bb"), false},
+	} {
+		if result, err := s.Add(tt.snap); err != nil || result != tt.expected {
+			t.Errorf("testcase: %d result: %v %v expected: %v", i, result, err, tt.expected)
+		}
+	}
+	if _, err = s.Add(snap("", "1.0", "aa")); err == nil {
+		t.Errorf("result: no error expected: no command")
+	}
+	if _, err = s.Add(snap("ls", "1.0", "")); err == nil {
+		t.Errorf("result: no error expected: no build")
+	}
+	expected := []Snapshot{old, updated}
+	if s, err = OpenSnapshots(path); err != nil || !reflect.DeepEqual(s.List("tar"), expected) {
+		t.Errorf("result: %v error: %v expected: %v", s.List("tar"), err, expected)
+	}
+	if result, ok := s.Latest("tar"); !ok || !reflect.DeepEqual(result, updated) {
+		t.Errorf("result: %v expected: %v", result, updated)
+	}
+
+	for i := 0; i < MaxSnapshots; i++ {
+		if _, err = s.Add(snap("tar", "2.0", fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if list := s.List("tar"); len(list) != MaxSnapshots || list[0].Hash != "0" {
+		t.Errorf("result: %v expected: the last %d", list, MaxSnapshots)
+	}
+	if list := s.List("git"); !reflect.DeepEqual(list, []Snapshot{git}) {
+		t.Errorf("result: %v expected: git kept", list)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sessions.json")
+	s, err := OpenSessions(path)
+	if err != nil || len(s.List()) != 0 {
+		t.Fatalf("result: %v error: %v", s, err)
+	}
+	saved := []SavedSession{
+		{Target: "ls", Values: map[string]interface{}{"-l": true, "<file>": []interface{}{"a", "b"}}, Layout: map[string]interface{}{"preview": true}},
+		{Target: "", Values: map[string]interface{}{}},
+	}
+	if err = s.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = OpenSessions(path); err != nil || !reflect.DeepEqual(s.List(), saved) {
+		t.Errorf("result: %v error: %v expected: %v", s.List(), err, saved)
+	}
+	if err = s.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = OpenSessions(path); err != nil || len(s.List()) != 0 {
+		t.Errorf("result: %v error: %v expected: no sessions", s.List(), err)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "settings.json")
+	// the preferences missing from the file are the default ones, hotkeys
+	// included
+	ioutil.WriteFile(path, []byte(`{"theme": "dark", "keys": {"run": "F5"}}`), 0644)
+	s, err := OpenSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DefaultPreferences
+	expected.Theme = ThemeDark
+	expected.Keys.Run = "F5"
+	if result := s.Get(); result != expected {
+		t.Errorf("result: %+v expected: %+v", result, expected)
+	}
+
+	expected.Shell, expected.Timeout, expected.LogLevel = "bash", 30, "debug"
+	expected.Update = Update{Enabled: true, Channel: "beta"}
+	expected.Registry = Registry{URL: "https://patterns.example", Key: "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="}
+	if err = s.Set(expected); err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range []Preferences{
+		{Theme: "blue", LogLevel: "info", Width: 800, Height: 600},
+		{Theme: ThemeLight, LogLevel: "loud", Width: 800, Height: 600},
+		{Theme: ThemeLight, LogLevel: "info", Timeout: -1, Width: 800, Height: 600},
+		{Theme: ThemeLight, LogLevel: "info", Width: 10, Height: 10},
+		{Theme: ThemeLight, LogLevel: "info", Width: 800, Height: 600, Keys: keymap.Keymap{Run: "R"}},
+		{Theme: ThemeLight, LogLevel: "info", Width: 800, Height: 600, Update: Update{Enabled: true, Channel: "nightly"}},
+		{Theme: ThemeLight, LogLevel: "info", Width: 800, Height: 600, Registry: Registry{URL: "ftp://patterns.example"}},
+		{Theme: ThemeLight, LogLevel: "info", Width: 800, Height: 600, Registry: Registry{URL: "https://patterns.example", Key: "c2hvcnQ="}},
+	} {
+		if err = s.Set(p); err == nil {
+			t.Errorf("testcase: %d result: no error", i)
+		}
+	}
+	if s, err = OpenSettings(path); err != nil || s.Get() != expected {
+		t.Errorf("result: %+v error: %v expected: %+v", s.Get(), err, expected)
+	}
+}
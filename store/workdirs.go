@@ -0,0 +1,41 @@
+package store
+
+import "sync"
+
+// WorkDirs are the default working directories of commands, the last ones
+// picked for them.
+type WorkDirs struct {
+	file *File
+
+	mu   sync.Mutex
+	dirs map[string]string
+}
+
+// OpenWorkDirs loads the working directories saved at path.
+func OpenWorkDirs(path string) (*WorkDirs, error) {
+	w := &WorkDirs{file: &File{Path: path}, dirs: map[string]string{}}
+	if err := w.file.Load(&w.dirs); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Get returns the working directory of command, "" if none was set.
+func (w *WorkDirs) Get(command string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dirs[command]
+}
+
+// Set makes dir the working directory of command and saves the directories.
+// An empty dir removes the command's.
+func (w *WorkDirs) Set(command, dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if dir == "" {
+		delete(w.dirs, command)
+	} else {
+		w.dirs[command] = dir
+	}
+	return w.file.Save(w.dirs)
+}
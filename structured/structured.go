@@ -0,0 +1,156 @@
+// Package structured detects output in a data format, JSON, CSV or TSV, and
+// parses it into a table and a tree a frontend can browse, such as the lists
+// of kubectl get -o json.
+package structured
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Format is a data format output is detected in.
+type Format string
+
+const (
+	JSON Format = "json"
+	CSV  Format = "csv"
+	TSV  Format = "tsv"
+)
+
+// Data is output parsed. Columns and Rows are the table of CSV and TSV, the
+// first line being the header, and of JSON holding a list of objects: an
+// array of them, or an object with an array of them as "items". Tree is the
+// JSON value as decoded, null for other formats.
+type Data struct {
+	Format  Format      `json:"format"`
+	Columns []string    `json:"columns,omitempty"`
+	Rows    [][]string  `json:"rows,omitempty"`
+	Tree    interface{} `json:"tree,omitempty"`
+}
+
+// Detect parses the output in the first format it's valid in, JSON first. It
+// returns false for output in none, such as plain text. Output is taken for
+// CSV or TSV only if it has a header and a row, of two fields at least and as
+// many in every line.
+func Detect(output string) (*Data, bool) {
+	if strings.TrimSpace(output) == "" {
+		return nil, false
+	}
+	if d, ok := parseJSON(output); ok {
+		return d, true
+	}
+	// a comma may well be in tab separated fields, but hardly the reverse
+	if line := strings.SplitN(output, "\n", 2)[0]; strings.Contains(line, "\t") {
+		if d, ok := parseTSV(output); ok {
+			return d, true
+		}
+	}
+	return parseCSV(output)
+}
+
+func parseJSON(output string) (*Data, bool) {
+	dec := json.NewDecoder(strings.NewReader(output))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, false
+	}
+	// a single value only
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+	d := &Data{Format: JSON, Tree: tree}
+	list, ok := tree.([]interface{})
+	if obj, isObj := tree.(map[string]interface{}); isObj {
+		list, ok = obj["items"].([]interface{})
+	}
+	if ok {
+		d.Columns, d.Rows = table(list)
+	}
+	return d, true
+}
+
+// table returns the table of the list if all of it is objects, their keys
+// being the columns in the order they're first seen, those of an object
+// sorted. Values that aren't
+// strings are written as JSON.
+func table(list []interface{}) ([]string, [][]string) {
+	var columns []string
+	index := map[string]int{}
+	objects := make([]map[string]interface{}, len(list))
+	for i, v := range list {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		objects[i] = obj
+		for _, key := range keys(obj) {
+			if _, seen := index[key]; !seen {
+				index[key] = len(columns)
+				columns = append(columns, key)
+			}
+		}
+	}
+	rows := make([][]string, len(objects))
+	for i, obj := range objects {
+		rows[i] = make([]string, len(columns))
+		for key, v := range obj {
+			rows[i][index[key]] = cell(v)
+		}
+	}
+	return columns, rows
+}
+
+func keys(obj map[string]interface{}) []string {
+	list := make([]string, 0, len(obj))
+	for key := range obj {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func cell(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case json.Number:
+		return v.String()
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func parseCSV(output string) (*Data, bool) {
+	records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	if err != nil {
+		return nil, false
+	}
+	return tableData(CSV, records)
+}
+
+// parseTSV splits the lines at tabs, tab separated values being quoted
+// nowhere, unlike CSV.
+func parseTSV(output string) (*Data, bool) {
+	var records [][]string
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		fields := strings.Split(strings.TrimSuffix(line, "\r"), "\t")
+		if len(records) > 0 && len(fields) != len(records[0]) {
+			return nil, false
+		}
+		records = append(records, fields)
+	}
+	return tableData(TSV, records)
+}
+
+func tableData(format Format, records [][]string) (*Data, bool) {
+	if len(records) < 2 || len(records[0]) < 2 {
+		return nil, false
+	}
+	return &Data{Format: format, Columns: records[0], Rows: records[1:]}, true
+}
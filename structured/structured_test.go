@@ -0,0 +1,45 @@
+package structured
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	for i, tt := range []struct {
+		output   string
+		format   Format
+		columns  []string
+		rows     [][]string
+		detected bool
+	}{
+		{"", "", nil, nil, false},
+		{"hello world\n", "", nil, nil, false},
+		{"hello, world\n", "", nil, nil, false},
+		{`{"a": 1}` + "\n", JSON, nil, nil, true},
+		{`{"a": 1} {"b": 2}`, "", nil, nil, false},
+		{`[{"name": "x", "size": 1}, {"name": "y", "tags": ["a"]}]`, JSON,
+			[]string{"name", "size", "tags"}, [][]string{{"x", "1", ""}, {"y", "", `["a"]`}}, true},
+		{`{"kind": "List", "items": [{"name": "pod", "ready": true}]}`, JSON,
+			[]string{"name", "ready"}, [][]string{{"pod", "true"}}, true},
+		{`[1, {"a": 1}]`, JSON, nil, nil, true},
+		{"name,size\nx,1\n\"y, z\",2\n", CSV, []string{"name", "size"}, [][]string{{"x", "1"}, {"y, z", "2"}}, true},
+		{"name,size\nx\n", "", nil, nil, false},
+		{"name\tnote\nx\t\"a, b\"\n", TSV, []string{"name", "note"}, [][]string{{"x", `"a, b"`}}, true},
+	} {
+		d, ok := Detect(tt.output)
+		if ok != tt.detected {
+			t.Errorf("testcase: %d result: %v expected: %v", i, ok, tt.detected)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if d.Format != tt.format || !reflect.DeepEqual(d.Columns, tt.columns) || !reflect.DeepEqual(d.Rows, tt.rows) {
+			t.Errorf("testcase: %d result: %+v expected: %s %q %q", i, d, tt.format, tt.columns, tt.rows)
+		}
+		if (d.Tree != nil) != (d.Format == JSON) {
+			t.Errorf("testcase: %d tree: %v", i, d.Tree)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gtoc/tray"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// tray_icon keeps gtoc in the tray of the desktop while the settings say so,
+// see store.Preferences.Tray. Closing the window then only hides it, the
+// jobs running on, and the menu of the tray lists the jobs, opens the window
+// again and quits gtoc.
+type tray_icon struct {
+	ctx     context.Context
+	jobs    *Jobs
+	tooltip string
+
+	mu sync.Mutex
+	// tray is nil while the icon isn't in the tray, and quitting is set
+	// once the user quit from it
+	tray     *tray.Tray
+	quitting bool
+}
+
+// start sets the icon up for the window of ctx, in the tray if the
+// settings say so, its menu following the jobs.
+func (t *tray_icon) start(ctx context.Context, b *Backend) {
+	t.ctx, t.jobs = ctx, b.Jobs
+	b.tray = t
+	b.events.changed = t.refresh
+	t.set(b.settings.Get().Tray)
+}
+
+// set puts the icon in the tray, or takes it out.
+func (t *tray_icon) set(on bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case on && t.tray == nil:
+		t.tray = tray.Start(t.tooltip, t.menu())
+	case !on && t.tray != nil:
+		t.tray.Stop()
+		t.tray = nil
+	}
+}
+
+// refresh sets the menu of the tray anew, for the jobs changed.
+func (t *tray_icon) refresh() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tray != nil {
+		t.tray.Set(t.menu())
+	}
+}
+
+// menu returns the items of the menu of the tray.
+func (t *tray_icon) menu() []tray.Item {
+	items := []tray.Item{{Title: "Open window", Action: func() { t.show("") }}, {}}
+	items = append(items, tray.Jobs(t.jobs.ListJobs(), time.Now(), t.show)...)
+	return append(items, tray.Item{}, tray.Item{Title: "Quit", Action: t.quit})
+}
+
+// show shows the window, on the job jobID unless it's "": the job is
+// emitted as a "tray:job" event for the frontend to bring its output up.
+func (t *tray_icon) show(jobID string) {
+	runtime.WindowShow(t.ctx)
+	if jobID != "" {
+		t.jobs.view.Emit("tray:job", jobID)
+	}
+}
+
+// quit quits gtoc, the jobs still running with it.
+func (t *tray_icon) quit() {
+	t.mu.Lock()
+	t.quitting = true
+	t.mu.Unlock()
+	runtime.Quit(t.ctx)
+}
+
+// before_close hides the window rather than letting it close while the icon
+// is in the tray, unless the user quit from it.
+func (t *tray_icon) before_close(ctx context.Context) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tray == nil || t.quitting {
+		return false
+	}
+	runtime.WindowHide(ctx)
+	// the jobs ending while it's hidden are notified
+	t.jobs.SetFocused(false)
+	return true
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package tray
+
+import _ "embed"
+
+// icon is the icon of the tray.
+//
+//go:embed icon.png
+var icon []byte
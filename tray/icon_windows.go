@@ -0,0 +1,8 @@
+package tray
+
+import _ "embed"
+
+// icon is the icon of the tray, which has to be an ICO on Windows.
+//
+//go:embed icon.ico
+var icon []byte
@@ -0,0 +1,158 @@
+// Package tray puts gtoc in the tray of the desktop, for it to keep running
+// its jobs in the background while its window is closed. The menu of the
+// tray is set anew whenever what it lists changes.
+package tray
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gtoc/runner"
+
+	"fyne.io/systray"
+)
+
+// Item is an entry of the menu of the tray. Items without an action are
+// only told, greyed out, and those without a title are separators.
+type Item struct {
+	Title  string
+	Action func()
+}
+
+// Tray is the icon of gtoc in the tray, with its menu.
+type Tray struct {
+	end func()
+
+	mu sync.Mutex
+	// stop is closed to stop waiting for clicks on the items of the menu
+	// shown, once it's replaced
+	stop chan struct{}
+}
+
+// Start shows the icon of gtoc in the tray, with the tooltip and the menu
+// of the items. The event loop of the desktop is the window's.
+func Start(tooltip string, items []Item) *Tray {
+	t := &Tray{}
+	ready := make(chan struct{})
+	start, end := systray.RunWithExternalLoop(func() {
+		systray.SetIcon(icon)
+		systray.SetTooltip(tooltip)
+		close(ready)
+	}, func() {})
+	start()
+	<-ready
+	t.end = end
+	t.Set(items)
+	return t
+}
+
+// Set replaces the menu of the tray with the items.
+func (t *Tray) Set(items []Item) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stop != nil {
+		close(t.stop)
+	}
+	t.stop = make(chan struct{})
+	systray.ResetMenu()
+	for _, item := range items {
+		if item.Title == "" {
+			systray.AddSeparator()
+			continue
+		}
+		entry := systray.AddMenuItem(item.Title, "")
+		if item.Action == nil {
+			entry.Disable()
+			continue
+		}
+		go func(clicked chan struct{}, action func(), stop chan struct{}) {
+			for {
+				select {
+				case <-clicked:
+					action()
+				case <-stop:
+					return
+				}
+			}
+		}(entry.ClickedCh, item.Action, t.stop)
+	}
+}
+
+// Stop takes the icon out of the tray.
+func (t *Tray) Stop() {
+	t.mu.Lock()
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+	t.mu.Unlock()
+	t.end()
+}
+
+// recent is how many of the jobs that finished Jobs lists.
+const recent = 5
+
+// Jobs returns the items of the jobs: those running or queued, then the
+// results of those that finished last, the latest first. Clicking one calls
+// show with its ID. The times are told as of now.
+func Jobs(jobs []runner.JobStatus, now time.Time, show func(jobID string)) []Item {
+	var active, done []Item
+	for i := len(jobs) - 1; i >= 0; i-- {
+		job := jobs[i]
+		id := job.ID
+		item := Item{Action: func() { show(id) }}
+		switch {
+		case job.State == runner.JobQueued:
+			item.Title = job.Program + " · queued"
+		case job.Result == nil:
+			item.Title = fmt.Sprintf("%s · %v", job.Program, now.Sub(job.Start).Round(time.Second))
+		case len(done) < recent:
+			item.Title = ended(job)
+		default:
+			continue
+		}
+		if job.Result == nil {
+			// the oldest first, as they're started
+			active = append([]Item{item}, active...)
+		} else {
+			done = append(done, item)
+		}
+	}
+	var items []Item
+	if len(active) > 0 {
+		items = append(items, Item{Title: "Running"})
+		items = append(items, active...)
+	}
+	if len(done) > 0 {
+		if len(items) > 0 {
+			items = append(items, Item{})
+		}
+		items = append(items, Item{Title: "Recent results"})
+		items = append(items, done...)
+	}
+	if len(items) == 0 {
+		items = append(items, Item{Title: "No jobs"})
+	}
+	return items
+}
+
+// ended returns the title of the job that finished: how it ended, and
+// after how long.
+func ended(job runner.JobStatus) string {
+	r := job.Result
+	took := r.Duration.Round(time.Millisecond)
+	switch job.State {
+	case runner.JobDone:
+		return fmt.Sprintf("✓ %s · %v", job.Program, took)
+	case runner.JobCanceled:
+		return fmt.Sprintf("⊘ %s · canceled", job.Program)
+	}
+	switch r.State {
+	case runner.StateExited:
+		return fmt.Sprintf("✗ %s · exit %d", job.Program, r.Code)
+	case runner.StateTimedOut:
+		return fmt.Sprintf("✗ %s · timed out", job.Program)
+	}
+	return fmt.Sprintf("✗ %s · %s", job.Program, r.State)
+}
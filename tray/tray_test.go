@@ -0,0 +1,69 @@
+package tray
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gtoc/runner"
+)
+
+func TestJobs(t *testing.T) {
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	exited := func(code int) *runner.RunResult {
+		return &runner.RunResult{State: runner.StateExited, Code: code, Duration: 1500 * time.Millisecond}
+	}
+	jobs := []runner.JobStatus{
+		{ID: "1", Program: "make", State: runner.JobDone, Result: exited(0)},
+		{ID: "2", Program: "ls", State: runner.JobFailed, Result: exited(2)},
+		{ID: "3", Program: "tar", State: runner.JobRunning, Start: now.Add(-90 * time.Second)},
+		{ID: "4", Program: "curl", State: runner.JobFailed, Result: &runner.RunResult{State: runner.StateTimedOut}},
+		{ID: "5", Program: "rsync", State: runner.JobRunning, Start: now.Add(-2 * time.Second)},
+		{ID: "6", Program: "sleep", State: runner.JobCanceled, Result: &runner.RunResult{State: runner.StateCanceled}},
+		{ID: "7", Program: "git", State: runner.JobQueued},
+	}
+	var shown []string
+	items := Jobs(jobs, now, func(id string) { shown = append(shown, id) })
+	var titles []string
+	for _, item := range items {
+		titles = append(titles, item.Title)
+		if item.Action != nil {
+			item.Action()
+		}
+	}
+	expected := []string{
+		"Running", "tar · 1m30s", "rsync · 2s", "git · queued",
+		"",
+		"Recent results", "⊘ sleep · canceled", "✗ curl · timed out", "✗ ls · exit 2", "✓ make · 1.5s",
+	}
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("result: %q expected: %q", titles, expected)
+	}
+	if ids := []string{"3", "5", "7", "6", "4", "2", "1"}; !reflect.DeepEqual(shown, ids) {
+		t.Errorf("result: %v expected: %v", shown, ids)
+	}
+
+	for i, tt := range []struct {
+		jobs     []runner.JobStatus
+		expected []string
+	}{
+		{nil, []string{"No jobs"}},
+		// only the last results are listed
+		{[]runner.JobStatus{
+			{ID: "1", Program: "a", State: runner.JobDone, Result: exited(0)},
+			{ID: "2", Program: "b", State: runner.JobDone, Result: exited(0)},
+			{ID: "3", Program: "c", State: runner.JobDone, Result: exited(0)},
+			{ID: "4", Program: "d", State: runner.JobDone, Result: exited(0)},
+			{ID: "5", Program: "e", State: runner.JobDone, Result: exited(0)},
+			{ID: "6", Program: "f", State: runner.JobDone, Result: exited(0)},
+		}, []string{"Recent results", "✓ f · 1.5s", "✓ e · 1.5s", "✓ d · 1.5s", "✓ c · 1.5s", "✓ b · 1.5s"}},
+	} {
+		var result []string
+		for _, item := range Jobs(tt.jobs, now, nil) {
+			result = append(result, item.Title)
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("testcase: %d result: %q expected: %q", i, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+// Package tui renders the form of a command and the output of its runs in a
+// terminal, with bubbletea, for users working over SSH or wherever else
+// there's no desktop for the GUI.
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gtoc/docopt"
+	"gtoc/form"
+	"gtoc/runner"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Model is the state of the TUI of a command: its form, the field the
+// cursor is on and what's typed in every field, and the run in progress or
+// the last one.
+type Model struct {
+	command string
+	pat     *docopt.Pattern
+	fields  []form.Field
+	// inputs are what's in the fields: "x" or "" for checkboxes, a number
+	// for counters, the text typed or the choice picked for the others
+	inputs []string
+	cursor int
+	// top is the first field shown, the form scrolling with the cursor
+	top    int
+	height int
+
+	runner  *runner.Runner
+	events  chan tea.Msg
+	running bool
+	job     string
+	line    string
+	output  strings.Builder
+	result  *runner.RunResult
+	err     error
+	// showing tells the output of the run is shown rather than the form
+	showing bool
+}
+
+// the messages the runner sends the TUI, through Model.events
+type (
+	chunkMsg runner.Chunk
+	exitMsg  runner.RunResult
+)
+
+// events is the runner.Sink sending what the runs report to the TUI.
+type events chan tea.Msg
+
+func (e events) Output(c runner.Chunk)   { e <- chunkMsg(c) }
+func (e events) Exit(r runner.RunResult) { e <- exitMsg(r) }
+
+// New returns the TUI of the command, whose pattern is pat, its fields
+// filled with their defaults.
+func New(command string, pat *docopt.Pattern) (*Model, error) {
+	spec, err := form.Build(pat)
+	if err != nil {
+		return nil, err
+	}
+	m := &Model{
+		command: command,
+		pat:     pat,
+		fields:  spec.Fields,
+		inputs:  make([]string, len(spec.Fields)),
+		height:  24,
+		events:  make(chan tea.Msg, 64),
+	}
+	m.runner = runner.New(events(m.events))
+	for i, f := range m.fields {
+		switch f.Widget {
+		case form.Counter:
+			m.inputs[i] = "0"
+		case form.Checkbox:
+		default:
+			m.inputs[i] = f.Default
+		}
+	}
+	return m, nil
+}
+
+// Run shows the TUI of the command in the terminal until the user quits.
+func Run(command string, pat *docopt.Pattern) error {
+	m, err := New(command, pat)
+	if err != nil {
+		return err
+	}
+	_, err = tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+// Values returns the values of the fields, keyed as in the pattern, those
+// left empty being left out.
+func (m *Model) Values() docopt.Opts {
+	values := make(docopt.Opts)
+	for i, f := range m.fields {
+		in := m.inputs[i]
+		switch {
+		case f.Widget == form.Checkbox:
+			values[f.ID] = in != ""
+		case f.Widget == form.Counter:
+			values[f.ID], _ = strconv.Atoi(in)
+		case in == "":
+		case f.Multiple || f.Widget == form.Multiselect:
+			// list items are shell words, to hold spaces when quoted
+			words, err := runner.Split(in)
+			if err != nil {
+				words = strings.Fields(in)
+			}
+			values[f.ID] = words
+		default:
+			values[f.ID] = in
+		}
+	}
+	return values
+}
+
+// request returns the request of a run of the values of the fields.
+func (m *Model) request() runner.Request {
+	return runner.Request{Program: m.command, Pattern: m.pat, Values: m.Values()}
+}
+
+// wait returns the command waiting for what the runner sends next.
+func (m *Model) wait() tea.Cmd {
+	return func() tea.Msg { return <-m.events }
+}
+
+// Init starts listening to the runner.
+func (m *Model) Init() tea.Cmd {
+	return m.wait()
+}
+
+// Update handles the keys typed and what the runner sends.
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case chunkMsg:
+		if msg.JobID == m.job {
+			m.output.WriteString(msg.Data)
+		}
+		return m, m.wait()
+	case exitMsg:
+		if msg.JobID == m.job {
+			result := runner.RunResult(msg)
+			m.result, m.running = &result, false
+		}
+		return m, m.wait()
+	case tea.KeyMsg:
+		if m.showing {
+			return m.outputKey(msg)
+		}
+		return m.formKey(msg)
+	}
+	return m, nil
+}
+
+// outputKey handles the keys typed while the output is shown.
+func (m *Model) outputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		if m.running {
+			m.runner.Cancel(m.job)
+			return m, nil
+		}
+		return m, tea.Quit
+	case "esc", "q":
+		m.showing = false
+	}
+	return m, nil
+}
+
+// formKey handles the keys typed in the form.
+func (m *Model) formKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if len(m.fields) == 0 {
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+		case "enter":
+			return m, m.run()
+		}
+		return m, nil
+	}
+	f, in := m.fields[m.cursor], &m.inputs[m.cursor]
+	switch msg.String() {
+	case "ctrl+c", "esc":
+		return m, tea.Quit
+	case "up", "shift+tab":
+		m.move(-1)
+	case "down", "tab":
+		m.move(1)
+	case "enter":
+		return m, m.run()
+	case " ":
+		switch f.Widget {
+		case form.Checkbox:
+			if *in == "" {
+				*in = "x"
+			} else {
+				*in = ""
+			}
+		case form.Counter, form.Select:
+		default:
+			*in += " "
+		}
+	case "left", "-":
+		m.step(-1, msg)
+	case "right", "+":
+		m.step(1, msg)
+	case "backspace":
+		if r := []rune(*in); len(r) > 0 && typed(f) {
+			*in = string(r[:len(r)-1])
+		}
+	default:
+		if msg.Type == tea.KeyRunes && typed(f) {
+			*in += string(msg.Runes)
+		}
+	}
+	return m, nil
+}
+
+// typed tells whether the value of the field is typed in.
+func typed(f form.Field) bool {
+	switch f.Widget {
+	case form.Checkbox, form.Counter, form.Select:
+		return false
+	}
+	return true
+}
+
+// move moves the cursor by delta fields, scrolling the form to keep it in
+// sight.
+func (m *Model) move(delta int) {
+	m.cursor = (m.cursor + delta + len(m.fields)) % len(m.fields)
+	rows := m.rows()
+	if m.cursor < m.top {
+		m.top = m.cursor
+	} else if m.cursor >= m.top+rows {
+		m.top = m.cursor - rows + 1
+	}
+}
+
+// rows returns how many fields the form shows at once.
+func (m *Model) rows() int {
+	if rows := m.height - 8; rows > 1 {
+		return rows
+	}
+	return 1
+}
+
+// step counts the counter the cursor is on up or down, or picks the next
+// or previous choice of a select. Elsewhere "-" and "+" are typed in.
+func (m *Model) step(delta int, msg tea.KeyMsg) {
+	f, in := m.fields[m.cursor], &m.inputs[m.cursor]
+	switch f.Widget {
+	case form.Counter:
+		n, _ := strconv.Atoi(*in)
+		if n += delta; n >= 0 && (f.Max < 0 || n <= f.Max) {
+			*in = strconv.Itoa(n)
+		}
+	case form.Select:
+		// "" picks none
+		choices := append([]string{""}, f.Choices...)
+		i := 0
+		for k, c := range choices {
+			if c == *in {
+				i = k
+			}
+		}
+		*in = choices[(i+delta+len(choices))%len(choices)]
+	default:
+		if msg.Type == tea.KeyRunes {
+			*in += string(msg.Runes)
+		}
+	}
+}
+
+// run starts a run of the values of the fields, and shows its output.
+func (m *Model) run() tea.Cmd {
+	if m.running {
+		m.showing = true
+		return nil
+	}
+	req := m.request()
+	preview, err := runner.DryRun(req)
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	m.line, m.err, m.result, m.showing = preview.Line, nil, nil, true
+	m.output.Reset()
+	// the job is started before its first output is read
+	id, err := m.runner.Start(req)
+	m.job, m.err, m.running = id, err, err == nil
+	return nil
+}
+
+// View renders the form, or the output of the run.
+func (m *Model) View() string {
+	var b strings.Builder
+	if m.showing {
+		m.viewOutput(&b)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%s\n\n", m.command)
+	if len(m.fields) == 0 {
+		b.WriteString("  (no options)\n")
+	}
+	problems, _ := form.Check(m.pat, m.Values())
+	for i := m.top; i < len(m.fields) && i < m.top+m.rows(); i++ {
+		f := m.fields[i]
+		cursor := "  "
+		if i == m.cursor {
+			cursor = "> "
+		}
+		fmt.Fprintf(&b, "%s%-24s %s", cursor, name(f), input(f, m.inputs[i]))
+		if p, ok := problems[f.ID]; ok {
+			fmt.Fprintf(&b, "  ! %s", p)
+		} else if i == m.cursor && f.Summary != "" {
+			fmt.Fprintf(&b, "  %s", f.Summary)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	if preview, err := runner.DryRun(m.request()); err != nil {
+		fmt.Fprintf(&b, "! %s\n", err)
+	} else {
+		fmt.Fprintf(&b, "$ %s\n", preview.Line)
+	}
+	if m.err != nil {
+		fmt.Fprintf(&b, "! %s\n", m.err)
+	}
+	b.WriteString("\n↑/↓ move · space toggle · ←/→ count or choose · enter run · esc quit\n")
+	return b.String()
+}
+
+// viewOutput renders the command line of the run, the end of its output
+// that fits and how it ended.
+func (m *Model) viewOutput(b *strings.Builder) {
+	fmt.Fprintf(b, "$ %s\n\n", m.line)
+	lines := strings.Split(strings.TrimRight(m.output.String(), "\n"), "\n")
+	if rows := m.height - 5; rows > 0 && len(lines) > rows {
+		lines = lines[len(lines)-rows:]
+	}
+	b.WriteString(strings.Join(lines, "\n"))
+	b.WriteString("\n\n")
+	switch {
+	case m.err != nil:
+		fmt.Fprintf(b, "! %s\n", m.err)
+	case m.result != nil:
+		fmt.Fprintf(b, "%s %d in %v\n", m.result.State, m.result.Code, m.result.Duration.Round(time.Millisecond))
+	default:
+		b.WriteString("running…\n")
+	}
+	if m.running {
+		b.WriteString("esc back to the form · ctrl+c cancel\n")
+	} else {
+		b.WriteString("esc back to the form · ctrl+c quit\n")
+	}
+}
+
+// name returns how the field is named in the form: its label, with the
+// placeholder of its value and "..." for lists.
+func name(f form.Field) string {
+	s := f.Label
+	if f.Placeholder != "" {
+		s += " " + f.Placeholder
+	}
+	if f.Multiple {
+		s += "..."
+	}
+	if f.Required {
+		s += " *"
+	}
+	return s
+}
+
+// input renders what's in the field.
+func input(f form.Field, in string) string {
+	switch f.Widget {
+	case form.Checkbox:
+		if in != "" {
+			return "[x]"
+		}
+		return "[ ]"
+	case form.Counter:
+		return "‹ " + in + " ›"
+	case form.Select:
+		if in == "" {
+			in = "-"
+		}
+		return "‹ " + in + " › " + strings.Join(f.Choices, "|")
+	}
+	return "[" + in + "▏]"
+}
@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gtoc/docopt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func key(t tea.KeyType) tea.KeyMsg {
+	return tea.KeyMsg{Type: t}
+}
+
+func runes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestForm(t *testing.T) {
+	pat, err := docopt.ParsePattern(`Usage: echo [-n] [-v...] [--colour=<c>] <word>...
+
+Options:
+  -n            No trailing newline.
+  -v            Talk more.
+  --colour=<c>  The colour (choices: red, green).
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New("echo", pat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, f := range m.fields {
+		ids = append(ids, f.ID)
+	}
+	// the fields are in the order of the usage
+	at := func(id string) {
+		for m.fields[m.cursor].ID != id {
+			m.Update(key(tea.KeyDown))
+		}
+	}
+	at("-n")
+	m.Update(key(tea.KeySpace))
+	at("-v")
+	m.Update(key(tea.KeyRight))
+	m.Update(key(tea.KeyRight))
+	m.Update(key(tea.KeyLeft))
+	m.Update(key(tea.KeyLeft))
+	m.Update(key(tea.KeyLeft))
+	m.Update(runes("+"))
+	at("--colour")
+	m.Update(key(tea.KeyLeft))
+	at("<word>")
+	m.Update(runes("hello 'big world'x"))
+	m.Update(key(tea.KeyBackspace))
+	expected := docopt.Opts{"-n": true, "-v": 1, "--colour": "green", "<word>": []string{"hello", "big world"}}
+	if result := m.Values(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("result: %v expected: %v (fields %v)", result, expected, ids)
+	}
+	view := m.View()
+	for _, line := range []string{
+		"$ echo -n -v --colour=green hello 'big world'",
+		"> word... *",
+		"[x]",
+		"‹ green › red|green",
+	} {
+		if !strings.Contains(view, line) {
+			t.Errorf("result: %s expected: a view with %q", view, line)
+		}
+	}
+
+	// a required value missing is told, and nothing runs
+	m.inputs[m.cursor] = ""
+	m.Update(key(tea.KeyEnter))
+	if m.showing || m.err == nil || !strings.Contains(m.View(), "! ") {
+		t.Errorf("result: %v expected: a run refused", m.err)
+	}
+	if _, cmd := m.Update(key(tea.KeyEsc)); cmd == nil {
+		t.Errorf("result: nil expected: esc quitting")
+	}
+}
+
+func TestRun(t *testing.T) {
+	pat, err := docopt.ParsePattern(`Usage: echo <word>...`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New("echo", pat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Update(runes("hi there"))
+	m.Update(key(tea.KeyEnter))
+	if !m.showing || m.err != nil {
+		t.Fatalf("result: %v expected: the output shown", m.err)
+	}
+	// the program loop waits for the runner's messages as Init does
+	for m.result == nil {
+		m.Update(m.Init()())
+	}
+	view := m.View()
+	for _, line := range []string{"$ echo hi there", "hi there\n", "exited 0 in "} {
+		if !strings.Contains(view, line) {
+			t.Errorf("result: %s expected: a view with %q", view, line)
+		}
+	}
+	m.Update(key(tea.KeyEsc))
+	if m.showing {
+		t.Errorf("result: the output expected: back to the form")
+	}
+}
@@ -0,0 +1,174 @@
+// Package update keeps gtoc up to date on desktops with no package manager to
+// do it: the releases of a channel are checked in a feed, the binary of the
+// newest is downloaded next to the running one and verified, and it's swapped
+// in as gtoc exits, for the next launch to run it.
+//
+// The feed of a channel is <feed>/<channel>.json, a Release. The binaries it
+// lists are signed with the ed25519 key of the releases, over Message.
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gtoc/version"
+)
+
+// Channels are the channels of releases, from the most stable.
+var Channels = []string{"stable", "beta"}
+
+// Release is the newest release of a channel: its version, such as "1.4.0"
+// or "1.5.0-beta.2", what's new in it, and its binaries by platform, such as
+// "linux/amd64".
+type Release struct {
+	Version string           `json:"version"`
+	Notes   string           `json:"notes,omitempty"`
+	Assets  map[string]Asset `json:"assets"`
+}
+
+// Asset is a binary of a release: where it's downloaded from, its hex
+// SHA-256 and the signature of Message.
+type Asset struct {
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature []byte `json:"signature"`
+}
+
+// Message returns what is signed of the binary of the release for the
+// platform.
+func Message(version, platform, sha256 string) []byte {
+	return []byte("gtoc " + version + " " + platform + " " + strings.ToLower(sha256) + "\n")
+}
+
+// Platform is the platform of the running binary, such as "linux/amd64".
+var Platform = runtime.GOOS + "/" + runtime.GOARCH
+
+// maxFeed bounds the size of a feed read, 1 MiB.
+const maxFeed = 1 << 20
+
+// Updater updates the binary at Exe, of the version Current, from the
+// releases of Feed signed with Key. HTTP is http.DefaultClient if it's nil.
+type Updater struct {
+	Feed    string
+	Key     ed25519.PublicKey
+	Current string
+	Exe     string
+	HTTP    *http.Client
+}
+
+// Check returns the newest release of the channel, and whether it's newer
+// than the current version and has a binary for the platform.
+func (u *Updater) Check(ctx context.Context, channel string) (*Release, bool, error) {
+	known := false
+	for _, c := range Channels {
+		known = known || c == channel
+	}
+	if !known {
+		return nil, false, fmt.Errorf("unknown channel %q", channel)
+	}
+	res, err := u.get(ctx, strings.TrimRight(u.Feed, "/")+"/"+channel+".json")
+	if err != nil {
+		return nil, false, err
+	}
+	defer res.Body.Close()
+	var r Release
+	if err = json.NewDecoder(io.LimitReader(res.Body, maxFeed)).Decode(&r); err != nil {
+		return nil, false, err
+	}
+	_, ok := r.Assets[Platform]
+	return &r, ok && version.Compare(r.Version, u.Current) > 0, nil
+}
+
+// Download downloads the binary of the release for the platform next to the
+// running one, see Staged, verifies it and makes it executable. A binary
+// that fails verification is removed.
+func (u *Updater) Download(ctx context.Context, r *Release) error {
+	a, ok := r.Assets[Platform]
+	if !ok {
+		return fmt.Errorf("gtoc %s has no binary for %s", r.Version, Platform)
+	}
+	if len(u.Key) != ed25519.PublicKeySize || !ed25519.Verify(u.Key, Message(r.Version, Platform, a.SHA256), a.Signature) {
+		return fmt.Errorf("the binary of gtoc %s isn't signed by the releases", r.Version)
+	}
+	res, err := u.get(ctx, a.URL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	tmp, err := ioutil.TempFile(filepath.Dir(u.Exe), filepath.Base(u.Exe)+".download.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), res.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != strings.ToLower(a.SHA256) {
+		return fmt.Errorf("the binary of gtoc %s doesn't match its hash", r.Version)
+	}
+	if err = os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), Staged(u.Exe))
+}
+
+func (u *Updater) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := u.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("%s answered %s", url, res.Status)
+	}
+	return res, nil
+}
+
+// Staged returns where the binary downloaded for exe waits to be swapped in.
+func Staged(exe string) string {
+	return exe + ".new"
+}
+
+// Swap swaps the binary downloaded for exe in, if there's one, the running
+// one kept as exe.old until the next Swap: a running binary can be renamed
+// but not overwritten on every system. It tells whether it swapped.
+func Swap(exe string) (bool, error) {
+	old := exe + ".old"
+	os.Remove(old)
+	if _, err := os.Stat(Staged(exe)); os.IsNotExist(err) {
+		return false, nil
+	}
+	if err := os.Rename(exe, old); err != nil {
+		return false, err
+	}
+	if err := os.Rename(Staged(exe), exe); err != nil {
+		// put the running one back
+		os.Rename(old, exe)
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,98 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdater(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := []byte("#!/bin/sh\necho gtoc 1.5.0\n")
+	sum := sha256.Sum256(binary)
+	hash := hex.EncodeToString(sum[:])
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	release := func(version, hash string) Release {
+		return Release{Version: version, Assets: map[string]Asset{Platform: {
+			URL:       srv.URL + "/gtoc",
+			SHA256:    hash,
+			Signature: ed25519.Sign(private, Message(version, Platform, hash)),
+		}}}
+	}
+	mux.HandleFunc("/stable.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(release("1.5.0", hash))
+	})
+	mux.HandleFunc("/beta.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Release{Version: "1.6.0-beta.1", Assets: map[string]Asset{"plan9/mips": {}}})
+	})
+	mux.HandleFunc("/gtoc", func(w http.ResponseWriter, r *http.Request) { w.Write(binary) })
+
+	dir, err := ioutil.TempDir("", "gtoc-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exe := filepath.Join(dir, "gtoc")
+	if err = ioutil.WriteFile(exe, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	u := &Updater{Feed: srv.URL + "/", Key: public, Current: "1.4.2", Exe: exe}
+	ctx := context.Background()
+
+	if _, _, err = u.Check(ctx, "nightly"); err == nil {
+		t.Errorf("result: no error expected: an unknown channel")
+	}
+	if r, newer, err := u.Check(ctx, "beta"); err != nil || newer || r.Version != "1.6.0-beta.1" {
+		t.Errorf("result: %v %v %v expected: no binary for the platform", r, newer, err)
+	}
+	r, newer, err := u.Check(ctx, "stable")
+	if err != nil || !newer || r.Version != "1.5.0" {
+		t.Fatalf("result: %v %v %v", r, newer, err)
+	}
+
+	// a binary signed for another version, or not matching its hash
+	forged := release("1.5.0", hash)
+	forged.Version = "1.5.1"
+	if err = u.Download(ctx, &forged); err == nil {
+		t.Errorf("result: no error expected: a bad signature")
+	}
+	tampered := release("1.5.0", hex.EncodeToString(make([]byte, sha256.Size)))
+	if err = u.Download(ctx, &tampered); err == nil {
+		t.Errorf("result: no error expected: a hash mismatch")
+	}
+	if _, err = os.Stat(Staged(exe)); !os.IsNotExist(err) {
+		t.Errorf("result: %v expected: nothing staged", err)
+	}
+
+	if err = u.Download(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if swapped, err := Swap(exe); err != nil || !swapped {
+		t.Fatalf("result: %v %v", swapped, err)
+	}
+	if data, _ := ioutil.ReadFile(exe); string(data) != string(binary) {
+		t.Errorf("result: %q expected: %q", data, binary)
+	}
+	if data, _ := ioutil.ReadFile(exe + ".old"); string(data) != "old" {
+		t.Errorf("result: %q expected: the old binary", data)
+	}
+	if swapped, err := Swap(exe); err != nil || swapped {
+		t.Errorf("result: %v %v expected: nothing to swap", swapped, err)
+	}
+	if _, err = os.Stat(exe + ".old"); !os.IsNotExist(err) {
+		t.Errorf("result: %v expected: the old binary removed", err)
+	}
+}
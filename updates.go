@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"os"
+	"time"
+
+	"gtoc/update"
+	"go.uber.org/zap"
+)
+
+// The feed of the releases of gtoc and the base64 of the ed25519 key they're
+// signed with, set when releases are built, as with
+// -ldflags "-X main.update_feed=https://... -X main.update_key=...". gtoc
+// doesn't update itself if they're not.
+var (
+	update_feed string
+	update_key  string
+)
+
+// update_timeout bounds checking for a release, and downloading it.
+const update_timeout = 5 * time.Minute
+
+// UpdateStatus is the newest release of the channel of the settings: its
+// version and what's new in it, whether it's newer than the running gtoc,
+// and whether it's downloaded, waiting for gtoc to exit to be swapped in.
+type UpdateStatus struct {
+	Current   string `json:"current"`
+	Latest    string `json:"latest"`
+	Notes     string `json:"notes"`
+	Available bool   `json:"available"`
+	Staged    bool   `json:"staged"`
+}
+
+// updater returns the updater of the running binary, or an error if gtoc
+// can't update itself: it wasn't built to, or it's an app baked, whose
+// bundle the binary downloaded wouldn't carry.
+func (b *Backend) updater() (*update.Updater, string, error) {
+	if update_feed == "" || b.baked != nil {
+		return nil, "", errors.New("this build of gtoc doesn't update itself")
+	}
+	key, err := base64.StdEncoding.DecodeString(update_key)
+	if err != nil {
+		return nil, "", err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, "", err
+	}
+	channel := b.settings.Get().Update.Channel
+	if channel == "" {
+		channel = update.Channels[0]
+	}
+	return &update.Updater{Feed: update_feed, Key: ed25519.PublicKey(key), Current: version, Exe: exe}, channel, nil
+}
+
+// CheckUpdate checks for the newest release of the channel of the settings.
+func (b *Backend) CheckUpdate() (UpdateStatus, error) {
+	u, channel, err := b.updater()
+	if err != nil {
+		return UpdateStatus{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), update_timeout)
+	defer cancel()
+	r, newer, err := u.Check(ctx, channel)
+	if err != nil {
+		return UpdateStatus{}, err
+	}
+	_, err = os.Stat(update.Staged(u.Exe))
+	return UpdateStatus{version, r.Version, r.Notes, newer, err == nil}, nil
+}
+
+// DownloadUpdate downloads the newest release of the channel of the
+// settings, if it's newer than the running gtoc, and verifies it. It's
+// swapped in as gtoc exits, see swap_update, and the status emitted as an
+// "update:staged" event carrying an UpdateStatus.
+func (b *Backend) DownloadUpdate() (UpdateStatus, error) {
+	u, channel, err := b.updater()
+	if err != nil {
+		return UpdateStatus{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), update_timeout)
+	defer cancel()
+	r, newer, err := u.Check(ctx, channel)
+	if err != nil {
+		return UpdateStatus{}, err
+	}
+	status := UpdateStatus{Current: version, Latest: r.Version, Notes: r.Notes, Available: newer}
+	if !newer {
+		return status, nil
+	}
+	if err = u.Download(ctx, r); err != nil {
+		return status, err
+	}
+	status.Staged = true
+	b.view.Emit("update:staged", status)
+	return status, nil
+}
+
+// check_update checks for a release as gtoc starts, if the user opted in,
+// emitting it as an "update:available" event carrying an UpdateStatus if
+// it's newer.
+func (b *Backend) check_update() {
+	if !b.settings.Get().Update.Enabled || update_feed == "" || b.baked != nil {
+		return
+	}
+	status, err := b.CheckUpdate()
+	if err != nil {
+		zap.S().Warnf("Checking for a release of gtoc failed: %s", err)
+		return
+	}
+	if status.Available {
+		b.view.Emit("update:available", status)
+	}
+}
+
+// swap_update swaps the release downloaded in as gtoc exits, for the next
+// launch to run it, see update.Swap.
+func (b *Backend) swap_update() error {
+	if update_feed == "" || b.baked != nil {
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	swapped, err := update.Swap(exe)
+	if swapped {
+		zap.S().Infof("Updated gtoc, to run on the next launch")
+	}
+	return err
+}
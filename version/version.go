@@ -0,0 +1,102 @@
+// Package version compares the versions of programs, those of gtoc's
+// releases as well as those the tools print, see packages update and
+// curated.
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Compare compares the versions a and b, such as "1.34", "1.1.1w",
+// "v1.5.0-beta.2" or "6.0-6ubuntu1", telling -1 if a is older, 1 if it's
+// newer and 0 if they're the same. They're compared part by part along their
+// dots, a part counting as the number it starts with, 0 if it doesn't, and a
+// missing one as 0. A version with a pre-release after its "-", one starting
+// with a letter such as "beta.2" or "rc.1", is older than the one without;
+// other suffixes, such as the revisions of distributions, are ignored.
+func Compare(a, b string) int {
+	acore, apre := split(a)
+	bcore, bpre := split(b)
+	as, bs := strings.Split(acore, "."), strings.Split(bcore, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		if c := compareInts(leading(as, i), leading(bs, i)); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case apre == bpre:
+		return 0
+	case apre == "":
+		return 1
+	case bpre == "":
+		return -1
+	}
+	return comparePre(strings.Split(apre, "."), strings.Split(bpre, "."))
+}
+
+// split returns the parts of the version before its "-" and its pre-release,
+// "" if it has none.
+func split(v string) (string, string) {
+	core, rest, _ := strings.Cut(strings.TrimPrefix(v, "v"), "-")
+	if rest == "" || !(rest[0] >= 'a' && rest[0] <= 'z' || rest[0] >= 'A' && rest[0] <= 'Z') {
+		return core, ""
+	}
+	return core, rest
+}
+
+// leading returns the number the part i starts with, 0 if it doesn't or
+// there's no such part.
+func leading(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	s := parts[i]
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
+
+// comparePre compares the parts of pre-releases, numbers by value and others
+// alphabetically, numbers being older than words and a missing part older
+// than any.
+func comparePre(as, bs []string) int {
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		switch {
+		case i >= len(as):
+			return -1
+		case i >= len(bs):
+			return 1
+		}
+		m, merr := strconv.Atoi(as[i])
+		n, nerr := strconv.Atoi(bs[i])
+		switch {
+		case merr == nil && nerr == nil:
+			if c := compareInts(m, n); c != 0 {
+				return c
+			}
+		case merr == nil:
+			return -1
+		case nerr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+func compareInts(m, n int) int {
+	switch {
+	case m < n:
+		return -1
+	case m > n:
+		return 1
+	}
+	return 0
+}
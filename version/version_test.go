@@ -0,0 +1,30 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	for i, tt := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.34", "1.26", 1},
+		{"1.1.1w", "1.1.1", 0},
+		{"3.0.2", "1.1.1", 1},
+		{"7.68.0", "7.82", -1},
+		{"4", "4.0.0", 0},
+		{"6.0-6ubuntu1", "6.1", -1},
+		{"6.1-1ubuntu1", "6.1", 0},
+		{"1.5.0", "1.4.2", 1},
+		{"v1.10.0", "1.9.9", 1},
+		{"1.5.0", "1.5.0", 0},
+		{"1.5.0-beta.2", "1.5.0", -1},
+		{"1.5.0-beta.2", "1.5.0-beta.10", -1},
+		{"1.5.0-rc.1", "1.5.0-beta.3", 1},
+		{"1.5.0-beta", "1.5.0-beta.1", -1},
+		{"0.1.0", "0.1.0-alpha", 1},
+	} {
+		if result := Compare(tt.a, tt.b); result != tt.expected {
+			t.Errorf("testcase: %d result: %d expected: %d", i, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	goruntime "runtime"
+
+	"gtoc/keymap"
+	"gtoc/server"
+	"github.com/wailsapp/wails/v2/pkg/menu"
+	"github.com/wailsapp/wails/v2/pkg/menu/keys"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// view is what shows the frontend: the native window of wails, or the
+// browsers of "gtoc serve". The backend emits its events to it, lets the
+// user pick files with its dialogs and copies text to its clipboard. It
+// registers the hotkeys of the keymap, emitting the action of the hotkey
+// pressed as a "hotkey" event carrying a keymap.Action, and sizes the
+// window.
+type view interface {
+	Emit(name string, data ...interface{})
+	// OpenFile, OpenDir and SaveFile return the path the user picked in a
+	// dialog, "" if the user gave up
+	OpenFile() (string, error)
+	OpenDir() (string, error)
+	SaveFile(name string) (string, error)
+	Copy(text string) error
+	Hotkeys(hotkeys keymap.Keymap)
+	// Size returns the size of the window, 0 by 0 if there's none
+	Size() (width, height int)
+	Resize(width, height int)
+}
+
+// window_view is the native window, through the runtime of wails.
+type window_view struct {
+	ctx context.Context
+}
+
+func (v window_view) Emit(name string, data ...interface{}) {
+	runtime.EventsEmit(v.ctx, name, data...)
+}
+
+func (v window_view) OpenFile() (string, error) {
+	return runtime.OpenFileDialog(v.ctx, runtime.OpenDialogOptions{})
+}
+
+func (v window_view) OpenDir() (string, error) {
+	return runtime.OpenDirectoryDialog(v.ctx, runtime.OpenDialogOptions{})
+}
+
+func (v window_view) SaveFile(name string) (string, error) {
+	return runtime.SaveFileDialog(v.ctx, runtime.SaveDialogOptions{DefaultFilename: name})
+}
+
+func (v window_view) Copy(text string) error {
+	return runtime.ClipboardSetText(v.ctx, text)
+}
+
+// Hotkeys sets the menu of the window to the actions of the keymap, their
+// hotkeys the accelerators of their items, anew whenever the keymap changes.
+// macOS keeps its menus of the app and of editing, copying and pasting
+// going through the latter.
+func (v window_view) Hotkeys(hotkeys keymap.Keymap) {
+	bar := menu.NewMenu()
+	if goruntime.GOOS == "darwin" {
+		bar.Append(menu.AppMenu())
+		bar.Append(menu.EditMenu())
+	}
+	actions := bar.AddSubmenu("Actions")
+	for _, action := range keymap.Actions {
+		action := action
+		var accelerator *keys.Accelerator
+		// the keymap was checked with the settings
+		if key, err := keymap.Parse(hotkeys.Get(action)); err == nil {
+			accelerator = &keys.Accelerator{Key: key.Key}
+			for _, m := range key.Modifiers {
+				accelerator.Modifiers = append(accelerator.Modifiers, modifiers[m])
+			}
+		}
+		actions.AddText(keymap.Labels[action], accelerator, func(*menu.CallbackData) {
+			v.Emit("hotkey", action)
+		})
+	}
+	runtime.MenuSetApplicationMenu(v.ctx, bar)
+	runtime.MenuUpdateApplicationMenu(v.ctx)
+}
+
+func (v window_view) Size() (int, int) {
+	return runtime.WindowGetSize(v.ctx)
+}
+
+func (v window_view) Resize(width, height int) {
+	runtime.WindowSetSize(v.ctx, width, height)
+}
+
+// modifiers are the modifiers of wails by those of the keymap.
+var modifiers = map[keymap.Modifier]keys.Modifier{
+	keymap.CmdOrCtrl: keys.CmdOrCtrlKey,
+	keymap.Ctrl:      keys.ControlKey,
+	keymap.Alt:       keys.OptionOrAltKey,
+	keymap.Shift:     keys.ShiftKey,
+}
+
+// web_view is the browsers of "gtoc serve". They have no dialogs of the
+// machine gtoc runs on, paths are typed in instead, and its clipboard isn't
+// theirs, the browser copies what the frontend shows.
+type web_view struct {
+	*server.Hub
+}
+
+var (
+	errNoDialogs   = errors.New("there are no file dialogs in a browser, type the path in")
+	errNoClipboard = errors.New("the clipboard of a browser is the browser's to copy to")
+)
+
+func (web_view) OpenFile() (string, error)            { return "", errNoDialogs }
+func (web_view) OpenDir() (string, error)             { return "", errNoDialogs }
+func (web_view) SaveFile(name string) (string, error) { return "", errNoDialogs }
+func (web_view) Copy(text string) error               { return errNoClipboard }
+
+// Hotkeys leaves the hotkeys to the browsers, the frontend handling the keys
+// of the keymap of GetKeymap itself.
+func (web_view) Hotkeys(hotkeys keymap.Keymap) {}
+
+// Size and Resize leave the size of the window to the browsers.
+func (web_view) Size() (int, int)         { return 0, 0 }
+func (web_view) Resize(width, height int) {}
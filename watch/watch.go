@@ -0,0 +1,268 @@
+// Package watch tells when the programs of commands change on disk,
+// upgraded or rebuilt, for the patterns probed from their help texts to be
+// probed again.
+package watch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gtoc/runner"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// poll is how often the programs are looked at, along with whenever
+	// they're notified to change: notifications may not come on every file
+	// system.
+	poll = 5 * time.Second
+	// settle is how long a program is left alone after it changed before
+	// it's looked at, for an upgrade to finish writing it
+	settle = 500 * time.Millisecond
+)
+
+// Watcher watches the programs of commands, calling changed with every
+// command of a program once its modification time or size changed along with
+// its content. Its methods may be called from several goroutines.
+type Watcher struct {
+	changed       func(command string)
+	poll, settle  time.Duration
+	notifications *fsnotify.Watcher
+
+	mu sync.Mutex
+	// programs are the programs watched, by the path they're run with,
+	// and dirs the directories watched for them
+	programs map[string]*program
+	dirs     map[string]bool
+	done     chan struct{}
+}
+
+// program is a program watched: the paths it's notified to change by, the
+// symbolic link it's run with and the file it links to, the commands it's
+// the program of, and how it was last looked at.
+type program struct {
+	paths    []string
+	commands map[string]bool
+	stamp    stamp
+}
+
+// stamp tells whether a program changed. sum is nil until it's needed, the
+// modification time and size changing first.
+type stamp struct {
+	mod  time.Time
+	size int64
+	sum  []byte
+}
+
+// New returns a watcher calling changed, watching no program until Set.
+func New(changed func(command string)) *Watcher {
+	return newWatcher(changed, poll, settle)
+}
+
+func newWatcher(changed func(command string), poll, settle time.Duration) *Watcher {
+	w := &Watcher{
+		changed:  changed,
+		poll:     poll,
+		settle:   settle,
+		programs: make(map[string]*program),
+		dirs:     make(map[string]bool),
+		done:     make(chan struct{}),
+	}
+	if n, err := fsnotify.NewWatcher(); err == nil {
+		w.notifications = n
+	}
+	go w.run()
+	return w
+}
+
+// Program returns the path of the program of the command, as it's found in
+// $PATH.
+func Program(command string) (string, error) {
+	words, err := runner.Split(command)
+	if err != nil {
+		return "", err
+	}
+	path, err := exec.LookPath(words[0])
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// Set watches the programs of the commands, and stops watching the others.
+// Commands whose program can't be found aren't watched.
+func (w *Watcher) Set(commands []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, p := range w.programs {
+		p.commands = make(map[string]bool)
+	}
+	for _, command := range commands {
+		path, err := Program(command)
+		if err != nil {
+			continue
+		}
+		p, ok := w.programs[path]
+		if !ok {
+			p = &program{paths: []string{path}, commands: make(map[string]bool)}
+			if target, err := filepath.EvalSymlinks(path); err == nil && target != path {
+				p.paths = append(p.paths, target)
+			}
+			p.stamp, _ = stampOf(path, nil)
+			w.programs[path] = p
+		}
+		p.commands[command] = true
+	}
+	dirs := make(map[string]bool)
+	for path, p := range w.programs {
+		if len(p.commands) == 0 {
+			delete(w.programs, path)
+			continue
+		}
+		for _, path := range p.paths {
+			dirs[filepath.Dir(path)] = true
+		}
+	}
+	if w.notifications == nil {
+		return
+	}
+	// the directories are watched, for the programs to be seen when
+	// they're replaced
+	for dir := range dirs {
+		if !w.dirs[dir] {
+			w.notifications.Add(dir)
+		}
+	}
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			w.notifications.Remove(dir)
+		}
+	}
+	w.dirs = dirs
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	close(w.done)
+	if w.notifications != nil {
+		return w.notifications.Close()
+	}
+	return nil
+}
+
+func (w *Watcher) run() {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if w.notifications != nil {
+		events, errs = w.notifications.Events, w.notifications.Errors
+	}
+	tick := time.NewTicker(w.poll)
+	defer tick.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case e := <-events:
+			w.notified(filepath.Clean(e.Name))
+		case <-errs:
+		case <-tick.C:
+			w.look()
+		}
+	}
+}
+
+// notified looks at the program of path once it settled, if path is one.
+func (w *Watcher) notified(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, p := range w.programs {
+		for _, q := range p.paths {
+			if q == path {
+				time.AfterFunc(w.settle, w.look)
+				return
+			}
+		}
+	}
+}
+
+// look looks at every program, calling changed with the commands of those
+// that changed.
+func (w *Watcher) look() {
+	var changed []string
+	w.mu.Lock()
+	for path, p := range w.programs {
+		s, err := stampOf(path, &p.stamp)
+		if err != nil || time.Since(s.mod) < w.settle {
+			// not there, or still being written
+			continue
+		}
+		same := s.mod.Equal(p.stamp.mod) && s.size == p.stamp.size
+		if !same && p.stamp.sum != nil && bytes.Equal(s.sum, p.stamp.sum) {
+			// rebuilt as it was
+			same = true
+		}
+		p.stamp = s
+		if !same {
+			for command := range p.commands {
+				changed = append(changed, command)
+			}
+		}
+	}
+	w.mu.Unlock()
+	for _, command := range changed {
+		w.changed(command)
+	}
+}
+
+// stampOf returns the stamp of the program at path. Its content is summed
+// only if its modification time or size differ from those of before, if
+// that's not nil.
+func stampOf(path string, before *stamp) (stamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return stamp{}, err
+	}
+	s := stamp{mod: info.ModTime(), size: info.Size()}
+	switch {
+	case before == nil:
+		return s, nil
+	case s.mod.Equal(before.mod) && s.size == before.size:
+		s.sum = before.sum
+		return s, nil
+	}
+	if s.sum, err = sum(path); err != nil {
+		return stamp{}, err
+	}
+	return s, nil
+}
+
+// Sum returns the hex SHA-256 of the program at path, telling a build of it
+// from another.
+func Sum(path string) (string, error) {
+	b, err := sum(path)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func sum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
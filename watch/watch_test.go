@@ -0,0 +1,80 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gtoc-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tool")
+	if err = ioutil.WriteFile(path, []byte("#!/bin/sh\necho 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	changed := make(chan string, 8)
+	w := newWatcher(func(command string) { changed <- command }, 20*time.Millisecond, 10*time.Millisecond)
+	defer w.Close()
+	command := path + " sub"
+	w.Set([]string{command})
+
+	expect := func(i int, expected string) {
+		select {
+		case result := <-changed:
+			if result != expected {
+				t.Errorf("testcase: %d result: %s expected: %s", i, result, expected)
+			}
+		case <-time.After(500 * time.Millisecond):
+			if expected != "" {
+				t.Errorf("testcase: %d result: nothing expected: %s", i, expected)
+			}
+		}
+	}
+	// nothing changed yet
+	expect(0, "")
+	// upgraded
+	if err = ioutil.WriteFile(path, []byte("#!/bin/sh\necho 2\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	expect(1, command)
+	// rebuilt as it was
+	earlier := time.Now().Add(-time.Minute)
+	os.Chtimes(path, earlier, earlier)
+	expect(2, "")
+	// not watched anymore
+	w.Set(nil)
+	ioutil.WriteFile(path, []byte("#!/bin/sh\necho 3\n"), 0755)
+	expect(3, "")
+}
+
+func TestSum(t *testing.T) {
+	f, err := ioutil.TempFile("", "gtoc-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("hello\n")
+	f.Close()
+	expected := "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	if result, err := Sum(f.Name()); err != nil || result != expected {
+		t.Errorf("result: %s %v expected: %s", result, err, expected)
+	}
+	if _, err := Sum(f.Name() + ".missing"); err == nil {
+		t.Errorf("result: no error expected: an error")
+	}
+}
+
+func TestProgram(t *testing.T) {
+	for i, command := range []string{"sh -c 'echo hi'", "'no such program'", "'unterminated"} {
+		path, err := Program(command)
+		if i == 0 && (err != nil || !filepath.IsAbs(path)) || i > 0 && err == nil {
+			t.Errorf("testcase: %d result: %s %v", i, path, err)
+		}
+	}
+}